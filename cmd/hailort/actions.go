@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/stream"
+)
+
+// contextActions is the action list generated for one context
+type contextActions struct {
+	name string
+	data []byte
+}
+
+// actionsCommand handles "hailort actions <subcommand>"
+func actionsCommand(args []string) {
+	if len(args) < 1 || args[0] != "dump" {
+		fmt.Println("Usage: hailort actions dump --hef <file> [--context all|preliminary|N] [--device path] [--compare file] [--hex]")
+		os.Exit(1)
+	}
+	actionsDump(args[1:])
+}
+
+// actionsDump prints the action lists generated for each context of a HEF,
+// optionally diffing one against a list captured from another runtime
+func actionsDump(args []string) {
+	fs := flag.NewFlagSet("actions dump", flag.ExitOnError)
+	hefPath := fs.String("hef", "", "HEF file (required)")
+	groupName := fs.String("network-group", "", "network group (default: first)")
+	contextName := fs.String("context", "all", "context to dump: all, preliminary or a dynamic context index")
+	devicePath := fs.String("device", "", "allocate channels on this device, as activation does")
+	comparePath := fs.String("compare", "", "binary action list to diff the selected context against")
+	showHex := fs.Bool("hex", false, "print the raw bytes of each action")
+	fs.Parse(args)
+
+	if *hefPath == "" {
+		fmt.Println("Usage: hailort actions dump --hef <file> [--context all|preliminary|N] [--device path] [--compare file] [--hex]")
+		os.Exit(1)
+	}
+	if *comparePath != "" && *contextName == "all" {
+		fmt.Println("Error: --compare needs a single --context")
+		os.Exit(1)
+	}
+
+	hefFile, err := hef.Parse(*hefPath)
+	if err != nil {
+		fmt.Printf("Error parsing HEF: %v\n", err)
+		os.Exit(1)
+	}
+
+	var lists []contextActions
+	var report *control.BuildReport
+	if *devicePath != "" {
+		lists, report, err = buildDeviceActionLists(*devicePath, hefFile, *groupName)
+	} else {
+		lists, report, err = buildHefActionLists(hefFile, *groupName)
+	}
+	if err != nil {
+		fmt.Printf("Error building action lists: %v\n", err)
+		os.Exit(1)
+	}
+
+	selected, err := selectContexts(lists, *contextName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, list := range selected {
+		printActionList(list, *showHex)
+	}
+	if report != nil && !report.Complete() {
+		fmt.Println("Build report:")
+		fmt.Print(report)
+	}
+
+	if *comparePath != "" {
+		if !compareActionList(selected[0], *comparePath) {
+			os.Exit(1)
+		}
+	}
+}
+
+// buildHefActionLists builds the action lists from the HEF alone. Actions
+// that need the channel allocation are left out; the report lists them.
+func buildHefActionLists(hefFile *hef.Hef, groupName string) ([]contextActions, *control.BuildReport, error) {
+	var info *hef.NetworkGroupInfo
+	var err error
+	if groupName == "" {
+		info, err = hefFile.GetDefaultNetworkGroup()
+	} else {
+		info, err = hefFile.GetNetworkGroup(groupName)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &control.BuildReport{}
+	build := func(name string, ops []hef.ConfigOperation) (contextActions, error) {
+		data, ctxReport, err := control.BuildContextActionListWithOptions(ops, control.BuildOptions{})
+		if err != nil {
+			return contextActions{}, fmt.Errorf("%s: %w", name, err)
+		}
+		ctxReport.Name = name
+		report.Contexts = append(report.Contexts, *ctxReport)
+		return contextActions{name: name, data: data}, nil
+	}
+
+	var preliminaryOps []hef.ConfigOperation
+	if info.PreliminaryConfig != nil {
+		preliminaryOps = info.PreliminaryConfig.Operations
+	}
+	list, err := build("preliminary", preliminaryOps)
+	if err != nil {
+		return nil, nil, err
+	}
+	lists := []contextActions{list}
+
+	for i, cfg := range info.Contexts {
+		list, err := build(fmt.Sprintf("dynamic %d", i), cfg.Operations)
+		if err != nil {
+			return nil, nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, report, nil
+}
+
+// buildDeviceActionLists builds the action lists the way activation does,
+// with channels and buffers allocated on the device
+func buildDeviceActionLists(path string, hefFile *hef.Hef, groupName string) ([]contextActions, *control.BuildReport, error) {
+	dev, err := device.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer dev.Close()
+
+	ng, err := dev.ConfigureNetworkGroup(hefFile, groupName)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer ng.Close()
+
+	if ng.IsMultiContext() {
+		res, err := stream.PrepareContextResources(ng, 1)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer res.Close()
+	}
+
+	preliminary, dynamic, report, err := ng.BuildActionLists()
+	if err != nil {
+		return nil, report, err
+	}
+
+	lists := []contextActions{{name: "preliminary", data: preliminary}}
+	for i, data := range dynamic {
+		lists = append(lists, contextActions{name: fmt.Sprintf("dynamic %d", i), data: data})
+	}
+	return lists, report, nil
+}
+
+// selectContexts picks the lists named by --context
+func selectContexts(lists []contextActions, name string) ([]contextActions, error) {
+	switch name {
+	case "all":
+		return lists, nil
+	case "preliminary":
+		return lists[:1], nil
+	}
+
+	i, err := strconv.Atoi(name)
+	if err != nil || i < 0 || i+1 >= len(lists) {
+		return nil, fmt.Errorf("no context %q: want all, preliminary or 0-%d", name, len(lists)-2)
+	}
+	return lists[i+1 : i+2], nil
+}
+
+// printActionList prints the decoded actions of one context
+func printActionList(list contextActions, showHex bool) {
+	actions, err := control.DecodeActionList(list.data)
+	fmt.Printf("=== %s (%d bytes, %d actions) ===\n", list.name, len(list.data), len(actions))
+	for i := range actions {
+		fmt.Printf("  %s\n", &actions[i])
+		if showHex {
+			fmt.Printf("       % x\n", actions[i].Raw)
+		}
+	}
+	if err != nil {
+		fmt.Printf("  decode error: %v\n", err)
+	}
+	fmt.Println()
+}
+
+// compareActionList diffs a generated list against a captured one,
+// reporting whether they match
+func compareActionList(list contextActions, path string) bool {
+	captured, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", path, err)
+		return false
+	}
+
+	ours, err := control.DecodeActionList(list.data)
+	if err != nil {
+		fmt.Printf("Error decoding generated %s list: %v\n", list.name, err)
+		return false
+	}
+	theirs, err := control.DecodeActionList(captured)
+	if err != nil {
+		fmt.Printf("Error decoding %s: %v (comparing the %d actions before it)\n", path, err, len(theirs))
+	}
+
+	diffs := control.DiffActionLists(ours, theirs)
+	if len(diffs) == 0 && err == nil {
+		fmt.Printf("%s matches %s (%d actions)\n", list.name, path, len(ours))
+		return true
+	}
+
+	fmt.Printf("%s differs from %s (a = generated, b = captured):\n", list.name, path)
+	for i := range diffs {
+		fmt.Printf("  %s\n", &diffs[i])
+	}
+	return false
+}
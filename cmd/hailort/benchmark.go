@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/infer"
+)
+
+const benchmarkUsage = "Usage: hailort benchmark --hef <file> [--time 10s | --frames N] [--batch 1,2,4] [--input name=file ...] [--measure-power] [--measure-temp] [--output file]"
+
+// benchReport is the JSON document hailort benchmark writes
+type benchReport struct {
+	Hef          string        `json:"hef"`
+	NetworkGroup string        `json:"network_group"`
+	Device       string        `json:"device"`
+	Input        string        `json:"input"` // "random" or "files"
+	Results      []benchResult `json:"results"`
+}
+
+// benchResult is the measurement of one batch size
+type benchResult struct {
+	BatchSize        int               `json:"batch_size"`
+	Frames           int               `json:"frames"`
+	DurationS        float64           `json:"duration_s"`
+	FPS              float64           `json:"fps"`
+	LatencyMs        *benchLatency     `json:"latency_ms,omitempty"`
+	HostCPUPercent   float64           `json:"host_cpu_percent"`
+	PowerW           *benchPower       `json:"power_w,omitempty"`
+	PowerError       string            `json:"power_error,omitempty"`
+	TemperatureC     *benchTemperature `json:"temperature_c,omitempty"`
+	TemperatureError string            `json:"temperature_error,omitempty"`
+	Error            string            `json:"error,omitempty"`
+}
+
+// benchLatency summarizes the latencies of the inferences, in ms. Every
+// frame of a batch has the latency of its batch.
+type benchLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// benchPower is the chip power over a run
+type benchPower struct {
+	Average float32 `json:"average"`
+	Min     float32 `json:"min"`
+	Max     float32 `json:"max"`
+	Samples uint32  `json:"samples"`
+}
+
+// benchTemperature is the chip temperature at the end of a run
+type benchTemperature struct {
+	TS0 float32 `json:"ts0"`
+	TS1 float32 `json:"ts1"`
+}
+
+// benchConfig is what every sweep point runs
+type benchConfig struct {
+	duration     time.Duration
+	frames       int
+	warmup       int
+	timeout      time.Duration
+	measurePower bool
+	measureTemp  bool
+}
+
+// benchmarkCommand handles "hailort benchmark": it runs a HEF for a time or
+// a frame count at each batch size and reports throughput,
+// latency, host CPU and, where the firmware allows, chip power and
+// temperature as JSON
+func benchmarkCommand(args []string) {
+	fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	hefPath := fs.String("hef", "", "HEF file (required)")
+	devicePath := fs.String("device", "", "device to open (default: first available)")
+	duration := fs.Duration("time", 10*time.Second, "how long to run each batch size")
+	frames := fs.Int("frames", 0, "frames to run instead of --time")
+	warmup := fs.Int("warmup", 10, "inferences to run before measuring")
+	batches := fs.String("batch", "1", "comma-separated batch sizes to sweep")
+	var inputs inputFlags
+	fs.Var(&inputs, "input", "input `name=file` as for hailort run (default: random frames)")
+	measurePower := fs.Bool("measure-power", false, "measure chip power while running")
+	measureTemp := fs.Bool("measure-temp", false, "read the chip temperature after each run")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each inference")
+	outputPath := fs.String("output", "", "write the JSON report to this file (default: stdout)")
+	fs.Parse(args)
+
+	batchSizes, err := parseIntList(*batches)
+	if err == nil {
+		err = runBenchmark(*hefPath, *devicePath, inputs, batchSizes, benchConfig{
+			duration:     *duration,
+			frames:       *frames,
+			warmup:       *warmup,
+			timeout:      *timeout,
+			measurePower: *measurePower,
+			measureTemp:  *measureTemp,
+		}, *outputPath)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if *hefPath == "" {
+			fmt.Fprintln(os.Stderr, benchmarkUsage)
+		}
+		os.Exit(1)
+	}
+}
+
+// runBenchmark opens the device, runs every sweep point and writes the
+// report
+func runBenchmark(hefPath, devicePath string, inputs []string, batchSizes []int, cfg benchConfig, outputPath string) error {
+	if hefPath == "" {
+		return fmt.Errorf("--hef is required")
+	}
+	if cfg.duration <= 0 && cfg.frames <= 0 {
+		return fmt.Errorf("--time or --frames must be positive")
+	}
+
+	hefFile, err := hef.Parse(hefPath)
+	if err != nil {
+		return fmt.Errorf("parsing HEF: %w", err)
+	}
+	ngInfo, err := hefFile.GetDefaultNetworkGroup()
+	if err != nil {
+		return err
+	}
+
+	report := benchReport{Hef: hefPath, NetworkGroup: ngInfo.Name, Input: "random"}
+	var frames []map[string][]byte
+	if len(inputs) > 0 {
+		if frames, err = loadRunInputs(inputs, ngInfo.InputStreams); err != nil {
+			return err
+		}
+		report.Input = "files"
+	} else {
+		frames = randomFrames(ngInfo.InputStreams, 8)
+	}
+
+	var dev *device.Device
+	if devicePath != "" {
+		dev, err = device.Open(devicePath)
+	} else {
+		dev, err = device.OpenFirst()
+	}
+	if err != nil {
+		return fmt.Errorf("opening device: %w", err)
+	}
+	defer dev.Close()
+	report.Device = dev.Path()
+
+	model, err := infer.NewModel(dev, hefFile)
+	if err != nil {
+		return fmt.Errorf("loading model: %w", err)
+	}
+	defer model.Close()
+
+	for _, batch := range batchSizes {
+		fmt.Fprintf(os.Stderr, "Benchmarking batch %d\n", batch)
+		result := benchmarkPoint(model, dev.Control(), frames, batch, cfg)
+		if result.Error != "" {
+			fmt.Fprintf(os.Stderr, "  failed: %s\n", result.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "  %.1f FPS, p50 %.3f ms, p99 %.3f ms\n",
+				result.FPS, result.LatencyMs.P50, result.LatencyMs.P99)
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if outputPath == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
+
+// benchmarkPoint measures one batch size. Inferences run one at a time, so
+// latency includes no queueing. Failures are recorded in the result so the
+// rest of the sweep still runs.
+func benchmarkPoint(model *infer.Model, channel *control.Channel, frames []map[string][]byte, batch int, cfg benchConfig) benchResult {
+	result := benchResult{BatchSize: batch}
+	session, err := model.NewSession(infer.WithBatchSize(batch), infer.WithTimeout(cfg.timeout))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer session.Close()
+
+	// Each inference takes the next batch of frames, wrapping around
+	next := 0
+	nextBatch := func() []map[string][]byte {
+		b := make([]map[string][]byte, batch)
+		for i := range b {
+			b[i] = frames[next%len(frames)]
+			next++
+		}
+		return b
+	}
+
+	for i := 0; i < cfg.warmup; i++ {
+		if _, err := session.InferBatch(nextBatch()); err != nil {
+			result.Error = fmt.Sprintf("warmup: %v", err)
+			return result
+		}
+	}
+
+	ctx := context.Background()
+	power := cfg.measurePower && channel != nil
+	if power {
+		if err := startPowerMeasurement(ctx, channel); err != nil {
+			result.PowerError = err.Error()
+			power = false
+		}
+	}
+
+	var latencies []time.Duration
+	cpuStart := processCPUTime()
+	start := time.Now()
+	for result.Frames < cfg.frames || (cfg.frames <= 0 && time.Since(start) < cfg.duration) {
+		begin := time.Now()
+		if _, err := session.InferBatch(nextBatch()); err != nil {
+			result.Error = err.Error()
+			break
+		}
+		latencies = append(latencies, time.Since(begin))
+		result.Frames += batch
+	}
+	elapsed := time.Since(start)
+	cpu := processCPUTime() - cpuStart
+
+	result.DurationS = elapsed.Seconds()
+	if elapsed > 0 {
+		result.FPS = float64(result.Frames) / elapsed.Seconds()
+		result.HostCPUPercent = 100 * cpu.Seconds() / elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		result.LatencyMs = summarizeLatencies(latencies)
+	}
+
+	if power {
+		m, err := channel.GetPowerMeasurement(ctx, 0, true)
+		if err != nil {
+			result.PowerError = err.Error()
+		} else {
+			result.PowerW = &benchPower{Average: m.Average, Min: m.Min, Max: m.Max, Samples: m.Samples}
+		}
+		channel.StopPowerMeasurement(ctx)
+	}
+	if cfg.measureTemp && channel != nil {
+		temp, err := channel.ChipTemperature(ctx)
+		if err != nil {
+			result.TemperatureError = err.Error()
+		} else {
+			result.TemperatureC = &benchTemperature{TS0: temp.TS0, TS1: temp.TS1}
+		}
+	}
+	return result
+}
+
+// startPowerMeasurement starts averaging chip power into measurement
+// buffer 0, as hailortcli --measure-power does
+func startPowerMeasurement(ctx context.Context, channel *control.Channel) error {
+	if err := channel.SetPowerMeasurement(ctx, 0, control.PowerDvmAuto, control.PowerTypePower); err != nil {
+		return err
+	}
+	return channel.StartPowerMeasurement(ctx, 0, control.PowerAveraging256, control.PowerSampling1100us)
+}
+
+// summarizeLatencies computes the latency statistics of a run
+func summarizeLatencies(latencies []time.Duration) *benchLatency {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, l := range sorted {
+		total += l
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Nanoseconds()) / 1e6 }
+	return &benchLatency{
+		Min:  ms(sorted[0]),
+		Mean: ms(total / time.Duration(len(sorted))),
+		P50:  ms(percentile(sorted, 50)),
+		P90:  ms(percentile(sorted, 90)),
+		P95:  ms(percentile(sorted, 95)),
+		P99:  ms(percentile(sorted, 99)),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted durations, or
+// zero when there are none
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[min(rank, len(sorted))-1]
+}
+
+// processCPUTime returns the user and system CPU time of the process
+func processCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
+
+// randomFrames makes count frames of random data for every input
+func randomFrames(streams []hef.StreamInfo, count int) []map[string][]byte {
+	frames := make([]map[string][]byte, count)
+	for i := range frames {
+		frames[i] = make(map[string][]byte)
+		for _, info := range streams {
+			data := make([]byte, inputFrameSize(info))
+			rand.Read(data)
+			frames[i][info.Name] = data
+		}
+	}
+	return frames
+}
+
+// parseIntList parses a comma-separated list of positive integers
+func parseIntList(s string) ([]int, error) {
+	var values []int
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || v < 1 {
+			return nil, fmt.Errorf("%q is not a list of positive integers", s)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,64 @@
+//go:build unit
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+func TestSummarizeLatencies(t *testing.T) {
+	// 1ms to 100ms, shuffled
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	got := summarizeLatencies(latencies)
+	want := benchLatency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P95: 95, P99: 99, Max: 100}
+	if *got != want {
+		t.Errorf("summarizeLatencies() = %+v, want %+v", *got, want)
+	}
+	if latencies[0] != 100*time.Millisecond {
+		t.Error("summarizeLatencies() reordered its input")
+	}
+
+	one := summarizeLatencies([]time.Duration{3 * time.Millisecond})
+	if one.P50 != 3 || one.P99 != 3 {
+		t.Errorf("single sample = %+v", *one)
+	}
+}
+
+func TestPercentileEmpty(t *testing.T) {
+	if got := percentile(nil, 99); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestParseIntList(t *testing.T) {
+	got, err := parseIntList("1, 2,8")
+	if err != nil || len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 8 {
+		t.Errorf("parseIntList() = %v, %v", got, err)
+	}
+	for _, bad := range []string{"", "0", "1,x", "-2"} {
+		if _, err := parseIntList(bad); err == nil {
+			t.Errorf("parseIntList(%q) expected error", bad)
+		}
+	}
+}
+
+func TestRandomFrames(t *testing.T) {
+	streams := []hef.StreamInfo{
+		{Name: "a", Shape: hef.ImageShape3D{Height: 4, Width: 4, Features: 3}},
+		{Name: "b", Shape: hef.ImageShape3D{Height: 1, Width: 1, Features: 8}, Format: hef.Format{Type: hef.FormatTypeUint16}},
+	}
+	frames := randomFrames(streams, 2)
+	if len(frames) != 2 {
+		t.Fatalf("%d frames, want 2", len(frames))
+	}
+	if len(frames[1]["a"]) != 48 || len(frames[1]["b"]) != 16 {
+		t.Errorf("frame sizes = %d, %d, want 48, 16", len(frames[1]["a"]), len(frames[1]["b"]))
+	}
+}
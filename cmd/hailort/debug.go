@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/stream"
+)
+
+const debugStepUsage = "Usage: hailort debug step [--context N] [--action N] [--steps N] [--irqs] [--device path] <hef>"
+
+// debugCommand handles "hailort debug [step]". Without a subcommand it
+// prints the IOCTL debug information.
+func debugCommand(args []string) {
+	if len(args) > 0 && args[0] == "step" {
+		debugStep(args[1:])
+		return
+	}
+	printDebugInfo()
+}
+
+// debugStep runs one inference with a breakpoint in the context switch,
+// printing the action and channels at every stop
+func debugStep(args []string) {
+	fs := flag.NewFlagSet("debug step", flag.ExitOnError)
+	hefPath := fs.String("hef", "", "HEF file (or the first argument)")
+	devicePath := fs.String("device", "", "device to open (default: first available)")
+	groupName := fs.String("network-group", "", "network group (default: first)")
+	contextIndex := fs.Int("context", 0, "dynamic context to break in")
+	actionIndex := fs.Int("action", 0, "action to break at")
+	steps := fs.Int("steps", 0, "actions to step through before continuing (0: prompt at every stop)")
+	showIrqs := fs.Bool("irqs", false, "poll the channel interrupts at every stop (takes them from the streams)")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long to wait for each stop")
+	fs.Parse(args)
+	if fs.NArg() > 0 && *hefPath == "" {
+		*hefPath = fs.Arg(0)
+		fs.Parse(fs.Args()[1:])
+	}
+
+	if *hefPath == "" || *contextIndex < 0 || *actionIndex < 0 {
+		fmt.Println(debugStepUsage)
+		os.Exit(1)
+	}
+
+	s, err := openDebugSession(*devicePath, *hefPath, *groupName)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer s.close()
+
+	bp := control.Breakpoint{
+		AnyBatch:     true,
+		ContextIndex: uint16(control.FirstDynamicContextIndex + *contextIndex),
+		ActionIndex:  uint16(*actionIndex),
+	}
+	if err := s.debugger.Break(context.Background(), bp); err != nil {
+		fmt.Printf("Error setting breakpoint: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Breakpoint at dynamic context %d action %d\n", *contextIndex, *actionIndex)
+
+	// One all-zero frame per input drives the context switch
+	inferDone := make(chan error, 1)
+	go func() {
+		inputs := make(map[string][]byte)
+		for _, in := range s.vstreams.Inputs {
+			inputs[in.Info().Name] = make([]byte, in.FrameSize()*uint64(in.BatchSize()))
+		}
+		_, err := s.vstreams.Infer(context.Background(), inputs)
+		inferDone <- err
+	}()
+
+	stdin := bufio.NewReader(os.Stdin)
+	for stepped := 0; ; stepped++ {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		hit, err := s.debugger.Wait(ctx)
+		cancel()
+		if err != nil {
+			fmt.Printf("Error waiting for the breakpoint: %v\n", err)
+			break
+		}
+		s.printStop(hit, *showIrqs)
+
+		cmd := "s"
+		if *steps == 0 {
+			fmt.Print("[s]tep, [c]ontinue, [q]uit> ")
+			line, _ := stdin.ReadString('\n')
+			if line = strings.TrimSpace(line); line != "" {
+				cmd = line[:1]
+			}
+		} else if stepped >= *steps {
+			cmd = "c"
+		}
+		if cmd != "s" {
+			break
+		}
+		if err := s.step(hit); err != nil {
+			fmt.Printf("Error stepping: %v\n", err)
+			break
+		}
+	}
+
+	// Closing clears the breakpoint and lets the inference finish
+	if err := s.debugger.Close(); err != nil {
+		fmt.Printf("Error clearing the breakpoint: %v\n", err)
+	}
+	select {
+	case err := <-inferDone:
+		if err != nil {
+			fmt.Printf("Inference failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Inference completed")
+	case <-time.After(*timeout):
+		fmt.Println("Inference did not complete")
+		os.Exit(1)
+	}
+}
+
+// debugSession is an activated network group under a debugger
+type debugSession struct {
+	dev       *device.Device
+	ng        *device.ConfiguredNetworkGroup
+	ang       *device.ActivatedNetworkGroup
+	resources *stream.ContextResources
+	vstreams  *stream.VStreamSet
+	debugger  *device.Debugger
+	lists     [][]control.DecodedAction // by firmware context index
+}
+
+// openDebugSession activates a network group and opens a debugger on it
+func openDebugSession(devicePath, hefPath, groupName string) (*debugSession, error) {
+	hefFile, err := hef.Parse(hefPath)
+	if err != nil {
+		return nil, fmt.Errorf("parsing HEF: %w", err)
+	}
+
+	s := &debugSession{}
+	if devicePath != "" {
+		s.dev, err = device.Open(devicePath)
+	} else {
+		s.dev, err = device.OpenFirst()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.activate(hefFile, groupName); err != nil {
+		s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// activate configures, activates and opens the debugger
+func (s *debugSession) activate(hefFile *hef.Hef, groupName string) error {
+	var err error
+	if s.ng, err = s.dev.ConfigureNetworkGroup(hefFile, groupName); err != nil {
+		return err
+	}
+	if s.ng.IsMultiContext() {
+		if s.resources, err = stream.PrepareContextResources(s.ng, 1); err != nil {
+			return err
+		}
+	}
+	if s.ang, err = s.ng.Activate(); err != nil {
+		return fmt.Errorf("activating: %w", err)
+	}
+	if s.vstreams, err = stream.BuildVStreams(s.ng, stream.DefaultVStreamParams()); err != nil {
+		return fmt.Errorf("building vstreams: %w", err)
+	}
+	if s.debugger, err = device.NewDebugger(s.ang); err != nil {
+		return err
+	}
+
+	// Decode the lists as activation sent them, to show the action at
+	// each stop
+	preliminary, dynamic, _, err := s.ng.BuildActionLists()
+	if err != nil {
+		return fmt.Errorf("building action lists: %w", err)
+	}
+	s.lists = make([][]control.DecodedAction, control.FirstDynamicContextIndex, control.FirstDynamicContextIndex+len(dynamic))
+	s.lists[control.FirstDynamicContextIndex-1] = decodeForStops("preliminary", preliminary)
+	for i, data := range dynamic {
+		s.lists = append(s.lists, decodeForStops(fmt.Sprintf("dynamic %d", i), data))
+	}
+	return nil
+}
+
+// decodeForStops decodes an action list for printStop. A list that does
+// not decode is reported and kept up to the error, since stepping does not
+// depend on it.
+func decodeForStops(name string, data []byte) []control.DecodedAction {
+	actions, err := control.DecodeActionList(data)
+	if err != nil {
+		fmt.Printf("Warning: cannot decode %s action list past action %d: %v\n", name, len(actions), err)
+	}
+	return actions
+}
+
+// printStop prints where the context switch stopped and the channels of
+// the context
+func (s *debugSession) printStop(hit *control.BreakpointHit, showIrqs bool) {
+	fmt.Printf("\nStopped at %s\n", hit)
+	if actions := s.actions(hit.ContextIndex); int(hit.ActionIndex) < len(actions) {
+		fmt.Printf("  next: %s\n", &actions[hit.ActionIndex])
+	}
+
+	state, err := s.debugger.State(context.Background())
+	if err != nil {
+		fmt.Printf("  state: %v\n", err)
+		return
+	}
+	fmt.Printf("  breakpoint %s, %d channels\n", state.Status, len(state.Channels))
+	for _, ch := range state.Channels {
+		fmt.Printf("    %s\n", ch)
+	}
+
+	if !showIrqs {
+		return
+	}
+	irqs, err := s.debugger.PendingInterrupts(state.Channels, 10*time.Millisecond)
+	if err != nil {
+		fmt.Printf("  interrupts: %v\n", err)
+		return
+	}
+	if len(irqs) == 0 {
+		fmt.Println("  no pending interrupts")
+	}
+	for _, irq := range irqs {
+		status := "ok"
+		if err := irq.Err(); err != nil {
+			status = err.Error()
+		}
+		fmt.Printf("    irq engine %d channel %2d: %d transfers, %s\n",
+			irq.Engine, irq.Channel, irq.TransfersCompleted, status)
+	}
+}
+
+// actions returns the decoded action list of a firmware context
+func (s *debugSession) actions(fwContext uint16) []control.DecodedAction {
+	if int(fwContext) >= len(s.lists) {
+		return nil
+	}
+	return s.lists[fwContext]
+}
+
+// step moves to the next action, or to the first action of the next
+// context after the last one
+func (s *debugSession) step(hit *control.BreakpointHit) error {
+	ctx := context.Background()
+	if int(hit.ActionIndex)+1 < len(s.actions(hit.ContextIndex)) {
+		return s.debugger.Step(ctx)
+	}
+
+	next := control.Breakpoint{AnyBatch: true, ContextIndex: hit.ContextIndex + 1}
+	if int(next.ContextIndex) >= len(s.lists) {
+		next.ContextIndex = control.FirstDynamicContextIndex
+	}
+	if err := s.debugger.Break(ctx, next); err != nil {
+		return err
+	}
+	return s.debugger.Continue(ctx)
+}
+
+// close deactivates the network group and releases the device
+func (s *debugSession) close() {
+	if s.debugger != nil {
+		s.debugger.Close()
+	}
+	if s.vstreams != nil {
+		s.vstreams.Close()
+	}
+	if s.ang != nil {
+		s.ang.Deactivate()
+	}
+	if s.resources != nil {
+		s.resources.Close()
+	}
+	if s.ng != nil {
+		s.ng.Close()
+	}
+	s.dev.Close()
+}
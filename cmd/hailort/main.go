@@ -25,15 +25,23 @@ func main() {
 
 	switch cmd {
 	case "scan":
-		scanDevices()
+		scanCommand(args)
 	case "info":
 		if len(args) < 1 {
 			fmt.Println("Usage: hailort info <device>")
 			os.Exit(1)
 		}
 		deviceInfo(args[0])
+	case "run":
+		runCommand(args)
+	case "benchmark":
+		benchmarkCommand(args)
+	case "serve":
+		serveCommand(args)
+	case "actions":
+		actionsCommand(args)
 	case "debug":
-		printDebugInfo()
+		debugCommand(args)
 	case "version":
 		printVersion()
 	case "help", "--help", "-h":
@@ -51,9 +59,14 @@ func printUsage() {
 	fmt.Println("Usage: hailort <command> [options]")
 	fmt.Println()
 	fmt.Println("Commands:")
-	fmt.Println("  scan              Scan for Hailo devices")
+	fmt.Println("  scan [--watch]    Scan for Hailo devices, or stream hot-plug events")
 	fmt.Println("  info <device>     Show device information")
+	fmt.Println("  run --hef <f>     Run inference on image, raw or npy input files")
+	fmt.Println("  benchmark         Measure FPS, latency and power of a HEF as JSON")
+	fmt.Println("  serve --hef <f>   Share a device with other processes over a Unix socket")
+	fmt.Println("  actions dump      Decode the action lists generated for a HEF")
 	fmt.Println("  debug             Print IOCTL debug information")
+	fmt.Println("  debug step <hef>  Step through the context switch from a breakpoint")
 	fmt.Println("  version           Print version information")
 	fmt.Println("  help              Show this help")
 }
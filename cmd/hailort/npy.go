@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// npyMagic starts every .npy file
+const npyMagic = "\x93NUMPY"
+
+// npyArray is a little-endian, C-ordered NumPy array
+type npyArray struct {
+	Descr string // dtype: |u1, <u2 or <f4
+	Shape []int
+	Data  []byte
+}
+
+// elements returns the number of elements in the array
+func (a *npyArray) elements() int {
+	n := 1
+	for _, d := range a.Shape {
+		n *= d
+	}
+	return n
+}
+
+// npyElemSize returns the byte size of the supported dtypes
+func npyElemSize(descr string) (int, error) {
+	switch descr {
+	case "|u1", "<u1", "|i1":
+		return 1, nil
+	case "<u2", "<i2", "<f2":
+		return 2, nil
+	case "<f4":
+		return 4, nil
+	}
+	return 0, fmt.Errorf("unsupported npy dtype %q", descr)
+}
+
+// readNpy parses a version 1 or 2 .npy file
+func readNpy(data []byte) (*npyArray, error) {
+	if len(data) < 10 || string(data[:6]) != npyMagic {
+		return nil, fmt.Errorf("not an npy file")
+	}
+
+	var headerLen, offset int
+	switch data[6] {
+	case 1:
+		headerLen, offset = int(binary.LittleEndian.Uint16(data[8:10])), 10
+	case 2, 3:
+		if len(data) < 12 {
+			return nil, fmt.Errorf("npy header truncated")
+		}
+		headerLen, offset = int(binary.LittleEndian.Uint32(data[8:12])), 12
+	default:
+		return nil, fmt.Errorf("unsupported npy version %d", data[6])
+	}
+	if len(data) < offset+headerLen {
+		return nil, fmt.Errorf("npy header truncated")
+	}
+	header := string(data[offset : offset+headerLen])
+
+	a := &npyArray{}
+	descr, err := npyHeaderValue(header, "descr")
+	if err != nil {
+		return nil, err
+	}
+	a.Descr = strings.Trim(descr, "'\"")
+	if order, _ := npyHeaderValue(header, "fortran_order"); order == "True" {
+		return nil, fmt.Errorf("fortran-ordered npy arrays are not supported")
+	}
+	shape, err := npyHeaderValue(header, "shape")
+	if err != nil {
+		return nil, err
+	}
+	for _, dim := range strings.Split(strings.Trim(shape, "()"), ",") {
+		if dim = strings.TrimSpace(dim); dim == "" {
+			continue
+		}
+		n, err := strconv.Atoi(dim)
+		if err != nil {
+			return nil, fmt.Errorf("bad npy shape %s", shape)
+		}
+		a.Shape = append(a.Shape, n)
+	}
+
+	elemSize, err := npyElemSize(a.Descr)
+	if err != nil {
+		return nil, err
+	}
+	a.Data = data[offset+headerLen:]
+	if want := a.elements() * elemSize; len(a.Data) != want {
+		return nil, fmt.Errorf("npy data is %d bytes, shape %v needs %d", len(a.Data), a.Shape, want)
+	}
+	return a, nil
+}
+
+// npyHeaderValue returns the raw value of a key in the header dictionary
+func npyHeaderValue(header, key string) (string, error) {
+	i := strings.Index(header, "'"+key+"'")
+	if i < 0 {
+		return "", fmt.Errorf("npy header has no %s", key)
+	}
+	rest := strings.TrimSpace(header[i+len(key)+2:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, ":"))
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.Index(rest, ")"); end >= 0 {
+			return rest[:end+1], nil
+		}
+		return "", fmt.Errorf("npy header %s is not terminated", key)
+	}
+	if end := strings.IndexAny(rest, ",}"); end >= 0 {
+		rest = rest[:end]
+	}
+	return strings.TrimSpace(rest), nil
+}
+
+// writeNpy writes a version 1 .npy file
+func writeNpy(w io.Writer, a *npyArray) error {
+	dims := make([]string, len(a.Shape))
+	for i, d := range a.Shape {
+		dims[i] = strconv.Itoa(d)
+	}
+	shape := strings.Join(dims, ", ")
+	if len(dims) == 1 {
+		shape += ","
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%s), }", a.Descr, shape)
+
+	// The header is padded so the data starts on a 64-byte boundary
+	total := len(npyMagic) + 4 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+
+	var buf bytes.Buffer
+	buf.WriteString(npyMagic)
+	buf.Write([]byte{1, 0})
+	binary.Write(&buf, binary.LittleEndian, uint16(len(header)))
+	buf.WriteString(header)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(a.Data)
+	return err
+}
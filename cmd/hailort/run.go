@@ -0,0 +1,561 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/infer"
+	"github.com/anthropics/purple-hailo/pkg/transform"
+)
+
+const runUsage = "Usage: hailort run --hef <file> --input [name=]file [--input ...] [--batch N] [--output raw|dequantized|nms] [--output-dir dir] [--format json|npy]"
+
+// inputFlags collects repeated --input flags
+type inputFlags []string
+
+func (f *inputFlags) String() string { return strings.Join(*f, ",") }
+
+func (f *inputFlags) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// runCommand handles "hailort run": it loads input files, runs them through
+// the default network group of a HEF and writes or summarizes the outputs
+func runCommand(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	hefPath := fs.String("hef", "", "HEF file (required)")
+	devicePath := fs.String("device", "", "device to open (default: first available)")
+	var inputs inputFlags
+	fs.Var(&inputs, "input", "input `name=file` (.jpg, .png, .bin or .npy); repeat for more inputs or frames")
+	batch := fs.Int("batch", 1, "frames per inference")
+	output := fs.String("output", "dequantized", "output values: raw, dequantized or nms (decode NMS detections)")
+	outputDir := fs.String("output-dir", "", "write the outputs to this directory (default: print a summary)")
+	format := fs.String("format", "json", "output file format: json or npy")
+	timeout := fs.Duration("timeout", 5*time.Second, "timeout of each inference")
+	fs.Parse(args)
+
+	if *hefPath == "" || len(inputs) == 0 || *batch < 1 {
+		fmt.Println(runUsage)
+		os.Exit(1)
+	}
+	if *output != "raw" && *output != "dequantized" && *output != "nms" {
+		fmt.Printf("Error: unknown --output %q\n", *output)
+		os.Exit(1)
+	}
+	if *format != "json" && *format != "npy" {
+		fmt.Printf("Error: unknown --format %q\n", *format)
+		os.Exit(1)
+	}
+
+	hefFile, err := hef.Parse(*hefPath)
+	if err != nil {
+		fmt.Printf("Error parsing HEF: %v\n", err)
+		os.Exit(1)
+	}
+	ngInfo, err := hefFile.GetDefaultNetworkGroup()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	nms := ngInfo.GetNmsInfo()
+	if *output == "nms" && nms == nil {
+		fmt.Println("Error: --output nms needs a HEF with an NMS layer")
+		os.Exit(1)
+	}
+
+	// --output nms decodes only the stream that carries the NMS results
+	decodeStreams := ngInfo.OutputStreams
+	if *output == "nms" {
+		stream, err := nmsOutputStream(ngInfo.OutputStreams, nms)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		decodeStreams = []hef.StreamInfo{stream}
+	}
+
+	frames, err := loadRunInputs(inputs, ngInfo.InputStreams)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var dev *device.Device
+	if *devicePath != "" {
+		dev, err = device.Open(*devicePath)
+	} else {
+		dev, err = device.OpenFirst()
+	}
+	if err != nil {
+		fmt.Printf("Error opening device: %v\n", err)
+		os.Exit(1)
+	}
+	defer dev.Close()
+
+	model, err := infer.NewModel(dev, hefFile)
+	if err != nil {
+		fmt.Printf("Error loading model: %v\n", err)
+		os.Exit(1)
+	}
+	defer model.Close()
+	session, err := model.NewSession(infer.WithBatchSize(*batch), infer.WithTimeout(*timeout))
+	if err != nil {
+		fmt.Printf("Error preparing model: %v\n", err)
+		os.Exit(1)
+	}
+	defer session.Close()
+
+	fmt.Printf("Running %d frame(s) through %s, batch %d\n", len(frames), ngInfo.Name, *batch)
+	results := make([]runFrame, 0, len(frames))
+	for start := 0; start < len(frames); start += *batch {
+		end := min(start+*batch, len(frames))
+		begin := time.Now()
+		outputs, err := session.InferBatch(frames[start:end])
+		latency := time.Since(begin)
+		if err != nil {
+			fmt.Printf("Error: frame %d: %v\n", start, err)
+			os.Exit(1)
+		}
+
+		// Every frame of a batch completes when the batch does
+		for i, out := range outputs {
+			frame := runFrame{
+				Index:     start + i,
+				LatencyMs: float64(latency.Microseconds()) / 1000,
+				Outputs:   make(map[string]*runOutput),
+			}
+			for _, info := range decodeStreams {
+				data, ok := out[info.Name]
+				if !ok {
+					continue
+				}
+				decoded, err := decodeRunOutput(data, info, *output, nms)
+				if err != nil {
+					fmt.Printf("Error: frame %d: %v\n", frame.Index, err)
+					os.Exit(1)
+				}
+				frame.Outputs[info.Name] = decoded
+			}
+			fmt.Printf("  frame %d: %.3f ms\n", frame.Index, frame.LatencyMs)
+			results = append(results, frame)
+		}
+	}
+
+	if *outputDir == "" {
+		printRunSummary(results)
+		return
+	}
+	if err := writeRunOutputs(*outputDir, *format, results); err != nil {
+		fmt.Printf("Error writing outputs: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Outputs written to %s\n", *outputDir)
+}
+
+// runFrame is the result of one input frame
+type runFrame struct {
+	Index     int                   `json:"index"`
+	LatencyMs float64               `json:"latency_ms"`
+	Outputs   map[string]*runOutput `json:"outputs"`
+}
+
+// runOutput is one output of a frame, raw, dequantized or decoded
+type runOutput struct {
+	Shape      []int          `json:"shape,omitempty"`
+	Raw        []int          `json:"raw,omitempty"`
+	Values     []float32      `json:"values,omitempty"`
+	Detections []runDetection `json:"detections,omitempty"`
+
+	array *npyArray
+}
+
+// runDetection is a decoded NMS detection with a normalized box
+type runDetection struct {
+	Class int        `json:"class"`
+	Score float32    `json:"score"`
+	Box   [4]float32 `json:"box"` // y_min, x_min, y_max, x_max
+}
+
+// loadRunInputs reads the --input files into frames, keyed by input stream.
+// An input given once is repeated to match the frame count of the others.
+func loadRunInputs(specs []string, streams []hef.StreamInfo) ([]map[string][]byte, error) {
+	perInput := make(map[string][][]byte)
+	for _, spec := range specs {
+		name, path, ok := strings.Cut(spec, "=")
+		if !ok {
+			if len(streams) != 1 {
+				return nil, fmt.Errorf("--input %s: the model has %d inputs, use name=file", spec, len(streams))
+			}
+			name, path = streams[0].Name, spec
+		}
+		info, err := findStream(streams, name)
+		if err != nil {
+			return nil, err
+		}
+		frames, err := loadInputFrames(path, info)
+		if err != nil {
+			return nil, fmt.Errorf("input %s: %w", name, err)
+		}
+		perInput[name] = append(perInput[name], frames...)
+	}
+
+	count := 1
+	for _, info := range streams {
+		frames, ok := perInput[info.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing --input for %s", info.Name)
+		}
+		if len(frames) > 1 {
+			if count > 1 && len(frames) != count {
+				return nil, fmt.Errorf("input %s has %d frames, others have %d", info.Name, len(frames), count)
+			}
+			count = len(frames)
+		}
+	}
+
+	result := make([]map[string][]byte, count)
+	for i := range result {
+		result[i] = make(map[string][]byte)
+		for name, frames := range perInput {
+			result[i][name] = frames[min(i, len(frames)-1)]
+		}
+	}
+	return result, nil
+}
+
+// findStream returns the stream with the given name
+func findStream(streams []hef.StreamInfo, name string) (hef.StreamInfo, error) {
+	var names []string
+	for _, s := range streams {
+		if s.Name == name {
+			return s, nil
+		}
+		names = append(names, s.Name)
+	}
+	return hef.StreamInfo{}, fmt.Errorf("unknown input %q (inputs: %s)", name, strings.Join(names, ", "))
+}
+
+// inputElemType returns the element type an input stream takes
+func inputElemType(info hef.StreamInfo) hef.FormatType {
+	if info.Format.Type == hef.FormatTypeAuto {
+		return hef.FormatTypeUint8
+	}
+	return info.Format.Type
+}
+
+// inputFrameSize returns the bytes of one frame of an input stream
+func inputFrameSize(info hef.StreamInfo) int {
+	return int(info.Shape.Height*info.Shape.Width*info.Shape.Features) * inputElemType(info).Size()
+}
+
+// loadInputFrames reads one or more frames for an input from a file
+func loadInputFrames(path string, info hef.StreamInfo) ([][]byte, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png":
+		frame, err := loadImageFrame(path, info)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{frame}, nil
+	case ".npy":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		a, err := readNpy(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return npyInputFrames(a, info)
+	case ".bin", ".raw":
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return splitInputFrames(data, info)
+	}
+	return nil, fmt.Errorf("%s: unsupported file type (use .jpg, .png, .bin or .npy)", path)
+}
+
+// splitInputFrames splits raw data into frames of the input stream
+func splitInputFrames(data []byte, info hef.StreamInfo) ([][]byte, error) {
+	size := inputFrameSize(info)
+	if size == 0 || len(data) == 0 || len(data)%size != 0 {
+		return nil, fmt.Errorf("%d bytes is not a whole number of %d byte frames", len(data), size)
+	}
+	var frames [][]byte
+	for off := 0; off < len(data); off += size {
+		frames = append(frames, data[off:off+size])
+	}
+	return frames, nil
+}
+
+// npyInputFrames converts an array to input frames. Float arrays are
+// quantized with the input's quantization parameters; integer arrays must
+// already have the input's element type.
+func npyInputFrames(a *npyArray, info hef.StreamInfo) ([][]byte, error) {
+	elemType := inputElemType(info)
+	if a.Descr != "<f4" {
+		if size, _ := npyElemSize(a.Descr); size != elemType.Size() {
+			return nil, fmt.Errorf("npy dtype %s does not match input type %s", a.Descr, elemType)
+		}
+		return splitInputFrames(a.Data, info)
+	}
+
+	n := int(info.Shape.Height * info.Shape.Width * info.Shape.Features)
+	if n == 0 || a.elements()%n != 0 {
+		return nil, fmt.Errorf("npy shape %v is not a whole number of %dx%dx%d frames",
+			a.Shape, info.Shape.Height, info.Shape.Width, info.Shape.Features)
+	}
+	qi := transform.QuantInfoFromHef(info.QuantInfo)
+	src := hef.Format{Type: hef.FormatTypeFloat32, Order: hef.FormatOrderNHWC}
+	dst := hef.Format{Type: elemType, Order: hef.FormatOrderNHWC}
+	var frames [][]byte
+	for off := 0; off < len(a.Data); off += n * 4 {
+		frame := make([]byte, n*elemType.Size())
+		if err := transform.ConvertFormat(a.Data[off:off+n*4], src, frame, dst, info.Shape, qi); err != nil {
+			return nil, err
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// loadImageFrame decodes an image and resizes it to the input shape, as
+// RGB for 3-feature inputs and grayscale for 1-feature inputs
+func loadImageFrame(path string, info hef.StreamInfo) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return imageFrame(img, info)
+}
+
+// imageFrame converts an image to an NHWC uint8 frame of the input shape
+func imageFrame(img image.Image, info hef.StreamInfo) ([]byte, error) {
+	channels := int(info.Shape.Features)
+	if channels != 1 && channels != 3 {
+		return nil, fmt.Errorf("images need a 1 or 3 feature input, %s has %d", info.Name, channels)
+	}
+	if inputElemType(info) != hef.FormatTypeUint8 {
+		return nil, fmt.Errorf("images need a uint8 input, %s takes %s", info.Name, info.Format.Type)
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	pixels := make([]byte, 0, w*h*channels)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if channels == 1 {
+				pixels = append(pixels, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+				continue
+			}
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			pixels = append(pixels, c.R, c.G, c.B)
+		}
+	}
+
+	dstH, dstW := int(info.Shape.Height), int(info.Shape.Width)
+	if w == dstW && h == dstH {
+		return pixels, nil
+	}
+	frame := make([]byte, dstH*dstW*channels)
+	transform.ResizeBilinear(pixels, frame, h, w, dstH, dstW, channels)
+	return frame, nil
+}
+
+// nmsOutputStream returns the output stream that carries the NMS results:
+// the one named after the NMS layer, or the only output of the HEF
+func nmsOutputStream(outputs []hef.StreamInfo, nms *hef.VStreamInfo) (hef.StreamInfo, error) {
+	for _, s := range outputs {
+		if s.Name == nms.Name {
+			return s, nil
+		}
+	}
+	if len(outputs) == 1 {
+		return outputs[0], nil
+	}
+	return hef.StreamInfo{}, fmt.Errorf("cannot tell which of %d outputs carries NMS layer %s", len(outputs), nms.Name)
+}
+
+// decodeRunOutput converts one output frame as --output asks. NMS outputs
+// are float32 detections whatever element type the HEF stream declares.
+func decodeRunOutput(data []byte, info hef.StreamInfo, mode string, nms *hef.VStreamInfo) (*runOutput, error) {
+	if mode == "nms" {
+		return decodeNmsOutput(data, info, nms)
+	}
+
+	format := info.Format
+	if format.Type == hef.FormatTypeAuto {
+		format.Type = hef.FormatTypeUint8
+	}
+	elemSize := format.Type.Size()
+	shape := []int{int(info.Shape.Height), int(info.Shape.Width), int(info.Shape.Features)}
+	if shape[0]*shape[1]*shape[2]*elemSize != len(data) {
+		shape = []int{len(data) / elemSize}
+	}
+
+	if mode == "raw" {
+		out := &runOutput{Shape: shape}
+		out.array = &npyArray{Descr: "|u1", Shape: shape, Data: data}
+		if elemSize == 2 {
+			out.array.Descr = "<u2"
+		}
+		for i := 0; i+elemSize <= len(data); i += elemSize {
+			if elemSize == 2 {
+				out.Raw = append(out.Raw, int(binary.LittleEndian.Uint16(data[i:])))
+			} else {
+				out.Raw = append(out.Raw, int(data[i]))
+			}
+		}
+		return out, nil
+	}
+
+	values, err := dequantizeOutput(data, format, shape, transform.QuantInfoFromHef(info.QuantInfo))
+	if err != nil {
+		return nil, fmt.Errorf("output %s: %w", info.Name, err)
+	}
+	return &runOutput{Shape: shape, Values: values, array: floatArray(shape, values)}, nil
+}
+
+// decodeNmsOutput decodes the per-class detection counts and boxes of a raw
+// NMS output
+func decodeNmsOutput(data []byte, info hef.StreamInfo, nms *hef.VStreamInfo) (*runOutput, error) {
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("output %s: %d bytes is not a whole number of float32 values", info.Name, len(data))
+	}
+	values := make([]float32, len(data)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+
+	detections := transform.ParseNmsByClass(values, int(nms.NmsShape.NumberOfClasses))
+	detections = transform.SortDetectionsByScore(detections)
+	out := &runOutput{Detections: []runDetection{}}
+	rows := make([]float32, 0, len(detections)*6)
+	for _, d := range detections {
+		box := [4]float32{d.BBox.YMin, d.BBox.XMin, d.BBox.YMax, d.BBox.XMax}
+		out.Detections = append(out.Detections, runDetection{Class: d.ClassId, Score: d.Score, Box: box})
+		rows = append(rows, box[0], box[1], box[2], box[3], d.Score, float32(d.ClassId))
+	}
+	out.array = floatArray([]int{len(detections), 6}, rows)
+	return out, nil
+}
+
+// dequantizeOutput converts raw output elements to floats in their order
+func dequantizeOutput(data []byte, format hef.Format, shape []int, qi transform.QuantInfo) ([]float32, error) {
+	dims := hef.ImageShape3D{Height: 1, Width: 1, Features: uint32(shape[len(shape)-1])}
+	if len(shape) == 3 {
+		dims = hef.ImageShape3D{Height: uint32(shape[0]), Width: uint32(shape[1]), Features: uint32(shape[2])}
+	}
+	if format.Type == hef.FormatTypeFloat32 {
+		values := make([]float32, len(data)/4)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+		}
+		return values, nil
+	}
+	return transform.DequantizeTensor(data, format, dims, qi)
+}
+
+// floatArray packs float values as a little-endian float32 array
+func floatArray(shape []int, values []float32) *npyArray {
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	return &npyArray{Descr: "<f4", Shape: shape, Data: data}
+}
+
+// printRunSummary prints the outputs of each frame without their values
+func printRunSummary(results []runFrame) {
+	for _, frame := range results {
+		fmt.Printf("Frame %d:\n", frame.Index)
+		for _, name := range sortedOutputNames(frame.Outputs) {
+			out := frame.Outputs[name]
+			switch {
+			case out.Detections != nil:
+				fmt.Printf("  %s: %d detections\n", name, len(out.Detections))
+				for _, d := range out.Detections[:min(len(out.Detections), 10)] {
+					fmt.Printf("    class %3d score %.3f box [%.3f %.3f %.3f %.3f]\n",
+						d.Class, d.Score, d.Box[0], d.Box[1], d.Box[2], d.Box[3])
+				}
+			case len(out.Values) > 0:
+				lo, hi := out.Values[0], out.Values[0]
+				var sum float64
+				for _, v := range out.Values {
+					lo, hi = min(lo, v), max(hi, v)
+					sum += float64(v)
+				}
+				fmt.Printf("  %s %v: min %.4f max %.4f mean %.4f\n",
+					name, out.Shape, lo, hi, sum/float64(len(out.Values)))
+			default:
+				fmt.Printf("  %s %v: %d values\n", name, out.Shape, len(out.Raw))
+			}
+		}
+	}
+}
+
+// writeRunOutputs writes results.json, or one .npy file per frame and
+// output
+func writeRunOutputs(dir, format string, results []runFrame) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if format == "json" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dir, "results.json"), append(data, '\n'), 0644)
+	}
+
+	for _, frame := range results {
+		for name, out := range frame.Outputs {
+			file := fmt.Sprintf("frame%d_%s.npy", frame.Index, strings.ReplaceAll(name, "/", "_"))
+			f, err := os.Create(filepath.Join(dir, file))
+			if err != nil {
+				return err
+			}
+			err = writeNpy(f, out.array)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sortedOutputNames returns the output names in order
+func sortedOutputNames(outputs map[string]*runOutput) []string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,225 @@
+//go:build unit
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+func TestNpyRoundTrip(t *testing.T) {
+	a := &npyArray{Descr: "<f4", Shape: []int{2, 3}, Data: make([]byte, 24)}
+	binary.LittleEndian.PutUint32(a.Data[20:], math.Float32bits(1.5))
+
+	var buf bytes.Buffer
+	if err := writeNpy(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	if (buf.Len()-len(a.Data))%64 != 0 {
+		t.Errorf("header is %d bytes, want a multiple of 64", buf.Len()-len(a.Data))
+	}
+
+	got, err := readNpy(buf.Bytes())
+	if err != nil {
+		t.Fatalf("readNpy() error = %v", err)
+	}
+	if got.Descr != "<f4" || len(got.Shape) != 2 || got.Shape[0] != 2 || got.Shape[1] != 3 {
+		t.Errorf("readNpy() = %s %v", got.Descr, got.Shape)
+	}
+	if !bytes.Equal(got.Data, a.Data) {
+		t.Error("data changed in the round trip")
+	}
+
+	if _, err := readNpy(buf.Bytes()[:buf.Len()-1]); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+func TestLoadRunInputs(t *testing.T) {
+	dir := t.TempDir()
+	streams := []hef.StreamInfo{
+		{Name: "image", Shape: hef.ImageShape3D{Height: 2, Width: 2, Features: 1}},
+		{Name: "params", Shape: hef.ImageShape3D{Height: 1, Width: 1, Features: 2},
+			QuantInfo: hef.QuantInfo{Scale: 0.5}},
+	}
+
+	// Two frames of the image input in one raw file
+	frames := filepath.Join(dir, "frames.bin")
+	os.WriteFile(frames, []byte{1, 2, 3, 4, 5, 6, 7, 8}, 0644)
+
+	// One float frame of params, quantized with scale 0.5
+	var params bytes.Buffer
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data[0:], math.Float32bits(1))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(2))
+	writeNpy(&params, &npyArray{Descr: "<f4", Shape: []int{1, 1, 2}, Data: data})
+	npyPath := filepath.Join(dir, "params.npy")
+	os.WriteFile(npyPath, params.Bytes(), 0644)
+
+	inputs, err := loadRunInputs([]string{"image=" + frames, "params=" + npyPath}, streams)
+	if err != nil {
+		t.Fatalf("loadRunInputs() error = %v", err)
+	}
+	if len(inputs) != 2 {
+		t.Fatalf("%d frames, want 2", len(inputs))
+	}
+	if !bytes.Equal(inputs[1]["image"], []byte{5, 6, 7, 8}) {
+		t.Errorf("frame 1 image = %v", inputs[1]["image"])
+	}
+	// The single params frame is repeated
+	for i, in := range inputs {
+		if !bytes.Equal(in["params"], []byte{2, 4}) {
+			t.Errorf("frame %d params = %v, want [2 4]", i, in["params"])
+		}
+	}
+
+	if _, err := loadRunInputs([]string{frames}, streams); err == nil {
+		t.Error("expected error for an unnamed input with two inputs")
+	}
+	if _, err := loadRunInputs([]string{"image=" + frames}, streams); err == nil {
+		t.Error("expected error for a missing input")
+	}
+}
+
+func TestImageFrameResizes(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+
+	info := hef.StreamInfo{Name: "rgb", Shape: hef.ImageShape3D{Height: 2, Width: 2, Features: 3}}
+	frame, err := imageFrame(img, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame) != 12 {
+		t.Fatalf("frame is %d bytes, want 12", len(frame))
+	}
+	if frame[0] != 200 || frame[1] != 100 || frame[2] != 50 {
+		t.Errorf("first pixel = %v, want [200 100 50]", frame[:3])
+	}
+
+	info.Shape.Features = 4
+	if _, err := imageFrame(img, info); err == nil {
+		t.Error("expected error for a 4 feature input")
+	}
+}
+
+func TestDecodeRunOutputNms(t *testing.T) {
+	// Class 0 has no detections, class 1 has one
+	values := []float32{0, 1, 0.1, 0.2, 0.3, 0.4, 0.9}
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	info := hef.StreamInfo{Name: "nms", Format: hef.Format{Type: hef.FormatTypeFloat32}}
+	nms := &hef.VStreamInfo{IsNms: true, NmsShape: hef.NmsShape{NumberOfClasses: 2}}
+
+	out, err := decodeRunOutput(data, info, "nms", nms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Detections) != 1 {
+		t.Fatalf("%d detections, want 1", len(out.Detections))
+	}
+	d := out.Detections[0]
+	if d.Class != 1 || d.Score != 0.9 || d.Box[2] != 0.3 {
+		t.Errorf("detection = %+v", d)
+	}
+	if out.array.Shape[0] != 1 || out.array.Shape[1] != 6 {
+		t.Errorf("npy shape = %v, want [1 6]", out.array.Shape)
+	}
+}
+
+func TestDecodeRunOutputNmsReadsFloats(t *testing.T) {
+	// The HEF declares the NMS stream as uint8, the device fills it with
+	// float32 detections
+	values := []float32{1, 0.5, 0.5, 0.75, 0.75, 0.8}
+	data := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+	info := hef.StreamInfo{Name: "nms", Format: hef.Format{Type: hef.FormatTypeUint8},
+		Shape: hef.ImageShape3D{Height: 1, Width: 1, Features: uint32(len(data))}}
+	nms := &hef.VStreamInfo{IsNms: true, NmsShape: hef.NmsShape{NumberOfClasses: 1}}
+
+	out, err := decodeRunOutput(data, info, "nms", nms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Detections) != 1 || out.Detections[0].Score != 0.8 {
+		t.Errorf("detections = %+v, want one with score 0.8", out.Detections)
+	}
+
+	if _, err := decodeRunOutput(data[:len(data)-1], info, "nms", nms); err == nil {
+		t.Error("expected error for a partial float")
+	}
+}
+
+func TestNmsOutputStream(t *testing.T) {
+	nms := &hef.VStreamInfo{Name: "yolo/nms", IsNms: true}
+	outputs := []hef.StreamInfo{{Name: "yolo/conv1"}, {Name: "yolo/nms"}}
+
+	s, err := nmsOutputStream(outputs, nms)
+	if err != nil || s.Name != "yolo/nms" {
+		t.Errorf("nmsOutputStream() = %s, %v, want yolo/nms", s.Name, err)
+	}
+
+	// A single output carries the NMS results whatever it is called
+	s, err = nmsOutputStream(outputs[:1], nms)
+	if err != nil || s.Name != "yolo/conv1" {
+		t.Errorf("nmsOutputStream(single) = %s, %v, want yolo/conv1", s.Name, err)
+	}
+
+	outputs[1].Name = "yolo/conv2"
+	if _, err := nmsOutputStream(outputs, nms); err == nil {
+		t.Error("expected error when no output matches the NMS layer")
+	}
+}
+
+func TestLoadRunInputsUint16(t *testing.T) {
+	dir := t.TempDir()
+	info := hef.StreamInfo{Name: "depth", Shape: hef.ImageShape3D{Height: 1, Width: 2, Features: 1},
+		Format: hef.Format{Type: hef.FormatTypeUint16, Order: hef.FormatOrderNHWC}, QuantInfo: hef.QuantInfo{Scale: 0.5}}
+	if got := inputFrameSize(info); got != 4 {
+		t.Fatalf("inputFrameSize() = %d, want 4", got)
+	}
+
+	// Raw frames are split at two bytes per element
+	raw := filepath.Join(dir, "depth.bin")
+	os.WriteFile(raw, []byte{1, 0, 2, 0, 3, 0, 4, 0}, 0644)
+	inputs, err := loadRunInputs([]string{raw}, []hef.StreamInfo{info})
+	if err != nil {
+		t.Fatalf("loadRunInputs(raw) error = %v", err)
+	}
+	if len(inputs) != 2 || !bytes.Equal(inputs[1]["depth"], []byte{3, 0, 4, 0}) {
+		t.Errorf("raw frames = %v", inputs)
+	}
+
+	// Float frames are quantized to uint16
+	var buf bytes.Buffer
+	data := make([]byte, 8)
+	binary.LittleEndian.PutUint32(data[0:], math.Float32bits(150))
+	binary.LittleEndian.PutUint32(data[4:], math.Float32bits(1))
+	writeNpy(&buf, &npyArray{Descr: "<f4", Shape: []int{1, 2, 1}, Data: data})
+	npyPath := filepath.Join(dir, "depth.npy")
+	os.WriteFile(npyPath, buf.Bytes(), 0644)
+
+	inputs, err = loadRunInputs([]string{npyPath}, []hef.StreamInfo{info})
+	if err != nil {
+		t.Fatalf("loadRunInputs(npy) error = %v", err)
+	}
+	if len(inputs) != 1 || !bytes.Equal(inputs[0]["depth"], []byte{44, 1, 2, 0}) {
+		t.Errorf("npy frame = %v, want [44 1 2 0]", inputs)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/device"
+)
+
+// scanCommand handles "hailort scan [--watch [--serial]]"
+func scanCommand(args []string) {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	watch := fs.Bool("watch", false, "keep running and report devices as they are added and removed")
+	serial := fs.Bool("serial", false, "ask the firmware of unclaimed devices for their serial number")
+	fs.Parse(args)
+
+	if !*watch {
+		scanDevices()
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scanner := device.NewScanner()
+	scanner.IdentifyFirmware = *serial
+	events, err := scanner.Watch(ctx)
+	if err != nil {
+		fmt.Printf("Error watching devices: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Watching for Hailo devices (Ctrl-C to stop)")
+	for ev := range events {
+		fmt.Printf("%s %-7s %s\n", time.Now().Format("15:04:05"), ev.Type, formatDeviceInfo(ev.Info))
+	}
+}
+
+// formatDeviceInfo renders the known fields of a device on one line
+func formatDeviceInfo(info device.DeviceInfo) string {
+	line := info.Path
+	if info.PCIeAddress != "" {
+		line += " pcie=" + info.PCIeAddress
+	}
+	if info.Described {
+		line += fmt.Sprintf(" board=%s fw_loaded=%v", info.BoardType, info.FirmwareLoaded)
+	}
+	if info.SerialNumber != "" {
+		line += " serial=" + info.SerialNumber
+	}
+	if info.Claimed {
+		line += " claimed"
+	}
+	return line
+}
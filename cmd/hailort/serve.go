@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/daemon"
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/stream"
+)
+
+// serveCommand handles "hailort serve --hef <file> [--socket path] [--device path] [--exclusive]"
+func serveCommand(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	hefPath := fs.String("hef", "", "HEF file to serve (required)")
+	socketPath := fs.String("socket", daemon.DefaultSocketPath, "Unix socket to listen on")
+	devicePath := fs.String("device", "", "device to open (default: first available)")
+	exclusive := fs.Bool("exclusive", true, "claim the device so no other process can open it")
+	recordPath := fs.String("record-controls", "", "record firmware control exchanges to this file for replay")
+	supervise := fs.Bool("supervise", true, "reset the device and retry when it stops responding")
+	fs.Parse(args)
+
+	if *hefPath == "" {
+		fmt.Println("Usage: hailort serve --hef <file> [--socket path] [--device path] [--exclusive=false]")
+		os.Exit(1)
+	}
+
+	hefFile, err := hef.Parse(*hefPath)
+	if err != nil {
+		fmt.Printf("Error parsing HEF: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := device.OpenOptions{Exclusive: *exclusive}
+	var dev *device.Device
+	if *devicePath != "" {
+		dev, err = device.OpenWithOptions(*devicePath, opts)
+	} else {
+		dev, err = device.OpenFirstWithOptions(opts)
+	}
+	if err != nil {
+		fmt.Printf("Error opening device: %v\n", err)
+		os.Exit(1)
+	}
+	defer dev.Close()
+
+	if *recordPath != "" {
+		f, err := os.Create(*recordPath)
+		if err != nil {
+			fmt.Printf("Error creating control recording: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		dev.SetTransport(control.NewRecordingTransport(dev.DeviceFile(), f))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var sup *device.Supervisor
+	if *supervise {
+		cfg := device.DefaultSupervisorConfig()
+		cfg.OnTrip = func(reason error) {
+			fmt.Printf("Device wedged, recovering: %v\n", reason)
+		}
+		cfg.OnRecoveryFailed = func(err error) {
+			fmt.Printf("Device recovery failed: %v\n", err)
+		}
+		sup = device.NewSupervisor(dev, cfg)
+		if err := sup.Start(ctx); err != nil {
+			fmt.Printf("Error watching device notifications: %v\n", err)
+			os.Exit(1)
+		}
+		defer sup.Close()
+	}
+
+	backend, err := daemon.NewDeviceBackend(dev, hefFile, stream.DefaultVStreamParams(), sup)
+	if err != nil {
+		fmt.Printf("Error preparing model: %v\n", err)
+		os.Exit(1)
+	}
+	defer backend.Close()
+
+	server := daemon.NewServer(backend)
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+
+	info := backend.Info()
+	fmt.Printf("Serving %s on %s from %s (Ctrl-C to stop)\n", info.NetworkGroup, *socketPath, info.Device)
+	if err := server.ListenAndServe(*socketPath); err != nil && !errors.Is(err, daemon.ErrServerClosed) {
+		fmt.Printf("Error serving: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,13 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"time"
 
-	"github.com/anthropics/purple-hailo/pkg/control"
 	"github.com/anthropics/purple-hailo/pkg/device"
 	"github.com/anthropics/purple-hailo/pkg/driver"
 	"github.com/anthropics/purple-hailo/pkg/hef"
@@ -69,14 +69,14 @@ func main() {
 
 	// Step 3: Test firmware communication
 	fmt.Println("\n=== Step 3: Testing Firmware Communication ===")
-	err = control.Identify(dev.DeviceFile(), 1)
+	_, err = dev.Control().Identify(context.Background())
 	if err != nil {
 		log.Printf("IDENTIFY (APP CPU) failed: %v", err)
 	} else {
 		fmt.Println("IDENTIFY (APP CPU): SUCCESS")
 	}
 
-	err = control.IdentifyCore(dev.DeviceFile(), 2)
+	err = dev.Control().IdentifyCore(context.Background())
 	if err != nil {
 		log.Printf("CORE_IDENTIFY failed: %v", err)
 	} else {
@@ -5,6 +5,8 @@ package integration
 import (
 	"testing"
 	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/transform"
 )
 
 // BenchmarkInferenceLatency measures per-frame inference time
@@ -135,6 +137,66 @@ func BenchmarkConcurrentInference(b *testing.B) {
 	})
 }
 
+// BenchmarkTransformKernels compares the scalar reference kernels against the
+// runtime-selected implementations on a 640x640x3 model input
+func BenchmarkTransformKernels(b *testing.B) {
+	const h, w, c = 640, 640, 3
+	size := h * w * c
+	qi := transform.QuantInfo{ZeroPoint: 128, Scale: 0.0078}
+
+	u8 := make([]uint8, size)
+	u8Out := make([]uint8, size)
+	f32 := make([]float32, size)
+	camera := make([]uint8, 1280*720*3)
+
+	kernels := []struct {
+		name      string
+		bytes     int64
+		scalar    func()
+		optimized func()
+	}{
+		{
+			name:      "Dequantize",
+			bytes:     int64(size),
+			scalar:    func() { transform.DequantizeBatchScalar(u8, f32, qi) },
+			optimized: func() { transform.DequantizeBatch(u8, f32, qi) },
+		},
+		{
+			name:      "Quantize",
+			bytes:     int64(size * 4),
+			scalar:    func() { transform.QuantizeBatchScalar(f32, u8Out, qi) },
+			optimized: func() { transform.QuantizeBatch(f32, u8Out, qi) },
+		},
+		{
+			name:      "NHWCtoNCHW",
+			bytes:     int64(size),
+			scalar:    func() { transform.ConvertNHWCtoNCHWScalar(u8, u8Out, h, w, c) },
+			optimized: func() { transform.ConvertNHWCtoNCHW(u8, u8Out, h, w, c) },
+		},
+		{
+			name:      "ResizeBilinear",
+			bytes:     int64(size),
+			scalar:    func() { transform.ResizeBilinearScalar(camera, u8Out, 720, 1280, h, w, c) },
+			optimized: func() { transform.ResizeBilinear(camera, u8Out, 720, 1280, h, w, c) },
+		},
+	}
+
+	for _, k := range kernels {
+		b.Run(k.name+"/scalar", func(b *testing.B) {
+			b.SetBytes(k.bytes)
+			for i := 0; i < b.N; i++ {
+				k.scalar()
+			}
+		})
+		b.Run(k.name+"/optimized", func(b *testing.B) {
+			b.SetBytes(k.bytes)
+			for i := 0; i < b.N; i++ {
+				k.optimized()
+			}
+		})
+	}
+}
+
 // Mock implementations for benchmarking
 
 func simulateInference(input []byte) []float32 {
@@ -5,6 +5,7 @@ package control
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/anthropics/purple-hailo/pkg/hef"
@@ -252,6 +253,232 @@ func SerializeSequencerDoneInterrupt(sequencerIndex uint8, timestamp uint32) []b
 	return append(header, sequencerIndex)
 }
 
+// Host buffer types from CONTROL_PROTOCOL__HOST_BUFFER_TYPE_t
+const (
+	HostBufferTypeExternalDesc uint8 = 0 // descriptor list
+	HostBufferTypeCcb          uint8 = 1 // continuous buffer
+)
+
+// Edge layer directions from CONTEXT_SWITCH_DEFS__EDGE_LAYER_DIRECTION_t
+const (
+	EdgeLayerDirectionHostToDevice uint8 = 0
+	EdgeLayerDirectionDeviceToHost uint8 = 1
+)
+
+// HostBufferInfo describes the host memory behind a vDMA channel
+// Matches CONTROL_PROTOCOL__host_buffer_info_t
+type HostBufferInfo struct {
+	BufferType     uint8
+	DmaAddress     uint64
+	DescPageSize   uint16
+	TotalDescCount uint32
+	BytesInPattern uint32
+}
+
+// hostBufferInfoSize is the packed size of HostBufferInfo
+const hostBufferInfoSize = 19
+
+// pack serializes the buffer info into dst
+func (h *HostBufferInfo) pack(dst []byte) {
+	dst[0] = h.BufferType
+	binary.LittleEndian.PutUint64(dst[1:9], h.DmaAddress)
+	binary.LittleEndian.PutUint16(dst[9:11], h.DescPageSize)
+	binary.LittleEndian.PutUint32(dst[11:15], h.TotalDescCount)
+	binary.LittleEndian.PutUint32(dst[15:19], h.BytesInPattern)
+}
+
+// StreamRegInfo holds the stream registers the firmware programs when it
+// activates an edge layer
+// Matches CONTEXT_SWITCH_DEFS__stream_reg_info_t
+type StreamRegInfo struct {
+	CoreBytesPerBuffer          uint16
+	CoreBuffersPerFrame         uint16
+	PeriphBytesPerBuffer        uint16
+	PeriphBuffersPerFrame       uint16
+	FeaturePaddingPayload       uint16
+	BufferPaddingPayload        uint32
+	BufferPadding               uint16
+	IsPeriphCalculatedInHailort bool
+	IsCoreHwPaddingConfigInDfc  bool
+}
+
+// streamRegInfoSize is the packed size of StreamRegInfo
+const streamRegInfoSize = 18
+
+// pack serializes the register info into dst
+func (r *StreamRegInfo) pack(dst []byte) {
+	binary.LittleEndian.PutUint16(dst[0:2], r.CoreBytesPerBuffer)
+	binary.LittleEndian.PutUint16(dst[2:4], r.CoreBuffersPerFrame)
+	binary.LittleEndian.PutUint16(dst[4:6], r.PeriphBytesPerBuffer)
+	binary.LittleEndian.PutUint16(dst[6:8], r.PeriphBuffersPerFrame)
+	binary.LittleEndian.PutUint16(dst[8:10], r.FeaturePaddingPayload)
+	binary.LittleEndian.PutUint32(dst[10:14], r.BufferPaddingPayload)
+	binary.LittleEndian.PutUint16(dst[14:16], r.BufferPadding)
+	dst[16] = boolByte(r.IsPeriphCalculatedInHailort)
+	dst[17] = boolByte(r.IsCoreHwPaddingConfigInDfc)
+}
+
+func boolByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// serializeChannelActivation packs the layout shared by the boundary and
+// inter-context activations: packed_vdma_channel_id, stream_index,
+// network_index, stream_reg_info, host_buffer_info and, for inputs,
+// initial_credit_size
+func serializeChannelActivation(actionType uint8, channelID, streamIndex, networkIndex uint8,
+	reg *StreamRegInfo, buf *HostBufferInfo, withCredit bool, initialCredit uint32, timestamp uint32) []byte {
+	header := PackActionHeader(actionType, timestamp)
+	size := 3 + streamRegInfoSize + hostBufferInfoSize
+	if withCredit {
+		size += 4
+	}
+	data := make([]byte, size)
+	data[0] = channelID
+	data[1] = streamIndex
+	data[2] = networkIndex
+	reg.pack(data[3:])
+	buf.pack(data[3+streamRegInfoSize:])
+	if withCredit {
+		binary.LittleEndian.PutUint32(data[3+streamRegInfoSize+hostBufferInfoSize:], initialCredit)
+	}
+	return append(header, data...)
+}
+
+// SerializeActivateBoundaryInput serializes an activate boundary input action
+// Matches CONTEXT_SWITCH_DEFS__activate_boundary_input_data_t
+func SerializeActivateBoundaryInput(channelID, streamIndex, networkIndex uint8,
+	reg *StreamRegInfo, buf *HostBufferInfo, initialCredit uint32, timestamp uint32) []byte {
+	return serializeChannelActivation(FwActionTypeActivateBoundaryInput, channelID, streamIndex, networkIndex,
+		reg, buf, true, initialCredit, timestamp)
+}
+
+// SerializeActivateBoundaryOutput serializes an activate boundary output action
+// Matches CONTEXT_SWITCH_DEFS__activate_boundary_output_data_t
+func SerializeActivateBoundaryOutput(channelID, streamIndex, networkIndex uint8,
+	reg *StreamRegInfo, buf *HostBufferInfo, timestamp uint32) []byte {
+	return serializeChannelActivation(FwActionTypeActivateBoundaryOutput, channelID, streamIndex, networkIndex,
+		reg, buf, false, 0, timestamp)
+}
+
+// SerializeActivateInterContextInput serializes an activate inter-context input action
+// Matches CONTEXT_SWITCH_DEFS__activate_inter_context_input_data_t
+func SerializeActivateInterContextInput(channelID, streamIndex, networkIndex uint8,
+	reg *StreamRegInfo, buf *HostBufferInfo, initialCredit uint32, timestamp uint32) []byte {
+	return serializeChannelActivation(FwActionTypeActivateInterContextInput, channelID, streamIndex, networkIndex,
+		reg, buf, true, initialCredit, timestamp)
+}
+
+// SerializeActivateInterContextOutput serializes an activate inter-context output action
+// Matches CONTEXT_SWITCH_DEFS__activate_inter_context_output_data_t
+func SerializeActivateInterContextOutput(channelID, streamIndex, networkIndex uint8,
+	reg *StreamRegInfo, buf *HostBufferInfo, timestamp uint32) []byte {
+	return serializeChannelActivation(FwActionTypeActivateInterContextOutput, channelID, streamIndex, networkIndex,
+		reg, buf, false, 0, timestamp)
+}
+
+// SerializeActivateDdrBufferInput serializes an activate DDR buffer input action
+// Matches CONTEXT_SWITCH_DEFS__activate_ddr_buffer_input_data_t
+func SerializeActivateDdrBufferInput(channelID, streamIndex uint8, reg *StreamRegInfo, buf *HostBufferInfo,
+	initialCredit uint32, connectedD2HChannelID uint8, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeActivateDdrBufferInput, timestamp)
+	// Data: packed_vdma_channel_id (1) + stream_index (1) + stream_reg_info (18) +
+	// host_buffer_info (19) + initial_credit_size (4) + connected_d2h_packed_vdma_channel_id (1) = 44 bytes
+	data := make([]byte, 2+streamRegInfoSize+hostBufferInfoSize+5)
+	data[0] = channelID
+	data[1] = streamIndex
+	reg.pack(data[2:])
+	buf.pack(data[2+streamRegInfoSize:])
+	off := 2 + streamRegInfoSize + hostBufferInfoSize
+	binary.LittleEndian.PutUint32(data[off:off+4], initialCredit)
+	data[off+4] = connectedD2HChannelID
+	return append(header, data...)
+}
+
+// SerializeActivateDdrBufferOutput serializes an activate DDR buffer output action
+// Matches CONTEXT_SWITCH_DEFS__activate_ddr_buffer_output_data_t
+func SerializeActivateDdrBufferOutput(channelID, streamIndex uint8, reg *StreamRegInfo, buf *HostBufferInfo,
+	bufferedRowsCount uint32, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeActivateDdrBufferOutput, timestamp)
+	// Data: packed_vdma_channel_id (1) + stream_index (1) + stream_reg_info (18) +
+	// host_buffer_info (19) + buffered_rows_count (4) = 43 bytes
+	data := make([]byte, 2+streamRegInfoSize+hostBufferInfoSize+4)
+	data[0] = channelID
+	data[1] = streamIndex
+	reg.pack(data[2:])
+	buf.pack(data[2+streamRegInfoSize:])
+	binary.LittleEndian.PutUint32(data[2+streamRegInfoSize+hostBufferInfoSize:], bufferedRowsCount)
+	return append(header, data...)
+}
+
+// SerializeFetchCfgChannelDescriptors serializes a fetch config channel descriptors action
+// Matches CONTEXT_SWITCH_DEFS__fetch_cfg_channel_descriptors_action_data_t
+func SerializeFetchCfgChannelDescriptors(descriptorsCount uint16, configStreamIndex uint8, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeFetchCfgChannelDescriptors, timestamp)
+	// Data: descriptors_count (2) + config_stream_index (1) = 3 bytes
+	data := make([]byte, 3)
+	binary.LittleEndian.PutUint16(data[0:2], descriptorsCount)
+	data[2] = configStreamIndex
+	return append(header, data...)
+}
+
+// SerializeFetchDataFromVdmaChannel serializes a fetch data action, which
+// lets an input channel start moving data into the core
+// Matches CONTEXT_SWITCH_DEFS__fetch_data_action_data_t
+func SerializeFetchDataFromVdmaChannel(channelID, streamIndex, networkIndex, hostBufferType uint8, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeFetchDataFromVdmaChannel, timestamp)
+	// Data: packed_vdma_channel_id (1) + stream_index (1) + network_index (1) + host_buffer_type (1) = 4 bytes
+	data := []byte{channelID, streamIndex, networkIndex, hostBufferType}
+	return append(header, data...)
+}
+
+// SerializeValidateVdmaChannel serializes a validate vDMA channel action
+// Matches CONTEXT_SWITCH_DEFS__validate_vdma_channel_action_data_t
+func SerializeValidateVdmaChannel(channelID, edgeLayerDirection uint8, isInterContext bool,
+	hostBufferType uint8, initialCredit uint32, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeValidateVdmaChannel, timestamp)
+	// Data: packed_vdma_channel_id (1) + edge_layer_direction (1) + is_inter_context (1) +
+	// host_buffer_type (1) + initial_credit_size (4) = 8 bytes
+	data := make([]byte, 8)
+	data[0] = channelID
+	data[1] = edgeLayerDirection
+	data[2] = boolByte(isInterContext)
+	data[3] = hostBufferType
+	binary.LittleEndian.PutUint32(data[4:8], initialCredit)
+	return append(header, data...)
+}
+
+// SerializeActivateCfgChannel serializes an activate config channel action,
+// which points a config channel at the config buffer of the context
+// Matches CONTEXT_SWITCH_DEFS__activate_cfg_channel_t
+func SerializeActivateCfgChannel(configStreamIndex, channelID uint8, buf *HostBufferInfo, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeActivateCfgChannel, timestamp)
+	// Data: config_stream_index (1) + packed_vdma_channel_id (1) + host_buffer_info (19) = 21 bytes
+	data := make([]byte, 2+hostBufferInfoSize)
+	data[0] = configStreamIndex
+	data[1] = channelID
+	buf.pack(data[2:])
+	return append(header, data...)
+}
+
+// SerializeDeactivateCfgChannel serializes a deactivate config channel action
+// Matches CONTEXT_SWITCH_DEFS__deactivate_cfg_channel_t
+func SerializeDeactivateCfgChannel(configStreamIndex, channelID uint8, timestamp uint32) []byte {
+	header := PackActionHeader(FwActionTypeDeactivateCfgChannel, timestamp)
+	// Data: config_stream_index (1) + packed_vdma_channel_id (1) = 2 bytes
+	data := []byte{configStreamIndex, channelID}
+	return append(header, data...)
+}
+
+// SerializeBurstCreditsTaskStart serializes a burst credits task start action
+func SerializeBurstCreditsTaskStart(timestamp uint32) []byte {
+	// Data: none
+	return PackActionHeader(FwActionTypeBurstCreditsTaskStart, timestamp)
+}
+
 // ActionListBuilder helps build action lists for contexts
 type ActionListBuilder struct {
 	actions   []byte
@@ -325,10 +552,10 @@ func ConvertHefActionToFirmware(action *hef.ConfigAction, timestamp uint32) ([]b
 
 	case hef.ActionTypeWriteDataCcw:
 		// WriteDataCcw actions are NOT written to the firmware action list!
-		// They use a separate config buffer DMA mechanism.
-		// The SDK says: "WriteDataCcwActions aren't written to the FW's action list."
-		// Skip these actions - they need to be handled via config buffers.
-		return nil, nil
+		// They use a separate config buffer DMA mechanism, and the action
+		// list only tells the firmware how many descriptors to fetch from
+		// the config channel. BuildContextActions emits that.
+		return nil, fmt.Errorf("%w: write CCW", ErrNeedsResources)
 
 	case hef.ActionTypeEnableNms:
 		if action.EnableNms == nil {
@@ -383,38 +610,59 @@ func ConvertHefActionToFirmware(action *hef.ConfigAction, timestamp uint32) ([]b
 		return nil, fmt.Errorf("WriteData action not supported in context switch")
 
 	case hef.ActionTypeAllowInputDataflow:
-		// AllowInputDataflow actions configure VDMA boundary channels for data flow.
-		// They require runtime DMA channel mapping that's not available from just the HEF.
-		// The SDK configures these dynamically during runtime stream setup.
-		// Skip these actions - they need to be handled via the DMA subsystem.
-		return nil, nil
+		// AllowInputDataflow actions let an input channel start moving data.
+		// They name the channel by stream index only; BuildContextActions
+		// resolves it through the runtime channel allocation.
+		return nil, fmt.Errorf("%w: allow input dataflow", ErrNeedsResources)
 
 	default:
 		return nil, fmt.Errorf("unsupported action type: %d", action.Type)
 	}
 }
 
-// BuildContextActionList builds the action list for a context from HEF operations
-// This is a simplified version that handles basic action types
+// BuildContextActionList builds the action list for a context from HEF
+// operations alone. Actions that need the runtime channel allocation are
+// left out, so the result only runs single-context models whose boundary
+// channels are set up by the host; use BuildContextActions for the rest.
 func BuildContextActionList(ops []hef.ConfigOperation) ([]byte, error) {
+	list, _, err := BuildContextActionListWithOptions(ops, BuildOptions{})
+	return list, err
+}
+
+// BuildContextActionListWithOptions builds the action list like
+// BuildContextActionList and reports what became of each action. A strict
+// build fails with ErrUnconvertedAction instead of leaving actions out.
+func BuildContextActionListWithOptions(ops []hef.ConfigOperation, opts BuildOptions) ([]byte, *ContextReport, error) {
 	builder := NewActionListBuilder()
 	timestamp := TimestampInitValue
+	report := &ContextReport{}
 
-	for _, op := range ops {
-		for _, action := range op.Actions {
+	for i, op := range ops {
+		for j, action := range op.Actions {
 			actionBytes, err := ConvertHefActionToFirmware(&action, timestamp)
-			if err != nil {
-				// Log but don't fail for unsupported actions
+			if errors.Is(err, ErrNeedsResources) {
+				if opts.Strict {
+					return nil, report, fmt.Errorf("operation %d action %d: %w: %w", i, j, ErrUnconvertedAction, err)
+				}
+				report.record(i, j, action.Type, ActionSkipped, err)
 				continue
 			}
-			if actionBytes != nil {
-				builder.AddAction(actionBytes)
-				timestamp-- // Decrement timestamp for next action
+			if err != nil {
+				return nil, report, fmt.Errorf("operation %d action %d: %w", i, j, err)
+			}
+			if actionBytes == nil {
+				report.record(i, j, action.Type, ActionNoop, nil)
+				continue
 			}
+			builder.AddAction(actionBytes)
+			timestamp-- // Decrement timestamp for next action
+			report.record(i, j, action.Type, ActionConverted, nil)
 		}
 	}
 
-	return builder.Build(), nil
+	list := builder.Build()
+	report.Bytes = len(list)
+	return list, report, nil
 }
 
 // BuildEmptyActionList creates a minimal action list for testing
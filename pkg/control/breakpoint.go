@@ -0,0 +1,219 @@
+package control
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+)
+
+// BreakpointControl is what a config breakpoint request does, from
+// CONTROL_PROTOCOL__context_switch_breakpoint_control_t
+type BreakpointControl uint8
+
+const (
+	BreakpointSet      BreakpointControl = 0 // CONTROL_PROTOCOL__CONTEXT_SWITCH_BREAKPOINT_CONTROL_SET
+	BreakpointContinue BreakpointControl = 1 // CONTROL_PROTOCOL__CONTEXT_SWITCH_BREAKPOINT_CONTROL_CONTINUE
+	BreakpointClear    BreakpointControl = 2 // CONTROL_PROTOCOL__CONTEXT_SWITCH_BREAKPOINT_CONTROL_CLEAR
+)
+
+// BreakpointStatus is the state of a breakpoint, from
+// CONTROL_PROTOCOL__context_switch_debug_sys_status_t
+type BreakpointStatus uint8
+
+const (
+	BreakpointCleared BreakpointStatus = 0 // no breakpoint set
+	BreakpointWaiting BreakpointStatus = 1 // set and not reached yet
+	BreakpointReached BreakpointStatus = 2 // reached; the context switch is stopped
+)
+
+// String returns the status name
+func (s BreakpointStatus) String() string {
+	switch s {
+	case BreakpointCleared:
+		return "cleared"
+	case BreakpointWaiting:
+		return "waiting"
+	case BreakpointReached:
+		return "reached"
+	}
+	return fmt.Sprintf("status(%d)", uint8(s))
+}
+
+// FirstDynamicContextIndex is the firmware index of dynamic context 0. The
+// firmware numbers contexts in the order they are sent: activation, batch
+// switching, preliminary, then the dynamic contexts.
+const FirstDynamicContextIndex = 3
+
+// Breakpoint is where the context switch stops. The Any fields match every
+// value of the field they precede.
+type Breakpoint struct {
+	AnyNetworkGroup   bool
+	NetworkGroupIndex uint8
+	AnyBatch          bool
+	BatchIndex        uint16
+	AnyContext        bool
+	ContextIndex      uint16 // firmware context index
+	AnyAction         bool
+	ActionIndex       uint16 // index of the action in the context's action list
+}
+
+// breakpointDataSize is the size of CONTROL_PROTOCOL__context_switch_breakpoint_data_t
+const breakpointDataSize = 11
+
+// pack encodes the breakpoint as CONTROL_PROTOCOL__context_switch_breakpoint_data_t
+func (b *Breakpoint) pack() []byte {
+	data := make([]byte, breakpointDataSize)
+	data[0] = boolByte(b.AnyNetworkGroup)
+	data[1] = b.NetworkGroupIndex
+	data[2] = boolByte(b.AnyBatch)
+	binary.BigEndian.PutUint16(data[3:5], b.BatchIndex)
+	data[5] = boolByte(b.AnyContext)
+	binary.BigEndian.PutUint16(data[6:8], b.ContextIndex)
+	data[8] = boolByte(b.AnyAction)
+	binary.BigEndian.PutUint16(data[9:11], b.ActionIndex)
+	return data
+}
+
+// PackConfigBreakpointRequest creates a request that sets, continues or
+// clears a breakpoint. Matches
+// CONTROL_PROTOCOL__config_context_switch_breakpoint_request_t.
+func PackConfigBreakpointRequest(sequence uint32, breakpointID uint8, ctrl BreakpointControl, bp *Breakpoint) []byte {
+	request := PackRequestHeader(sequence, OpcodeConfigBreakpoint)
+
+	paramCount := make([]byte, 4)
+	binary.BigEndian.PutUint32(paramCount, 3)
+	request = append(request, paramCount...)
+
+	if bp == nil {
+		bp = &Breakpoint{}
+	}
+	request = append(request, packParameter([]byte{breakpointID})...)
+	request = append(request, packParameter([]byte{uint8(ctrl)})...)
+	request = append(request, packParameter(bp.pack())...)
+	return request
+}
+
+// PackGetBreakpointStatusRequest creates a request for the status of a
+// breakpoint
+func PackGetBreakpointStatusRequest(sequence uint32, breakpointID uint8) []byte {
+	request := PackRequestHeader(sequence, OpcodeGetBreakpointStatus)
+
+	paramCount := make([]byte, 4)
+	binary.BigEndian.PutUint32(paramCount, 1)
+	request = append(request, paramCount...)
+	return append(request, packParameter([]byte{breakpointID})...)
+}
+
+// ConfigBreakpointContext sends a config breakpoint request
+func ConfigBreakpointContext(ctx context.Context, device Transport, sequence uint32, breakpointID uint8, ctrl BreakpointControl, bp *Breakpoint) error {
+	request := PackConfigBreakpointRequest(sequence, breakpointID, ctrl, bp)
+	log.Printf("[control] ConfigBreakpoint: seq=%d, id=%d, control=%d", sequence, breakpointID, ctrl)
+
+	response, _, err := device.FwControlContext(ctx, request, computeRequestMD5(request), DefaultTimeoutMs, CpuIdCoreCpu)
+	if err != nil {
+		return fmt.Errorf("config breakpoint failed: %w", err)
+	}
+	return ValidateResponse(response, sequence, OpcodeConfigBreakpoint)
+}
+
+// GetBreakpointStatusContext reads the status of a breakpoint
+func GetBreakpointStatusContext(ctx context.Context, device Transport, sequence uint32, breakpointID uint8) (BreakpointStatus, error) {
+	request := PackGetBreakpointStatusRequest(sequence, breakpointID)
+
+	response, _, err := device.FwControlContext(ctx, request, computeRequestMD5(request), DefaultTimeoutMs, CpuIdCoreCpu)
+	if err != nil {
+		return 0, fmt.Errorf("get breakpoint status failed: %w", err)
+	}
+	if err := ValidateResponse(response, sequence, OpcodeGetBreakpointStatus); err != nil {
+		return 0, err
+	}
+	return ParseBreakpointStatusResponse(response)
+}
+
+// ParseBreakpointStatusResponse reads the status from a get breakpoint
+// status response
+func ParseBreakpointStatusResponse(response []byte) (BreakpointStatus, error) {
+	params, err := ParseResponseParameters(response)
+	if err != nil {
+		return 0, err
+	}
+	if len(params) < 1 || len(params[0]) < 1 {
+		return 0, fmt.Errorf("breakpoint status response has no status")
+	}
+	return BreakpointStatus(params[0][0]), nil
+}
+
+// BreakpointHit is the payload of an EventContextSwitchBreakpoint
+// notification, CONTEXT_SWITCH_BREAKPOINT_REACHED_EVENT_MESSAGE_t
+type BreakpointHit struct {
+	NetworkGroupIndex uint8
+	BatchIndex        uint16
+	ContextIndex      uint16
+	ActionIndex       uint16
+}
+
+// breakpointHitSize is the size of the notification payload
+const breakpointHitSize = 7
+
+// ParseBreakpointHit reads where the context switch stopped from a
+// breakpoint notification
+func ParseBreakpointHit(n *Notification) (*BreakpointHit, error) {
+	if n.EventID != EventContextSwitchBreakpoint {
+		return nil, fmt.Errorf("not a breakpoint notification: %s", n)
+	}
+	if len(n.Payload) < breakpointHitSize {
+		return nil, fmt.Errorf("breakpoint notification too short: %d bytes, need %d", len(n.Payload), breakpointHitSize)
+	}
+	p := n.Payload
+	return &BreakpointHit{
+		NetworkGroupIndex: p[0],
+		BatchIndex:        binary.LittleEndian.Uint16(p[1:3]),
+		ContextIndex:      binary.LittleEndian.Uint16(p[3:5]),
+		ActionIndex:       binary.LittleEndian.Uint16(p[5:7]),
+	}, nil
+}
+
+// String describes where the context switch stopped
+func (h *BreakpointHit) String() string {
+	ctx := fmt.Sprintf("context %d", h.ContextIndex)
+	if h.ContextIndex >= FirstDynamicContextIndex {
+		ctx = fmt.Sprintf("dynamic context %d", h.ContextIndex-FirstDynamicContextIndex)
+	}
+	return fmt.Sprintf("network group %d batch %d %s action %d",
+		h.NetworkGroupIndex, h.BatchIndex, ctx, h.ActionIndex)
+}
+
+// SetBreakpoint sets a breakpoint in the context switch
+func (c *Channel) SetBreakpoint(ctx context.Context, breakpointID uint8, bp *Breakpoint) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return ConfigBreakpointContext(ctx, t, seq, breakpointID, BreakpointSet, bp)
+	})
+}
+
+// ContinueBreakpoint resumes the context switch stopped at a breakpoint,
+// keeping the breakpoint set
+func (c *Channel) ContinueBreakpoint(ctx context.Context, breakpointID uint8) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return ConfigBreakpointContext(ctx, t, seq, breakpointID, BreakpointContinue, nil)
+	})
+}
+
+// ClearBreakpoint removes a breakpoint, resuming the context switch if it
+// is stopped there
+func (c *Channel) ClearBreakpoint(ctx context.Context, breakpointID uint8) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return ConfigBreakpointContext(ctx, t, seq, breakpointID, BreakpointClear, nil)
+	})
+}
+
+// BreakpointStatus reads the state of a breakpoint
+func (c *Channel) BreakpointStatus(ctx context.Context, breakpointID uint8) (BreakpointStatus, error) {
+	var status BreakpointStatus
+	err := c.query(ctx, func(t Transport, seq uint32) error {
+		var err error
+		status, err = GetBreakpointStatusContext(ctx, t, seq, breakpointID)
+		return err
+	})
+	return status, err
+}
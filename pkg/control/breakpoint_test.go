@@ -0,0 +1,56 @@
+//go:build unit
+
+package control
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestPackConfigBreakpointRequest(t *testing.T) {
+	bp := &Breakpoint{NetworkGroupIndex: 1, AnyBatch: true, ContextIndex: 4, ActionIndex: 0x0102}
+	request := PackConfigBreakpointRequest(9, 0, BreakpointSet, bp)
+
+	if seq, opcode := requestHeaderFields(request); seq != 9 || opcode != OpcodeConfigBreakpoint {
+		t.Errorf("header = seq %d opcode %d", seq, opcode)
+	}
+	params := request[RequestHeaderSize:]
+	if binary.BigEndian.Uint32(params[0:4]) != 3 {
+		t.Fatalf("parameter count = %d, want 3", binary.BigEndian.Uint32(params[0:4]))
+	}
+	want := []byte{
+		0, 0, 0, 1, 0, // breakpoint id
+		0, 0, 0, 1, 0, // control: set
+		0, 0, 0, 11, 0, 1, 1, 0, 0, 0, 0, 4, 0, 1, 2,
+	}
+	if !bytes.Equal(params[4:], want) {
+		t.Errorf("parameters = % x\nwant         % x", params[4:], want)
+	}
+}
+
+func TestParseBreakpointHit(t *testing.T) {
+	n := &Notification{
+		EventID: EventContextSwitchBreakpoint,
+		Payload: []byte{2, 3, 0, 5, 0, 7, 1},
+	}
+	hit, err := ParseBreakpointHit(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hit.NetworkGroupIndex != 2 || hit.BatchIndex != 3 || hit.ContextIndex != 5 || hit.ActionIndex != 0x107 {
+		t.Errorf("hit = %+v", hit)
+	}
+	if hit.String() != "network group 2 batch 3 dynamic context 2 action 263" {
+		t.Errorf("String() = %q", hit.String())
+	}
+
+	n.Payload = n.Payload[:6]
+	if _, err := ParseBreakpointHit(n); err == nil {
+		t.Error("expected error for short payload")
+	}
+	n.EventID = EventDebug
+	if _, err := ParseBreakpointHit(n); err == nil {
+		t.Error("expected error for another event")
+	}
+}
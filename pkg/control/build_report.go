@@ -0,0 +1,132 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// ErrUnconvertedAction is returned by strict builds for an action that
+// would be left out of the action list
+var ErrUnconvertedAction = errors.New("action not converted")
+
+// BuildOptions controls action list generation
+type BuildOptions struct {
+	// Strict fails the build on any action that is not converted, instead
+	// of leaving it out of the list
+	Strict bool
+}
+
+// ActionOutcome is what became of a HEF action in the action list
+type ActionOutcome int
+
+const (
+	ActionConverted ActionOutcome = iota // written as a firmware action
+	ActionMerged                         // CCW write folded into a config fetch
+	ActionNoop                           // nothing to do
+	ActionSkipped                        // left out of the list
+)
+
+// String returns the outcome name
+func (o ActionOutcome) String() string {
+	switch o {
+	case ActionConverted:
+		return "converted"
+	case ActionMerged:
+		return "merged"
+	case ActionNoop:
+		return "noop"
+	case ActionSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("outcome(%d)", int(o))
+}
+
+// ActionRecord records one HEF action by its position in the context
+type ActionRecord struct {
+	Operation int
+	Action    int
+	Type      hef.ActionType
+	Outcome   ActionOutcome
+	Reason    string // why the action was skipped
+}
+
+// ContextReport records how the action list of one context was built
+type ContextReport struct {
+	Name    string // "preliminary" or "dynamic N"
+	Bytes   int    // size of the action list sent
+	Actions []ActionRecord
+
+	// Fallback is set when the list failed to build and an empty list was
+	// sent in its place; Error says why
+	Fallback bool
+	Error    string
+}
+
+// record appends an action record
+func (r *ContextReport) record(op, action int, t hef.ActionType, outcome ActionOutcome, reason error) {
+	rec := ActionRecord{Operation: op, Action: action, Type: t, Outcome: outcome}
+	if reason != nil {
+		rec.Reason = reason.Error()
+	}
+	r.Actions = append(r.Actions, rec)
+}
+
+// Count returns the number of actions with the given outcome
+func (r *ContextReport) Count(outcome ActionOutcome) int {
+	n := 0
+	for _, a := range r.Actions {
+		if a.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Skipped returns the actions left out of the list
+func (r *ContextReport) Skipped() []ActionRecord {
+	var skipped []ActionRecord
+	for _, a := range r.Actions {
+		if a.Outcome == ActionSkipped {
+			skipped = append(skipped, a)
+		}
+	}
+	return skipped
+}
+
+// BuildReport records how the action lists of a network group were built
+type BuildReport struct {
+	Contexts []ContextReport
+}
+
+// Complete reports whether every action made it into the lists and no
+// context fell back to an empty list
+func (r *BuildReport) Complete() bool {
+	for i := range r.Contexts {
+		if r.Contexts[i].Fallback || r.Contexts[i].Count(ActionSkipped) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// String summarizes the report, one line per context and one per skipped
+// action
+func (r *BuildReport) String() string {
+	var b strings.Builder
+	for i := range r.Contexts {
+		c := &r.Contexts[i]
+		fmt.Fprintf(&b, "%s: %d bytes, %d converted, %d merged, %d skipped",
+			c.Name, c.Bytes, c.Count(ActionConverted), c.Count(ActionMerged), c.Count(ActionSkipped))
+		if c.Fallback {
+			fmt.Fprintf(&b, ", fell back to an empty list: %s", c.Error)
+		}
+		b.WriteByte('\n')
+		for _, a := range c.Skipped() {
+			fmt.Fprintf(&b, "  operation %d action %d (%s): %s\n", a.Operation, a.Action, a.Type, a.Reason)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// ErrSequenceMismatch is matched by responses to a different request than
+// the one sent
+var ErrSequenceMismatch = errors.New("control sequence mismatch")
+
+// SequenceError is a response carrying the sequence number of another
+// request, such as a late response to one that timed out
+type SequenceError struct {
+	Expected uint32
+	Got      uint32
+}
+
+// Error implements the error interface
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("sequence mismatch: expected %d, got %d", e.Expected, e.Got)
+}
+
+// Is matches ErrSequenceMismatch
+func (e *SequenceError) Is(target error) bool {
+	return target == ErrSequenceMismatch
+}
+
+// ChannelConfig configures a control channel
+type ChannelConfig struct {
+	// MaxAttempts is the number of times a query is tried before its
+	// error is returned (default 3). Requests that change firmware state
+	// are sent once.
+	MaxAttempts int
+
+	// RetryBackoff is the delay before the second attempt, doubled for
+	// each further attempt (default 10ms)
+	RetryBackoff time.Duration
+}
+
+// DefaultChannelConfig returns the default channel configuration
+func DefaultChannelConfig() ChannelConfig {
+	return ChannelConfig{
+		MaxAttempts:  3,
+		RetryBackoff: 10 * time.Millisecond,
+	}
+}
+
+// Channel is the control path to one device. It owns the sequence
+// counter, sends one exchange at a time, and retries queries that fail
+// transiently with fresh sequence numbers.
+type Channel struct {
+	transport Transport
+	cfg       ChannelConfig
+
+	// sleep is replaced in tests
+	sleep func(time.Duration)
+
+	mu       sync.Mutex
+	sequence uint32 // last sequence number used
+}
+
+// NewChannel creates a channel over a transport. Zero config fields take
+// their defaults.
+func NewChannel(transport Transport, cfg ChannelConfig) *Channel {
+	def := DefaultChannelConfig()
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = def.RetryBackoff
+	}
+	return &Channel{
+		transport: transport,
+		cfg:       cfg,
+		sleep:     time.Sleep,
+	}
+}
+
+// Transport returns the transport the channel sends through
+func (c *Channel) Transport() Transport {
+	return c.transport
+}
+
+// Sequence returns the last sequence number used
+func (c *Channel) Sequence() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sequence
+}
+
+// Exchange runs fn once with the channel to itself. fn takes a sequence
+// number for each request it sends by incrementing *seq. The counter then
+// moves past the sequence numbers fn used, so a late response to a failed
+// request is never taken for a new one. A failure is not retried: a
+// request that timed out may still have changed the firmware state.
+func (c *Channel) Exchange(ctx context.Context, fn func(t Transport, seq *uint32) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.exchangeLocked(fn)
+}
+
+// Query is like Exchange but runs fn again after a transient failure,
+// with the sequence numbers that follow the ones it used. fn must only
+// read firmware state, so that sending it twice does no harm.
+func (c *Channel) Query(ctx context.Context, fn func(t Transport, seq *uint32) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	backoff := c.cfg.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := c.exchangeLocked(fn)
+		if err == nil || attempt >= c.cfg.MaxAttempts || !transient(ctx, err) {
+			return err
+		}
+		c.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// exchangeLocked runs fn once and resyncs the counter
+func (c *Channel) exchangeLocked(fn func(t Transport, seq *uint32) error) error {
+	seq := c.sequence
+	err := fn(c.transport, &seq)
+	c.resync(seq, err)
+	return err
+}
+
+// resync moves the counter past the sequence numbers an exchange used and
+// past any the firmware answered with instead
+func (c *Channel) resync(used uint32, err error) {
+	c.sequence = used
+	var seqErr *SequenceError
+	if errors.As(err, &seqErr) && seqErr.Got > c.sequence {
+		c.sequence = seqErr.Got
+	}
+}
+
+// transient reports whether a failed query is worth retrying. Statuses
+// the firmware returned are final; timeouts, interrupted waits and
+// responses to other requests are not.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var fwErr *FirmwareError
+	if errors.As(err, &fwErr) {
+		return false
+	}
+	if errors.Is(err, ErrSequenceMismatch) {
+		return true
+	}
+	for _, status := range []driver.Status{driver.StatusDriverTimeout, driver.StatusDriverInterrupted, driver.StatusTimeout} {
+		if errors.Is(err, driver.NewError(status, "")) {
+			return true
+		}
+	}
+	return false
+}
+
+// send runs an exchange of a single request
+func (c *Channel) send(ctx context.Context, fn func(t Transport, seq uint32) error) error {
+	return c.Exchange(ctx, single(fn))
+}
+
+// query runs a query of a single request
+func (c *Channel) query(ctx context.Context, fn func(t Transport, seq uint32) error) error {
+	return c.Query(ctx, single(fn))
+}
+
+// single sends one request with the next sequence number
+func single(fn func(t Transport, seq uint32) error) func(t Transport, seq *uint32) error {
+	return func(t Transport, seq *uint32) error {
+		*seq++
+		return fn(t, *seq)
+	}
+}
+
+// Identify reads the identity of the device
+func (c *Channel) Identify(ctx context.Context) (*IdentifyResponse, error) {
+	var identity *IdentifyResponse
+	err := c.query(ctx, func(t Transport, seq uint32) error {
+		var err error
+		identity, err = IdentifyDevice(t, seq)
+		return err
+	})
+	return identity, err
+}
+
+// IdentifyCore identifies the core CPU
+func (c *Channel) IdentifyCore(ctx context.Context) error {
+	return c.query(ctx, func(t Transport, seq uint32) error {
+		return IdentifyCore(t, seq)
+	})
+}
+
+// ClearConfiguredApps clears the network groups configured in the firmware
+func (c *Channel) ClearConfiguredApps(ctx context.Context) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return ClearConfiguredAppsContext(ctx, t, seq)
+	})
+}
+
+// SetNetworkGroupHeader sends the network group header
+func (c *Channel) SetNetworkGroupHeader(ctx context.Context, appHeader *ApplicationHeader) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return SetNetworkGroupHeaderContext(ctx, t, seq, appHeader)
+	})
+}
+
+// SendContextInfo sends the action list of a context, split into chunks.
+// A failure is not retried, since the firmware may have taken some of the
+// chunks; the network group has to be configured again.
+func (c *Channel) SendContextInfo(ctx context.Context, contextType uint8, data []byte) error {
+	return c.Exchange(ctx, func(t Transport, seq *uint32) error {
+		return SendContextInfoChunksContext(ctx, t, seq, contextType, data)
+	})
+}
+
+// EnableCoreOp enables a network group in the context switch state machine
+func (c *Channel) EnableCoreOp(ctx context.Context, networkGroupIndex uint8, dynamicBatchSize, batchCount uint16) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return EnableCoreOpContext(ctx, t, seq, networkGroupIndex, dynamicBatchSize, batchCount)
+	})
+}
+
+// ResetContextSwitchStateMachine resets the context switch state machine
+func (c *Channel) ResetContextSwitchStateMachine(ctx context.Context) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return ResetContextSwitchStateMachineContext(ctx, t, seq)
+	})
+}
+
+// Reset resets the chip or a part of it
+func (c *Channel) Reset(ctx context.Context, resetType uint8) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return Reset(t, seq, resetType)
+	})
+}
@@ -0,0 +1,238 @@
+//go:build unit
+
+package control
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// scriptedFirmware acknowledges requests, failing or answering with a
+// stale sequence number for the ones listed, and records the sequence
+// numbers it was sent
+type scriptedFirmware struct {
+	mu        sync.Mutex
+	sequences []uint32
+	fail      map[int]error  // by request index
+	stale     map[int]uint32 // sequence to answer with, by request index
+}
+
+func (f *scriptedFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := len(f.sequences)
+	seq, _ := requestHeaderFields(request)
+	f.sequences = append(f.sequences, seq)
+	if err := f.fail[i]; err != nil {
+		return nil, [16]byte{}, err
+	}
+
+	response := make([]byte, ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], ProtocolVersion)
+	copy(response[8:16], request[8:16]) // sequence and opcode
+	if stale, ok := f.stale[i]; ok {
+		binary.BigEndian.PutUint32(response[8:12], stale)
+	}
+	return response, md5.Sum(response), nil
+}
+
+func newTestChannel(t Transport) (*Channel, *[]time.Duration) {
+	var sleeps []time.Duration
+	c := NewChannel(t, ChannelConfig{})
+	c.sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+	return c, &sleeps
+}
+
+func TestChannelSequence(t *testing.T) {
+	fw := &scriptedFirmware{}
+	c, _ := newTestChannel(fw)
+	ctx := context.Background()
+
+	if err := c.ClearConfiguredApps(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// Two chunks take two sequence numbers
+	if err := c.SendContextInfo(ctx, ContextTypeDynamic, make([]byte, MaxContextNetworkDataSize+1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.EnableCoreOp(ctx, 0, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []uint32{1, 2, 3, 4}
+	if len(fw.sequences) != len(want) {
+		t.Fatalf("sequences = %v, want %v", fw.sequences, want)
+	}
+	for i := range want {
+		if fw.sequences[i] != want[i] {
+			t.Fatalf("sequences = %v, want %v", fw.sequences, want)
+		}
+	}
+	if c.Sequence() != 4 {
+		t.Errorf("Sequence() = %d, want 4", c.Sequence())
+	}
+}
+
+func TestChannelConcurrent(t *testing.T) {
+	fw := &scriptedFirmware{}
+	c, _ := newTestChannel(fw)
+
+	const workers, each = 8, 20
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				if err := c.ResetContextSwitchStateMachine(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	seen := make(map[uint32]bool)
+	for _, seq := range fw.sequences {
+		if seen[seq] {
+			t.Fatalf("sequence %d sent twice", seq)
+		}
+		seen[seq] = true
+	}
+	if len(seen) != workers*each {
+		t.Errorf("%d requests sent, want %d", len(seen), workers*each)
+	}
+}
+
+func TestChannelRetryTransient(t *testing.T) {
+	fw := &scriptedFirmware{fail: map[int]error{
+		0: driver.NewError(driver.StatusDriverTimeout, "ioctl"),
+		1: driver.NewError(driver.StatusDriverInterrupted, "ioctl"),
+	}}
+	c, sleeps := newTestChannel(fw)
+
+	if err := c.IdentifyCore(context.Background()); err != nil {
+		t.Fatalf("IdentifyCore() = %v, want success on the third attempt", err)
+	}
+	// Every attempt takes a fresh sequence number
+	if len(fw.sequences) != 3 || fw.sequences[0] != 1 || fw.sequences[1] != 2 || fw.sequences[2] != 3 {
+		t.Errorf("sequences = %v, want [1 2 3]", fw.sequences)
+	}
+	if len(*sleeps) != 2 || (*sleeps)[1] != 2*(*sleeps)[0] {
+		t.Errorf("backoff = %v, want two doubling delays", *sleeps)
+	}
+}
+
+func TestChannelRetryExhausted(t *testing.T) {
+	timeout := driver.NewError(driver.StatusDriverTimeout, "ioctl")
+	fw := &scriptedFirmware{fail: map[int]error{0: timeout, 1: timeout, 2: timeout}}
+	c, _ := newTestChannel(fw)
+
+	err := c.IdentifyCore(context.Background())
+	if !errors.Is(err, driver.NewError(driver.StatusDriverTimeout, "")) {
+		t.Errorf("IdentifyCore() = %v, want the driver timeout", err)
+	}
+	if len(fw.sequences) != DefaultChannelConfig().MaxAttempts {
+		t.Errorf("%d attempts, want %d", len(fw.sequences), DefaultChannelConfig().MaxAttempts)
+	}
+}
+
+func TestChannelResync(t *testing.T) {
+	// The firmware answers the first request with a later sequence number,
+	// as a late response to an earlier request would
+	fw := &scriptedFirmware{stale: map[int]uint32{0: 9}}
+	c, _ := newTestChannel(fw)
+
+	if err := c.IdentifyCore(context.Background()); err != nil {
+		t.Fatalf("IdentifyCore() = %v", err)
+	}
+	if len(fw.sequences) != 2 || fw.sequences[1] != 10 {
+		t.Errorf("sequences = %v, want the retry sent as 10", fw.sequences)
+	}
+	if c.Sequence() != 10 {
+		t.Errorf("Sequence() = %d, want 10", c.Sequence())
+	}
+}
+
+func TestChannelNoRetry(t *testing.T) {
+	fw := &rejectingFirmware{status: FwStatusUnsupportedOpcode}
+	c, sleeps := newTestChannel(fw)
+	if err := c.EnableCoreOp(context.Background(), 0, 0, 0); !errors.Is(err, ErrFwUnsupportedOpcode) {
+		t.Errorf("EnableCoreOp() = %v, want ErrFwUnsupportedOpcode", err)
+	}
+	if fw.requests != 1 || len(*sleeps) != 0 {
+		t.Errorf("firmware error retried: %d requests", fw.requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout := driver.NewError(driver.StatusDriverTimeout, "ioctl")
+	sf := &scriptedFirmware{fail: map[int]error{0: timeout, 1: timeout}}
+	c, _ = newTestChannel(sf)
+	if err := c.EnableCoreOp(ctx, 0, 0, 0); err == nil || len(sf.sequences) != 1 {
+		t.Errorf("EnableCoreOp() = %v after %d requests, want one failed request once canceled", err, len(sf.sequences))
+	}
+}
+
+func TestChannelStateChangesSentOnce(t *testing.T) {
+	timeout := driver.NewError(driver.StatusDriverTimeout, "ioctl")
+	requests := []struct {
+		name string
+		send func(c *Channel) error
+	}{
+		{"ClearConfiguredApps", func(c *Channel) error { return c.ClearConfiguredApps(context.Background()) }},
+		{"SetNetworkGroupHeader", func(c *Channel) error {
+			return c.SetNetworkGroupHeader(context.Background(), &ApplicationHeader{})
+		}},
+		{"SendContextInfo", func(c *Channel) error {
+			return c.SendContextInfo(context.Background(), ContextTypeDynamic, make([]byte, 8))
+		}},
+		{"EnableCoreOp", func(c *Channel) error { return c.EnableCoreOp(context.Background(), 0, 0, 0) }},
+		// Reset takes a lost response for the chip going down, so only
+		// the request count is checked
+		{"Reset", func(c *Channel) error { return c.Reset(context.Background(), ResetTypeSoft) }},
+	}
+
+	for _, tt := range requests {
+		fw := &scriptedFirmware{fail: map[int]error{0: timeout}}
+		c, _ := newTestChannel(fw)
+		err := tt.send(c)
+		if tt.name != "Reset" && !errors.Is(err, driver.NewError(driver.StatusDriverTimeout, "")) {
+			t.Errorf("%s() = %v, want the driver timeout", tt.name, err)
+		}
+		if len(fw.sequences) != 1 {
+			t.Errorf("%s sent %d times, want once", tt.name, len(fw.sequences))
+		}
+	}
+
+	// A late response is not retried either, but the counter moves past it
+	fw := &scriptedFirmware{stale: map[int]uint32{0: 9}}
+	c, _ := newTestChannel(fw)
+	if err := c.EnableCoreOp(context.Background(), 0, 0, 0); !errors.Is(err, ErrSequenceMismatch) {
+		t.Errorf("EnableCoreOp() = %v, want ErrSequenceMismatch", err)
+	}
+	if len(fw.sequences) != 1 || c.Sequence() != 9 {
+		t.Errorf("sequences = %v, Sequence() = %d, want one request and 9", fw.sequences, c.Sequence())
+	}
+}
+
+// rejectingFirmware fails every request with a firmware status
+type rejectingFirmware struct {
+	status   FirmwareStatus
+	requests int
+}
+
+func (f *rejectingFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	f.requests++
+	seq, opcode := requestHeaderFields(request)
+	response := failedResponse(seq, opcode, f.status, 0)
+	return response, md5.Sum(response), nil
+}
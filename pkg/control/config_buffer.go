@@ -0,0 +1,98 @@
+package control
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// PreliminaryContextIndex keys the config buffers of the preliminary
+// context, which has no index of its own
+const PreliminaryContextIndex = ^uint32(0)
+
+// ccwHeaderSize is the size of a CCW header. An all-zero header is a NOP,
+// which is what config buffers are padded with.
+const ccwHeaderSize = 8
+
+// CfgBufferKey identifies the config buffer of one config channel in one
+// context
+type CfgBufferKey struct {
+	Context uint32
+	Index   uint32 // config stream index
+}
+
+// ccwRun is a run of consecutive CCW writes to one config channel. The
+// firmware fetches each run with a single action.
+type ccwRun struct {
+	channel uint32
+	size    uint64
+	writes  [][]byte
+}
+
+// ccwRuns splits the CCW writes of a context into runs. A run ends at any
+// other action or at a write to a different channel, the same way
+// BuildContextActions flushes its fetches.
+func ccwRuns(ops []hef.ConfigOperation) []ccwRun {
+	var runs []ccwRun
+	var cur *ccwRun
+	for _, op := range ops {
+		for i := range op.Actions {
+			action := &op.Actions[i]
+			if action.Type != hef.ActionTypeWriteDataCcw {
+				cur = nil
+				continue
+			}
+			channel := uint32(action.Address)
+			if cur == nil || cur.channel != channel {
+				runs = append(runs, ccwRun{channel: channel})
+				cur = &runs[len(runs)-1]
+			}
+			cur.writes = append(cur.writes, action.Data)
+			cur.size += uint64(len(action.Data))
+		}
+	}
+	return runs
+}
+
+// CcwChannels returns the config stream indexes a context writes CCWs to,
+// in ascending order
+func CcwChannels(ops []hef.ConfigOperation) []uint32 {
+	seen := make(map[uint32]bool)
+	var channels []uint32
+	for _, run := range ccwRuns(ops) {
+		if !seen[run.channel] {
+			seen[run.channel] = true
+			channels = append(channels, run.channel)
+		}
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i] < channels[j] })
+	return channels
+}
+
+// BuildConfigImages gathers the CCW writes of a context into the contents
+// of one config buffer per config stream index. Each run of writes is
+// padded with NOP headers to a whole page, so every fetch the action list
+// makes starts on a page boundary and moves whole pages.
+func BuildConfigImages(ops []hef.ConfigOperation, pageSize uint16) (map[uint32][]byte, error) {
+	if pageSize == 0 || pageSize%ccwHeaderSize != 0 {
+		return nil, fmt.Errorf("invalid config page size %d", pageSize)
+	}
+
+	images := make(map[uint32][]byte)
+	for _, run := range ccwRuns(ops) {
+		if run.size%ccwHeaderSize != 0 {
+			return nil, fmt.Errorf("config channel %d: CCW run of %d bytes is not a multiple of %d",
+				run.channel, run.size, ccwHeaderSize)
+		}
+		image := images[run.channel]
+		for _, data := range run.writes {
+			image = append(image, data...)
+		}
+		if residue := run.size % uint64(pageSize); residue != 0 {
+			image = append(image, make([]byte, uint64(pageSize)-residue)...)
+		}
+		images[run.channel] = image
+	}
+	return images, nil
+}
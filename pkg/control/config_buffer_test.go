@@ -0,0 +1,103 @@
+//go:build unit
+
+package control
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+func ccw(channel uint64, size int, fill byte) hef.ConfigAction {
+	return hef.ConfigAction{Type: hef.ActionTypeWriteDataCcw, Address: channel, Data: bytes.Repeat([]byte{fill}, size)}
+}
+
+func TestBuildConfigImages(t *testing.T) {
+	ops := []hef.ConfigOperation{
+		{Actions: []hef.ConfigAction{ccw(0, 16, 0xAA), ccw(0, 8, 0xBB)}},
+		{Actions: []hef.ConfigAction{
+			ccw(0, 8, 0xCC), // still the first run
+			{Type: hef.ActionTypeDisableLcu},
+			ccw(0, 40, 0xDD),
+			ccw(1, 8, 0xEE),
+		}},
+	}
+
+	images, err := BuildConfigImages(ops, 32)
+	if err != nil {
+		t.Fatalf("BuildConfigImages failed: %v", err)
+	}
+
+	// Channel 0 holds a 32-byte run and a 40-byte run padded to 64
+	img := images[0]
+	if len(img) != 96 {
+		t.Fatalf("channel 0 image = %d bytes, want 96", len(img))
+	}
+	if img[0] != 0xAA || img[16] != 0xBB || img[24] != 0xCC || img[32] != 0xDD || img[71] != 0xDD {
+		t.Errorf("channel 0 image = % x", img)
+	}
+	if !bytes.Equal(img[72:], make([]byte, 24)) {
+		t.Errorf("padding = % x, want NOPs", img[72:])
+	}
+	if len(images[1]) != 32 {
+		t.Errorf("channel 1 image = %d bytes, want 32", len(images[1]))
+	}
+
+	if got := fmt.Sprint(CcwChannels(ops)); got != "[0 1]" {
+		t.Errorf("CcwChannels = %s", got)
+	}
+}
+
+func TestBuildConfigImagesUnaligned(t *testing.T) {
+	ops := []hef.ConfigOperation{{Actions: []hef.ConfigAction{ccw(0, 12, 0)}}}
+	if _, err := BuildConfigImages(ops, 32); err == nil {
+		t.Error("expected error for a run that is not whole CCW headers")
+	}
+	if _, err := BuildConfigImages(nil, 0); err == nil {
+		t.Error("expected error for zero page size")
+	}
+}
+
+// The fetch sizes the action list asks for match the padded images
+func TestConfigImagesMatchFetches(t *testing.T) {
+	ops := []hef.ConfigOperation{{Actions: []hef.ConfigAction{
+		ccw(0, 520, 1), ccw(0, 8, 2),
+		{Type: hef.ActionTypeDisableLcu, DisableLcu: &hef.DisableLcuParams{}},
+		ccw(0, 1024, 3),
+	}}}
+
+	images, err := BuildConfigImages(ops, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := NewContextResources()
+	res.CfgChannels[0] = CfgChannel{DescPageSize: 512}
+	res.CfgBuffers[CfgBufferKey{Context: 0, Index: 0}] = HostBufferInfo{}
+	list, _, err := BuildContextActions(0, nil, ops, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for len(list) > 0 {
+		switch list[0] {
+		case FwActionTypeFetchCfgChannelDescriptors:
+			total += int(list[5]) | int(list[6])<<8
+			list = list[8:]
+		case FwActionTypeDisableLcu:
+			list = list[6:]
+		case FwActionTypeActivateCfgChannel:
+			list = list[26:]
+		case FwActionTypeDeactivateCfgChannel:
+			list = list[7:]
+		default:
+			t.Fatalf("unexpected action type %d", list[0])
+		}
+	}
+	if want := len(images[0]) / 512; total != want {
+		t.Errorf("fetched %d descriptors, image has %d pages", total, want)
+	}
+}
@@ -0,0 +1,384 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// Errors for action list building
+var (
+	ErrNeedsResources = errors.New("action needs the runtime channel allocation")
+	ErrMissingChannel = errors.New("no vDMA channel allocated")
+	ErrMissingConfig  = errors.New("no config buffer allocated")
+)
+
+// EdgeKey identifies an edge layer of a context
+type EdgeKey struct {
+	Context   uint32
+	Name      string
+	Direction hef.StreamDirection
+}
+
+// EdgeChannel is the runtime placement of an edge layer: the vDMA channel
+// it moves over and the host memory behind it
+type EdgeChannel struct {
+	Engine        uint8
+	Channel       uint8
+	Buffer        HostBufferInfo
+	InitialCredit uint32 // bytes an input may send before the core asks for more
+}
+
+// PackedID returns the channel id as the firmware packs it
+func (c EdgeChannel) PackedID() uint8 {
+	return PackedVdmaChannelId(uint32(c.Engine), uint32(c.Channel))
+}
+
+// CfgChannel is the runtime placement of a config channel
+type CfgChannel struct {
+	Engine       uint8
+	Channel      uint8
+	DescPageSize uint16
+}
+
+// PackedID returns the channel id as the firmware packs it
+func (c CfgChannel) PackedID() uint8 {
+	return PackedVdmaChannelId(uint32(c.Engine), uint32(c.Channel))
+}
+
+// ContextResources is the runtime channel allocation of a network group,
+// which BuildContextActions combines with the HEF context metadata
+type ContextResources struct {
+	Edges       map[EdgeKey]EdgeChannel
+	CfgChannels map[uint32]CfgChannel // by config stream index
+	CfgBuffers  map[CfgBufferKey]HostBufferInfo
+}
+
+// NewContextResources creates an empty allocation
+func NewContextResources() *ContextResources {
+	return &ContextResources{
+		Edges:       make(map[EdgeKey]EdgeChannel),
+		CfgChannels: make(map[uint32]CfgChannel),
+		CfgBuffers:  make(map[CfgBufferKey]HostBufferInfo),
+	}
+}
+
+// edge looks up the channel of an edge layer
+func (r *ContextResources) edge(contextIndex uint32, layer *hef.EdgeLayer) (EdgeChannel, error) {
+	ch, ok := r.Edges[EdgeKey{Context: contextIndex, Name: layer.Name, Direction: layer.Direction}]
+	if !ok {
+		return EdgeChannel{}, fmt.Errorf("%w: %s edge %s in context %d",
+			ErrMissingChannel, layer.ConnectionType, layer.Name, contextIndex)
+	}
+	return ch, nil
+}
+
+// BuildContextActions builds the firmware action list of a context. The
+// list points each config channel the context writes to at its config
+// buffer, validates and activates every edge layer of the context on its
+// allocated channel, starts the burst credits task when the context has
+// inputs, then runs the HEF operations. Within the operations, each run of
+// CCW writes becomes a fetch from its config buffer and each
+// AllowInputDataflow a data fetch on the input's channel. The config
+// channels are deactivated last. Any action that cannot be converted
+// fails the build, so the build is always strict; the report records what
+// became of each HEF action.
+func BuildContextActions(contextIndex uint32, edges []hef.EdgeLayer, ops []hef.ConfigOperation, res *ContextResources) ([]byte, *ContextReport, error) {
+	return buildContextActions(contextIndex, contextIndex, edges, ops, res)
+}
+
+// BuildPreliminaryActions builds the action list of the preliminary
+// context. Its edges belong to the first dynamic context, edgeContext, and
+// its config buffers are keyed by PreliminaryContextIndex.
+func BuildPreliminaryActions(edgeContext uint32, edges []hef.EdgeLayer, ops []hef.ConfigOperation, res *ContextResources) ([]byte, *ContextReport, error) {
+	return buildContextActions(edgeContext, PreliminaryContextIndex, edges, ops, res)
+}
+
+func buildContextActions(edgeContext, cfgContext uint32, edges []hef.EdgeLayer, ops []hef.ConfigOperation, res *ContextResources) ([]byte, *ContextReport, error) {
+	if res == nil {
+		res = NewContextResources()
+	}
+
+	b := &contextActionBuilder{
+		index:      edgeContext,
+		cfgContext: cfgContext,
+		edges:      edges,
+		res:        res,
+		list:       NewActionListBuilder(),
+		timestamp:  TimestampInitValue,
+		report:     &ContextReport{},
+	}
+
+	channels := CcwChannels(ops)
+	if err := b.activateCfgChannels(channels); err != nil {
+		return nil, b.report, err
+	}
+	if err := b.addEdgeLayers(); err != nil {
+		return nil, b.report, err
+	}
+	if err := b.addOperations(ops); err != nil {
+		return nil, b.report, err
+	}
+	b.deactivateCfgChannels(channels)
+
+	list := b.list.Build()
+	b.report.Bytes = len(list)
+	return list, b.report, nil
+}
+
+// contextActionBuilder holds the state of one BuildContextActions call
+type contextActionBuilder struct {
+	index      uint32 // context the edges are keyed by
+	cfgContext uint32 // context the config buffers are keyed by
+	edges      []hef.EdgeLayer
+	res        *ContextResources
+	list       *ActionListBuilder
+	timestamp  uint32
+	report     *ContextReport
+
+	// pending CCW run, flushed as one descriptor fetch
+	ccwChannel uint32
+	ccwBytes   uint64
+	ccwPending bool
+}
+
+// add appends an action and advances the timestamp
+func (b *contextActionBuilder) add(action []byte) {
+	b.list.AddAction(action)
+	b.timestamp--
+}
+
+// cfgChannel looks up the placement and config buffer of a config channel
+func (b *contextActionBuilder) cfgChannel(index uint32) (CfgChannel, HostBufferInfo, error) {
+	cfg, ok := b.res.CfgChannels[index]
+	if !ok {
+		return CfgChannel{}, HostBufferInfo{}, fmt.Errorf("%w: config channel %d", ErrMissingChannel, index)
+	}
+	buf, ok := b.res.CfgBuffers[CfgBufferKey{Context: b.cfgContext, Index: index}]
+	if !ok {
+		return CfgChannel{}, HostBufferInfo{}, fmt.Errorf("%w: config channel %d in context %d",
+			ErrMissingConfig, index, b.index)
+	}
+	return cfg, buf, nil
+}
+
+// activateCfgChannels points each config channel at its config buffer
+func (b *contextActionBuilder) activateCfgChannels(channels []uint32) error {
+	for _, index := range channels {
+		cfg, buf, err := b.cfgChannel(index)
+		if err != nil {
+			return err
+		}
+		b.add(SerializeActivateCfgChannel(uint8(index), cfg.PackedID(), &buf, b.timestamp))
+	}
+	return nil
+}
+
+// deactivateCfgChannels releases the config channels after the operations
+func (b *contextActionBuilder) deactivateCfgChannels(channels []uint32) {
+	for _, index := range channels {
+		cfg := b.res.CfgChannels[index]
+		b.add(SerializeDeactivateCfgChannel(uint8(index), cfg.PackedID(), b.timestamp))
+	}
+}
+
+// addEdgeLayers activates the edge layers of the context
+func (b *contextActionBuilder) addEdgeLayers() error {
+	hasInputs := false
+	for i := range b.edges {
+		layer := &b.edges[i]
+		ch, err := b.res.edge(b.index, layer)
+		if err != nil {
+			return err
+		}
+
+		input := layer.Direction == hef.StreamDirectionInput
+		hasInputs = hasInputs || input
+		reg := streamRegInfo(layer)
+		streamIndex := uint8(layer.SysIndex)
+		networkIndex := uint8(layer.NetworkIndex)
+
+		switch layer.ConnectionType {
+		case hef.EdgeConnectionBoundary:
+			direction := EdgeLayerDirectionDeviceToHost
+			if input {
+				direction = EdgeLayerDirectionHostToDevice
+			}
+			b.add(SerializeValidateVdmaChannel(ch.PackedID(), direction, false,
+				ch.Buffer.BufferType, ch.InitialCredit, b.timestamp))
+			if input {
+				b.add(SerializeActivateBoundaryInput(ch.PackedID(), streamIndex, networkIndex,
+					reg, &ch.Buffer, ch.InitialCredit, b.timestamp))
+			} else {
+				b.add(SerializeActivateBoundaryOutput(ch.PackedID(), streamIndex, networkIndex,
+					reg, &ch.Buffer, b.timestamp))
+			}
+
+		case hef.EdgeConnectionInterContext:
+			if input {
+				b.add(SerializeActivateInterContextInput(ch.PackedID(), streamIndex, networkIndex,
+					reg, &ch.Buffer, ch.InitialCredit, b.timestamp))
+			} else {
+				b.add(SerializeActivateInterContextOutput(ch.PackedID(), streamIndex, networkIndex,
+					reg, &ch.Buffer, b.timestamp))
+			}
+
+		case hef.EdgeConnectionDdr:
+			if input {
+				// The DDR input reads back what the paired output of the
+				// same context wrote
+				out, err := b.ddrOutput(layer)
+				if err != nil {
+					return err
+				}
+				b.add(SerializeActivateDdrBufferInput(ch.PackedID(), streamIndex, reg, &ch.Buffer,
+					ch.InitialCredit, out.PackedID(), b.timestamp))
+			} else {
+				b.add(SerializeActivateDdrBufferOutput(ch.PackedID(), streamIndex, reg, &ch.Buffer,
+					layer.Buffers, b.timestamp))
+			}
+
+		default:
+			return fmt.Errorf("context %d: edge %s: unsupported connection type %s",
+				b.index, layer.Name, layer.ConnectionType)
+		}
+	}
+
+	if hasInputs {
+		b.add(SerializeBurstCreditsTaskStart(b.timestamp))
+	}
+	return nil
+}
+
+// ddrOutput finds the channel of the output a DDR input is paired with
+func (b *contextActionBuilder) ddrOutput(input *hef.EdgeLayer) (EdgeChannel, error) {
+	for i := range b.edges {
+		layer := &b.edges[i]
+		if layer.ConnectionType == hef.EdgeConnectionDdr && layer.Direction == hef.StreamDirectionOutput &&
+			layer.SysIndex == input.ConnectedSysIndex {
+			return b.res.edge(b.index, layer)
+		}
+	}
+	return EdgeChannel{}, fmt.Errorf("context %d: DDR input %s: no output with stream index %d",
+		b.index, input.Name, input.ConnectedSysIndex)
+}
+
+// addOperations converts the HEF operations of the context
+func (b *contextActionBuilder) addOperations(ops []hef.ConfigOperation) error {
+	for i, op := range ops {
+		for j := range op.Actions {
+			outcome, err := b.addAction(&op.Actions[j])
+			if err != nil {
+				return fmt.Errorf("context %d: operation %d action %d: %w", b.index, i, j, err)
+			}
+			b.report.record(i, j, op.Actions[j].Type, outcome, nil)
+		}
+	}
+	return b.flushCcw()
+}
+
+// addAction converts one HEF action
+func (b *contextActionBuilder) addAction(action *hef.ConfigAction) (ActionOutcome, error) {
+	if action.Type == hef.ActionTypeWriteDataCcw {
+		channel := uint32(action.Address)
+		if b.ccwPending && channel != b.ccwChannel {
+			if err := b.flushCcw(); err != nil {
+				return ActionSkipped, err
+			}
+		}
+		b.ccwChannel = channel
+		b.ccwBytes += uint64(len(action.Data))
+		b.ccwPending = true
+		return ActionMerged, nil
+	}
+
+	if err := b.flushCcw(); err != nil {
+		return ActionSkipped, err
+	}
+
+	if action.Type == hef.ActionTypeAllowInputDataflow {
+		return ActionConverted, b.addAllowInputDataflow(action)
+	}
+
+	data, err := ConvertHefActionToFirmware(action, b.timestamp)
+	if err != nil {
+		return ActionSkipped, err
+	}
+	if data == nil {
+		return ActionNoop, nil
+	}
+	b.add(data)
+	return ActionConverted, nil
+}
+
+// flushCcw emits the descriptor fetch for the pending run of CCW writes
+func (b *contextActionBuilder) flushCcw() error {
+	if !b.ccwPending {
+		return nil
+	}
+	b.ccwPending = false
+
+	cfg, buf, err := b.cfgChannel(b.ccwChannel)
+	if err != nil {
+		return err
+	}
+	if cfg.DescPageSize == 0 {
+		return fmt.Errorf("config channel %d has no descriptor page size", b.ccwChannel)
+	}
+
+	// BuildConfigImages pads each run to whole pages, so the run spans
+	// this many descriptors, or bursts of a continuous buffer
+	pages := (b.ccwBytes + uint64(cfg.DescPageSize) - 1) / uint64(cfg.DescPageSize)
+	if pages > 0xFFFF {
+		return fmt.Errorf("config channel %d: %d bytes of CCW need %d pages, more than one fetch allows",
+			b.ccwChannel, b.ccwBytes, pages)
+	}
+	b.ccwBytes = 0
+
+	if buf.BufferType == HostBufferTypeCcb {
+		b.add(SerializeFetchCcwBursts(uint16(pages), uint8(b.ccwChannel), b.timestamp))
+	} else {
+		b.add(SerializeFetchCfgChannelDescriptors(uint16(pages), uint8(b.ccwChannel), b.timestamp))
+	}
+	return nil
+}
+
+// addAllowInputDataflow resolves the input an AllowInputDataflow action
+// names by stream index and emits a data fetch on its channel
+func (b *contextActionBuilder) addAllowInputDataflow(action *hef.ConfigAction) error {
+	sysIndex := uint32(action.Address)
+	connection := hef.EdgeConnectionBoundary
+	if p := action.AllowInputDataflow; p != nil {
+		sysIndex = p.SysIndex
+		connection = p.ConnectionType
+	}
+
+	for i := range b.edges {
+		layer := &b.edges[i]
+		if layer.Direction != hef.StreamDirectionInput || layer.SysIndex != sysIndex ||
+			layer.ConnectionType != connection {
+			continue
+		}
+		ch, err := b.res.edge(b.index, layer)
+		if err != nil {
+			return err
+		}
+		b.add(SerializeFetchDataFromVdmaChannel(ch.PackedID(), uint8(sysIndex),
+			uint8(layer.NetworkIndex), ch.Buffer.BufferType, b.timestamp))
+		return nil
+	}
+	return fmt.Errorf("allow input dataflow: no %s input with stream index %d", connection, sysIndex)
+}
+
+// streamRegInfo derives the stream registers of an edge layer. The host
+// side uses the core geometry unchanged.
+func streamRegInfo(layer *hef.EdgeLayer) *StreamRegInfo {
+	return &StreamRegInfo{
+		CoreBytesPerBuffer:          uint16(layer.CoreBytesPerBuffer),
+		CoreBuffersPerFrame:         uint16(layer.CoreBuffersPerFrame),
+		PeriphBytesPerBuffer:        uint16(layer.CoreBytesPerBuffer),
+		PeriphBuffersPerFrame:       uint16(layer.CoreBuffersPerFrame),
+		IsPeriphCalculatedInHailort: true,
+	}
+}
@@ -0,0 +1,302 @@
+//go:build unit
+
+package control
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// actionDataSizes holds the payload size of the action types these tests emit
+var actionDataSizes = map[uint8]int{
+	FwActionTypeFetchCfgChannelDescriptors: 3,
+	FwActionTypeFetchDataFromVdmaChannel:   4,
+	FwActionTypeEnableLcuDefault:           2,
+	FwActionTypeActivateBoundaryInput:      44,
+	FwActionTypeActivateBoundaryOutput:     40,
+	FwActionTypeActivateInterContextInput:  44,
+	FwActionTypeActivateInterContextOutput: 40,
+	FwActionTypeActivateDdrBufferInput:     44,
+	FwActionTypeActivateDdrBufferOutput:    43,
+	FwActionTypeValidateVdmaChannel:        8,
+	FwActionTypeBurstCreditsTaskStart:      0,
+	FwActionTypeActivateCfgChannel:         21,
+	FwActionTypeDeactivateCfgChannel:       2,
+	FwActionTypeFetchCcwBursts:             3,
+}
+
+// splitActions splits an action list into actions, keyed by type
+func splitActions(t *testing.T, list []byte) (types []uint8, actions [][]byte) {
+	t.Helper()
+	for len(list) > 0 {
+		size, ok := actionDataSizes[list[0]]
+		if !ok {
+			t.Fatalf("unexpected action type %d", list[0])
+		}
+		size += 5
+		if len(list) < size {
+			t.Fatalf("action type %d truncated: %d of %d bytes", list[0], len(list), size)
+		}
+		types = append(types, list[0])
+		actions = append(actions, list[:size])
+		list = list[size:]
+	}
+	return types, actions
+}
+
+func TestSerializeEdgeActionSizes(t *testing.T) {
+	reg := &StreamRegInfo{}
+	buf := &HostBufferInfo{}
+
+	tests := []struct {
+		name   string
+		action []byte
+		want   int
+	}{
+		{"boundary input", SerializeActivateBoundaryInput(0, 0, 0, reg, buf, 0, 0), 49},
+		{"boundary output", SerializeActivateBoundaryOutput(0, 0, 0, reg, buf, 0), 45},
+		{"inter-context input", SerializeActivateInterContextInput(0, 0, 0, reg, buf, 0, 0), 49},
+		{"inter-context output", SerializeActivateInterContextOutput(0, 0, 0, reg, buf, 0), 45},
+		{"ddr input", SerializeActivateDdrBufferInput(0, 0, reg, buf, 0, 0, 0), 49},
+		{"ddr output", SerializeActivateDdrBufferOutput(0, 0, reg, buf, 0, 0), 48},
+		{"validate", SerializeValidateVdmaChannel(0, 0, false, 0, 0, 0), 13},
+		{"fetch data", SerializeFetchDataFromVdmaChannel(0, 0, 0, 0, 0), 9},
+		{"fetch cfg descriptors", SerializeFetchCfgChannelDescriptors(0, 0, 0), 8},
+		{"burst credits", SerializeBurstCreditsTaskStart(0), 5},
+		{"activate cfg channel", SerializeActivateCfgChannel(0, 0, buf, 0), 26},
+		{"deactivate cfg channel", SerializeDeactivateCfgChannel(0, 0, 0), 7},
+	}
+
+	for _, tt := range tests {
+		if len(tt.action) != tt.want {
+			t.Errorf("%s: %d bytes, want %d", tt.name, len(tt.action), tt.want)
+		}
+	}
+}
+
+func TestSerializeActivateBoundaryInputLayout(t *testing.T) {
+	reg := &StreamRegInfo{CoreBytesPerBuffer: 640, CoreBuffersPerFrame: 480}
+	buf := &HostBufferInfo{
+		BufferType:     HostBufferTypeExternalDesc,
+		DmaAddress:     0x1122334455667788,
+		DescPageSize:   512,
+		TotalDescCount: 600,
+		BytesInPattern: 640 * 480,
+	}
+	data := SerializeActivateBoundaryInput(PackedVdmaChannelId(1, 2), 3, 4, reg, buf, 4096, 0xFFFFFFFE)
+
+	if data[0] != FwActionTypeActivateBoundaryInput || binary.LittleEndian.Uint32(data[1:5]) != 0xFFFFFFFE {
+		t.Errorf("header = % x", data[:5])
+	}
+	if data[5] != 0x22 || data[6] != 3 || data[7] != 4 {
+		t.Errorf("channel/stream/network = % x", data[5:8])
+	}
+	if got := binary.LittleEndian.Uint16(data[8:10]); got != 640 {
+		t.Errorf("core_bytes_per_buffer = %d", got)
+	}
+	hostBuf := data[8+streamRegInfoSize:]
+	if got := binary.LittleEndian.Uint64(hostBuf[1:9]); got != buf.DmaAddress {
+		t.Errorf("dma_address = %#x", got)
+	}
+	if got := binary.LittleEndian.Uint32(data[len(data)-4:]); got != 4096 {
+		t.Errorf("initial_credit_size = %d", got)
+	}
+}
+
+// multiContextFixture returns a context with every edge kind and the
+// allocation that places it
+func multiContextFixture() ([]hef.EdgeLayer, []hef.ConfigOperation, *ContextResources) {
+	edges := []hef.EdgeLayer{
+		{Name: "input", Direction: hef.StreamDirectionInput, ConnectionType: hef.EdgeConnectionBoundary, SysIndex: 0},
+		{Name: "to_ctx2", Direction: hef.StreamDirectionOutput, ConnectionType: hef.EdgeConnectionInterContext, SysIndex: 1},
+		{Name: "ddr_out", Direction: hef.StreamDirectionOutput, ConnectionType: hef.EdgeConnectionDdr, SysIndex: 2, Buffers: 16},
+		{Name: "ddr_in", Direction: hef.StreamDirectionInput, ConnectionType: hef.EdgeConnectionDdr, SysIndex: 3, ConnectedSysIndex: 2},
+	}
+
+	ops := []hef.ConfigOperation{
+		{Actions: []hef.ConfigAction{
+			{Type: hef.ActionTypeWriteDataCcw, Address: 0, Data: make([]byte, 400)},
+			{Type: hef.ActionTypeWriteDataCcw, Address: 0, Data: make([]byte, 300)},
+			{Type: hef.ActionTypeEnableLcu, EnableLcu: &hef.EnableLcuParams{
+				KernelDoneAddress: uint32(EnableLcuDefaultKernelAddress), KernelDoneCount: EnableLcuDefaultKernelCount,
+			}},
+			{Type: hef.ActionTypeAllowInputDataflow, AllowInputDataflow: &hef.AllowInputDataflowParams{
+				SysIndex: 0, ConnectionType: hef.EdgeConnectionBoundary,
+			}},
+		}},
+	}
+
+	res := NewContextResources()
+	for i, edge := range edges {
+		res.Edges[EdgeKey{Context: 1, Name: edge.Name, Direction: edge.Direction}] = EdgeChannel{Engine: 0, Channel: uint8(i)}
+	}
+	res.CfgChannels[0] = CfgChannel{Channel: 10, DescPageSize: 512}
+	res.CfgBuffers[CfgBufferKey{Context: 1, Index: 0}] = HostBufferInfo{
+		BufferType: HostBufferTypeExternalDesc, DmaAddress: 0x1000, DescPageSize: 512, TotalDescCount: 2, BytesInPattern: 1024,
+	}
+	return edges, ops, res
+}
+
+func TestBuildContextActions(t *testing.T) {
+	edges, ops, res := multiContextFixture()
+
+	list, _, err := BuildContextActions(1, edges, ops, res)
+	if err != nil {
+		t.Fatalf("BuildContextActions failed: %v", err)
+	}
+
+	types, actions := splitActions(t, list)
+	want := []uint8{
+		FwActionTypeActivateCfgChannel,
+		FwActionTypeValidateVdmaChannel,
+		FwActionTypeActivateBoundaryInput,
+		FwActionTypeActivateInterContextOutput,
+		FwActionTypeActivateDdrBufferOutput,
+		FwActionTypeActivateDdrBufferInput,
+		FwActionTypeBurstCreditsTaskStart,
+		FwActionTypeFetchCfgChannelDescriptors,
+		FwActionTypeEnableLcuDefault,
+		FwActionTypeFetchDataFromVdmaChannel,
+		FwActionTypeDeactivateCfgChannel,
+	}
+	if fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Fatalf("action types = %v, want %v", types, want)
+	}
+
+	// Timestamps count down from the initial value
+	for i, action := range actions {
+		if got := binary.LittleEndian.Uint32(action[1:5]); got != TimestampInitValue-uint32(i) {
+			t.Errorf("action %d timestamp = %#x", i, got)
+		}
+	}
+
+	// The config channel points at the context's config buffer
+	activate := actions[0]
+	if activate[5] != 0 || activate[6] != PackedVdmaChannelId(0, 10) {
+		t.Errorf("activate cfg channel index/channel = % x", activate[5:7])
+	}
+	if got := binary.LittleEndian.Uint64(activate[8:16]); got != 0x1000 {
+		t.Errorf("config buffer dma_address = %#x", got)
+	}
+
+	// 700 bytes of CCW on 512-byte pages is two descriptors
+	fetch := actions[7]
+	if got := binary.LittleEndian.Uint16(fetch[5:7]); got != 2 {
+		t.Errorf("descriptors_count = %d, want 2", got)
+	}
+
+	// The DDR input points at the output it reads back
+	ddrIn := actions[5]
+	if got := ddrIn[len(ddrIn)-1]; got != PackedVdmaChannelId(0, 2) {
+		t.Errorf("connected D2H channel = %#x, want ddr_out's", got)
+	}
+
+	// Dataflow resolves to the boundary input's channel
+	if got := actions[9][5]; got != PackedVdmaChannelId(0, 0) {
+		t.Errorf("fetch data channel = %#x", got)
+	}
+}
+
+func TestBuildContextActionsMissingChannel(t *testing.T) {
+	edges, ops, res := multiContextFixture()
+	delete(res.Edges, EdgeKey{Context: 1, Name: "to_ctx2", Direction: hef.StreamDirectionOutput})
+
+	if _, _, err := BuildContextActions(1, edges, ops, res); !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("error = %v, want ErrMissingChannel", err)
+	}
+
+	edges, ops, res = multiContextFixture()
+	delete(res.CfgChannels, 0)
+	if _, _, err := BuildContextActions(1, edges, ops, res); !errors.Is(err, ErrMissingChannel) {
+		t.Errorf("error = %v, want ErrMissingChannel for config channel", err)
+	}
+
+	edges, ops, res = multiContextFixture()
+	delete(res.CfgBuffers, CfgBufferKey{Context: 1, Index: 0})
+	if _, _, err := BuildContextActions(1, edges, ops, res); !errors.Is(err, ErrMissingConfig) {
+		t.Errorf("error = %v, want ErrMissingConfig", err)
+	}
+}
+
+func TestBuildContextActionsContinuousConfigBuffer(t *testing.T) {
+	edges, ops, res := multiContextFixture()
+	key := CfgBufferKey{Context: 1, Index: 0}
+	buf := res.CfgBuffers[key]
+	buf.BufferType = HostBufferTypeCcb
+	res.CfgBuffers[key] = buf
+
+	list, _, err := BuildContextActions(1, edges, ops, res)
+	if err != nil {
+		t.Fatalf("BuildContextActions failed: %v", err)
+	}
+
+	types, actions := splitActions(t, list)
+	if types[7] != FwActionTypeFetchCcwBursts {
+		t.Fatalf("action 7 type = %d, want FetchCcwBursts", types[7])
+	}
+	if got := binary.LittleEndian.Uint16(actions[7][5:7]); got != 2 {
+		t.Errorf("ccw_bursts = %d, want 2", got)
+	}
+}
+
+func TestBuildPreliminaryActions(t *testing.T) {
+	edges, ops, res := multiContextFixture()
+
+	// Edges stay keyed by the dynamic context; the config buffer is the
+	// preliminary context's own
+	if _, _, err := BuildPreliminaryActions(1, edges, ops, res); !errors.Is(err, ErrMissingConfig) {
+		t.Fatalf("error = %v, want ErrMissingConfig without a preliminary config buffer", err)
+	}
+
+	res.CfgBuffers[CfgBufferKey{Context: PreliminaryContextIndex, Index: 0}] = HostBufferInfo{DmaAddress: 0x2000}
+	list, _, err := BuildPreliminaryActions(1, edges, ops, res)
+	if err != nil {
+		t.Fatalf("BuildPreliminaryActions failed: %v", err)
+	}
+	_, actions := splitActions(t, list)
+	if got := binary.LittleEndian.Uint64(actions[0][8:16]); got != 0x2000 {
+		t.Errorf("config buffer dma_address = %#x, want the preliminary buffer", got)
+	}
+}
+
+func TestBuildContextActionListPropagatesErrors(t *testing.T) {
+	ops := []hef.ConfigOperation{{Actions: []hef.ConfigAction{
+		{Type: hef.ActionTypeWriteDataCcw, Data: []byte{1}},
+		{Type: hef.ActionTypeEnableLcu},
+	}}}
+
+	if _, err := BuildContextActionList(ops); err == nil {
+		t.Error("expected error for EnableLcu without parameters")
+	}
+
+	// Actions that need the channel allocation are left out
+	ops[0].Actions = ops[0].Actions[:1]
+	list, err := BuildContextActionList(ops)
+	if err != nil || len(list) != 0 {
+		t.Errorf("BuildContextActionList = %d bytes, %v; want empty list", len(list), err)
+	}
+}
+
+func TestBuildContextActionListStrict(t *testing.T) {
+	ops := []hef.ConfigOperation{{Actions: []hef.ConfigAction{
+		{Type: hef.ActionTypeNone},
+		{Type: hef.ActionTypeWriteDataCcw, Data: []byte{1}},
+	}}}
+
+	_, report, err := BuildContextActionListWithOptions(ops, BuildOptions{})
+	if err != nil {
+		t.Fatalf("non-strict build failed: %v", err)
+	}
+	if report.Count(ActionNoop) != 1 || len(report.Skipped()) != 1 || report.Skipped()[0].Action != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	if _, _, err := BuildContextActionListWithOptions(ops, BuildOptions{Strict: true}); !errors.Is(err, ErrUnconvertedAction) ||
+		!errors.Is(err, ErrNeedsResources) {
+		t.Errorf("strict build error = %v, want ErrUnconvertedAction wrapping ErrNeedsResources", err)
+	}
+}
@@ -0,0 +1,423 @@
+package control
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// fieldKind is how a field of an action is laid out
+type fieldKind int
+
+const (
+	fieldU8 fieldKind = iota
+	fieldU16
+	fieldU32
+	fieldHex32
+	fieldHex64
+	fieldBool
+	fieldLcu        // packed cluster and LCU index
+	fieldChannel    // packed engine and vDMA channel index
+	fieldStreamReg  // CONTEXT_SWITCH_DEFS__stream_reg_info_t
+	fieldHostBuffer // CONTROL_PROTOCOL__host_buffer_info_t
+)
+
+// size returns the packed size of a field kind
+func (k fieldKind) size() int {
+	switch k {
+	case fieldU16:
+		return 2
+	case fieldU32, fieldHex32:
+		return 4
+	case fieldHex64:
+		return 8
+	case fieldStreamReg:
+		return streamRegInfoSize
+	case fieldHostBuffer:
+		return hostBufferInfoSize
+	}
+	return 1
+}
+
+type fieldSpec struct {
+	name string
+	kind fieldKind
+}
+
+// actionLayout describes the payload of one firmware action type
+type actionLayout struct {
+	name   string
+	fields []fieldSpec
+}
+
+// size returns the packed payload size of the layout
+func (l *actionLayout) size() int {
+	n := 0
+	for _, f := range l.fields {
+		n += f.kind.size()
+	}
+	return n
+}
+
+// Field specs shared by several layouts
+var (
+	specChannel     = fieldSpec{"channel", fieldChannel}
+	specLcu         = fieldSpec{"lcu", fieldLcu}
+	specStreamIndex = fieldSpec{"stream_index", fieldU8}
+	specNetwork     = fieldSpec{"network_index", fieldU8}
+	specStreamReg   = fieldSpec{"stream_reg", fieldStreamReg}
+	specHostBuffer  = fieldSpec{"host_buffer", fieldHostBuffer}
+	specCredit      = fieldSpec{"initial_credit_size", fieldU32}
+	specCfgIndex    = fieldSpec{"config_stream_index", fieldU8}
+)
+
+// actionLayouts holds the payload layout of every action type, from
+// context_switch_defs.h
+var actionLayouts = map[uint8]*actionLayout{
+	FwActionTypeFetchCfgChannelDescriptors: {"fetch_cfg_channel_descriptors", []fieldSpec{
+		{"descriptors_count", fieldU16}, specCfgIndex}},
+	FwActionTypeTriggerSequencer: {"trigger_sequencer", []fieldSpec{
+		{"cluster_index", fieldU8}, {"initial_l3_cut", fieldU8}, {"initial_l3_offset", fieldU16},
+		{"active_apu", fieldHex32}, {"active_ia", fieldHex32}, {"active_sc", fieldHex64},
+		{"active_l2", fieldHex64}, {"l2_offset_0", fieldHex64}, {"l2_offset_1", fieldHex64}}},
+	FwActionTypeFetchDataFromVdmaChannel: {"fetch_data_from_vdma_channel", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, {"host_buffer_type", fieldU8}}},
+	FwActionTypeEnableLcuDefault: {"enable_lcu_default", []fieldSpec{specLcu, specNetwork}},
+	FwActionTypeEnableLcuNonDefault: {"enable_lcu_non_default", []fieldSpec{
+		specLcu, specNetwork, {"kernel_done_address", fieldU16}, {"kernel_done_count", fieldU32}}},
+	FwActionTypeDisableLcu: {"disable_lcu", []fieldSpec{specLcu}},
+	FwActionTypeActivateBoundaryInput: {"activate_boundary_input", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer, specCredit}},
+	FwActionTypeActivateBoundaryOutput: {"activate_boundary_output", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer}},
+	FwActionTypeActivateInterContextInput: {"activate_inter_context_input", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer, specCredit}},
+	FwActionTypeActivateInterContextOutput: {"activate_inter_context_output", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer}},
+	FwActionTypeActivateDdrBufferInput: {"activate_ddr_buffer_input", []fieldSpec{
+		specChannel, specStreamIndex, specStreamReg, specHostBuffer, specCredit,
+		{"connected_d2h_channel", fieldChannel}}},
+	FwActionTypeActivateDdrBufferOutput: {"activate_ddr_buffer_output", []fieldSpec{
+		specChannel, specStreamIndex, specStreamReg, specHostBuffer, {"buffered_rows_count", fieldU32}}},
+	FwActionTypeDeactivateVdmaChannel: {"deactivate_vdma_channel", []fieldSpec{specChannel}},
+	FwActionTypeChangeVdmaToStreamMapping: {"change_vdma_to_stream_mapping", []fieldSpec{
+		specChannel, specStreamIndex, {"is_dummy_stream", fieldBool}}},
+	FwActionTypeAddDdrPairInfo: {"add_ddr_pair_info", []fieldSpec{
+		{"h2d_channel", fieldChannel}, {"d2h_channel", fieldChannel},
+		{"descriptors_per_frame", fieldU32}, {"programmed_descriptors_count", fieldU16}}},
+	FwActionTypeDdrBufferingStart:          {"ddr_buffering_start", nil},
+	FwActionTypeLcuInterrupt:               {"lcu_interrupt", []fieldSpec{specLcu, specNetwork}},
+	FwActionTypeSequencerDoneInterrupt:     {"sequencer_done_interrupt", []fieldSpec{{"sequencer_index", fieldU8}}},
+	FwActionTypeInputChannelTransferDone:   {"input_channel_transfer_done", []fieldSpec{specChannel}},
+	FwActionTypeOutputChannelTransferDone:  {"output_channel_transfer_done", []fieldSpec{specChannel}},
+	FwActionTypeModuleConfigDoneInterrupt:  {"module_config_done_interrupt", []fieldSpec{{"module_index", fieldU8}}},
+	FwActionTypeApplicationChangeInterrupt: {"application_change_interrupt", nil},
+	FwActionTypeActivateCfgChannel: {"activate_cfg_channel", []fieldSpec{
+		specCfgIndex, specChannel, specHostBuffer}},
+	FwActionTypeDeactivateCfgChannel: {"deactivate_cfg_channel", []fieldSpec{specCfgIndex, specChannel}},
+	FwActionTypeRepeatedAction: {"repeated_action", []fieldSpec{
+		{"count", fieldU8}, {"last_executed", fieldU8}, {"sub_action_type", fieldU8}}},
+	FwActionTypeWaitForDmaIdle: {"wait_for_dma_idle", []fieldSpec{
+		specChannel, {"is_inter_context", fieldBool}, specStreamIndex}},
+	FwActionTypeWaitForNms: {"wait_for_nms", []fieldSpec{
+		{"nms_unit_index", fieldU8}, {"aggregator_index", fieldU8}}},
+	FwActionTypeFetchCcwBursts: {"fetch_ccw_bursts", []fieldSpec{{"ccw_bursts", fieldU16}, specCfgIndex}},
+	FwActionTypeValidateVdmaChannel: {"validate_vdma_channel", []fieldSpec{
+		specChannel, {"edge_layer_direction", fieldU8}, {"is_inter_context", fieldBool},
+		{"host_buffer_type", fieldU8}, specCredit}},
+	FwActionTypeBurstCreditsTaskStart: {"burst_credits_task_start", nil},
+	FwActionTypeBurstCreditsTaskReset: {"burst_credits_task_reset", nil},
+	FwActionTypeDdrBufferingReset:     {"ddr_buffering_reset", nil},
+	FwActionTypeOpenBoundaryInputChannel: {"open_boundary_input_channel", []fieldSpec{
+		specChannel, specHostBuffer}},
+	FwActionTypeOpenBoundaryOutputChannel: {"open_boundary_output_channel", []fieldSpec{
+		specChannel, specHostBuffer}},
+	FwActionTypeEnableNms: {"enable_nms", []fieldSpec{
+		{"nms_unit_index", fieldU8}, specNetwork, {"number_of_classes", fieldU16},
+		{"burst_size", fieldU16}, {"division_factor", fieldU8}}},
+	FwActionTypeWriteDataByType: {"write_data_by_type", []fieldSpec{
+		{"address", fieldHex32}, {"data_type", fieldU8}, {"data", fieldHex32},
+		{"shift", fieldU8}, {"mask", fieldHex32}, specNetwork}},
+	FwActionTypeSwitchLcuBatch: {"switch_lcu_batch", []fieldSpec{
+		specLcu, specNetwork, {"kernel_done_count", fieldU32}}},
+	FwActionTypeChangeBoundaryInputBatch: {"change_boundary_input_batch", []fieldSpec{specChannel}},
+	FwActionTypePauseVdmaChannel:         {"pause_vdma_channel", []fieldSpec{specChannel}},
+	FwActionTypeResumeVdmaChannel:        {"resume_vdma_channel", []fieldSpec{specChannel}},
+	FwActionTypeActivateCacheInput: {"activate_cache_input", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer, specCredit}},
+	FwActionTypeActivateCacheOutput: {"activate_cache_output", []fieldSpec{
+		specChannel, specStreamIndex, specNetwork, specStreamReg, specHostBuffer}},
+	FwActionTypeWaitForCacheUpdated: {"wait_for_cache_updated", nil},
+	FwActionTypeSleep:               {"sleep", []fieldSpec{{"sleep_time_us", fieldU32}}},
+	FwActionTypeHalt:                {"halt", nil},
+}
+
+// FwActionTypeName returns the name of a firmware action type
+func FwActionTypeName(actionType uint8) string {
+	if l, ok := actionLayouts[actionType]; ok {
+		return l.name
+	}
+	return fmt.Sprintf("action_type(%d)", actionType)
+}
+
+// Field is one decoded parameter of an action. Packed IDs and nested
+// structs are flattened into dotted names, such as channel.engine or
+// host_buffer.dma_address.
+type Field struct {
+	Name  string
+	Value uint64
+	Hex   bool // addresses and masks read better in hex
+}
+
+// String returns the field as name=value
+func (f Field) String() string {
+	if f.Hex {
+		return fmt.Sprintf("%s=%#x", f.Name, f.Value)
+	}
+	return fmt.Sprintf("%s=%d", f.Name, f.Value)
+}
+
+// DecodedAction is one action of a firmware action list
+type DecodedAction struct {
+	Offset    int // of the action header in the list
+	Type      uint8
+	Name      string
+	Timestamp uint32
+	Fields    []Field
+	Raw       []byte // header and payload
+
+	// Repeated holds the sub-actions of a repeated action. They share its
+	// header, so their Timestamp and Offset are those of the payload.
+	Repeated []DecodedAction
+}
+
+// Field returns the value of the named field
+func (a *DecodedAction) Field(name string) (uint64, bool) {
+	for _, f := range a.Fields {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return 0, false
+}
+
+// String returns the action on one line
+func (a *DecodedAction) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%04x ts=%08x %s", a.Offset, a.Timestamp, a.Name)
+	for _, f := range a.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.String())
+	}
+	for i := range a.Repeated {
+		fmt.Fprintf(&b, "\n    [%d] %s", i, a.Repeated[i].Name)
+		for _, f := range a.Repeated[i].Fields {
+			b.WriteByte(' ')
+			b.WriteString(f.String())
+		}
+	}
+	return b.String()
+}
+
+// actionHeaderSize is the packed size of ActionHeader
+const actionHeaderSize = 5
+
+// DecodeActionList parses a firmware action list, as the builders in this
+// package produce it, back into its actions. On a malformed list it returns
+// the actions decoded before the error.
+func DecodeActionList(data []byte) ([]DecodedAction, error) {
+	var actions []DecodedAction
+	for off := 0; off < len(data); {
+		if len(data)-off < actionHeaderSize {
+			return actions, fmt.Errorf("offset %#x: truncated action header", off)
+		}
+		actionType := data[off]
+		layout, ok := actionLayouts[actionType]
+		if !ok {
+			return actions, fmt.Errorf("offset %#x: unknown action type %d", off, actionType)
+		}
+
+		payload := data[off+actionHeaderSize:]
+		size := layout.size()
+		if len(payload) < size {
+			return actions, fmt.Errorf("offset %#x: %s truncated: %d of %d payload bytes",
+				off, layout.name, len(payload), size)
+		}
+
+		action := DecodedAction{
+			Offset:    off,
+			Type:      actionType,
+			Name:      layout.name,
+			Timestamp: binary.LittleEndian.Uint32(data[off+1 : off+5]),
+			Fields:    decodeFields(layout.fields, payload[:size]),
+		}
+
+		if actionType == FwActionTypeRepeatedAction {
+			sub, n, err := decodeRepeated(payload, size, off+actionHeaderSize, action.Timestamp)
+			if err != nil {
+				return actions, fmt.Errorf("offset %#x: %w", off, err)
+			}
+			action.Repeated = sub
+			size += n
+		}
+
+		action.Raw = data[off : off+actionHeaderSize+size]
+		actions = append(actions, action)
+		off += actionHeaderSize + size
+	}
+	return actions, nil
+}
+
+// decodeRepeated decodes the header-less sub-actions that follow a
+// repeated action, returning them and the bytes they take
+func decodeRepeated(payload []byte, headerSize, base int, timestamp uint32) ([]DecodedAction, int, error) {
+	count := int(payload[0])
+	subType := payload[2]
+	layout, ok := actionLayouts[subType]
+	if !ok || subType == FwActionTypeRepeatedAction {
+		return nil, 0, fmt.Errorf("repeated action of unsupported type %d", subType)
+	}
+
+	size := layout.size()
+	if len(payload)-headerSize < count*size {
+		return nil, 0, fmt.Errorf("repeated %s truncated: %d of %d bytes",
+			layout.name, len(payload)-headerSize, count*size)
+	}
+
+	sub := make([]DecodedAction, count)
+	for i := range sub {
+		off := headerSize + i*size
+		sub[i] = DecodedAction{
+			Offset:    base + off,
+			Type:      subType,
+			Name:      layout.name,
+			Timestamp: timestamp,
+			Fields:    decodeFields(layout.fields, payload[off:off+size]),
+			Raw:       payload[off : off+size],
+		}
+	}
+	return sub, count * size, nil
+}
+
+// decodeFields unpacks a payload by its field specs
+func decodeFields(specs []fieldSpec, payload []byte) []Field {
+	var fields []Field
+	for _, spec := range specs {
+		p := payload[:spec.kind.size()]
+		payload = payload[spec.kind.size():]
+
+		switch spec.kind {
+		case fieldU8:
+			fields = append(fields, Field{Name: spec.name, Value: uint64(p[0])})
+		case fieldBool:
+			fields = append(fields, Field{Name: spec.name, Value: uint64(p[0])})
+		case fieldU16:
+			fields = append(fields, Field{Name: spec.name, Value: uint64(binary.LittleEndian.Uint16(p))})
+		case fieldU32:
+			fields = append(fields, Field{Name: spec.name, Value: uint64(binary.LittleEndian.Uint32(p))})
+		case fieldHex32:
+			fields = append(fields, Field{Name: spec.name, Value: uint64(binary.LittleEndian.Uint32(p)), Hex: true})
+		case fieldHex64:
+			fields = append(fields, Field{Name: spec.name, Value: binary.LittleEndian.Uint64(p), Hex: true})
+		case fieldLcu:
+			// bits 0-3 = lcu_index, bits 4-6 = cluster_index
+			fields = append(fields,
+				Field{Name: spec.name + ".cluster", Value: uint64(p[0]>>4) & 0x07},
+				Field{Name: spec.name + ".index", Value: uint64(p[0]) & 0x0F})
+		case fieldChannel:
+			// bits 0-4 = vdma_channel_index, bits 5-6 = engine_index
+			fields = append(fields,
+				Field{Name: spec.name + ".engine", Value: uint64(p[0]>>5) & 0x03},
+				Field{Name: spec.name + ".index", Value: uint64(p[0]) & 0x1F})
+		case fieldStreamReg:
+			fields = append(fields, decodeFields([]fieldSpec{
+				{spec.name + ".core_bytes_per_buffer", fieldU16},
+				{spec.name + ".core_buffers_per_frame", fieldU16},
+				{spec.name + ".periph_bytes_per_buffer", fieldU16},
+				{spec.name + ".periph_buffers_per_frame", fieldU16},
+				{spec.name + ".feature_padding_payload", fieldU16},
+				{spec.name + ".buffer_padding_payload", fieldU32},
+				{spec.name + ".buffer_padding", fieldU16},
+				{spec.name + ".is_periph_calculated_in_hailort", fieldBool},
+				{spec.name + ".is_core_hw_padding_config_in_dfc", fieldBool},
+			}, p)...)
+		case fieldHostBuffer:
+			fields = append(fields, decodeFields([]fieldSpec{
+				{spec.name + ".type", fieldU8},
+				{spec.name + ".dma_address", fieldHex64},
+				{spec.name + ".desc_page_size", fieldU16},
+				{spec.name + ".total_desc_count", fieldU32},
+				{spec.name + ".bytes_in_pattern", fieldU32},
+			}, p)...)
+		}
+	}
+	return fields
+}
+
+// ActionDiff is a difference between two action lists at one position
+type ActionDiff struct {
+	Index int
+	A, B  *DecodedAction // nil where one list is shorter
+	What  []string       // differing fields, as "name: a != b"
+}
+
+// String describes the difference
+func (d *ActionDiff) String() string {
+	switch {
+	case d.A == nil:
+		return fmt.Sprintf("#%d only in b: %s", d.Index, d.B)
+	case d.B == nil:
+		return fmt.Sprintf("#%d only in a: %s", d.Index, d.A)
+	}
+	return fmt.Sprintf("#%d %s: %s", d.Index, d.A.Name, strings.Join(d.What, ", "))
+}
+
+// DiffActionLists compares two decoded action lists action by action, by
+// type, timestamp and every field
+func DiffActionLists(a, b []DecodedAction) []ActionDiff {
+	var diffs []ActionDiff
+	for i := 0; i < len(a) || i < len(b); i++ {
+		switch {
+		case i >= len(a):
+			diffs = append(diffs, ActionDiff{Index: i, B: &b[i]})
+			continue
+		case i >= len(b):
+			diffs = append(diffs, ActionDiff{Index: i, A: &a[i]})
+			continue
+		}
+
+		if what := diffAction(&a[i], &b[i]); len(what) > 0 {
+			diffs = append(diffs, ActionDiff{Index: i, A: &a[i], B: &b[i], What: what})
+		}
+	}
+	return diffs
+}
+
+// diffAction lists the differences between two actions
+func diffAction(a, b *DecodedAction) []string {
+	if a.Type != b.Type {
+		return []string{fmt.Sprintf("type: %s != %s", a.Name, b.Name)}
+	}
+
+	var what []string
+	if a.Timestamp != b.Timestamp {
+		what = append(what, fmt.Sprintf("timestamp: %#x != %#x", a.Timestamp, b.Timestamp))
+	}
+	for j := range a.Fields {
+		fa, fb := a.Fields[j], b.Fields[j]
+		if fa.Value != fb.Value {
+			what = append(what, fmt.Sprintf("%s: %s != %s",
+				fa.Name, strings.TrimPrefix(fa.String(), fa.Name+"="), strings.TrimPrefix(fb.String(), fb.Name+"=")))
+		}
+	}
+	if len(a.Repeated) != len(b.Repeated) {
+		what = append(what, fmt.Sprintf("repeated: %d != %d actions", len(a.Repeated), len(b.Repeated)))
+	} else {
+		for j := range a.Repeated {
+			for _, w := range diffAction(&a.Repeated[j], &b.Repeated[j]) {
+				what = append(what, fmt.Sprintf("[%d] %s", j, w))
+			}
+		}
+	}
+	return what
+}
@@ -0,0 +1,164 @@
+//go:build unit
+
+package control
+
+import (
+	"strings"
+	"testing"
+)
+
+// Every serializer must produce exactly what its layout decodes
+func TestDecodeLayoutsMatchSerializers(t *testing.T) {
+	reg := &StreamRegInfo{}
+	buf := &HostBufferInfo{}
+	seq := &SequencerConfig{}
+
+	actions := [][]byte{
+		SerializeEnableLcuDefault(1, 2, 0, 0),
+		SerializeEnableLcuNonDefault(1, 2, 0, 3, 4, 0),
+		SerializeDisableLcu(1, 2, 0),
+		SerializeTriggerSequencer(1, seq, 0),
+		SerializeFetchCcwBursts(1, 0, 0),
+		SerializeEnableNms(0, 0, 80, 8, 1, 0),
+		SerializeWriteDataByType(0, 0, 0, 0, 0, 0, 0),
+		SerializeSwitchLcuBatch(0, 0, 0, 2, 0),
+		SerializeSleep(10, 0),
+		SerializeHalt(0),
+		SerializeModuleConfigDoneInterrupt(0, 0),
+		SerializeSequencerDoneInterrupt(0, 0),
+		SerializeActivateBoundaryInput(0, 0, 0, reg, buf, 0, 0),
+		SerializeActivateBoundaryOutput(0, 0, 0, reg, buf, 0),
+		SerializeActivateInterContextInput(0, 0, 0, reg, buf, 0, 0),
+		SerializeActivateInterContextOutput(0, 0, 0, reg, buf, 0),
+		SerializeActivateDdrBufferInput(0, 0, reg, buf, 0, 0, 0),
+		SerializeActivateDdrBufferOutput(0, 0, reg, buf, 0, 0),
+		SerializeFetchCfgChannelDescriptors(0, 0, 0),
+		SerializeFetchDataFromVdmaChannel(0, 0, 0, 0, 0),
+		SerializeValidateVdmaChannel(0, 0, false, 0, 0, 0),
+		SerializeBurstCreditsTaskStart(0),
+		SerializeActivateCfgChannel(0, 0, buf, 0),
+		SerializeDeactivateCfgChannel(0, 0, 0),
+	}
+
+	for _, action := range actions {
+		decoded, err := DecodeActionList(action)
+		if err != nil || len(decoded) != 1 {
+			t.Errorf("%s: decoded %d actions, %v", FwActionTypeName(action[0]), len(decoded), err)
+			continue
+		}
+		if len(decoded[0].Raw) != len(action) {
+			t.Errorf("%s: layout is %d bytes, serializer writes %d",
+				decoded[0].Name, len(decoded[0].Raw), len(action))
+		}
+	}
+}
+
+func TestDecodeActionList(t *testing.T) {
+	builder := NewActionListBuilder()
+	builder.AddAction(SerializeEnableLcuNonDefault(3, 5, 1, 0x10, 7, 0xFFFFFFFF))
+	builder.AddAction(SerializeActivateBoundaryInput(PackedVdmaChannelId(1, 4), 2, 0,
+		&StreamRegInfo{CoreBytesPerBuffer: 640}, &HostBufferInfo{DmaAddress: 0xABCD000}, 4096, 0xFFFFFFFE))
+	builder.AddAction(SerializeHalt(0xFFFFFFFD))
+
+	actions, err := DecodeActionList(builder.Build())
+	if err != nil {
+		t.Fatalf("DecodeActionList failed: %v", err)
+	}
+	if len(actions) != 3 {
+		t.Fatalf("decoded %d actions, want 3", len(actions))
+	}
+
+	lcu := &actions[0]
+	if lcu.Name != "enable_lcu_non_default" || lcu.Timestamp != 0xFFFFFFFF || lcu.Offset != 0 {
+		t.Errorf("action 0 = %s", lcu)
+	}
+	for name, want := range map[string]uint64{
+		"lcu.cluster": 3, "lcu.index": 5, "network_index": 1, "kernel_done_address": 0x10, "kernel_done_count": 7,
+	} {
+		if got, ok := lcu.Field(name); !ok || got != want {
+			t.Errorf("%s = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+
+	input := &actions[1]
+	for name, want := range map[string]uint64{
+		"channel.engine": 1, "channel.index": 4, "stream_index": 2,
+		"stream_reg.core_bytes_per_buffer": 640, "host_buffer.dma_address": 0xABCD000, "initial_credit_size": 4096,
+	} {
+		if got, ok := input.Field(name); !ok || got != want {
+			t.Errorf("%s = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+	if !strings.Contains(input.String(), "host_buffer.dma_address=0xabcd000") {
+		t.Errorf("String() = %s", input)
+	}
+
+	if actions[2].Name != "halt" || actions[2].Offset != len(actions[0].Raw)+len(actions[1].Raw) {
+		t.Errorf("action 2 = %s", &actions[2])
+	}
+}
+
+func TestDecodeRepeatedAction(t *testing.T) {
+	list := PackActionHeader(FwActionTypeRepeatedAction, 0xFFFFFFFF)
+	list = append(list, 2, 0, FwActionTypeDisableLcu, PackedLcuId(0, 1), PackedLcuId(0, 2))
+	list = append(list, SerializeHalt(0xFFFFFFFE)...)
+
+	actions, err := DecodeActionList(list)
+	if err != nil {
+		t.Fatalf("DecodeActionList failed: %v", err)
+	}
+	if len(actions) != 2 || len(actions[0].Repeated) != 2 {
+		t.Fatalf("decoded %d actions", len(actions))
+	}
+	if got, _ := actions[0].Repeated[1].Field("lcu.index"); got != 2 {
+		t.Errorf("second repeated lcu.index = %d", got)
+	}
+}
+
+func TestDecodeActionListErrors(t *testing.T) {
+	halt := SerializeHalt(0)
+
+	tests := []struct {
+		name string
+		list []byte
+		want int // actions decoded before the error
+	}{
+		{"truncated header", append(append([]byte{}, halt...), 0, 1), 1},
+		{"truncated payload", SerializeSleep(1, 0)[:7], 0},
+		{"unknown type", append(append([]byte{}, halt...), 200, 0, 0, 0, 0), 1},
+	}
+	for _, tt := range tests {
+		actions, err := DecodeActionList(tt.list)
+		if err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+		if len(actions) != tt.want {
+			t.Errorf("%s: decoded %d actions before the error, want %d", tt.name, len(actions), tt.want)
+		}
+	}
+
+	if _, err := DecodeActionList(nil); err != nil {
+		t.Errorf("empty list: %v", err)
+	}
+}
+
+func TestDiffActionLists(t *testing.T) {
+	a, _ := DecodeActionList(append(SerializeDisableLcu(0, 1, 0xFFFFFFFF), SerializeSleep(10, 0xFFFFFFFE)...))
+	b, _ := DecodeActionList(append(append(SerializeDisableLcu(0, 1, 0xFFFFFFFF), SerializeSleep(20, 0xFFFFFFFE)...),
+		SerializeHalt(0xFFFFFFFD)...))
+
+	diffs := DiffActionLists(a, b)
+	if len(diffs) != 2 {
+		t.Fatalf("diffs = %v, want 2", diffs)
+	}
+	if diffs[0].Index != 1 || len(diffs[0].What) != 1 || diffs[0].What[0] != "sleep_time_us: 10 != 20" {
+		t.Errorf("diff 0 = %s", &diffs[0])
+	}
+	if diffs[1].A != nil || diffs[1].B.Name != "halt" {
+		t.Errorf("diff 1 = %s", &diffs[1])
+	}
+
+	if d := DiffActionLists(a, a); len(d) != 0 {
+		t.Errorf("list differs from itself: %v", d)
+	}
+}
@@ -1,12 +1,12 @@
 package control
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/binary"
 	"fmt"
 	"log"
-
-	"github.com/anthropics/purple-hailo/pkg/driver"
+	"strings"
 )
 
 // computeRequestMD5 calculates the MD5 hash of the request buffer.
@@ -23,7 +23,12 @@ func computeRequestMD5(request []byte) [16]byte {
 // - batch_size is an array of MAX_NETWORKS_PER_NETWORK_GROUP (8) uint16 values
 // - No config_channels_count or config_channel_info fields
 // If using newer firmware (v4.21.0+), the struct format would need to be updated.
-func SetNetworkGroupHeader(device *driver.DeviceFile, sequence uint32, appHeader *ApplicationHeader) error {
+func SetNetworkGroupHeader(device Transport, sequence uint32, appHeader *ApplicationHeader) error {
+	return SetNetworkGroupHeaderContext(context.Background(), device, sequence, appHeader)
+}
+
+// SetNetworkGroupHeaderContext is like SetNetworkGroupHeader but takes a context
+func SetNetworkGroupHeaderContext(ctx context.Context, device Transport, sequence uint32, appHeader *ApplicationHeader) error {
 	request := PackSetNetworkGroupHeaderRequest(sequence, appHeader)
 
 	reqLen := len(request)
@@ -37,7 +42,7 @@ func SetNetworkGroupHeader(device *driver.DeviceFile, sequence uint32, appHeader
 
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(ctx, request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		log.Printf("[control] SetNetworkGroupHeader: FwControl failed: %v", err)
 		return fmt.Errorf("set_network_group_header FwControl failed: %w", err)
@@ -79,7 +84,13 @@ func CreateDefaultApplicationHeader(dynamicContextsCount uint16) *ApplicationHea
 // EnableCoreOp enables the context switch state machine for a network group.
 // This is the critical function that tells the firmware to start processing.
 // Maps to Control::enable_core_op() in the official HailoRT.
-func EnableCoreOp(device *driver.DeviceFile, sequence uint32, networkGroupIndex uint8,
+func EnableCoreOp(device Transport, sequence uint32, networkGroupIndex uint8,
+	dynamicBatchSize, batchCount uint16) error {
+	return EnableCoreOpContext(context.Background(), device, sequence, networkGroupIndex, dynamicBatchSize, batchCount)
+}
+
+// EnableCoreOpContext is like EnableCoreOp but takes a context
+func EnableCoreOpContext(ctx context.Context, device Transport, sequence uint32, networkGroupIndex uint8,
 	dynamicBatchSize, batchCount uint16) error {
 
 	request := PackChangeContextSwitchStatusRequest(
@@ -96,7 +107,7 @@ func EnableCoreOp(device *driver.DeviceFile, sequence uint32, networkGroupIndex
 	// Calculate MD5 of request for firmware integrity check
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(ctx, request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		log.Printf("[control] EnableCoreOp: FwControl failed: %v", err)
 		return fmt.Errorf("enable_core_op FwControl failed: %w", err)
@@ -117,7 +128,12 @@ func EnableCoreOp(device *driver.DeviceFile, sequence uint32, networkGroupIndex
 // ResetContextSwitchStateMachine resets the context switch state machine.
 // This should be called when deactivating a network group.
 // Maps to Control::reset_context_switch_state_machine() in the official HailoRT.
-func ResetContextSwitchStateMachine(device *driver.DeviceFile, sequence uint32) error {
+func ResetContextSwitchStateMachine(device Transport, sequence uint32) error {
+	return ResetContextSwitchStateMachineContext(context.Background(), device, sequence)
+}
+
+// ResetContextSwitchStateMachineContext is like ResetContextSwitchStateMachine but takes a context
+func ResetContextSwitchStateMachineContext(ctx context.Context, device Transport, sequence uint32) error {
 	request := PackChangeContextSwitchStatusRequest(
 		sequence,
 		ContextSwitchStatusReset,
@@ -128,7 +144,7 @@ func ResetContextSwitchStateMachine(device *driver.DeviceFile, sequence uint32)
 
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(ctx, request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		return fmt.Errorf("reset_context_switch_state_machine FwControl failed: %w", err)
 	}
@@ -150,7 +166,7 @@ const (
 
 // Reset sends a reset command to the device.
 // resetType: 0=Chip, 1=NNCore, 2=Soft, 3=ForcedSoft
-func Reset(device *driver.DeviceFile, sequence uint32, resetType uint8) error {
+func Reset(device Transport, sequence uint32, resetType uint8) error {
 	header := PackRequestHeader(sequence, OpcodeReset)
 
 	// Parameter 1: reset_type
@@ -171,7 +187,7 @@ func Reset(device *driver.DeviceFile, sequence uint32, resetType uint8) error {
 
 	// Reset command goes to APP CPU
 	// Note: The firmware may not respond after reset, so we accept timeout
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdAppCpu)
+	response, _, err := device.FwControlContext(context.Background(), request, reqMD5, DefaultTimeoutMs, CpuIdAppCpu)
 	if err != nil {
 		// Timeout is expected for reset command
 		log.Printf("[control] Reset: FwControl returned: %v (may be expected for reset)", err)
@@ -182,7 +198,7 @@ func Reset(device *driver.DeviceFile, sequence uint32, resetType uint8) error {
 		// Don't validate opcode for reset - response might have different opcode
 		header, _ := ParseResponseHeader(response)
 		if header != nil && header.MajorStatus != 0 {
-			return fmt.Errorf("reset failed: status=%d", header.MajorStatus)
+			return fmt.Errorf("reset failed: %w", NewFirmwareError(header))
 		}
 	}
 
@@ -191,19 +207,24 @@ func Reset(device *driver.DeviceFile, sequence uint32, resetType uint8) error {
 }
 
 // SoftReset sends a soft reset command to the device.
-func SoftReset(device *driver.DeviceFile, sequence uint32) error {
+func SoftReset(device Transport, sequence uint32) error {
 	return Reset(device, sequence, ResetTypeSoft)
 }
 
 // ResetNNCore sends a NN Core reset command to the device.
 // This specifically resets the neural network processing core.
-func ResetNNCore(device *driver.DeviceFile, sequence uint32) error {
+func ResetNNCore(device Transport, sequence uint32) error {
 	return Reset(device, sequence, ResetTypeNNCore)
 }
 
 // ClearConfiguredApps clears all configured applications from the device.
 // This can be called before configuring a new network group.
-func ClearConfiguredApps(device *driver.DeviceFile, sequence uint32) error {
+func ClearConfiguredApps(device Transport, sequence uint32) error {
+	return ClearConfiguredAppsContext(context.Background(), device, sequence)
+}
+
+// ClearConfiguredAppsContext is like ClearConfiguredApps but takes a context
+func ClearConfiguredAppsContext(ctx context.Context, device Transport, sequence uint32) error {
 	header := PackRequestHeader(sequence, OpcodeClearConfiguredApps)
 
 	// No parameters for this command
@@ -214,7 +235,7 @@ func ClearConfiguredApps(device *driver.DeviceFile, sequence uint32) error {
 
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(ctx, request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		return fmt.Errorf("clear_configured_apps FwControl failed: %w", err)
 	}
@@ -226,9 +247,35 @@ func ClearConfiguredApps(device *driver.DeviceFile, sequence uint32) error {
 	return nil
 }
 
+// IdentifyResponse holds the fields of CONTROL_PROTOCOL__identify_response_t
+type IdentifyResponse struct {
+	ProtocolVersion    uint32
+	FirmwareMajor      uint32
+	FirmwareMinor      uint32
+	FirmwareRevision   uint32
+	LoggerVersion      uint32
+	BoardName          string
+	DeviceArchitecture uint32
+	SerialNumber       string
+	PartNumber         string
+	ProductName        string
+}
+
+// FirmwareVersion returns the firmware version as major.minor.revision
+func (r *IdentifyResponse) FirmwareVersion() string {
+	return fmt.Sprintf("%d.%d.%d", r.FirmwareMajor, r.FirmwareMinor, r.FirmwareRevision)
+}
+
 // Identify sends the basic identify command to APP CPU to get firmware version.
 // This is the simplest firmware command and should always work if the device is functioning.
-func Identify(device *driver.DeviceFile, sequence uint32) error {
+func Identify(device Transport, sequence uint32) error {
+	_, err := IdentifyDevice(device, sequence)
+	return err
+}
+
+// IdentifyDevice sends the identify command and parses the firmware
+// version, board name and serial number from the response
+func IdentifyDevice(device Transport, sequence uint32) (*IdentifyResponse, error) {
 	header := PackRequestHeader(sequence, OpcodeIdentify)
 
 	// No parameters
@@ -241,27 +288,74 @@ func Identify(device *driver.DeviceFile, sequence uint32) error {
 	reqMD5 := computeRequestMD5(request)
 
 	// Use APP CPU (CPU0) for basic identify
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdAppCpu)
+	response, _, err := device.FwControlContext(context.Background(), request, reqMD5, DefaultTimeoutMs, CpuIdAppCpu)
 	if err != nil {
 		log.Printf("[control] Identify: FwControl failed: %v", err)
-		return fmt.Errorf("identify FwControl failed: %w", err)
+		return nil, fmt.Errorf("identify FwControl failed: %w", err)
 	}
 
 	log.Printf("[control] Identify: response len=%d", len(response))
 
 	if err := ValidateResponse(response, sequence, OpcodeIdentify); err != nil {
 		log.Printf("[control] Identify: validation failed: %v", err)
-		return fmt.Errorf("identify validation failed: %w", err)
+		return nil, fmt.Errorf("identify validation failed: %w", err)
 	}
 
-	log.Printf("[control] Identify: success")
-	return nil
+	identity, err := ParseIdentifyResponse(response)
+	if err != nil {
+		return nil, fmt.Errorf("identify parse failed: %w", err)
+	}
+
+	log.Printf("[control] Identify: success (firmware %s)", identity.FirmwareVersion())
+	return identity, nil
+}
+
+// ParseIdentifyResponse parses the parameters of an identify response
+func ParseIdentifyResponse(response []byte) (*IdentifyResponse, error) {
+	params, err := ParseResponseParameters(response)
+	if err != nil {
+		return nil, err
+	}
+	if len(params) < 6 {
+		return nil, fmt.Errorf("identify response has %d parameters, need at least 6", len(params))
+	}
+
+	u32 := func(p []byte, index int) uint32 {
+		if len(p) < (index+1)*4 {
+			return 0
+		}
+		return binary.BigEndian.Uint32(p[index*4:])
+	}
+	str := func(i int) string {
+		if i >= len(params) {
+			return ""
+		}
+		return strings.TrimRight(string(params[i]), "\x00")
+	}
+
+	return &IdentifyResponse{
+		ProtocolVersion:    u32(params[0], 0),
+		FirmwareMajor:      u32(params[1], 0),
+		FirmwareMinor:      u32(params[1], 1),
+		FirmwareRevision:   u32(params[1], 2),
+		LoggerVersion:      u32(params[2], 0),
+		BoardName:          str(3),
+		DeviceArchitecture: u32(params[4], 0),
+		SerialNumber:       str(5),
+		PartNumber:         str(6),
+		ProductName:        str(7),
+	}, nil
 }
 
 // SetContextInfo sends a context info chunk to configure a context.
 // This is called after SetNetworkGroupHeader and before EnableCoreOp.
 // Multiple chunks may be needed for large contexts.
-func SetContextInfo(device *driver.DeviceFile, sequence uint32, chunk *ContextInfoChunk) error {
+func SetContextInfo(device Transport, sequence uint32, chunk *ContextInfoChunk) error {
+	return SetContextInfoContext(context.Background(), device, sequence, chunk)
+}
+
+// SetContextInfoContext is like SetContextInfo but takes a context
+func SetContextInfoContext(ctx context.Context, device Transport, sequence uint32, chunk *ContextInfoChunk) error {
 	request := PackSetContextInfoRequest(sequence, chunk)
 
 	log.Printf("[control] SetContextInfo: seq=%d, type=%d, isFirst=%v, isLast=%v, dataLen=%d",
@@ -269,7 +363,7 @@ func SetContextInfo(device *driver.DeviceFile, sequence uint32, chunk *ContextIn
 
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(ctx, request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		log.Printf("[control] SetContextInfo: FwControl failed: %v", err)
 		return fmt.Errorf("set_context_info FwControl failed: %w", err)
@@ -288,7 +382,12 @@ func SetContextInfo(device *driver.DeviceFile, sequence uint32, chunk *ContextIn
 
 // SendContextInfoChunks sends multiple context info chunks for a context type.
 // This handles splitting large contexts into multiple control messages.
-func SendContextInfoChunks(device *driver.DeviceFile, startSequence *uint32, contextType uint8, data []byte) error {
+func SendContextInfoChunks(device Transport, startSequence *uint32, contextType uint8, data []byte) error {
+	return SendContextInfoChunksContext(context.Background(), device, startSequence, contextType, data)
+}
+
+// SendContextInfoChunksContext is like SendContextInfoChunks but takes a context
+func SendContextInfoChunksContext(ctx context.Context, device Transport, startSequence *uint32, contextType uint8, data []byte) error {
 	if len(data) == 0 {
 		// Send empty context (required for some context types)
 		*startSequence++
@@ -298,7 +397,7 @@ func SendContextInfoChunks(device *driver.DeviceFile, startSequence *uint32, con
 			ContextType:  contextType,
 			Data:         []byte{},
 		}
-		return SetContextInfo(device, *startSequence, chunk)
+		return SetContextInfoContext(ctx, device, *startSequence, chunk)
 	}
 
 	// Split into chunks if needed
@@ -318,7 +417,7 @@ func SendContextInfoChunks(device *driver.DeviceFile, startSequence *uint32, con
 			Data:         data[offset : offset+chunkSize],
 		}
 
-		if err := SetContextInfo(device, *startSequence, chunk); err != nil {
+		if err := SetContextInfoContext(ctx, device, *startSequence, chunk); err != nil {
 			return err
 		}
 
@@ -330,7 +429,7 @@ func SendContextInfoChunks(device *driver.DeviceFile, startSequence *uint32, con
 
 // IdentifyCore sends the core identify command to get firmware version.
 // This is useful for verifying firmware communication works.
-func IdentifyCore(device *driver.DeviceFile, sequence uint32) error {
+func IdentifyCore(device Transport, sequence uint32) error {
 	header := PackRequestHeader(sequence, OpcodeCoreIdentify)
 
 	// No parameters
@@ -342,7 +441,7 @@ func IdentifyCore(device *driver.DeviceFile, sequence uint32) error {
 
 	reqMD5 := computeRequestMD5(request)
 
-	response, _, err := device.FwControl(request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
+	response, _, err := device.FwControlContext(context.Background(), request, reqMD5, DefaultTimeoutMs, CpuIdCoreCpu)
 	if err != nil {
 		log.Printf("[control] IdentifyCore: FwControl failed: %v", err)
 		return fmt.Errorf("identify_core FwControl failed: %w", err)
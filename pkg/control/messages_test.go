@@ -0,0 +1,74 @@
+//go:build unit
+
+package control
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseIdentifyResponse(t *testing.T) {
+	response := make([]byte, ResponseHeaderSize)
+	param := func(data []byte) {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(data)))
+		response = append(response, length...)
+		response = append(response, data...)
+	}
+	u32s := func(values ...uint32) []byte {
+		data := make([]byte, 4*len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint32(data[i*4:], v)
+		}
+		return data
+	}
+
+	response = append(response, u32s(8)...) // parameter count
+	param(u32s(2))                          // protocol version
+	param(u32s(4, 20, 0))                   // firmware version
+	param(u32s(1))                          // logger version
+	param([]byte("Hailo-8\x00"))
+	param(u32s(3))
+	param([]byte("HLLWM2B233500123"))
+	param([]byte("HM218B1C2FA"))
+	param([]byte("HAILO-8 AI ACC M.2 M KEY"))
+
+	identity, err := ParseIdentifyResponse(response)
+	if err != nil {
+		t.Fatalf("ParseIdentifyResponse failed: %v", err)
+	}
+	if identity.FirmwareVersion() != "4.20.0" {
+		t.Errorf("FirmwareVersion() = %s, want 4.20.0", identity.FirmwareVersion())
+	}
+	if identity.BoardName != "Hailo-8" || identity.SerialNumber != "HLLWM2B233500123" {
+		t.Errorf("identity = %+v", identity)
+	}
+	if identity.DeviceArchitecture != 3 || identity.ProductName != "HAILO-8 AI ACC M.2 M KEY" {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	if _, err := ParseIdentifyResponse(response[:len(response)-3]); err == nil {
+		t.Error("expected error for truncated parameter")
+	}
+}
+
+func TestParseResponseParametersBogusCount(t *testing.T) {
+	response := make([]byte, ResponseHeaderSize+12)
+	binary.BigEndian.PutUint32(response[ResponseHeaderSize:], 0xffffffff)
+	binary.BigEndian.PutUint32(response[ResponseHeaderSize+4:], 4)
+
+	if _, err := ParseResponseParameters(response); err == nil {
+		t.Error("expected error for a count larger than the response")
+	}
+
+	// Two empty parameters fit in the same 8 bytes
+	binary.BigEndian.PutUint32(response[ResponseHeaderSize:], 2)
+	binary.BigEndian.PutUint32(response[ResponseHeaderSize+4:], 0)
+	params, err := ParseResponseParameters(response)
+	if err != nil {
+		t.Fatalf("ParseResponseParameters() error = %v", err)
+	}
+	if len(params) != 2 {
+		t.Errorf("got %d parameters, want 2", len(params))
+	}
+}
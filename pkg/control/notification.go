@@ -0,0 +1,109 @@
+package control
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// D2H event IDs from d2h_events.h (D2H_EVENT_ID_t)
+const (
+	EventEthRxError                uint32 = 0  // ETHERNET_SERVICE_RX_ERROR_EVENT_ID
+	EventTemperatureAlarm          uint32 = 1  // HEALTH_MONITOR_TEMPERATURE_ALARM_D2H_EVENT_ID
+	EventClosedStreams             uint32 = 2  // HEALTH_MONITOR_CLOSED_STREAMS_D2H_EVENT_ID
+	EventOvercurrentAlarm          uint32 = 3  // HEALTH_MONITOR_OVERCURRENT_PROTECTION_ALERT_EVENT_ID
+	EventLcuEccCorrectable         uint32 = 4  // HEALTH_MONITOR_LCU_ECC_CORRECTABLE_EVENT_ID
+	EventLcuEccUncorrectable       uint32 = 5  // HEALTH_MONITOR_LCU_ECC_UNCORRECTABLE_EVENT_ID
+	EventCpuEccError               uint32 = 6  // HEALTH_MONITOR_CPU_ECC_ERROR_EVENT_ID
+	EventCpuEccFatal               uint32 = 7  // HEALTH_MONITOR_CPU_ECC_FATAL_EVENT_ID
+	EventDebug                     uint32 = 8  // D2H_HOST_INFO_EVENT_ID (debug message)
+	EventContextSwitchBreakpoint   uint32 = 9  // CONTEXT_SWITCH_BREAKPOINT_REACHED
+	EventClockChanged              uint32 = 10 // HEALTH_MONITOR_CLOCK_CHANGED_EVENT_ID
+	EventHwInferDone               uint32 = 11 // HW_INFER_MANAGER_INFER_DONE
+	EventContextSwitchRunTimeError uint32 = 12 // CONTEXT_SWITCH_RUN_TIME_ERROR
+)
+
+// NotificationHeaderSize is the size of D2H_EVENT_HEADER_t
+const NotificationHeaderSize = 28
+
+// Notification is a device-to-host event read with
+// driver.DeviceFile.ReadNotification. Unlike control responses, the firmware
+// sends the event header in its native little-endian order.
+type Notification struct {
+	Version        uint32
+	Sequence       uint32
+	Priority       uint32
+	ModuleID       uint32
+	EventID        uint32
+	ParameterCount uint32
+	Payload        []byte
+}
+
+// ParseNotification parses a raw D2H notification
+func ParseNotification(data []byte) (*Notification, error) {
+	if len(data) < NotificationHeaderSize {
+		return nil, fmt.Errorf("notification too short: %d bytes, need %d", len(data), NotificationHeaderSize)
+	}
+
+	payloadLen := binary.LittleEndian.Uint32(data[24:28])
+	if uint64(payloadLen) > uint64(len(data)-NotificationHeaderSize) {
+		return nil, fmt.Errorf("notification payload length %d exceeds %d available bytes",
+			payloadLen, len(data)-NotificationHeaderSize)
+	}
+
+	return &Notification{
+		Version:        binary.LittleEndian.Uint32(data[0:4]),
+		Sequence:       binary.LittleEndian.Uint32(data[4:8]),
+		Priority:       binary.LittleEndian.Uint32(data[8:12]),
+		ModuleID:       binary.LittleEndian.Uint32(data[12:16]),
+		EventID:        binary.LittleEndian.Uint32(data[16:20]),
+		ParameterCount: binary.LittleEndian.Uint32(data[20:24]),
+		Payload:        data[NotificationHeaderSize : NotificationHeaderSize+payloadLen],
+	}, nil
+}
+
+// IsHealthEvent reports whether the event comes from the firmware health
+// monitor or signals a context switch failure
+func (n *Notification) IsHealthEvent() bool {
+	switch n.EventID {
+	case EventTemperatureAlarm, EventClosedStreams, EventOvercurrentAlarm,
+		EventLcuEccCorrectable, EventLcuEccUncorrectable,
+		EventCpuEccError, EventCpuEccFatal, EventContextSwitchRunTimeError:
+		return true
+	}
+	return false
+}
+
+// IsFatal reports whether the device cannot keep running inference after
+// the event without a reset
+func (n *Notification) IsFatal() bool {
+	switch n.EventID {
+	case EventLcuEccUncorrectable, EventCpuEccFatal, EventContextSwitchRunTimeError:
+		return true
+	}
+	return false
+}
+
+// String returns a short description of the event
+func (n *Notification) String() string {
+	name, ok := eventNames[n.EventID]
+	if !ok {
+		name = fmt.Sprintf("event %d", n.EventID)
+	}
+	return fmt.Sprintf("%s (module %d, %d bytes)", name, n.ModuleID, len(n.Payload))
+}
+
+var eventNames = map[uint32]string{
+	EventEthRxError:                "ethernet rx error",
+	EventTemperatureAlarm:          "temperature alarm",
+	EventClosedStreams:             "closed streams",
+	EventOvercurrentAlarm:          "overcurrent alarm",
+	EventLcuEccCorrectable:         "LCU ECC correctable error",
+	EventLcuEccUncorrectable:       "LCU ECC uncorrectable error",
+	EventCpuEccError:               "CPU ECC error",
+	EventCpuEccFatal:               "CPU ECC fatal error",
+	EventDebug:                     "debug",
+	EventContextSwitchBreakpoint:   "context switch breakpoint reached",
+	EventClockChanged:              "clock changed",
+	EventHwInferDone:               "hw infer done",
+	EventContextSwitchRunTimeError: "context switch run time error",
+}
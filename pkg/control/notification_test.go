@@ -0,0 +1,68 @@
+//go:build unit
+
+package control
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// packNotification builds a raw D2H notification
+func packNotification(eventID uint32, payload []byte) []byte {
+	data := make([]byte, NotificationHeaderSize, NotificationHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(data[0:4], 1)         // version
+	binary.LittleEndian.PutUint32(data[4:8], 7)         // sequence
+	binary.LittleEndian.PutUint32(data[12:16], 3)       // module id
+	binary.LittleEndian.PutUint32(data[16:20], eventID) // event id
+	binary.LittleEndian.PutUint32(data[20:24], 1)       // parameter count
+	binary.LittleEndian.PutUint32(data[24:28], uint32(len(payload)))
+	return append(data, payload...)
+}
+
+func TestParseNotification(t *testing.T) {
+	payload := []byte{0xaa, 0xbb, 0xcc, 0xdd}
+	n, err := ParseNotification(packNotification(EventTemperatureAlarm, payload))
+	if err != nil {
+		t.Fatalf("ParseNotification failed: %v", err)
+	}
+
+	if n.Version != 1 || n.Sequence != 7 || n.ModuleID != 3 || n.ParameterCount != 1 {
+		t.Errorf("header = %+v", n)
+	}
+	if n.EventID != EventTemperatureAlarm {
+		t.Errorf("EventID = %d, want %d", n.EventID, EventTemperatureAlarm)
+	}
+	if !bytes.Equal(n.Payload, payload) {
+		t.Errorf("Payload = %x, want %x", n.Payload, payload)
+	}
+	if !n.IsHealthEvent() || n.IsFatal() {
+		t.Errorf("temperature alarm: health=%v fatal=%v", n.IsHealthEvent(), n.IsFatal())
+	}
+}
+
+func TestParseNotificationErrors(t *testing.T) {
+	if _, err := ParseNotification(make([]byte, NotificationHeaderSize-1)); err == nil {
+		t.Error("expected error for short header")
+	}
+
+	data := packNotification(EventDebug, []byte{1, 2})
+	if _, err := ParseNotification(data[:len(data)-1]); err == nil {
+		t.Error("expected error for truncated payload")
+	}
+}
+
+func TestNotificationFatal(t *testing.T) {
+	fatal := []uint32{EventLcuEccUncorrectable, EventCpuEccFatal, EventContextSwitchRunTimeError}
+	for _, id := range fatal {
+		n := &Notification{EventID: id}
+		if !n.IsFatal() || !n.IsHealthEvent() {
+			t.Errorf("%s: expected fatal health event", n)
+		}
+	}
+
+	n := &Notification{EventID: EventHwInferDone}
+	if n.IsFatal() || n.IsHealthEvent() {
+		t.Errorf("%s: expected ordinary event", n)
+	}
+}
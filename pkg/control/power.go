@@ -0,0 +1,234 @@
+package control
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// PowerDvm selects the power sensor to measure, from hailo_dvm_options_t
+type PowerDvm uint32
+
+const (
+	PowerDvmVddCore PowerDvm = 0          // HAILO_DVM_OPTIONS_VDD_CORE
+	PowerDvmVddIo   PowerDvm = 1          // HAILO_DVM_OPTIONS_VDD_IO
+	PowerDvmAuto    PowerDvm = 0x7fffffff // HAILO_DVM_OPTIONS_AUTO: the sensor the board has
+)
+
+// PowerMeasurementType is what a power sensor reports, from
+// hailo_power_measurement_types_t
+type PowerMeasurementType uint32
+
+const (
+	PowerTypeShuntVoltage PowerMeasurementType = 0          // HAILO_POWER_MEASUREMENT_TYPES__SHUNT_VOLTAGE, mV
+	PowerTypeBusVoltage   PowerMeasurementType = 1          // HAILO_POWER_MEASUREMENT_TYPES__BUS_VOLTAGE, mV
+	PowerTypePower        PowerMeasurementType = 2          // HAILO_POWER_MEASUREMENT_TYPES__POWER, W
+	PowerTypeCurrent      PowerMeasurementType = 3          // HAILO_POWER_MEASUREMENT_TYPES__CURRENT, mA
+	PowerTypeAuto         PowerMeasurementType = 0x7fffffff // HAILO_POWER_MEASUREMENT_TYPES__AUTO
+)
+
+// PowerAveraging is how many samples the sensor averages per value, from
+// hailo_averaging_factor_t
+type PowerAveraging uint16
+
+const (
+	PowerAveraging1   PowerAveraging = 0 // HAILO_AVERAGE_FACTOR_1
+	PowerAveraging256 PowerAveraging = 5 // HAILO_AVERAGE_FACTOR_256
+)
+
+// PowerSamplingPeriod is the sensor conversion time, from
+// hailo_sampling_period_t
+type PowerSamplingPeriod uint16
+
+const (
+	PowerSampling140us  PowerSamplingPeriod = 0 // HAILO_SAMPLING_PERIOD_140US
+	PowerSampling1100us PowerSamplingPeriod = 4 // HAILO_SAMPLING_PERIOD_1100US
+)
+
+// PowerMeasurement is the result of a continuous power measurement, from
+// CONTROL_PROTOCOL__get_power_measurement_response_t
+type PowerMeasurement struct {
+	Samples       uint32
+	Min           float32
+	Max           float32
+	Average       float32
+	AverageTimeMs float32 // time each averaged value covers
+}
+
+// Temperature is the chip temperature from its two sensors, from
+// CONTROL_PROTOCOL__temperature_info_t
+type Temperature struct {
+	TS0     float32 // degrees Celsius
+	TS1     float32
+	Samples uint16
+}
+
+// packRequest creates a request with the given parameters
+func packRequest(sequence, opcode uint32, params ...[]byte) []byte {
+	request := PackRequestHeader(sequence, opcode)
+	request = binary.BigEndian.AppendUint32(request, uint32(len(params)))
+	for _, p := range params {
+		request = append(request, packParameter(p)...)
+	}
+	return request
+}
+
+// u32Param packs a big-endian uint32 parameter
+func u32Param(v uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+// u16Param packs a big-endian uint16 parameter
+func u16Param(v uint16) []byte {
+	return binary.BigEndian.AppendUint16(nil, v)
+}
+
+// sendRequest sends an application CPU request and returns the response
+// parameters
+func sendRequest(ctx context.Context, device Transport, sequence, opcode uint32, params ...[]byte) ([][]byte, error) {
+	request := packRequest(sequence, opcode, params...)
+	response, _, err := device.FwControlContext(ctx, request, computeRequestMD5(request), DefaultTimeoutMs, CpuIdAppCpu)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", OpcodeName(opcode), err)
+	}
+	if err := ValidateResponse(response, sequence, opcode); err != nil {
+		return nil, err
+	}
+	if len(response) == ResponseHeaderSize {
+		return nil, nil
+	}
+	return ParseResponseParameters(response)
+}
+
+// float32Param reads a float parameter. The firmware copies floats into
+// responses as they are in its memory, little-endian, unlike the integer
+// fields.
+func float32Param(p []byte) float32 {
+	return math.Float32frombits(binary.LittleEndian.Uint32(p))
+}
+
+// SetPowerMeasurementContext selects the sensor and value a continuous
+// measurement buffer records
+func SetPowerMeasurementContext(ctx context.Context, device Transport, sequence, index uint32, dvm PowerDvm, measurementType PowerMeasurementType) error {
+	_, err := sendRequest(ctx, device, sequence, OpcodeSetPowerMeasurement,
+		u32Param(index), u32Param(uint32(dvm)), u32Param(uint32(measurementType)))
+	return err
+}
+
+// StartPowerMeasurementContext starts continuous power measurement after
+// delayMs
+func StartPowerMeasurementContext(ctx context.Context, device Transport, sequence, delayMs uint32, averaging PowerAveraging, period PowerSamplingPeriod) error {
+	_, err := sendRequest(ctx, device, sequence, OpcodeStartPowerMeasurement,
+		u32Param(delayMs), u16Param(uint16(averaging)), u16Param(uint16(period)))
+	return err
+}
+
+// GetPowerMeasurementContext reads a measurement buffer, clearing it if
+// asked
+func GetPowerMeasurementContext(ctx context.Context, device Transport, sequence, index uint32, clear bool) (*PowerMeasurement, error) {
+	params, err := sendRequest(ctx, device, sequence, OpcodeGetPowerMeasurement,
+		u32Param(index), []byte{boolByte(clear)})
+	if err != nil {
+		return nil, err
+	}
+	return ParsePowerMeasurement(params)
+}
+
+// ParsePowerMeasurement reads the parameters of a get power measurement
+// response
+func ParsePowerMeasurement(params [][]byte) (*PowerMeasurement, error) {
+	if len(params) < 5 || len(params[0]) < 4 {
+		return nil, fmt.Errorf("power measurement response has %d parameters, need 5", len(params))
+	}
+	for _, p := range params[1:5] {
+		if len(p) < 4 {
+			return nil, fmt.Errorf("power measurement value is %d bytes, need 4", len(p))
+		}
+	}
+	return &PowerMeasurement{
+		Samples:       binary.BigEndian.Uint32(params[0]),
+		Min:           float32Param(params[1]),
+		Max:           float32Param(params[2]),
+		Average:       float32Param(params[3]),
+		AverageTimeMs: float32Param(params[4]),
+	}, nil
+}
+
+// StopPowerMeasurementContext stops continuous power measurement
+func StopPowerMeasurementContext(ctx context.Context, device Transport, sequence uint32) error {
+	_, err := sendRequest(ctx, device, sequence, OpcodeStopPowerMeasurement)
+	return err
+}
+
+// GetChipTemperatureContext reads the chip temperature sensors
+func GetChipTemperatureContext(ctx context.Context, device Transport, sequence uint32) (*Temperature, error) {
+	params, err := sendRequest(ctx, device, sequence, OpcodeGetChipTemperature)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemperature(params)
+}
+
+// ParseTemperature reads the parameters of a get chip temperature response
+func ParseTemperature(params [][]byte) (*Temperature, error) {
+	if len(params) < 1 || len(params[0]) < 10 {
+		return nil, fmt.Errorf("chip temperature response has no temperature info")
+	}
+	info := params[0]
+	return &Temperature{
+		TS0:     float32Param(info[0:4]),
+		TS1:     float32Param(info[4:8]),
+		Samples: binary.BigEndian.Uint16(info[8:10]),
+	}, nil
+}
+
+// SetPowerMeasurement selects what measurement buffer index records
+func (c *Channel) SetPowerMeasurement(ctx context.Context, index uint32, dvm PowerDvm, measurementType PowerMeasurementType) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return SetPowerMeasurementContext(ctx, t, seq, index, dvm, measurementType)
+	})
+}
+
+// StartPowerMeasurement starts continuous power measurement
+func (c *Channel) StartPowerMeasurement(ctx context.Context, delayMs uint32, averaging PowerAveraging, period PowerSamplingPeriod) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return StartPowerMeasurementContext(ctx, t, seq, delayMs, averaging, period)
+	})
+}
+
+// GetPowerMeasurement reads a measurement buffer. A read that clears the
+// buffer is not retried, since a lost response would drop its samples.
+func (c *Channel) GetPowerMeasurement(ctx context.Context, index uint32, clear bool) (*PowerMeasurement, error) {
+	var m *PowerMeasurement
+	fn := func(t Transport, seq uint32) error {
+		var err error
+		m, err = GetPowerMeasurementContext(ctx, t, seq, index, clear)
+		return err
+	}
+	var err error
+	if clear {
+		err = c.send(ctx, fn)
+	} else {
+		err = c.query(ctx, fn)
+	}
+	return m, err
+}
+
+// StopPowerMeasurement stops continuous power measurement
+func (c *Channel) StopPowerMeasurement(ctx context.Context) error {
+	return c.send(ctx, func(t Transport, seq uint32) error {
+		return StopPowerMeasurementContext(ctx, t, seq)
+	})
+}
+
+// ChipTemperature reads the chip temperature
+func (c *Channel) ChipTemperature(ctx context.Context) (*Temperature, error) {
+	var temp *Temperature
+	err := c.query(ctx, func(t Transport, seq uint32) error {
+		var err error
+		temp, err = GetChipTemperatureContext(ctx, t, seq)
+		return err
+	})
+	return temp, err
+}
@@ -0,0 +1,94 @@
+//go:build unit
+
+package control
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// sensorFirmware answers power measurement and temperature requests and
+// keeps the requests it was sent
+type sensorFirmware struct {
+	requests [][]byte
+}
+
+func (f *sensorFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	f.requests = append(f.requests, request)
+
+	response := make([]byte, ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], ProtocolVersion)
+	copy(response[8:16], request[8:16]) // sequence and opcode
+
+	float := func(v float32) []byte {
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(v))
+	}
+	var params [][]byte
+	switch binary.BigEndian.Uint32(request[12:16]) {
+	case OpcodeGetPowerMeasurement:
+		params = [][]byte{u32Param(40), float(1.25), float(2.5), float(1.75), float(281.6)}
+	case OpcodeGetChipTemperature:
+		info := append(float(45.5), float(46)...)
+		params = [][]byte{binary.BigEndian.AppendUint16(info, 3)}
+	}
+	if params != nil {
+		response = binary.BigEndian.AppendUint32(response, uint32(len(params)))
+		for _, p := range params {
+			response = append(response, packParameter(p)...)
+		}
+	}
+	return response, md5.Sum(response), nil
+}
+
+func TestPowerMeasurement(t *testing.T) {
+	fw := &sensorFirmware{}
+	c, _ := newTestChannel(fw)
+	ctx := context.Background()
+
+	if err := c.SetPowerMeasurement(ctx, 0, PowerDvmAuto, PowerTypePower); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.StartPowerMeasurement(ctx, 0, PowerAveraging256, PowerSampling1100us); err != nil {
+		t.Fatal(err)
+	}
+	m, err := c.GetPowerMeasurement(ctx, 0, true)
+	if err != nil {
+		t.Fatalf("GetPowerMeasurement() error = %v", err)
+	}
+	if m.Samples != 40 || m.Min != 1.25 || m.Max != 2.5 || m.Average != 1.75 {
+		t.Errorf("measurement = %+v", m)
+	}
+	if err := c.StopPowerMeasurement(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// start: count, delay (4+4), averaging (4+2), period (4+2)
+	start := fw.requests[1][RequestHeaderSize:]
+	want := []byte{0, 0, 0, 3, 0, 0, 0, 4, 0, 0, 0, 0, 0, 0, 0, 2, 0, 5, 0, 0, 0, 2, 0, 4}
+	if string(start) != string(want) {
+		t.Errorf("start parameters = % x\nwant               % x", start, want)
+	}
+	if stop := fw.requests[3]; len(stop) != RequestHeaderSize+4 {
+		t.Errorf("stop request is %d bytes, want %d", len(stop), RequestHeaderSize+4)
+	}
+}
+
+func TestChipTemperature(t *testing.T) {
+	c, _ := newTestChannel(&sensorFirmware{})
+	temp, err := c.ChipTemperature(context.Background())
+	if err != nil {
+		t.Fatalf("ChipTemperature() error = %v", err)
+	}
+	if temp.TS0 != 45.5 || temp.TS1 != 46 || temp.Samples != 3 {
+		t.Errorf("temperature = %+v", temp)
+	}
+
+	if _, err := ParseTemperature([][]byte{{1, 2}}); err == nil {
+		t.Error("expected error for short temperature info")
+	}
+}
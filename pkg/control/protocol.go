@@ -20,11 +20,19 @@ const (
 	OpcodeOpenStream                    = 4  // HAILO_CONTROL_OPCODE_OPEN_STREAM
 	OpcodeCloseStream                   = 5  // HAILO_CONTROL_OPCODE_CLOSE_STREAM
 	OpcodeReset                         = 7  // HAILO_CONTROL_OPCODE_RESET
+	OpcodePowerMeasurement              = 9  // HAILO_CONTROL_OPCODE_POWER_MEASUEMENT
+	OpcodeSetPowerMeasurement           = 10 // HAILO_CONTROL_OPCODE_SET_POWER_MEASUEMENT
+	OpcodeGetPowerMeasurement           = 11 // HAILO_CONTROL_OPCODE_GET_POWER_MEASUEMENT
+	OpcodeStartPowerMeasurement         = 12 // HAILO_CONTROL_OPCODE_START_POWER_MEASUEMENT
+	OpcodeStopPowerMeasurement          = 13 // HAILO_CONTROL_OPCODE_STOP_POWER_MEASUEMENT
 	OpcodeSetNetworkGroupHeader         = 32 // HAILO_CONTROL_OPCODE_CONTEXT_SWITCH_SET_NETWORK_GROUP_HEADER (line 33)
 	OpcodeSetContextInfo                = 33 // HAILO_CONTROL_OPCODE_CONTEXT_SWITCH_SET_CONTEXT_INFO (line 34)
 	OpcodeDownloadContextActionList     = 36 // HAILO_CONTROL_OPCODE_DOWNLOAD_CONTEXT_ACTION_LIST (line 37)
 	OpcodeChangeContextSwitchStatus     = 37 // HAILO_CONTROL_OPCODE_CHANGE_CONTEXT_SWITCH_STATUS (line 38)
 	OpcodeCoreIdentify                  = 42 // HAILO_CONTROL_OPCODE_CORE_IDENTIFY (line 43)
+	OpcodeGetChipTemperature            = 46 // HAILO_CONTROL_OPCODE_GET_CHIP_TEMPERATURE
+	OpcodeConfigBreakpoint              = 52 // HAILO_CONTROL_OPCODE_CONFIG_CONTEXT_SWITCH_BREAKPOINT (line 53)
+	OpcodeGetBreakpointStatus           = 53 // HAILO_CONTROL_OPCODE_GET_CONTEXT_SWITCH_BREAKPOINT_STATUS (line 54)
 	OpcodeClearConfiguredApps           = 71 // HAILO_CONTROL_OPCODE_CONTEXT_SWITCH_CLEAR_CONFIGURED_APPS (line 158)
 )
 
@@ -326,6 +334,7 @@ func PackSetContextInfoRequest(sequence uint32, chunk *ContextInfoChunk) []byte
 }
 
 // ValidateResponse checks that a response matches expectations and indicates success.
+// A failed status is returned as a *FirmwareError.
 func ValidateResponse(response []byte, expectedSeq, expectedOpcode uint32) error {
 	header, err := ParseResponseHeader(response)
 	if err != nil {
@@ -333,7 +342,7 @@ func ValidateResponse(response []byte, expectedSeq, expectedOpcode uint32) error
 	}
 
 	if header.Sequence != expectedSeq {
-		return fmt.Errorf("sequence mismatch: expected %d, got %d", expectedSeq, header.Sequence)
+		return &SequenceError{Expected: expectedSeq, Got: header.Sequence}
 	}
 
 	if header.Opcode != expectedOpcode {
@@ -341,9 +350,40 @@ func ValidateResponse(response []byte, expectedSeq, expectedOpcode uint32) error
 	}
 
 	if header.MajorStatus != 0 {
-		return fmt.Errorf("firmware error: major_status=%d minor_status=%d",
-			header.MajorStatus, header.MinorStatus)
+		return NewFirmwareError(header)
 	}
 
 	return nil
 }
+
+// ParseResponseParameters splits the parameters that follow a response
+// header. Each parameter is a big-endian length followed by its data.
+func ParseResponseParameters(response []byte) ([][]byte, error) {
+	if len(response) < ResponseHeaderSize+4 {
+		return nil, fmt.Errorf("response too short for parameters: %d bytes", len(response))
+	}
+
+	data := response[ResponseHeaderSize:]
+	count := binary.BigEndian.Uint32(data[0:4])
+	data = data[4:]
+
+	// Every parameter carries at least its 4-byte length
+	if uint64(count) > uint64(len(data)/4) {
+		return nil, fmt.Errorf("parameter count %d exceeds the %d remaining bytes", count, len(data))
+	}
+
+	params := make([][]byte, 0, count)
+	for i := uint32(0); i < count; i++ {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("parameter %d: missing length", i)
+		}
+		length := binary.BigEndian.Uint32(data[0:4])
+		data = data[4:]
+		if uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("parameter %d: length %d exceeds %d remaining bytes", i, length, len(data))
+		}
+		params = append(params, data[:length:length])
+		data = data[length:]
+	}
+	return params, nil
+}
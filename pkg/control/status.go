@@ -0,0 +1,233 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// FirmwareStatus is a status code reported in a control response header.
+// The high 16 bits select the firmware module that raised it and the low
+// 16 bits index that module's status list in firmware_status.h, so
+// 0x40030060 is status 0x60 of the control protocol module.
+type FirmwareStatus uint32
+
+// FirmwareModule is the module part of a firmware status
+type FirmwareModule uint16
+
+// Firmware modules from firmware_status.h
+const (
+	FirmwareModuleGeneral         FirmwareModule = 0x4000 // FIRMWARE_MODULE__GENERAL
+	FirmwareModuleControl         FirmwareModule = 0x4001 // FIRMWARE_MODULE__CONTROL
+	FirmwareModuleControlProtocol FirmwareModule = 0x4003 // FIRMWARE_MODULE__CONTROL_PROTOCOL
+	FirmwareModuleContextSwitch   FirmwareModule = 0x4010 // FIRMWARE_MODULE__CONTEXT_SWITCH
+)
+
+// Module returns the firmware module that raised the status
+func (s FirmwareStatus) Module() FirmwareModule {
+	return FirmwareModule(s >> 16)
+}
+
+// Firmware statuses from firmware_status.h that have a typed error
+const (
+	FwStatusSuccess FirmwareStatus = 0
+
+	FwStatusControlTimeout FirmwareStatus = 0x40010001 // CONTROL_STATUS_TIMEOUT
+
+	FwStatusInvalidVersion              FirmwareStatus = 0x40030001 // CONTROL_PROTOCOL_STATUS_INVALID_VERSION
+	FwStatusInvalidOpcode               FirmwareStatus = 0x40030002 // CONTROL_PROTOCOL_STATUS_INVALID_OPCODE
+	FwStatusInvalidParameterCount       FirmwareStatus = 0x40030003 // CONTROL_PROTOCOL_STATUS_INVALID_PARAMETER_COUNT
+	FwStatusInvalidParameterLength      FirmwareStatus = 0x40030004 // CONTROL_PROTOCOL_STATUS_INVALID_PARAMETER_LENGTH
+	FwStatusUnsupportedOpcode           FirmwareStatus = 0x40030005 // CONTROL_PROTOCOL_STATUS_UNSUPPORTED_OPCODE
+	FwStatusInvalidAppHeaderLength      FirmwareStatus = 0x40030060 // CONTROL_PROTOCOL_STATUS_INVALID_CONTEXT_SWITCH_APP_HEADER_LENGTH
+	FwStatusInvalidContextSwitchContext FirmwareStatus = 0x40030061 // CONTROL_PROTOCOL_STATUS_INVALID_CONTEXT_SWITCH_CONTEXT_INDEX
+
+	FwStatusContextSwitchInvalidAction     FirmwareStatus = 0x40100001 // CONTEXT_SWITCH_STATUS_INVALID_ACTION_TYPE
+	FwStatusContextSwitchActionListTooLong FirmwareStatus = 0x40100002 // CONTEXT_SWITCH_STATUS_ACTION_LIST_TOO_LONG
+	FwStatusContextSwitchInvalidState      FirmwareStatus = 0x40100003 // CONTEXT_SWITCH_STATUS_INVALID_STATE
+	FwStatusContextSwitchNoNetworkGroup    FirmwareStatus = 0x40100004 // CONTEXT_SWITCH_STATUS_NETWORK_GROUP_NOT_CONFIGURED
+	FwStatusContextSwitchTimeout           FirmwareStatus = 0x40100005 // CONTEXT_SWITCH_STATUS_TIMEOUT
+)
+
+// Errors for whole firmware modules. Every status raised by a module
+// matches its error, including statuses without one of their own.
+var (
+	ErrFwGeneral         = errors.New("firmware error")
+	ErrFwControl         = errors.New("firmware control error")
+	ErrFwControlProtocol = errors.New("firmware control protocol error")
+	ErrFwContextSwitch   = errors.New("firmware context switch error")
+)
+
+// Errors for individual firmware statuses
+var (
+	ErrFwControlTimeout = errors.New("firmware control timed out")
+
+	ErrFwInvalidVersion              = errors.New("control protocol version not supported by firmware")
+	ErrFwInvalidOpcode               = errors.New("invalid control opcode")
+	ErrFwInvalidParameterCount       = errors.New("wrong control parameter count")
+	ErrFwInvalidParameterLength      = errors.New("wrong control parameter length")
+	ErrFwUnsupportedOpcode           = errors.New("control opcode not supported by firmware")
+	ErrFwInvalidAppHeaderLength      = errors.New("invalid network group header length")
+	ErrFwInvalidContextSwitchContext = errors.New("invalid context index")
+
+	ErrFwContextSwitchInvalidAction     = errors.New("invalid context switch action")
+	ErrFwContextSwitchActionListTooLong = errors.New("context switch action list too long")
+	ErrFwContextSwitchInvalidState      = errors.New("context switch in wrong state")
+	ErrFwContextSwitchNoNetworkGroup    = errors.New("no network group configured")
+	ErrFwContextSwitchTimeout           = errors.New("context switch timed out")
+)
+
+// statusEntry describes a firmware status
+type statusEntry struct {
+	name   string
+	err    error
+	status driver.Status // runtime status it maps to
+}
+
+// firmwareStatuses is the status table. Statuses missing from it are still
+// reported by module and code.
+var firmwareStatuses = map[FirmwareStatus]statusEntry{
+	FwStatusControlTimeout: {"CONTROL_STATUS_TIMEOUT", ErrFwControlTimeout, driver.StatusTimeout},
+
+	FwStatusInvalidVersion:              {"CONTROL_PROTOCOL_STATUS_INVALID_VERSION", ErrFwInvalidVersion, driver.StatusInvalidProtocolVersion},
+	FwStatusInvalidOpcode:               {"CONTROL_PROTOCOL_STATUS_INVALID_OPCODE", ErrFwInvalidOpcode, driver.StatusFirmwareControlFailure},
+	FwStatusInvalidParameterCount:       {"CONTROL_PROTOCOL_STATUS_INVALID_PARAMETER_COUNT", ErrFwInvalidParameterCount, driver.StatusFirmwareControlFailure},
+	FwStatusInvalidParameterLength:      {"CONTROL_PROTOCOL_STATUS_INVALID_PARAMETER_LENGTH", ErrFwInvalidParameterLength, driver.StatusFirmwareControlFailure},
+	FwStatusUnsupportedOpcode:           {"CONTROL_PROTOCOL_STATUS_UNSUPPORTED_OPCODE", ErrFwUnsupportedOpcode, driver.StatusFirmwareControlFailure},
+	FwStatusInvalidAppHeaderLength:      {"CONTROL_PROTOCOL_STATUS_INVALID_CONTEXT_SWITCH_APP_HEADER_LENGTH", ErrFwInvalidAppHeaderLength, driver.StatusHefNotCompatibleWithDevice},
+	FwStatusInvalidContextSwitchContext: {"CONTROL_PROTOCOL_STATUS_INVALID_CONTEXT_SWITCH_CONTEXT_INDEX", ErrFwInvalidContextSwitchContext, driver.StatusInvalidArgument},
+
+	FwStatusContextSwitchInvalidAction:     {"CONTEXT_SWITCH_STATUS_INVALID_ACTION_TYPE", ErrFwContextSwitchInvalidAction, driver.StatusFirmwareControlFailure},
+	FwStatusContextSwitchActionListTooLong: {"CONTEXT_SWITCH_STATUS_ACTION_LIST_TOO_LONG", ErrFwContextSwitchActionListTooLong, driver.StatusFirmwareControlFailure},
+	FwStatusContextSwitchInvalidState:      {"CONTEXT_SWITCH_STATUS_INVALID_STATE", ErrFwContextSwitchInvalidState, driver.StatusInvalidOperation},
+	FwStatusContextSwitchNoNetworkGroup:    {"CONTEXT_SWITCH_STATUS_NETWORK_GROUP_NOT_CONFIGURED", ErrFwContextSwitchNoNetworkGroup, driver.StatusInvalidOperation},
+	FwStatusContextSwitchTimeout:           {"CONTEXT_SWITCH_STATUS_TIMEOUT", ErrFwContextSwitchTimeout, driver.StatusTimeout},
+}
+
+// moduleErrors maps modules to their errors
+var moduleErrors = map[FirmwareModule]error{
+	FirmwareModuleGeneral:         ErrFwGeneral,
+	FirmwareModuleControl:         ErrFwControl,
+	FirmwareModuleControlProtocol: ErrFwControlProtocol,
+	FirmwareModuleContextSwitch:   ErrFwContextSwitch,
+}
+
+// String returns the firmware_status.h name of the status, or just its
+// code if it is not in the table
+func (s FirmwareStatus) String() string {
+	if entry, ok := firmwareStatuses[s]; ok {
+		return fmt.Sprintf("%s (0x%08x)", entry.name, uint32(s))
+	}
+	if s == FwStatusSuccess {
+		return "success"
+	}
+	return fmt.Sprintf("status 0x%08x", uint32(s))
+}
+
+// Err returns the typed error of the status: its own if it has one, else
+// its module's, else nil
+func (s FirmwareStatus) Err() error {
+	if entry, ok := firmwareStatuses[s]; ok {
+		return entry.err
+	}
+	return moduleErrors[s.Module()]
+}
+
+// DriverStatus returns the runtime status the firmware status maps to
+func (s FirmwareStatus) DriverStatus() driver.Status {
+	if entry, ok := firmwareStatuses[s]; ok {
+		return entry.status
+	}
+	return driver.StatusFirmwareControlFailure
+}
+
+// opcodeNames names the opcodes in error messages
+var opcodeNames = map[uint32]string{
+	OpcodeIdentify:                  "identify",
+	OpcodeWriteMemory:               "write_memory",
+	OpcodeReadMemory:                "read_memory",
+	OpcodeConfigStream:              "config_stream",
+	OpcodeOpenStream:                "open_stream",
+	OpcodeCloseStream:               "close_stream",
+	OpcodeReset:                     "reset",
+	OpcodePowerMeasurement:          "power_measurement",
+	OpcodeSetPowerMeasurement:       "set_power_measurement",
+	OpcodeGetPowerMeasurement:       "get_power_measurement",
+	OpcodeStartPowerMeasurement:     "start_power_measurement",
+	OpcodeStopPowerMeasurement:      "stop_power_measurement",
+	OpcodeSetNetworkGroupHeader:     "set_network_group_header",
+	OpcodeSetContextInfo:            "set_context_info",
+	OpcodeDownloadContextActionList: "download_context_action_list",
+	OpcodeChangeContextSwitchStatus: "change_context_switch_status",
+	OpcodeCoreIdentify:              "core_identify",
+	OpcodeGetChipTemperature:        "get_chip_temperature",
+	OpcodeConfigBreakpoint:          "config_context_switch_breakpoint",
+	OpcodeGetBreakpointStatus:       "get_context_switch_breakpoint_status",
+	OpcodeClearConfiguredApps:       "clear_configured_apps",
+}
+
+// OpcodeName returns the name of a control opcode
+func OpcodeName(opcode uint32) string {
+	if name, ok := opcodeNames[opcode]; ok {
+		return name
+	}
+	return fmt.Sprintf("opcode(%d)", opcode)
+}
+
+// FirmwareError is a control request the firmware rejected. It matches
+// the typed errors of its statuses and module with errors.Is, and a
+// *driver.HailoError of its runtime status with errors.Is and errors.As.
+type FirmwareError struct {
+	Opcode   uint32
+	Sequence uint32
+	Major    FirmwareStatus
+	Minor    FirmwareStatus
+}
+
+// NewFirmwareError returns the error for a failed response header
+func NewFirmwareError(header *ResponseHeader) *FirmwareError {
+	return &FirmwareError{
+		Opcode:   header.Opcode,
+		Sequence: header.Sequence,
+		Major:    FirmwareStatus(header.MajorStatus),
+		Minor:    FirmwareStatus(header.MinorStatus),
+	}
+}
+
+// Error implements the error interface
+func (e *FirmwareError) Error() string {
+	msg := fmt.Sprintf("firmware rejected %s (sequence %d): %s", OpcodeName(e.Opcode), e.Sequence, e.Major)
+	if err := e.Major.Err(); err != nil {
+		msg += ": " + err.Error()
+	}
+	if e.Minor != FwStatusSuccess {
+		msg += fmt.Sprintf(", minor %s", e.Minor)
+	}
+	return msg
+}
+
+// Status returns the runtime status of the error
+func (e *FirmwareError) Status() driver.Status {
+	return e.Major.DriverStatus()
+}
+
+// Unwrap returns the typed errors of the major and minor statuses and
+// their modules, and the runtime errors. Every firmware error matches
+// driver.StatusFirmwareControlFailure as well as its own runtime status.
+func (e *FirmwareError) Unwrap() []error {
+	var errs []error
+	for _, s := range []FirmwareStatus{e.Major, e.Minor} {
+		if entry, ok := firmwareStatuses[s]; ok {
+			errs = append(errs, entry.err)
+		}
+		if err, ok := moduleErrors[s.Module()]; ok {
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, driver.NewError(e.Status(), OpcodeName(e.Opcode)))
+	if e.Status() != driver.StatusFirmwareControlFailure {
+		errs = append(errs, driver.NewError(driver.StatusFirmwareControlFailure, OpcodeName(e.Opcode)))
+	}
+	return errs
+}
@@ -0,0 +1,104 @@
+//go:build unit
+
+package control
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// failedResponse builds a response header carrying the given statuses
+func failedResponse(seq, opcode uint32, major, minor FirmwareStatus) []byte {
+	response := make([]byte, ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], ProtocolVersion)
+	binary.BigEndian.PutUint32(response[8:12], seq)
+	binary.BigEndian.PutUint32(response[12:16], opcode)
+	binary.BigEndian.PutUint32(response[16:20], uint32(major))
+	binary.BigEndian.PutUint32(response[20:24], uint32(minor))
+	return response
+}
+
+func TestValidateResponseFirmwareError(t *testing.T) {
+	response := failedResponse(7, OpcodeSetNetworkGroupHeader, FwStatusInvalidAppHeaderLength, 0)
+	err := ValidateResponse(response, 7, OpcodeSetNetworkGroupHeader)
+
+	var fwErr *FirmwareError
+	if !errors.As(err, &fwErr) {
+		t.Fatalf("ValidateResponse() = %v, want *FirmwareError", err)
+	}
+	if fwErr.Opcode != OpcodeSetNetworkGroupHeader || fwErr.Sequence != 7 {
+		t.Errorf("opcode/sequence = %d/%d, want %d/7", fwErr.Opcode, fwErr.Sequence, OpcodeSetNetworkGroupHeader)
+	}
+	if !errors.Is(err, ErrFwInvalidAppHeaderLength) || !errors.Is(err, ErrFwControlProtocol) {
+		t.Errorf("%v does not match its status and module errors", err)
+	}
+	if errors.Is(err, ErrFwContextSwitch) || errors.Is(err, ErrFwUnsupportedOpcode) {
+		t.Errorf("%v matches errors of other statuses", err)
+	}
+
+	msg := err.Error()
+	for _, want := range []string{"set_network_group_header", "CONTROL_PROTOCOL_STATUS_INVALID_CONTEXT_SWITCH_APP_HEADER_LENGTH", "0x40030060"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not mention %q", msg, want)
+		}
+	}
+}
+
+func TestFirmwareErrorDriverStatus(t *testing.T) {
+	err := ValidateResponse(failedResponse(1, OpcodeChangeContextSwitchStatus, FwStatusContextSwitchTimeout, 0),
+		1, OpcodeChangeContextSwitchStatus)
+
+	if !errors.Is(err, driver.NewError(driver.StatusTimeout, "")) {
+		t.Errorf("%v does not match StatusTimeout", err)
+	}
+	if !errors.Is(err, driver.NewError(driver.StatusFirmwareControlFailure, "")) {
+		t.Errorf("%v does not match StatusFirmwareControlFailure", err)
+	}
+	var hailoErr *driver.HailoError
+	if !errors.As(err, &hailoErr) || hailoErr.Status != driver.StatusTimeout {
+		t.Errorf("errors.As(%v) = %v, want StatusTimeout", err, hailoErr)
+	}
+}
+
+func TestFirmwareErrorMinorStatus(t *testing.T) {
+	err := ValidateResponse(failedResponse(3, OpcodeDownloadContextActionList, FwStatusInvalidParameterLength, FwStatusContextSwitchInvalidAction),
+		3, OpcodeDownloadContextActionList)
+
+	if !errors.Is(err, ErrFwInvalidParameterLength) || !errors.Is(err, ErrFwContextSwitchInvalidAction) {
+		t.Errorf("%v does not match both statuses", err)
+	}
+	if !strings.Contains(err.Error(), "CONTEXT_SWITCH_STATUS_INVALID_ACTION_TYPE") {
+		t.Errorf("error %q does not name the minor status", err)
+	}
+}
+
+func TestFirmwareErrorUnknownStatus(t *testing.T) {
+	unknown := FirmwareStatus(uint32(FirmwareModuleContextSwitch)<<16 | 0x7f)
+	err := ValidateResponse(failedResponse(2, OpcodeSetContextInfo, unknown, 0), 2, OpcodeSetContextInfo)
+
+	if !errors.Is(err, ErrFwContextSwitch) {
+		t.Errorf("%v does not match its module error", err)
+	}
+	if errors.Is(err, ErrFwContextSwitchInvalidAction) {
+		t.Errorf("%v matches a status it is not", err)
+	}
+	if !strings.Contains(err.Error(), "0x4010007f") {
+		t.Errorf("error %q does not report the code", err)
+	}
+	if !errors.Is(err, driver.NewError(driver.StatusFirmwareControlFailure, "")) {
+		t.Errorf("%v does not match StatusFirmwareControlFailure", err)
+	}
+}
+
+func TestValidateResponseSuccess(t *testing.T) {
+	if err := ValidateResponse(failedResponse(4, OpcodeIdentify, 0, 0), 4, OpcodeIdentify); err != nil {
+		t.Errorf("ValidateResponse() = %v, want nil", err)
+	}
+	if err := ValidateResponse(failedResponse(4, OpcodeIdentify, 0, 0), 5, OpcodeIdentify); err == nil {
+		t.Error("expected sequence mismatch")
+	}
+}
@@ -0,0 +1,249 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// Transport carries firmware control requests to the device and returns
+// the responses. *driver.DeviceFile is the ioctl implementation; the
+// recording and replay transports wrap or stand in for it.
+type Transport interface {
+	FwControlContext(ctx context.Context, request []byte, md5 [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error)
+}
+
+var _ Transport = (*driver.DeviceFile)(nil)
+
+// Errors for replayed control exchanges
+var (
+	ErrReplayMismatch   = errors.New("control request does not match the recording")
+	ErrReplayExhausted  = errors.New("control recording exhausted")
+	ErrReplayIncomplete = errors.New("control recording not fully replayed")
+)
+
+// ControlRecord is one request/response exchange of a recording
+type ControlRecord struct {
+	Sequence    uint32       `json:"sequence"`
+	Opcode      uint32       `json:"opcode"`
+	CpuId       driver.CpuId `json:"cpu_id"`
+	TimeoutMs   uint32       `json:"timeout_ms"`
+	Request     []byte       `json:"request"`
+	RequestMD5  string       `json:"request_md5"`
+	Response    []byte       `json:"response,omitempty"`
+	ResponseMD5 string       `json:"response_md5,omitempty"`
+	Error       string       `json:"error,omitempty"`
+
+	// The typed parts of Error, so a replay fails the same way
+	DriverStatus  driver.Status  `json:"driver_status,omitempty"`
+	FirmwareMajor FirmwareStatus `json:"firmware_major,omitempty"`
+	FirmwareMinor FirmwareStatus `json:"firmware_minor,omitempty"`
+}
+
+// recordError stores a failed exchange's message and typed statuses
+func (rec *ControlRecord) recordError(err error) {
+	rec.Error = err.Error()
+	var hailoErr *driver.HailoError
+	if errors.As(err, &hailoErr) {
+		rec.DriverStatus = hailoErr.Status
+	}
+	var fwErr *FirmwareError
+	if errors.As(err, &fwErr) {
+		rec.FirmwareMajor, rec.FirmwareMinor = fwErr.Major, fwErr.Minor
+	}
+}
+
+// replayError rebuilds a recorded failure: the original message, wrapping
+// a *FirmwareError or *driver.HailoError when the recording has one
+func (rec *ControlRecord) replayError() error {
+	var cause error
+	switch {
+	case rec.FirmwareMajor != FwStatusSuccess:
+		cause = &FirmwareError{Opcode: rec.Opcode, Sequence: rec.Sequence, Major: rec.FirmwareMajor, Minor: rec.FirmwareMinor}
+	case rec.DriverStatus != driver.StatusSuccess:
+		cause = driver.NewError(rec.DriverStatus, "")
+	}
+	return &replayedError{msg: rec.Error, cause: cause}
+}
+
+// replayedError is a recorded failure served back by a ReplayTransport
+type replayedError struct {
+	msg   string
+	cause error
+}
+
+// Error returns the recorded message
+func (e *replayedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the rebuilt typed error, if any
+func (e *replayedError) Unwrap() error {
+	return e.cause
+}
+
+// requestHeaderFields reads the sequence and opcode of a request
+func requestHeaderFields(request []byte) (sequence, opcode uint32) {
+	if len(request) < RequestHeaderSize {
+		return 0, 0
+	}
+	return binary.BigEndian.Uint32(request[8:12]), binary.BigEndian.Uint32(request[12:16])
+}
+
+// RecordingTransport passes requests to another transport and writes
+// each exchange to w as one JSON line
+type RecordingTransport struct {
+	inner Transport
+	mu    sync.Mutex
+	enc   *json.Encoder
+}
+
+// NewRecordingTransport records the exchanges of inner to w
+func NewRecordingTransport(inner Transport, w io.Writer) *RecordingTransport {
+	return &RecordingTransport{inner: inner, enc: json.NewEncoder(w)}
+}
+
+// FwControlContext sends the request through the inner transport and
+// records the exchange, including a failed one
+func (t *RecordingTransport) FwControlContext(ctx context.Context, request []byte, md5 [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	response, respMD5, err := t.inner.FwControlContext(ctx, request, md5, timeoutMs, cpuId)
+
+	sequence, opcode := requestHeaderFields(request)
+	rec := ControlRecord{
+		Sequence:   sequence,
+		Opcode:     opcode,
+		CpuId:      cpuId,
+		TimeoutMs:  timeoutMs,
+		Request:    request,
+		RequestMD5: hex.EncodeToString(md5[:]),
+	}
+	if err != nil {
+		rec.recordError(err)
+	} else {
+		rec.Response = response
+		rec.ResponseMD5 = hex.EncodeToString(respMD5[:])
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if encErr := t.enc.Encode(&rec); encErr != nil && err == nil {
+		return response, respMD5, fmt.Errorf("recording control exchange: %w", encErr)
+	}
+	return response, respMD5, err
+}
+
+// ReplayTransport serves the exchanges of a recording back in order. Each
+// request must match the recorded one byte for byte.
+type ReplayTransport struct {
+	mu      sync.Mutex
+	records []ControlRecord
+	next    int
+}
+
+// NewReplayTransport reads a recording made by RecordingTransport
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+	var records []ControlRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var rec ControlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("recording line %d: %w", line, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &ReplayTransport{records: records}, nil
+}
+
+// LoadReplay reads a recording file
+func LoadReplay(path string) (*ReplayTransport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewReplayTransport(f)
+}
+
+// FwControlContext returns the recorded response to the next request
+func (t *ReplayTransport) FwControlContext(ctx context.Context, request []byte, md5 [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, [16]byte{}, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	sequence, opcode := requestHeaderFields(request)
+	if t.next >= len(t.records) {
+		return nil, [16]byte{}, fmt.Errorf("%w: request %d (sequence %d, opcode %d) after %d exchanges",
+			ErrReplayExhausted, t.next, sequence, opcode, len(t.records))
+	}
+	rec := &t.records[t.next]
+
+	if !bytes.Equal(request, rec.Request) || cpuId != rec.CpuId {
+		return nil, [16]byte{}, fmt.Errorf("%w: exchange %d: %s", ErrReplayMismatch, t.next,
+			describeMismatch(rec, request, cpuId))
+	}
+	t.next++
+
+	if rec.Error != "" {
+		return nil, [16]byte{}, rec.replayError()
+	}
+
+	var respMD5 [16]byte
+	hex.Decode(respMD5[:], []byte(rec.ResponseMD5))
+	return append([]byte(nil), rec.Response...), respMD5, nil
+}
+
+// describeMismatch says how a request differs from the recorded one
+func describeMismatch(rec *ControlRecord, request []byte, cpuId driver.CpuId) string {
+	sequence, opcode := requestHeaderFields(request)
+	switch {
+	case opcode != rec.Opcode:
+		return fmt.Sprintf("opcode %d, recorded %d", opcode, rec.Opcode)
+	case sequence != rec.Sequence:
+		return fmt.Sprintf("opcode %d: sequence %d, recorded %d", opcode, sequence, rec.Sequence)
+	case cpuId != rec.CpuId:
+		return fmt.Sprintf("opcode %d: cpu %d, recorded %d", opcode, cpuId, rec.CpuId)
+	case len(request) != len(rec.Request):
+		return fmt.Sprintf("opcode %d: %d bytes, recorded %d", opcode, len(request), len(rec.Request))
+	}
+	for i := range request {
+		if request[i] != rec.Request[i] {
+			return fmt.Sprintf("opcode %d: byte %d is %#02x, recorded %#02x", opcode, i, request[i], rec.Request[i])
+		}
+	}
+	return fmt.Sprintf("opcode %d", opcode)
+}
+
+// Remaining returns the number of exchanges not yet replayed
+func (t *ReplayTransport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.records) - t.next
+}
+
+// Done reports whether every recorded exchange was replayed
+func (t *ReplayTransport) Done() error {
+	if n := t.Remaining(); n > 0 {
+		return fmt.Errorf("%w: %d exchanges left", ErrReplayIncomplete, n)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+//go:build unit
+
+package control
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// fakeFirmware acknowledges every request with a success response
+type fakeFirmware struct {
+	requests int
+	fail     error
+}
+
+func (f *fakeFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	f.requests++
+	if f.fail != nil {
+		return nil, [16]byte{}, f.fail
+	}
+	response := make([]byte, ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], ProtocolVersion)
+	copy(response[8:16], request[8:16]) // sequence and opcode
+	return response, md5.Sum(response), nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	var recording bytes.Buffer
+	fw := &fakeFirmware{}
+	rec := NewRecordingTransport(fw, &recording)
+
+	if err := ClearConfiguredApps(rec, 1); err != nil {
+		t.Fatalf("ClearConfiguredApps: %v", err)
+	}
+	if err := EnableCoreOp(rec, 2, 0, 4, 0); err != nil {
+		t.Fatalf("EnableCoreOp: %v", err)
+	}
+	if lines := strings.Count(recording.String(), "\n"); lines != 2 {
+		t.Fatalf("recording has %d lines, want 2", lines)
+	}
+
+	replay, err := NewReplayTransport(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayTransport: %v", err)
+	}
+	if err := ClearConfiguredApps(replay, 1); err != nil {
+		t.Errorf("replayed ClearConfiguredApps: %v", err)
+	}
+	if err := replay.Done(); !errors.Is(err, ErrReplayIncomplete) {
+		t.Errorf("Done() = %v before the last exchange, want ErrReplayIncomplete", err)
+	}
+	if err := EnableCoreOp(replay, 2, 0, 4, 0); err != nil {
+		t.Errorf("replayed EnableCoreOp: %v", err)
+	}
+	if err := replay.Done(); err != nil {
+		t.Errorf("Done() = %v", err)
+	}
+	if fw.requests != 2 {
+		t.Errorf("firmware saw %d requests, want 2 (replay must not reach it)", fw.requests)
+	}
+
+	if err := ClearConfiguredApps(replay, 3); !errors.Is(err, ErrReplayExhausted) {
+		t.Errorf("request past the recording = %v, want ErrReplayExhausted", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	var recording bytes.Buffer
+	rec := NewRecordingTransport(&fakeFirmware{}, &recording)
+	if err := EnableCoreOp(rec, 5, 0, 4, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := NewReplayTransport(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A different batch size changes one byte of the request
+	err = EnableCoreOp(replay, 5, 0, 8, 0)
+	if !errors.Is(err, ErrReplayMismatch) || !strings.Contains(err.Error(), "byte") {
+		t.Errorf("error = %v, want ErrReplayMismatch naming the byte", err)
+	}
+	if replay.Remaining() != 1 {
+		t.Errorf("Remaining() = %d after a mismatch, want 1", replay.Remaining())
+	}
+
+	err = EnableCoreOp(replay, 6, 0, 4, 0)
+	if !errors.Is(err, ErrReplayMismatch) || !strings.Contains(err.Error(), "sequence 6, recorded 5") {
+		t.Errorf("error = %v, want a sequence mismatch", err)
+	}
+}
+
+func TestReplayRecordedError(t *testing.T) {
+	var recording bytes.Buffer
+	rec := NewRecordingTransport(&fakeFirmware{fail: errors.New("ioctl timed out")}, &recording)
+	if err := ClearConfiguredApps(rec, 1); err == nil {
+		t.Fatal("expected error from the failing firmware")
+	}
+
+	replay, err := NewReplayTransport(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ClearConfiguredApps(replay, 1); err == nil || !strings.Contains(err.Error(), "ioctl timed out") {
+		t.Errorf("replayed error = %v", err)
+	}
+}
+
+func TestReplayTypedErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fail error
+	}{
+		{"driver", driver.NewError(driver.StatusTimeout, "firmware control")},
+		{"firmware", &FirmwareError{Opcode: OpcodeClearConfiguredApps, Sequence: 1, Major: FwStatusUnsupportedOpcode}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recording bytes.Buffer
+			rec := NewRecordingTransport(&fakeFirmware{fail: tt.fail}, &recording)
+			if err := ClearConfiguredApps(rec, 1); err == nil {
+				t.Fatal("expected error from the failing firmware")
+			}
+
+			replay, err := NewReplayTransport(&recording)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = ClearConfiguredApps(replay, 1)
+			if err == nil || !strings.Contains(err.Error(), tt.fail.Error()) {
+				t.Fatalf("replayed error = %v, want %v", err, tt.fail)
+			}
+
+			var hailoErr *driver.HailoError
+			if !errors.As(err, &hailoErr) {
+				t.Fatalf("replayed error %v is not a *driver.HailoError", err)
+			}
+			var want *FirmwareError
+			if errors.As(tt.fail, &want) {
+				var got *FirmwareError
+				if !errors.As(err, &got) || *got != *want {
+					t.Fatalf("replayed firmware error = %+v, want %+v", got, want)
+				}
+			} else if !errors.Is(err, driver.NewError(driver.StatusTimeout, "")) {
+				t.Errorf("replayed status = %s, want timeout", hailoErr.Status)
+			}
+		})
+	}
+}
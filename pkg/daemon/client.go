@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// Client talks to a daemon server. Requests on one client are sent one at
+// a time; open several clients for concurrent inference.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	enc    *gob.Encoder
+	dec    *gob.Decoder
+	closed bool
+}
+
+// Dial connects to the server listening on the Unix socket at path
+func Dial(path string) (*Client, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a client on an established connection
+func NewClient(conn net.Conn) *Client {
+	return &Client{
+		conn: conn,
+		enc:  gob.NewEncoder(conn),
+		dec:  gob.NewDecoder(conn),
+	}
+}
+
+// Info describes the model the server runs
+func (c *Client) Info() (*Info, error) {
+	resp, err := c.roundTrip(context.Background(), &request{Op: opInfo})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Info == nil {
+		return nil, fmt.Errorf("daemon returned no info")
+	}
+	return resp.Info, nil
+}
+
+// Infer runs one inference on the server. inputs must hold a full batch
+// for every input stream, keyed by stream name.
+func (c *Client) Infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	req := &request{Op: opInfer, Inputs: inputs}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Timeout = time.Until(deadline)
+		if req.Timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+
+	resp, err := c.roundTrip(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Outputs, nil
+}
+
+// roundTrip sends a request and waits for its response. A canceled ctx
+// unblocks the wait by expiring the connection deadline; the connection is
+// then out of step with the server and the client is closed.
+func (c *Client) roundTrip(ctx context.Context, req *request) (*response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, ErrClientClosed
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		c.conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	var resp response
+	err := c.enc.Encode(req)
+	if err == nil {
+		err = c.dec.Decode(&resp)
+	}
+	if err != nil {
+		c.closed = true
+		c.conn.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, fmt.Errorf("daemon request failed: %w", err)
+	}
+
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return &resp, nil
+}
+
+// Close disconnects from the server
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.conn.Close()
+}
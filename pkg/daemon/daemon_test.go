@@ -0,0 +1,185 @@
+//go:build unit
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// echoBackend returns each input reversed under "out_<name>"
+type echoBackend struct {
+	block chan struct{} // when set, Infer waits on it or ctx
+}
+
+func (b *echoBackend) Info() Info {
+	return Info{
+		Device:       "/dev/hailo0",
+		NetworkGroup: "echo",
+		Inputs:       []StreamInfo{{Name: "in", FrameSize: 4, BatchSize: 1}},
+		Outputs:      []StreamInfo{{Name: "out_in", FrameSize: 4, BatchSize: 1}},
+	}
+}
+
+func (b *echoBackend) Infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	if b.block != nil {
+		select {
+		case <-b.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	data, ok := inputs["in"]
+	if !ok {
+		return nil, errors.New("missing input in")
+	}
+	out := make([]byte, len(data))
+	for i := range data {
+		out[len(data)-1-i] = data[i]
+	}
+	return map[string][]byte{"out_in": out}, nil
+}
+
+// startServer serves backend on a temporary socket
+func startServer(t *testing.T, backend Backend) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "hailort.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	s := NewServer(backend)
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+
+	t.Cleanup(func() {
+		s.Close()
+		if err := <-done; !errors.Is(err, ErrServerClosed) {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	})
+	return path
+}
+
+func TestClientInfo(t *testing.T) {
+	path := startServer(t, &echoBackend{})
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	info, err := c.Info()
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	if info.NetworkGroup != "echo" || len(info.Inputs) != 1 || info.Inputs[0].FrameSize != 4 {
+		t.Errorf("info = %+v", info)
+	}
+}
+
+func TestConcurrentClients(t *testing.T) {
+	path := startServer(t, &echoBackend{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			c, err := Dial(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer c.Close()
+
+			for j := 0; j < 10; j++ {
+				in := []byte{byte(i), byte(j), 2, 3}
+				out, err := c.Infer(context.Background(), map[string][]byte{"in": in})
+				if err != nil {
+					t.Errorf("client %d: Infer failed: %v", i, err)
+					return
+				}
+				want := []byte{3, 2, byte(j), byte(i)}
+				if !bytes.Equal(out["out_in"], want) {
+					t.Errorf("client %d: out = %v, want %v", i, out["out_in"], want)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestInferBackendError(t *testing.T) {
+	path := startServer(t, &echoBackend{})
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Infer(context.Background(), map[string][]byte{"other": {1}}); err == nil {
+		t.Fatal("expected error for missing input")
+	}
+
+	// The connection stays usable after a backend error
+	if _, err := c.Infer(context.Background(), map[string][]byte{"in": {1}}); err != nil {
+		t.Errorf("Infer after backend error failed: %v", err)
+	}
+}
+
+func TestInferDeadline(t *testing.T) {
+	path := startServer(t, &echoBackend{block: make(chan struct{})})
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.Infer(ctx, map[string][]byte{"in": {1}})
+	if err == nil {
+		t.Fatal("expected deadline error")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Infer took %v after the deadline", elapsed)
+	}
+}
+
+func TestInferCancelClosesClient(t *testing.T) {
+	path := startServer(t, &echoBackend{block: make(chan struct{})})
+
+	c, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if _, err := c.Infer(ctx, map[string][]byte{"in": {1}}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Infer error = %v, want context.Canceled", err)
+	}
+
+	// The response may still be in flight, so the client cannot be reused
+	if _, err := c.Info(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Info after cancel = %v, want ErrClientClosed", err)
+	}
+}
@@ -0,0 +1,56 @@
+// Package daemon shares one Hailo device between several processes. A
+// server owns the device, with the network group configured and activated
+// once, and serves inference to clients over a Unix socket.
+package daemon
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultSocketPath is where the server listens unless told otherwise
+const DefaultSocketPath = "/tmp/hailort.sock"
+
+// Errors for daemon operations
+var (
+	ErrClientClosed = errors.New("daemon client is closed")
+	ErrServerClosed = errors.New("daemon server is closed")
+)
+
+// Request operations
+const (
+	opInfo  = "info"
+	opInfer = "infer"
+)
+
+// StreamInfo describes a stream served by the daemon
+type StreamInfo struct {
+	Name      string
+	FrameSize uint64 // bytes per frame
+	BatchSize uint32 // frames per inference
+	Height    uint32
+	Width     uint32
+	Channels  uint32
+}
+
+// Info describes the model the daemon serves
+type Info struct {
+	Device       string
+	NetworkGroup string
+	Inputs       []StreamInfo
+	Outputs      []StreamInfo
+}
+
+// request is sent by the client, gob encoded
+type request struct {
+	Op      string
+	Inputs  map[string][]byte
+	Timeout time.Duration // zero waits as long as the server allows
+}
+
+// response answers exactly one request
+type response struct {
+	Info    *Info
+	Outputs map[string][]byte
+	Error   string
+}
@@ -0,0 +1,306 @@
+package daemon
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/stream"
+)
+
+// Backend runs the inferences a server receives
+type Backend interface {
+	Info() Info
+	Infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error)
+}
+
+// DeviceBackend serves the default network group of a HEF on a device.
+// Inferences from all clients are serialized onto the one set of streams.
+type DeviceBackend struct {
+	mu        sync.Mutex
+	info      Info
+	cng       *device.ConfiguredNetworkGroup
+	ang       *device.ActivatedNetworkGroup
+	vstreams  *stream.VStreamSet
+	resources *stream.ContextResources // multi-context models only
+	sup       *device.Supervisor
+	unhook    func() // removes the stream reset from sup
+}
+
+// NewDeviceBackend configures and activates the default network group of
+// hefFile on dev and builds its VStreams. When sup is not nil inferences
+// run under it and the streams are reset after it recovers the device.
+func NewDeviceBackend(dev *device.Device, hefFile *hef.Hef, params stream.VStreamParams, sup *device.Supervisor) (*DeviceBackend, error) {
+	cng, err := dev.ConfigureDefaultNetworkGroup(hefFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure network group: %w", err)
+	}
+
+	var resources *stream.ContextResources
+	if cng.IsMultiContext() {
+		resources, err = stream.PrepareContextResources(cng, params.BatchSize)
+		if err != nil {
+			cng.Close()
+			return nil, fmt.Errorf("failed to prepare context resources: %w", err)
+		}
+	}
+	cleanup := func() {
+		cng.Close()
+		if resources != nil {
+			resources.Close()
+		}
+	}
+
+	ang, err := cng.Activate()
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed to activate network group: %w", err)
+	}
+
+	vstreams, err := stream.BuildVStreams(cng, params)
+	if err != nil {
+		ang.Deactivate()
+		cleanup()
+		return nil, fmt.Errorf("failed to build vstreams: %w", err)
+	}
+
+	b := &DeviceBackend{
+		info: Info{
+			Device:       dev.Path(),
+			NetworkGroup: cng.Name(),
+		},
+		cng:       cng,
+		ang:       ang,
+		vstreams:  vstreams,
+		resources: resources,
+		sup:       sup,
+	}
+	if sup != nil {
+		b.unhook = sup.AddRecoveryHook(vstreams.Reset)
+	}
+	for _, in := range vstreams.Inputs {
+		b.info.Inputs = append(b.info.Inputs, streamInfo(in.Info(), in.BatchSize()))
+	}
+	for _, out := range vstreams.Outputs {
+		b.info.Outputs = append(b.info.Outputs, streamInfo(out.Info(), out.BatchSize()))
+	}
+	return b, nil
+}
+
+func streamInfo(info stream.VStreamInfo, batchSize uint32) StreamInfo {
+	return StreamInfo{
+		Name:      info.Name,
+		FrameSize: info.FrameSize,
+		BatchSize: batchSize,
+		Height:    info.Height,
+		Width:     info.Width,
+		Channels:  info.Channels,
+	}
+}
+
+// Info describes the served network group
+func (b *DeviceBackend) Info() Info {
+	return b.info
+}
+
+// Infer runs one inference
+func (b *DeviceBackend) Infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.sup == nil {
+		return b.vstreams.Infer(ctx, inputs)
+	}
+
+	var outputs map[string][]byte
+	err := b.sup.Do(func() error {
+		var err error
+		outputs, err = b.vstreams.Infer(ctx, inputs)
+		return err
+	})
+	return outputs, err
+}
+
+// Close releases the streams and the network group
+func (b *DeviceBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.unhook != nil {
+		b.unhook()
+	}
+	err := b.vstreams.Close()
+	if derr := b.ang.Deactivate(); err == nil {
+		err = derr
+	}
+	if cerr := b.cng.Close(); err == nil {
+		err = cerr
+	}
+	if b.resources != nil {
+		if rerr := b.resources.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// Server accepts client connections and runs their requests on a backend
+type Server struct {
+	backend Backend
+	ctx     context.Context // canceled by Close to abort running requests
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewServer creates a server for a backend
+func NewServer(backend Backend) *Server {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		backend: backend,
+		ctx:     ctx,
+		cancel:  cancel,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts connections on l until Close is called. It always returns
+// a non-nil error; after Close that is ErrServerClosed.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listener = l
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+// ListenAndServe listens on the Unix socket at path and serves it. A stale
+// socket file left by a previous server is replaced.
+func (s *Server) ListenAndServe(path string) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("another server is listening on %s", path)
+	}
+	os.Remove(path)
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	defer os.Remove(path)
+	return s.Serve(l)
+}
+
+// handle serves the requests of one client until it disconnects
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	dec := gob.NewDecoder(conn)
+	enc := gob.NewEncoder(conn)
+
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return // io.EOF on a clean disconnect
+		}
+
+		resp := s.serve(&req)
+		if err := enc.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// serve runs one request
+func (s *Server) serve(req *request) *response {
+	switch req.Op {
+	case opInfo:
+		info := s.backend.Info()
+		return &response{Info: &info}
+
+	case opInfer:
+		ctx := s.ctx
+		if req.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, req.Timeout)
+			defer cancel()
+		}
+
+		outputs, err := s.backend.Infer(ctx, req.Inputs)
+		if err != nil {
+			return &response{Error: err.Error()}
+		}
+		return &response{Outputs: outputs}
+	}
+	return &response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+}
+
+// Close stops accepting connections, aborts running requests, disconnects
+// all clients and waits for their handlers to return. The backend is not
+// closed.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return err
+}
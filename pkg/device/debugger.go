@@ -0,0 +1,308 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// debugBreakpointID is the firmware breakpoint the debugger uses
+const debugBreakpointID = 0
+
+// Debugger stops the context switch of an activated network group at a
+// breakpoint and reports the state of the channels there. It reads the
+// device notifications itself, so no Supervisor may watch the device
+// while a debugger is open.
+type Debugger struct {
+	ang     *ActivatedNetworkGroup
+	channel *control.Channel
+
+	// read and stop read and interrupt device notifications, and
+	// interrupts polls the vDMA interrupts; replaced in tests
+	read       func() ([]byte, error)
+	stop       func() error
+	interrupts func(bitmap [driver.MaxVdmaEngines]uint32, timeout time.Duration) ([]driver.ChannelIrq, error)
+
+	events chan debugEvent
+	quit   chan struct{} // closed by Close
+	done   chan struct{} // closed when watch returns
+
+	mu         sync.Mutex
+	breakpoint *control.Breakpoint
+	hit        *control.BreakpointHit // where the context switch is stopped
+	closed     bool
+}
+
+// debugEvent is a breakpoint hit or a fatal notification
+type debugEvent struct {
+	hit *control.BreakpointHit
+	err error
+}
+
+// DebugChannel is a vDMA channel a context uses
+type DebugChannel struct {
+	Name      string // edge layer name, or "cfg N" for config channels
+	Direction hef.StreamDirection
+	Engine    uint8
+	Channel   uint8
+	Config    bool
+}
+
+// String describes the channel
+func (c DebugChannel) String() string {
+	dir := "in"
+	if c.Direction == hef.StreamDirectionOutput {
+		dir = "out"
+	}
+	if c.Config {
+		dir = "cfg"
+	}
+	return fmt.Sprintf("engine %d channel %2d %-3s %s", c.Engine, c.Channel, dir, c.Name)
+}
+
+// DebugState is the state of the context switch at a breakpoint
+type DebugState struct {
+	Status   control.BreakpointStatus
+	Hit      *control.BreakpointHit // nil unless stopped
+	Channels []DebugChannel         // channels of the stopped context
+}
+
+// NewDebugger opens a debugger on an activated network group
+func NewDebugger(ang *ActivatedNetworkGroup) (*Debugger, error) {
+	dev := ang.Device()
+	if dev == nil || dev.DeviceFile() == nil {
+		return nil, ErrDeviceClosed
+	}
+	df := dev.DeviceFile()
+	interrupts := func(bitmap [driver.MaxVdmaEngines]uint32, timeout time.Duration) ([]driver.ChannelIrq, error) {
+		params, err := df.VdmaInterruptsWaitWithTimeout(bitmap, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return params.Channels(), nil
+	}
+	return newDebugger(ang, dev.Control(), df.ReadNotification, df.DisableNotification, interrupts), nil
+}
+
+// newDebugger starts reading notifications for a debugger
+func newDebugger(ang *ActivatedNetworkGroup, channel *control.Channel,
+	read func() ([]byte, error), stop func() error,
+	interrupts func([driver.MaxVdmaEngines]uint32, time.Duration) ([]driver.ChannelIrq, error)) *Debugger {
+
+	d := &Debugger{
+		ang:        ang,
+		channel:    channel,
+		read:       read,
+		stop:       stop,
+		interrupts: interrupts,
+		events:     make(chan debugEvent, 1),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go d.watch()
+	return d
+}
+
+// watch reads notifications until they are stopped, passing on breakpoint
+// hits and fatal health events
+func (d *Debugger) watch() {
+	defer close(d.done)
+	for {
+		data, err := d.read()
+		if err != nil {
+			return
+		}
+		n, err := control.ParseNotification(data)
+		if err != nil {
+			continue
+		}
+
+		var ev debugEvent
+		switch {
+		case n.EventID == control.EventContextSwitchBreakpoint:
+			ev.hit, ev.err = control.ParseBreakpointHit(n)
+		case n.IsFatal():
+			ev.err = &HealthEventError{Notification: n}
+		default:
+			continue
+		}
+		select {
+		case d.events <- ev:
+		case <-d.quit:
+			return
+		}
+	}
+}
+
+// Break sets the breakpoint. It applies to the debugged network group
+// unless bp.AnyNetworkGroup is set.
+func (d *Debugger) Break(ctx context.Context, bp control.Breakpoint) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrDebuggerClosed
+	}
+	bp.NetworkGroupIndex = d.ang.configured.networkGroupIndex
+	if err := d.channel.SetBreakpoint(ctx, debugBreakpointID, &bp); err != nil {
+		return err
+	}
+	d.breakpoint = &bp
+	return nil
+}
+
+// Wait blocks until the context switch reaches the breakpoint
+func (d *Debugger) Wait(ctx context.Context) (*control.BreakpointHit, error) {
+	select {
+	case ev := <-d.events:
+		if ev.err != nil {
+			return nil, ev.err
+		}
+		d.mu.Lock()
+		d.hit = ev.hit
+		d.mu.Unlock()
+		return ev.hit, nil
+	case <-d.quit:
+		return nil, ErrDebuggerClosed
+	case <-d.done:
+		return nil, ErrDebuggerClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Continue resumes the context switch. The breakpoint stays set and stops
+// it again the next time it is reached.
+func (d *Debugger) Continue(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.hit == nil {
+		return ErrNotStopped
+	}
+	if err := d.channel.ContinueBreakpoint(ctx, debugBreakpointID); err != nil {
+		return err
+	}
+	d.hit = nil
+	return nil
+}
+
+// Step moves the breakpoint to the action after the one the context switch
+// is stopped at and resumes it. Past the last action of the context, the
+// next stop is at the same action of the next batch; use Break and
+// Continue to move on to another context.
+func (d *Debugger) Step(ctx context.Context) error {
+	d.mu.Lock()
+	hit, bp := d.hit, d.breakpoint
+	d.mu.Unlock()
+	if hit == nil || bp == nil {
+		return ErrNotStopped
+	}
+
+	next := *bp
+	next.AnyContext, next.ContextIndex = false, hit.ContextIndex
+	next.AnyAction, next.ActionIndex = false, hit.ActionIndex+1
+	if err := d.Break(ctx, next); err != nil {
+		return err
+	}
+	return d.Continue(ctx)
+}
+
+// State reads the breakpoint status and lists the channels of the context
+// the context switch is stopped in
+func (d *Debugger) State(ctx context.Context) (*DebugState, error) {
+	status, err := d.channel.BreakpointStatus(ctx, debugBreakpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	state := &DebugState{Status: status, Hit: d.hit}
+	d.mu.Unlock()
+	if state.Hit != nil {
+		state.Channels = d.contextChannels(state.Hit.ContextIndex)
+	}
+	return state, nil
+}
+
+// contextChannels lists the channels a firmware context moves data over:
+// its edge layers for a dynamic context, and the config channels
+func (d *Debugger) contextChannels(fwContext uint16) []DebugChannel {
+	d.ang.configured.mu.RLock()
+	res := d.ang.configured.resources
+	d.ang.configured.mu.RUnlock()
+	if res == nil {
+		return nil
+	}
+
+	var channels []DebugChannel
+	if fwContext >= control.FirstDynamicContextIndex {
+		index := uint32(fwContext - control.FirstDynamicContextIndex)
+		for key, ch := range res.Edges {
+			if key.Context == index {
+				channels = append(channels, DebugChannel{
+					Name: key.Name, Direction: key.Direction, Engine: ch.Engine, Channel: ch.Channel,
+				})
+			}
+		}
+	}
+	for index, ch := range res.CfgChannels {
+		channels = append(channels, DebugChannel{
+			Name: fmt.Sprintf("cfg %d", index), Engine: ch.Engine, Channel: ch.Channel, Config: true,
+		})
+	}
+	sort.Slice(channels, func(i, j int) bool {
+		if channels[i].Engine != channels[j].Engine {
+			return channels[i].Engine < channels[j].Engine
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels
+}
+
+// PendingInterrupts waits up to timeout for interrupts on the given
+// channels and returns the ones that fired. Reading interrupts consumes
+// them: a stream waiting on the same channel will not see them, and a
+// wait that times out stays queued in the driver and takes the next one.
+func (d *Debugger) PendingInterrupts(channels []DebugChannel, timeout time.Duration) ([]driver.ChannelIrq, error) {
+	var bitmap [driver.MaxVdmaEngines]uint32
+	for _, ch := range channels {
+		if int(ch.Engine) < len(bitmap) {
+			bitmap[ch.Engine] |= 1 << ch.Channel
+		}
+	}
+	irqs, err := d.interrupts(bitmap, timeout)
+	if err != nil && isTimeout(err) {
+		return nil, nil
+	}
+	return irqs, err
+}
+
+// isTimeout reports whether an interrupt wait timed out with nothing pending
+func isTimeout(err error) bool {
+	return errors.Is(err, driver.NewError(driver.StatusTimeout, "")) ||
+		errors.Is(err, driver.NewError(driver.StatusDriverTimeout, ""))
+}
+
+// Close clears the breakpoint, which resumes the context switch if it is
+// stopped, and stops reading notifications
+func (d *Debugger) Close() error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mu.Unlock()
+
+	err := d.channel.ClearBreakpoint(context.Background(), debugBreakpointID)
+	close(d.quit)
+	d.stop()
+	<-d.done
+	return err
+}
@@ -0,0 +1,179 @@
+//go:build unit
+
+package device
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// debugFirmware acknowledges control requests, remembers the breakpoint
+// requests and reports a breakpoint as reached
+type debugFirmware struct {
+	mu          sync.Mutex
+	breakpoints [][]byte // breakpoint data of each set request
+	controls    []control.BreakpointControl
+}
+
+func (f *debugFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	response := make([]byte, control.ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], control.ProtocolVersion)
+	copy(response[8:16], request[8:16]) // sequence and opcode
+
+	switch binary.BigEndian.Uint32(request[12:16]) {
+	case control.OpcodeConfigBreakpoint:
+		// header, count, id (4+1), control (4+1), data (4+11)
+		ctrl := control.BreakpointControl(request[control.RequestHeaderSize+4+5+4])
+		f.controls = append(f.controls, ctrl)
+		if ctrl == control.BreakpointSet {
+			f.breakpoints = append(f.breakpoints, request[len(request)-11:])
+		}
+	case control.OpcodeGetBreakpointStatus:
+		response = binary.BigEndian.AppendUint32(response, 1)
+		response = binary.BigEndian.AppendUint32(response, 1)
+		response = append(response, byte(control.BreakpointReached))
+	}
+	return response, md5.Sum(response), nil
+}
+
+// breakpointNotification builds a raw breakpoint reached notification
+func breakpointNotification(context, action uint16) []byte {
+	data := make([]byte, control.NotificationHeaderSize+7)
+	binary.LittleEndian.PutUint32(data[16:20], control.EventContextSwitchBreakpoint)
+	binary.LittleEndian.PutUint32(data[24:28], 7)
+	p := data[control.NotificationHeaderSize:]
+	binary.LittleEndian.PutUint16(p[3:5], context)
+	binary.LittleEndian.PutUint16(p[5:7], action)
+	return data
+}
+
+// newTestDebugger activates a mock network group with one dynamic context
+// and opens a debugger fed from the returned notification channel
+func newTestDebugger(t *testing.T) (*Debugger, *debugFirmware, chan []byte) {
+	t.Helper()
+	fw := &debugFirmware{}
+	ng := createMockConfiguredNetworkGroup("debug_network", false)
+	ng.device = &Device{transport: fw}
+	ng.resources = control.NewContextResources()
+	ng.resources.Edges[control.EdgeKey{Context: 0, Name: "in0", Direction: hef.StreamDirectionInput}] = control.EdgeChannel{Engine: 0, Channel: 2}
+	ng.resources.Edges[control.EdgeKey{Context: 1, Name: "other", Direction: hef.StreamDirectionInput}] = control.EdgeChannel{Engine: 0, Channel: 3}
+	ng.resources.CfgChannels[0] = control.CfgChannel{Engine: 0, Channel: 20}
+
+	ang, err := ng.Activate()
+	if err != nil {
+		t.Fatalf("Activate() error = %v", err)
+	}
+
+	notifications := make(chan []byte, 4)
+	read := func() ([]byte, error) {
+		data, ok := <-notifications
+		if !ok {
+			return nil, driver.NewError(driver.StatusDriverWaitCanceled, "read notification")
+		}
+		return data, nil
+	}
+	stop := func() error {
+		close(notifications)
+		return nil
+	}
+	interrupts := func(bitmap [driver.MaxVdmaEngines]uint32, timeout time.Duration) ([]driver.ChannelIrq, error) {
+		return nil, driver.NewError(driver.StatusTimeout, "interrupts")
+	}
+	return newDebugger(ang, ang.Device().Control(), read, stop, interrupts), fw, notifications
+}
+
+func TestDebuggerBreakAndStep(t *testing.T) {
+	d, fw, notifications := newTestDebugger(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := d.Continue(ctx); !errors.Is(err, ErrNotStopped) {
+		t.Errorf("Continue() before a hit = %v, want ErrNotStopped", err)
+	}
+
+	bp := control.Breakpoint{AnyBatch: true, ContextIndex: control.FirstDynamicContextIndex, ActionIndex: 4}
+	if err := d.Break(ctx, bp); err != nil {
+		t.Fatal(err)
+	}
+	notifications <- breakpointNotification(control.FirstDynamicContextIndex, 4)
+	hit, err := d.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	if hit.ContextIndex != control.FirstDynamicContextIndex || hit.ActionIndex != 4 {
+		t.Errorf("hit = %s, want dynamic context 0 action 4", hit)
+	}
+
+	state, err := d.State(ctx)
+	if err != nil {
+		t.Fatalf("State() error = %v", err)
+	}
+	if state.Status != control.BreakpointReached {
+		t.Errorf("Status = %s, want reached", state.Status)
+	}
+	// The edge of dynamic context 0 and the config channel, not context 1's edge
+	if len(state.Channels) != 2 || state.Channels[0].Name != "in0" || !state.Channels[1].Config {
+		t.Errorf("Channels = %v", state.Channels)
+	}
+	if irqs, err := d.PendingInterrupts(state.Channels, time.Millisecond); err != nil || len(irqs) != 0 {
+		t.Errorf("PendingInterrupts() = %v, %v, want none", irqs, err)
+	}
+
+	if err := d.Step(ctx); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if len(fw.breakpoints) != 2 {
+		t.Fatalf("%d breakpoints set, want 2", len(fw.breakpoints))
+	}
+	step := fw.breakpoints[1]
+	if got := binary.BigEndian.Uint16(step[9:11]); got != 5 || step[8] != 0 {
+		t.Errorf("step breakpoint action = %d (any %d), want 5", got, step[8])
+	}
+	if step[2] != 1 {
+		t.Error("step breakpoint lost AnyBatch")
+	}
+
+	if err := d.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	want := []control.BreakpointControl{control.BreakpointSet, control.BreakpointSet, control.BreakpointContinue, control.BreakpointClear}
+	if len(fw.controls) != len(want) {
+		t.Fatalf("controls = %v, want %v", fw.controls, want)
+	}
+	for i := range want {
+		if fw.controls[i] != want[i] {
+			t.Fatalf("controls = %v, want %v", fw.controls, want)
+		}
+	}
+	if _, err := d.Wait(ctx); !errors.Is(err, ErrDebuggerClosed) {
+		t.Errorf("Wait() after Close = %v, want ErrDebuggerClosed", err)
+	}
+}
+
+func TestDebuggerFatalNotification(t *testing.T) {
+	d, _, notifications := newTestDebugger(t)
+	defer d.Close()
+
+	fatal := make([]byte, control.NotificationHeaderSize)
+	binary.LittleEndian.PutUint32(fatal[16:20], control.EventContextSwitchRunTimeError)
+	notifications <- fatal
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var healthErr *HealthEventError
+	if _, err := d.Wait(ctx); !errors.As(err, &healthErr) {
+		t.Errorf("Wait() = %v, want a HealthEventError", err)
+	}
+}
@@ -1,9 +1,11 @@
 package device
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 
+	"github.com/anthropics/purple-hailo/pkg/control"
 	"github.com/anthropics/purple-hailo/pkg/driver"
 	"github.com/anthropics/purple-hailo/pkg/hef"
 )
@@ -15,15 +17,51 @@ type Device struct {
 	driverInfo *driver.DriverInfo
 	mu         sync.RWMutex
 	closed     bool
+
+	// Firmware controls go through transport when set, else the device file
+	transport  control.Transport
+	control    *control.Channel // created on first use
+	controlCfg control.ChannelConfig
+
+	groupsMu sync.Mutex
+	groups   []*ConfiguredNetworkGroup // configured on this device, for recovery
+}
+
+// OpenOptions controls how a device is opened
+type OpenOptions struct {
+	// Exclusive claims the device for this process. Opening fails with
+	// ErrDeviceBusy if another process already claimed it. The claim is
+	// released when the device is closed.
+	Exclusive bool
+
+	// Control configures the retries of firmware queries
+	Control control.ChannelConfig
 }
 
 // Open opens a Hailo device by path
 func Open(path string) (*Device, error) {
+	return OpenWithOptions(path, OpenOptions{})
+}
+
+// OpenWithOptions opens a Hailo device by path with the given options
+func OpenWithOptions(path string, opts OpenOptions) (*Device, error) {
 	df, err := driver.OpenDevice(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open device: %w", err)
 	}
 
+	if opts.Exclusive {
+		inUse, err := df.MarkAsInUse()
+		if err != nil {
+			df.Close()
+			return nil, fmt.Errorf("failed to claim device: %w", err)
+		}
+		if inUse {
+			df.Close()
+			return nil, fmt.Errorf("%w: %s", ErrDeviceBusy, path)
+		}
+	}
+
 	// Query device properties
 	props, err := df.QueryDeviceProperties()
 	if err != nil {
@@ -42,6 +80,7 @@ func Open(path string) (*Device, error) {
 		df:         df,
 		properties: props,
 		driverInfo: driverInfo,
+		controlCfg: opts.Control,
 	}, nil
 }
 
@@ -59,6 +98,33 @@ func OpenFirst() (*Device, error) {
 	return Open(devices[0].Path)
 }
 
+// OpenFirstWithOptions opens the first Hailo device that can be opened
+// with the given options. With Exclusive set, devices claimed by other
+// processes are skipped.
+func OpenFirstWithOptions(opts OpenOptions) (*Device, error) {
+	devices, err := Scan()
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan devices: %w", err)
+	}
+
+	if len(devices) == 0 {
+		return nil, ErrNoDevices
+	}
+
+	var busyErr error
+	for _, info := range devices {
+		dev, err := OpenWithOptions(info.Path, opts)
+		if err == nil {
+			return dev, nil
+		}
+		if !errors.Is(err, ErrDeviceBusy) {
+			return nil, err
+		}
+		busyErr = err
+	}
+	return nil, busyErr
+}
+
 // Close closes the device
 func (d *Device) Close() error {
 	d.mu.Lock()
@@ -95,11 +161,61 @@ func (d *Device) DriverVersion() string {
 		d.driverInfo.RevisionVersion)
 }
 
+// Properties returns the device properties reported by the driver
+func (d *Device) Properties() driver.DeviceProperties {
+	return *d.properties
+}
+
 // DeviceFile returns the underlying driver device file
 func (d *Device) DeviceFile() *driver.DeviceFile {
 	return d.df
 }
 
+// SetTransport routes the firmware controls of the device through t, such
+// as a control.RecordingTransport wrapping the device file or a
+// control.ReplayTransport standing in for it. A nil t restores the device
+// file.
+func (d *Device) SetTransport(t control.Transport) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.transport = t
+	d.control = nil
+}
+
+// Transport returns the transport firmware controls go through, or nil when
+// the device has neither a transport nor a device file
+func (d *Device) Transport() control.Transport {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.transport != nil {
+		return d.transport
+	}
+	if d.df == nil {
+		return nil
+	}
+	return d.df
+}
+
+// Control returns the control channel of the device, which every firmware
+// control goes through, or nil when the device has no transport
+func (d *Device) Control() *control.Channel {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.control != nil {
+		return d.control
+	}
+
+	var t control.Transport = d.transport
+	if t == nil {
+		if d.df == nil {
+			return nil
+		}
+		t = d.df
+	}
+	d.control = control.NewChannel(t, d.controlCfg)
+	return d.control
+}
+
 // ConfigureNetworkGroup configures a network group from a HEF
 func (d *Device) ConfigureNetworkGroup(hefFile *hef.Hef, groupName string) (*ConfiguredNetworkGroup, error) {
 	d.mu.RLock()
@@ -133,29 +249,36 @@ func (d *Device) ConfigureNetworkGroup(hefFile *hef.Hef, groupName string) (*Con
 
 	// Copy stream info
 	for i, s := range ngInfo.InputStreams {
-		cng.inputs[i] = StreamInfo{
-			Name:      s.Name,
-			FrameSize: uint64(s.Shape.Height * s.Shape.Width * s.Shape.Features),
-			Height:    s.Shape.Height,
-			Width:     s.Shape.Width,
-			Channels:  s.Shape.Features,
-			QuantInfo: s.QuantInfo,
-		}
+		cng.inputs[i] = newStreamInfo(s)
 	}
 	for i, s := range ngInfo.OutputStreams {
-		cng.outputs[i] = StreamInfo{
-			Name:      s.Name,
-			FrameSize: uint64(s.Shape.Height * s.Shape.Width * s.Shape.Features),
-			Height:    s.Shape.Height,
-			Width:     s.Shape.Width,
-			Channels:  s.Shape.Features,
-			QuantInfo: s.QuantInfo,
-		}
+		cng.outputs[i] = newStreamInfo(s)
 	}
 
+	d.groupsMu.Lock()
+	d.groups = append(d.groups, cng)
+	d.groupsMu.Unlock()
+
 	return cng, nil
 }
 
+// NetworkGroups returns the network groups configured on the device that
+// have not been closed
+func (d *Device) NetworkGroups() []*ConfiguredNetworkGroup {
+	d.groupsMu.Lock()
+	defer d.groupsMu.Unlock()
+
+	groups := d.groups[:0]
+	for _, ng := range d.groups {
+		if ng.State() != StateUninitialized {
+			groups = append(groups, ng)
+		}
+	}
+	d.groups = groups
+
+	return append([]*ConfiguredNetworkGroup(nil), groups...)
+}
+
 // ConfigureDefaultNetworkGroup configures the default network group from a HEF
 func (d *Device) ConfigureDefaultNetworkGroup(hefFile *hef.Hef) (*ConfiguredNetworkGroup, error) {
 	return d.ConfigureNetworkGroup(hefFile, "")
@@ -10,4 +10,8 @@ var (
 	ErrStillActivated  = errors.New("network group still activated")
 	ErrNotConfigured   = errors.New("network group not configured")
 	ErrAlreadyActivated = errors.New("network group already activated")
+	ErrRecoveryFailed  = errors.New("device recovery failed")
+	ErrDeviceBusy      = errors.New("device is in use by another process")
+	ErrNotStopped      = errors.New("context switch not stopped at a breakpoint")
+	ErrDebuggerClosed  = errors.New("debugger is closed")
 )
@@ -1,6 +1,7 @@
 package device
 
 import (
+	"context"
 	"fmt"
 	"sync"
 
@@ -25,7 +26,38 @@ type StreamInfo struct {
 	Height    uint32
 	Width     uint32
 	Channels  uint32
+	Format    hef.Format
 	QuantInfo hef.QuantInfo
+
+	// DMA placement from the HEF, used by the stream channel allocator
+	EngineIndex         uint32
+	SysIndex            uint32
+	CoreBytesPerBuffer  uint32
+	CoreBuffersPerFrame uint32
+	HasDmaInfo          bool
+}
+
+// newStreamInfo converts HEF stream information. Frames are sized in bytes
+// of the stream's element type; an unspecified type is taken as uint8.
+func newStreamInfo(s hef.StreamInfo) StreamInfo {
+	elemSize := uint64(s.Format.Type.Size())
+	if elemSize == 0 {
+		elemSize = 1
+	}
+	return StreamInfo{
+		Name:                s.Name,
+		FrameSize:           uint64(s.Shape.Height*s.Shape.Width*s.Shape.Features) * elemSize,
+		Height:              s.Shape.Height,
+		Width:               s.Shape.Width,
+		Channels:            s.Shape.Features,
+		Format:              s.Format,
+		QuantInfo:           s.QuantInfo,
+		EngineIndex:         s.EngineIndex,
+		SysIndex:            s.SysIndex,
+		CoreBytesPerBuffer:  s.CoreBytesPerBuffer,
+		CoreBuffersPerFrame: s.CoreBuffersPerFrame,
+		HasDmaInfo:          s.HasDmaInfo,
+	}
 }
 
 // ConfiguredNetworkGroup represents a configured network group
@@ -40,7 +72,15 @@ type ConfiguredNetworkGroup struct {
 
 	// Control protocol state
 	networkGroupIndex uint8  // Index of this network group (0 for first/default)
-	controlSequence   uint32 // Incrementing sequence number for control messages
+	batchSize         uint16 // Frames per context-switch batch (0 = firmware default)
+
+	// Runtime channel allocation for the context switch; nil falls back to
+	// action lists built from the HEF alone
+	resources *control.ContextResources
+
+	// How action lists are built, and how the last build went
+	buildOptions control.BuildOptions
+	report       *control.BuildReport
 }
 
 // Name returns the network group name
@@ -90,14 +130,7 @@ func (ng *ConfiguredNetworkGroup) GetUserInputs() []StreamInfo {
 	userInputs := ng.info.GetUserInputs()
 	result := make([]StreamInfo, len(userInputs))
 	for i, s := range userInputs {
-		result[i] = StreamInfo{
-			Name:      s.Name,
-			FrameSize: uint64(s.Shape.Height * s.Shape.Width * s.Shape.Features),
-			Height:    s.Shape.Height,
-			Width:     s.Shape.Width,
-			Channels:  s.Shape.Features,
-			QuantInfo: s.QuantInfo,
-		}
+		result[i] = newStreamInfo(s)
 	}
 	return result
 }
@@ -107,20 +140,84 @@ func (ng *ConfiguredNetworkGroup) GetUserOutputs() []StreamInfo {
 	userOutputs := ng.info.GetUserOutputs()
 	result := make([]StreamInfo, len(userOutputs))
 	for i, s := range userOutputs {
-		result[i] = StreamInfo{
-			Name:      s.Name,
-			FrameSize: uint64(s.Shape.Height * s.Shape.Width * s.Shape.Features),
-			Height:    s.Shape.Height,
-			Width:     s.Shape.Width,
-			Channels:  s.Shape.Features,
-			QuantInfo: s.QuantInfo,
-		}
+		result[i] = newStreamInfo(s)
 	}
 	return result
 }
 
+// SetBatchSize sets how many frames the firmware runs through each context
+// before switching. It must match the VStream batch size and takes effect on
+// the next Activate.
+func (ng *ConfiguredNetworkGroup) SetBatchSize(batchSize uint16) error {
+	ng.mu.Lock()
+	defer ng.mu.Unlock()
+
+	if ng.state == StateActivated {
+		return ErrStillActivated
+	}
+
+	ng.batchSize = batchSize
+	return nil
+}
+
+// BatchSize returns the configured batch size (0 = firmware default)
+func (ng *ConfiguredNetworkGroup) BatchSize() uint16 {
+	ng.mu.RLock()
+	defer ng.mu.RUnlock()
+	return ng.batchSize
+}
+
+// Contexts returns the dynamic contexts of the network group
+func (ng *ConfiguredNetworkGroup) Contexts() []hef.ContextConfig {
+	return ng.info.Contexts
+}
+
+// PreliminaryOperations returns the operations of the preliminary context
+func (ng *ConfiguredNetworkGroup) PreliminaryOperations() []hef.ConfigOperation {
+	if ng.info.PreliminaryConfig == nil {
+		return nil
+	}
+	return ng.info.PreliminaryConfig.Operations
+}
+
+// CfgChannels returns the config channels the HEF places
+func (ng *ConfiguredNetworkGroup) CfgChannels() []hef.CfgChannelInfo {
+	return ng.info.CfgChannels
+}
+
+// SetContextResources sets the runtime channel allocation the next
+// activation builds its action lists with. Multi-context models need it.
+func (ng *ConfiguredNetworkGroup) SetContextResources(res *control.ContextResources) {
+	ng.mu.Lock()
+	defer ng.mu.Unlock()
+	ng.resources = res
+}
+
+// SetBuildOptions sets how the next activation builds its action lists.
+// Strict activations fail rather than leave HEF actions out.
+func (ng *ConfiguredNetworkGroup) SetBuildOptions(opts control.BuildOptions) {
+	ng.mu.Lock()
+	defer ng.mu.Unlock()
+	ng.buildOptions = opts
+}
+
+// BuildReport returns how the action lists of the last activation attempt
+// or BuildActionLists call were built, or nil before the first. It is kept
+// when activation fails.
+func (ng *ConfiguredNetworkGroup) BuildReport() *control.BuildReport {
+	ng.mu.RLock()
+	defer ng.mu.RUnlock()
+	return ng.report
+}
+
 // Activate activates the network group for inference
 func (ng *ConfiguredNetworkGroup) Activate() (*ActivatedNetworkGroup, error) {
+	return ng.ActivateContext(context.Background())
+}
+
+// ActivateContext is like Activate but gives up when ctx is done. The
+// network group then stays in its previous state and can be activated again.
+func (ng *ConfiguredNetworkGroup) ActivateContext(ctx context.Context) (*ActivatedNetworkGroup, error) {
 	ng.mu.Lock()
 	defer ng.mu.Unlock()
 
@@ -128,121 +225,250 @@ func (ng *ConfiguredNetworkGroup) Activate() (*ActivatedNetworkGroup, error) {
 		return nil, ErrInvalidState
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := ng.loadFirmwareLocked(ctx); err != nil {
+		return nil, err
+	}
+
+	ng.state = StateActivated
+	return &ActivatedNetworkGroup{
+		configured: ng,
+		report:     ng.report,
+	}, nil
+}
+
+// loadFirmwareLocked sends the network group to the firmware and enables
+// it. ng.mu must be held.
+func (ng *ConfiguredNetworkGroup) loadFirmwareLocked(ctx context.Context) error {
+	// Build every action list before touching the firmware, so a failed
+	// build leaves the device as it was
+	preliminaryData, dynamicData, err := ng.buildActionListsLocked()
+	if err != nil {
+		return err
+	}
+
 	// Only call firmware if we have a real device (not a mock)
-	if ng.device != nil && ng.device.DeviceFile() != nil {
-		// Step 0: Clear any previously configured apps
-		ng.controlSequence++
-		fmt.Printf("[activate] Clearing configured apps (sequence %d)\n", ng.controlSequence)
-		if err := control.ClearConfiguredApps(ng.device.DeviceFile(), ng.controlSequence); err != nil {
-			fmt.Printf("[activate] Warning: clear_configured_apps failed: %v (continuing anyway)\n", err)
-		}
+	var channel *control.Channel
+	if ng.device != nil {
+		channel = ng.device.Control()
+	}
+	if channel == nil {
+		fmt.Printf("[activate] No device, skipping firmware calls\n")
+		return nil
+	}
 
-		// Step 1: Send network group header
-		ng.controlSequence++
-		fmt.Printf("[activate] Sending network group header (sequence %d)\n", ng.controlSequence)
+	// Step 0: Clear any previously configured apps
+	fmt.Printf("[activate] Clearing configured apps\n")
+	if err := channel.ClearConfiguredApps(ctx); err != nil {
+		fmt.Printf("[activate] Warning: clear_configured_apps failed: %v (continuing anyway)\n", err)
+	}
 
-		// Count dynamic contexts from HEF
-		dynamicContextsCount := uint16(len(ng.info.Contexts))
-		if dynamicContextsCount == 0 {
-			dynamicContextsCount = 1 // At least one context
-		}
+	// Step 1: Send network group header
+	fmt.Printf("[activate] Sending network group header\n")
 
-		// Create application header with HEF metadata (v4.20.0 format)
-		appHeader := control.CreateDefaultApplicationHeader(dynamicContextsCount)
+	dynamicContextsCount := uint16(len(dynamicData))
 
-		err := control.SetNetworkGroupHeader(
-			ng.device.DeviceFile(),
-			ng.controlSequence,
-			appHeader,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("set_network_group_header failed: %w", err)
-		}
-		fmt.Printf("[activate] Network group header sent successfully\n")
-
-		// Step 2: Send context info for each context type
-		// Build action lists from HEF context configurations
-		fmt.Printf("[activate] Sending context info...\n")
-
-		// Send ACTIVATION context - typically empty for most models
-		// ACTIVATION context runs during activation (not inference)
-		activationData := control.BuildEmptyActionList()
-		if err := control.SendContextInfoChunks(ng.device.DeviceFile(), &ng.controlSequence,
-			control.ContextTypeActivation, activationData); err != nil {
-			return nil, fmt.Errorf("send activation context failed: %w", err)
-		}
-		fmt.Printf("[activate] Activation context sent (%d bytes)\n", len(activationData))
+	// Create application header with HEF metadata (v4.20.0 format)
+	appHeader := control.CreateDefaultApplicationHeader(dynamicContextsCount)
+	if ng.batchSize > 0 {
+		appHeader.BatchSize[0] = ng.batchSize
+	}
 
-		// Send BATCH_SWITCHING context - typically empty
-		batchSwitchingData := control.BuildEmptyActionList()
-		if err := control.SendContextInfoChunks(ng.device.DeviceFile(), &ng.controlSequence,
-			control.ContextTypeBatchSwitching, batchSwitchingData); err != nil {
-			return nil, fmt.Errorf("send batch_switching context failed: %w", err)
-		}
-		fmt.Printf("[activate] Batch switching context sent (%d bytes)\n", len(batchSwitchingData))
-
-		// Send PRELIMINARY context from HEF
-		preliminaryData := []byte{}
-		if ng.info.PreliminaryConfig != nil && len(ng.info.PreliminaryConfig.Operations) > 0 {
-			var err error
-			preliminaryData, err = control.BuildContextActionList(ng.info.PreliminaryConfig.Operations)
-			if err != nil {
-				fmt.Printf("[activate] Warning: failed to build preliminary action list: %v\n", err)
-				preliminaryData = control.BuildEmptyActionList()
-			}
-		} else {
-			preliminaryData = control.BuildEmptyActionList()
+	if err := channel.SetNetworkGroupHeader(ctx, appHeader); err != nil {
+		return fmt.Errorf("set_network_group_header failed: %w", err)
+	}
+	fmt.Printf("[activate] Network group header sent successfully\n")
+
+	// Step 2: Send context info for each context type
+	// Build action lists from HEF context configurations
+	fmt.Printf("[activate] Sending context info...\n")
+
+	// Send ACTIVATION context - typically empty for most models
+	// ACTIVATION context runs during activation (not inference)
+	activationData := control.BuildEmptyActionList()
+	if err := channel.SendContextInfo(ctx, control.ContextTypeActivation, activationData); err != nil {
+		return fmt.Errorf("send activation context failed: %w", err)
+	}
+	fmt.Printf("[activate] Activation context sent (%d bytes)\n", len(activationData))
+
+	// Send BATCH_SWITCHING context - typically empty
+	batchSwitchingData := control.BuildEmptyActionList()
+	if err := channel.SendContextInfo(ctx, control.ContextTypeBatchSwitching, batchSwitchingData); err != nil {
+		return fmt.Errorf("send batch_switching context failed: %w", err)
+	}
+	fmt.Printf("[activate] Batch switching context sent (%d bytes)\n", len(batchSwitchingData))
+
+	// Send PRELIMINARY context from HEF
+	if err := channel.SendContextInfo(ctx, control.ContextTypePreliminary, preliminaryData); err != nil {
+		return fmt.Errorf("send preliminary context failed: %w", err)
+	}
+	fmt.Printf("[activate] Preliminary context sent (%d bytes)\n", len(preliminaryData))
+
+	// Send DYNAMIC contexts from HEF (one per HEF context)
+	for i, data := range dynamicData {
+		if err := channel.SendContextInfo(ctx, control.ContextTypeDynamic, data); err != nil {
+			return fmt.Errorf("send dynamic context %d failed: %w", i, err)
 		}
-		if err := control.SendContextInfoChunks(ng.device.DeviceFile(), &ng.controlSequence,
-			control.ContextTypePreliminary, preliminaryData); err != nil {
-			return nil, fmt.Errorf("send preliminary context failed: %w", err)
+		fmt.Printf("[activate] Dynamic context %d sent (%d bytes)\n", i, len(data))
+	}
+
+	// Step 3: Enable core op
+	fmt.Printf("[activate] Enabling core op for network group %d (batch %d)\n",
+		ng.networkGroupIndex, ng.batchSize)
+
+	err = channel.EnableCoreOp(ctx,
+		ng.networkGroupIndex,
+		ng.batchSize, // dynamic batch size (0 = use default)
+		0,            // batch count (0 = infinite)
+	)
+	if err != nil {
+		return fmt.Errorf("enable_core_op failed: %w", err)
+	}
+	fmt.Printf("[activate] Core op enabled successfully\n")
+	return nil
+}
+
+// BuildActionLists builds the preliminary and dynamic context action lists
+// the next activation would send, without sending them. The report
+// becomes the one BuildReport returns.
+func (ng *ConfiguredNetworkGroup) BuildActionLists() ([]byte, [][]byte, *control.BuildReport, error) {
+	ng.mu.Lock()
+	defer ng.mu.Unlock()
+
+	preliminary, dynamic, err := ng.buildActionListsLocked()
+	return preliminary, dynamic, ng.report, err
+}
+
+// buildActionListsLocked builds the preliminary and dynamic context action
+// lists and records how in ng.report. There is always at least one
+// dynamic context.
+func (ng *ConfiguredNetworkGroup) buildActionListsLocked() ([]byte, [][]byte, error) {
+	report := &control.BuildReport{}
+	ng.report = report
+
+	preliminary, err := ng.buildPreliminaryLocked(report)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	count := len(ng.info.Contexts)
+	if count == 0 {
+		count = 1
+	}
+	dynamic := make([][]byte, count)
+	for i := range dynamic {
+		if dynamic[i], err = ng.buildDynamicLocked(i, report); err != nil {
+			return nil, nil, err
 		}
-		fmt.Printf("[activate] Preliminary context sent (%d bytes)\n", len(preliminaryData))
-
-		// Send DYNAMIC contexts from HEF (one per HEF context)
-		for i := 0; i < int(dynamicContextsCount); i++ {
-			var dynamicData []byte
-			if i < len(ng.info.Contexts) && len(ng.info.Contexts[i].Operations) > 0 {
-				var err error
-				dynamicData, err = control.BuildContextActionList(ng.info.Contexts[i].Operations)
-				if err != nil {
-					fmt.Printf("[activate] Warning: failed to build dynamic context %d action list: %v\n", i, err)
-					dynamicData = control.BuildEmptyActionList()
+	}
+	return preliminary, dynamic, nil
+}
+
+// buildPreliminaryLocked builds the preliminary context action list. With
+// context resources, it activates the boundary edges of the first dynamic
+// context, which the preliminary operations feed.
+func (ng *ConfiguredNetworkGroup) buildPreliminaryLocked(report *control.BuildReport) ([]byte, error) {
+	const name = "preliminary"
+	if ng.info.PreliminaryConfig == nil || len(ng.info.PreliminaryConfig.Operations) == 0 {
+		return emptyContext(report, name), nil
+	}
+	ops := ng.info.PreliminaryConfig.Operations
+
+	if ng.resources != nil {
+		var index uint32
+		var edges []hef.EdgeLayer
+		if len(ng.info.Contexts) > 0 {
+			index = ng.info.Contexts[0].Index
+			for _, edge := range ng.info.Contexts[0].EdgeLayers {
+				if edge.ConnectionType == hef.EdgeConnectionBoundary {
+					edges = append(edges, edge)
 				}
-			} else {
-				dynamicData = control.BuildEmptyActionList()
 			}
-			if err := control.SendContextInfoChunks(ng.device.DeviceFile(), &ng.controlSequence,
-				control.ContextTypeDynamic, dynamicData); err != nil {
-				return nil, fmt.Errorf("send dynamic context %d failed: %w", i, err)
-			}
-			fmt.Printf("[activate] Dynamic context %d sent (%d bytes)\n", i, len(dynamicData))
 		}
+		data, ctxReport, err := control.BuildPreliminaryActions(index, edges, ops, ng.resources)
+		addContextReport(report, name, ctxReport)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build preliminary action list: %w", err)
+		}
+		return data, nil
+	}
 
-		// Step 3: Enable core op
-		ng.controlSequence++
-		fmt.Printf("[activate] Enabling core op for network group %d (sequence %d)\n",
-			ng.networkGroupIndex, ng.controlSequence)
-
-		err = control.EnableCoreOp(
-			ng.device.DeviceFile(),
-			ng.controlSequence,
-			ng.networkGroupIndex,
-			0, // dynamic batch size (0 = use default)
-			0, // batch count (0 = infinite)
-		)
+	return ng.buildLegacyLocked(report, name, ops)
+}
+
+// buildDynamicLocked builds the action list of dynamic context i. With
+// context resources its edge layers are activated even when the context
+// has no operations.
+func (ng *ConfiguredNetworkGroup) buildDynamicLocked(i int, report *control.BuildReport) ([]byte, error) {
+	name := fmt.Sprintf("dynamic %d", i)
+	if i >= len(ng.info.Contexts) {
+		return emptyContext(report, name), nil
+	}
+	cfg := &ng.info.Contexts[i]
+
+	if ng.resources != nil {
+		data, ctxReport, err := control.BuildContextActions(cfg.Index, cfg.EdgeLayers, cfg.Operations, ng.resources)
+		addContextReport(report, name, ctxReport)
 		if err != nil {
-			return nil, fmt.Errorf("enable_core_op failed: %w", err)
+			return nil, fmt.Errorf("failed to build dynamic context %d action list: %w", i, err)
 		}
-		fmt.Printf("[activate] Core op enabled successfully\n")
-	} else {
-		fmt.Printf("[activate] No device, skipping firmware calls\n")
+		return data, nil
 	}
 
-	ng.state = StateActivated
-	return &ActivatedNetworkGroup{
-		configured: ng,
-	}, nil
+	if len(cfg.Operations) == 0 {
+		return emptyContext(report, name), nil
+	}
+	return ng.buildLegacyLocked(report, name, cfg.Operations)
+}
+
+// buildLegacyLocked builds an action list from the HEF alone. Unless the
+// build is strict, a list that fails to build is replaced by an empty one.
+func (ng *ConfiguredNetworkGroup) buildLegacyLocked(report *control.BuildReport, name string, ops []hef.ConfigOperation) ([]byte, error) {
+	data, ctxReport, err := control.BuildContextActionListWithOptions(ops, ng.buildOptions)
+	addContextReport(report, name, ctxReport)
+	if err == nil {
+		return data, nil
+	}
+	if ng.buildOptions.Strict {
+		return nil, fmt.Errorf("failed to build %s action list: %w", name, err)
+	}
+
+	fmt.Printf("[activate] Warning: failed to build %s action list: %v\n", name, err)
+	data = control.BuildEmptyActionList()
+	last := &report.Contexts[len(report.Contexts)-1]
+	last.Fallback = true
+	last.Error = err.Error()
+	last.Bytes = len(data)
+	return data, nil
+}
+
+// emptyContext records and returns the list of a context without
+// operations
+func emptyContext(report *control.BuildReport, name string) []byte {
+	data := control.BuildEmptyActionList()
+	report.Contexts = append(report.Contexts, control.ContextReport{Name: name, Bytes: len(data)})
+	return data
+}
+
+// addContextReport adds the report of one context under its name
+func addContextReport(report *control.BuildReport, name string, ctxReport *control.ContextReport) {
+	ctxReport.Name = name
+	report.Contexts = append(report.Contexts, *ctxReport)
+}
+
+// reactivate loads an activated network group into the firmware again,
+// after a reset cleared it. The state and activation handle are kept.
+func (ng *ConfiguredNetworkGroup) reactivate() error {
+	ng.mu.Lock()
+	defer ng.mu.Unlock()
+
+	if ng.state != StateActivated {
+		return ErrInvalidState
+	}
+	return ng.loadFirmwareLocked(context.Background())
 }
 
 // Close closes the configured network group
@@ -261,6 +487,7 @@ func (ng *ConfiguredNetworkGroup) Close() error {
 // ActivatedNetworkGroup represents an activated network group ready for inference
 type ActivatedNetworkGroup struct {
 	configured  *ConfiguredNetworkGroup
+	report      *control.BuildReport
 	mu          sync.Mutex
 	deactivated bool
 }
@@ -275,15 +502,14 @@ func (ang *ActivatedNetworkGroup) Deactivate() error {
 	}
 
 	// Only call firmware if we have a real device (not a mock)
-	if ang.configured.device != nil && ang.configured.device.DeviceFile() != nil {
-		ang.configured.controlSequence++
-		fmt.Printf("[deactivate] Resetting context switch state machine (sequence %d)\n",
-			ang.configured.controlSequence)
-
-		err := control.ResetContextSwitchStateMachine(
-			ang.configured.device.DeviceFile(),
-			ang.configured.controlSequence,
-		)
+	var channel *control.Channel
+	if ang.configured.device != nil {
+		channel = ang.configured.device.Control()
+	}
+	if channel != nil {
+		fmt.Printf("[deactivate] Resetting context switch state machine\n")
+
+		err := channel.ResetContextSwitchStateMachine(context.Background())
 		if err != nil {
 			// Log but don't fail - deactivation should still proceed
 			fmt.Printf("[deactivate] Warning: reset_context_switch_state_machine failed: %v\n", err)
@@ -300,6 +526,11 @@ func (ang *ActivatedNetworkGroup) Deactivate() error {
 	return nil
 }
 
+// BuildReport returns how the action lists of this activation were built
+func (ang *ActivatedNetworkGroup) BuildReport() *control.BuildReport {
+	return ang.report
+}
+
 // IsActive returns whether the network group is active
 func (ang *ActivatedNetworkGroup) IsActive() bool {
 	ang.mu.Lock()
@@ -3,9 +3,17 @@
 package device
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"sync"
 	"testing"
 
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/driver"
 	"github.com/anthropics/purple-hailo/pkg/hef"
 )
 
@@ -263,6 +271,28 @@ func TestNetworkGroupInputShape(t *testing.T) {
 	}
 }
 
+func TestNewStreamInfoElementSize(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 4, Width: 5, Features: 3}
+	tests := []struct {
+		format hef.FormatType
+		want   uint64
+	}{
+		{hef.FormatTypeAuto, 60},
+		{hef.FormatTypeUint8, 60},
+		{hef.FormatTypeUint16, 120},
+		{hef.FormatTypeFloat32, 240},
+	}
+	for _, tt := range tests {
+		info := newStreamInfo(hef.StreamInfo{Name: "s", Shape: shape, Format: hef.Format{Type: tt.format}})
+		if info.FrameSize != tt.want {
+			t.Errorf("%s: FrameSize = %d, want %d", tt.format, info.FrameSize, tt.want)
+		}
+		if info.Format.Type != tt.format {
+			t.Errorf("%s: Format.Type = %s", tt.format, info.Format.Type)
+		}
+	}
+}
+
 // Benchmarks
 
 func BenchmarkNetworkGroupActivateDeactivate(b *testing.B) {
@@ -284,3 +314,219 @@ func BenchmarkNetworkGroupStreamInfoAccess(b *testing.B) {
 		_ = ng.OutputStreamInfos()
 	}
 }
+
+func TestNetworkGroupBatchSize(t *testing.T) {
+	ng := createMockConfiguredNetworkGroup("test", true)
+
+	if ng.BatchSize() != 0 {
+		t.Errorf("BatchSize() = %d, expected 0 (firmware default)", ng.BatchSize())
+	}
+	if err := ng.SetBatchSize(8); err != nil {
+		t.Fatalf("SetBatchSize() error = %v", err)
+	}
+	if ng.BatchSize() != 8 {
+		t.Errorf("BatchSize() = %d, expected 8", ng.BatchSize())
+	}
+
+	ang, err := ng.Activate()
+	if err != nil {
+		t.Fatalf("Activate() error = %v", err)
+	}
+	if err := ng.SetBatchSize(4); err != ErrStillActivated {
+		t.Errorf("SetBatchSize() while activated = %v, expected ErrStillActivated", err)
+	}
+
+	ang.Deactivate()
+	if err := ng.SetBatchSize(4); err != nil {
+		t.Errorf("SetBatchSize() after Deactivate error = %v", err)
+	}
+}
+
+func TestNetworkGroupActivateContextCanceled(t *testing.T) {
+	ng := createMockConfiguredNetworkGroup("test_network", false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ng.ActivateContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ActivateContext error = %v, want context.Canceled", err)
+	}
+	if ng.State() != StateConfigured {
+		t.Errorf("State() = %d after canceled activation, want StateConfigured", ng.State())
+	}
+
+	if _, err := ng.ActivateContext(context.Background()); err != nil {
+		t.Errorf("ActivateContext after cancellation failed: %v", err)
+	}
+}
+
+// withCcwContext gives a mock network group a context whose CCW writes
+// need the channel allocation
+func withCcwContext(ng *ConfiguredNetworkGroup) *ConfiguredNetworkGroup {
+	ng.info.Contexts = []hef.ContextConfig{{
+		Operations: []hef.ConfigOperation{{Actions: []hef.ConfigAction{
+			{Type: hef.ActionTypeDisableLcu, DisableLcu: &hef.DisableLcuParams{}},
+			{Type: hef.ActionTypeWriteDataCcw, Data: make([]byte, 8)},
+		}}},
+	}}
+	return ng
+}
+
+func TestNetworkGroupActivateBuildReport(t *testing.T) {
+	ng := withCcwContext(createMockConfiguredNetworkGroup("test_network", false))
+
+	ang, err := ng.Activate()
+	if err != nil {
+		t.Fatalf("Activate() error = %v", err)
+	}
+	defer ang.Deactivate()
+
+	report := ang.BuildReport()
+	if report == nil || len(report.Contexts) != 2 {
+		t.Fatalf("report = %+v, want preliminary and one dynamic context", report)
+	}
+	if report.Complete() {
+		t.Error("report is complete with a skipped CCW write")
+	}
+
+	dynamic := report.Contexts[1]
+	skipped := dynamic.Skipped()
+	if dynamic.Name != "dynamic 0" || dynamic.Count(control.ActionConverted) != 1 || len(skipped) != 1 {
+		t.Fatalf("dynamic report = %+v", dynamic)
+	}
+	if skipped[0].Operation != 0 || skipped[0].Action != 1 || skipped[0].Type != hef.ActionTypeWriteDataCcw {
+		t.Errorf("skipped = %+v, want operation 0 action 1 write_data_ccw", skipped[0])
+	}
+}
+
+func TestNetworkGroupActivateStrict(t *testing.T) {
+	ng := withCcwContext(createMockConfiguredNetworkGroup("test_network", false))
+	ng.SetBuildOptions(control.BuildOptions{Strict: true})
+
+	if _, err := ng.Activate(); !errors.Is(err, control.ErrUnconvertedAction) {
+		t.Fatalf("Activate() error = %v, want ErrUnconvertedAction", err)
+	}
+	if ng.State() != StateConfigured {
+		t.Errorf("State() = %d after failed activation, want StateConfigured", ng.State())
+	}
+	if ng.BuildReport() == nil {
+		t.Error("BuildReport() = nil after failed activation")
+	}
+}
+
+func TestNetworkGroupBuildEdgesWithoutOperations(t *testing.T) {
+	ng := createMockConfiguredNetworkGroup("test_network", false)
+	edge := hef.EdgeLayer{Name: "out0", Direction: hef.StreamDirectionOutput, ConnectionType: hef.EdgeConnectionBoundary}
+	ng.info.Contexts = []hef.ContextConfig{{Index: 1, EdgeLayers: []hef.EdgeLayer{edge}}}
+
+	res := control.NewContextResources()
+	res.Edges[control.EdgeKey{Context: 1, Name: edge.Name, Direction: edge.Direction}] = control.EdgeChannel{Channel: 16}
+	ng.SetContextResources(res)
+
+	_, dynamic, _, err := ng.BuildActionLists()
+	if err != nil {
+		t.Fatalf("BuildActionLists() error = %v", err)
+	}
+	if bytes.Equal(dynamic[0], control.BuildEmptyActionList()) {
+		t.Error("context without operations lost its edge layer actions")
+	}
+}
+
+// ackFirmware acknowledges every control request
+type ackFirmware struct{}
+
+func (ackFirmware) FwControlContext(ctx context.Context, request []byte, md5sum [16]byte, timeoutMs uint32, cpuId driver.CpuId) ([]byte, [16]byte, error) {
+	response := make([]byte, control.ResponseHeaderSize)
+	binary.BigEndian.PutUint32(response[0:4], control.ProtocolVersion)
+	copy(response[8:16], request[8:16]) // sequence and opcode
+	return response, md5.Sum(response), nil
+}
+
+// The activation handshake replays byte for byte from a recording, and any
+// change to it shows up as a mismatch
+func TestNetworkGroupActivateReplay(t *testing.T) {
+	newGroup := func(transport control.Transport) *ConfiguredNetworkGroup {
+		ng := createMockConfiguredNetworkGroup("test_network", false)
+		ng.info.Contexts = []hef.ContextConfig{{
+			Operations: []hef.ConfigOperation{{Actions: []hef.ConfigAction{
+				{Type: hef.ActionTypeDisableLcu, DisableLcu: &hef.DisableLcuParams{}},
+			}}},
+		}}
+		ng.device = &Device{transport: transport}
+		return ng
+	}
+
+	var recording bytes.Buffer
+	ang, err := newGroup(control.NewRecordingTransport(ackFirmware{}, &recording)).Activate()
+	if err != nil {
+		t.Fatalf("recorded Activate() error = %v", err)
+	}
+	ang.Deactivate()
+
+	replay, err := control.NewReplayTransport(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ang, err = newGroup(replay).Activate()
+	if err != nil {
+		t.Fatalf("replayed Activate() error = %v", err)
+	}
+	ang.Deactivate()
+	if err := replay.Done(); err != nil {
+		t.Errorf("replay: %v", err)
+	}
+
+	replay, _ = control.NewReplayTransport(bytes.NewReader(recording.Bytes()))
+	ng := newGroup(replay)
+	ng.SetBatchSize(4)
+	if _, err := ng.Activate(); !errors.Is(err, control.ErrReplayMismatch) {
+		t.Errorf("Activate() with another batch size = %v, want ErrReplayMismatch", err)
+	}
+}
+
+// Network groups on one device and other control users share the device's
+// sequence counter
+func TestNetworkGroupsShareControlSequence(t *testing.T) {
+	var recording bytes.Buffer
+	dev := &Device{transport: control.NewRecordingTransport(ackFirmware{}, &recording)}
+	newGroup := func(name string) *ConfiguredNetworkGroup {
+		ng := createMockConfiguredNetworkGroup(name, false)
+		ng.device = dev
+		return ng
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			if err := dev.Control().ResetContextSwitchStateMachine(context.Background()); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for _, ng := range []*ConfiguredNetworkGroup{newGroup("first"), newGroup("second")} {
+		ang, err := ng.Activate()
+		if err != nil {
+			t.Fatalf("%s: Activate() error = %v", ng.Name(), err)
+		}
+		ang.Deactivate()
+	}
+	<-done
+
+	seen := make(map[uint32]bool)
+	dec := json.NewDecoder(&recording)
+	for dec.More() {
+		var rec control.ControlRecord
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		if seen[rec.Sequence] {
+			t.Fatalf("sequence %d sent twice", rec.Sequence)
+		}
+		seen[rec.Sequence] = true
+	}
+	if dev.Control().Sequence() != uint32(len(seen)) {
+		t.Errorf("Sequence() = %d after %d requests", dev.Control().Sequence(), len(seen))
+	}
+}
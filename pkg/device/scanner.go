@@ -1,24 +1,43 @@
 package device
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/anthropics/purple-hailo/pkg/control"
 	"github.com/anthropics/purple-hailo/pkg/driver"
 )
 
-// DeviceInfo contains discovered device information
+// DeviceInfo contains discovered device information. The fields after
+// BoardType are only set by Describe and Watch.
 type DeviceInfo struct {
 	Path      string
 	DeviceID  string
 	BoardType driver.BoardType
+
+	PCIeAddress    string // e.g. 0000:01:00.0, from sysfs
+	FirmwareLoaded bool
+	SerialNumber   string // empty if not queried or the firmware did not answer
+	Claimed        bool   // another process claimed it, so the firmware was not queried
+	Described      bool   // whether the device was opened and queried
 }
 
 // DeviceScanner scans for Hailo devices
 type DeviceScanner struct {
 	sysfsPath string
 	devPath   string
+
+	// IdentifyFirmware makes Describe ask the firmware of unclaimed devices
+	// for their serial number. Off by default: the query is a firmware
+	// control, and a process using the device without claiming it would
+	// see it interleaved with its own.
+	IdentifyFirmware bool
+
+	// describe replaces Describe in Watch; used by tests
+	describe func(DeviceInfo) DeviceInfo
 }
 
 // NewScanner creates a new device scanner
@@ -77,6 +96,65 @@ func (s *DeviceScanner) Scan() ([]DeviceInfo, error) {
 	return devices, nil
 }
 
+// Describe enriches a scanned device with its PCIe address from sysfs and,
+// if the device can be opened, its board type and firmware state. With
+// IdentifyFirmware set it also reads the serial number of devices no
+// process has claimed. Failures leave the corresponding fields empty.
+func (s *DeviceScanner) Describe(info DeviceInfo) DeviceInfo {
+	info.PCIeAddress = s.pcieAddress(info.DeviceID)
+
+	df, err := driver.OpenDevice(info.Path)
+	if err != nil {
+		return info
+	}
+	defer df.Close()
+
+	props, err := df.QueryDeviceProperties()
+	if err != nil {
+		return info
+	}
+	info.BoardType = props.BoardType
+	info.FirmwareLoaded = props.IsFwLoaded
+	info.Described = true
+
+	if s.IdentifyFirmware && props.IsFwLoaded {
+		info.SerialNumber, info.Claimed = identifyUnclaimed(df)
+	}
+	return info
+}
+
+// identifyUnclaimed reads the serial number of a device unless another
+// process claimed it. Describe holds the claim until it closes df, so no
+// stream setup can start under the query.
+func identifyUnclaimed(df *driver.DeviceFile) (serial string, claimed bool) {
+	inUse, err := df.MarkAsInUse()
+	if err != nil || inUse {
+		return "", inUse
+	}
+
+	identity, err := control.NewChannel(df, control.ChannelConfig{}).Identify(context.Background())
+	if err != nil {
+		return "", false
+	}
+	return identity.SerialNumber, false
+}
+
+// pcieAddress resolves the PCI device behind a hailo_chardev entry
+func (s *DeviceScanner) pcieAddress(name string) string {
+	entry := filepath.Join(s.sysfsPath, name)
+
+	// The device link points at the PCI function, named after its address
+	if target, err := filepath.EvalSymlinks(filepath.Join(entry, "device")); err == nil {
+		return filepath.Base(target)
+	}
+
+	// Older drivers expose it as an attribute instead
+	if data, err := os.ReadFile(filepath.Join(entry, "board_location")); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return ""
+}
+
 // ScanByType finds devices of a specific type
 func (s *DeviceScanner) ScanByType(boardType driver.BoardType) ([]DeviceInfo, error) {
 	all, err := s.Scan()
@@ -3,9 +3,11 @@
 package device
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestScanFindsDevicesInMockSysfs(t *testing.T) {
@@ -148,3 +150,126 @@ func TestNewScanner(t *testing.T) {
 		t.Errorf("unexpected dev path: %s", scanner.devPath)
 	}
 }
+
+func TestPCIeAddressFromSysfs(t *testing.T) {
+	tmpDir := t.TempDir()
+	sysfs := filepath.Join(tmpDir, "hailo_chardev")
+	pciDev := filepath.Join(tmpDir, "pci0000:00", "0000:01:00.0")
+	for _, dir := range []string{filepath.Join(sysfs, "hailo0"), filepath.Join(sysfs, "hailo1"), pciDev} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// hailo0 links to its PCI function, hailo1 only has the attribute
+	if err := os.Symlink(pciDev, filepath.Join(sysfs, "hailo0", "device")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(sysfs, "hailo1", "board_location"), []byte("0000:02:00.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := &DeviceScanner{sysfsPath: sysfs, devPath: tmpDir}
+	if got := scanner.pcieAddress("hailo0"); got != "0000:01:00.0" {
+		t.Errorf("pcieAddress(hailo0) = %q, want 0000:01:00.0", got)
+	}
+	if got := scanner.pcieAddress("hailo1"); got != "0000:02:00.0" {
+		t.Errorf("pcieAddress(hailo1) = %q, want 0000:02:00.0", got)
+	}
+	if got := scanner.pcieAddress("hailo2"); got != "" {
+		t.Errorf("pcieAddress(hailo2) = %q, want empty", got)
+	}
+}
+
+func TestDiffDevices(t *testing.T) {
+	known := make(map[string]DeviceInfo)
+	describe := func(info DeviceInfo) DeviceInfo {
+		info.Described = true
+		return info
+	}
+
+	events := diffDevices(known, []DeviceInfo{{Path: "/dev/hailo0"}, {Path: "/dev/hailo1"}}, describe)
+	if len(events) != 2 || events[0].Type != DeviceAdded || !events[0].Info.Described {
+		t.Fatalf("initial events = %+v", events)
+	}
+
+	// Unchanged scans report nothing
+	if events := diffDevices(known, []DeviceInfo{{Path: "/dev/hailo0"}, {Path: "/dev/hailo1"}}, describe); len(events) != 0 {
+		t.Errorf("unchanged scan events = %+v", events)
+	}
+
+	events = diffDevices(known, []DeviceInfo{{Path: "/dev/hailo1"}, {Path: "/dev/hailo2"}}, describe)
+	if len(events) != 2 {
+		t.Fatalf("events = %+v, want one removal and one addition", events)
+	}
+	if events[0].Type != DeviceRemoved || events[0].Info.Path != "/dev/hailo0" || !events[0].Info.Described {
+		t.Errorf("removal event = %+v", events[0])
+	}
+	if events[1].Type != DeviceAdded || events[1].Info.Path != "/dev/hailo2" {
+		t.Errorf("addition event = %+v", events[1])
+	}
+}
+
+func TestWatchReportsHotplug(t *testing.T) {
+	tmpDir := t.TempDir()
+	devDir := filepath.Join(tmpDir, "dev")
+	sysfs := filepath.Join(tmpDir, "hailo_chardev")
+	for _, dir := range []string{devDir, sysfs} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(devDir, "hailo0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := &DeviceScanner{
+		sysfsPath: sysfs,
+		devPath:   devDir,
+		describe:  func(info DeviceInfo) DeviceInfo { return info },
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := scanner.Watch(ctx)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	next := func() DeviceEvent {
+		t.Helper()
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("event channel closed")
+			}
+			return ev
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for device event")
+		}
+		return DeviceEvent{}
+	}
+
+	if ev := next(); ev.Type != DeviceAdded || ev.Info.DeviceID != "hailo0" {
+		t.Errorf("initial event = %+v, want hailo0 added", ev)
+	}
+
+	if err := os.WriteFile(filepath.Join(devDir, "hailo1"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); ev.Type != DeviceAdded || ev.Info.DeviceID != "hailo1" {
+		t.Errorf("event = %+v, want hailo1 added", ev)
+	}
+
+	if err := os.Remove(filepath.Join(devDir, "hailo0")); err != nil {
+		t.Fatal(err)
+	}
+	if ev := next(); ev.Type != DeviceRemoved || ev.Info.DeviceID != "hailo0" {
+		t.Errorf("event = %+v, want hailo0 removed", ev)
+	}
+
+	cancel()
+	for range events {
+	}
+}
@@ -0,0 +1,447 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// SupervisorConfig controls when a Supervisor trips and how it recovers
+type SupervisorConfig struct {
+	// TimeoutThreshold is the number of consecutive transfer timeouts or
+	// DMA errors that trips the supervisor (default 3)
+	TimeoutThreshold int
+
+	// ControlFailureThreshold is the number of consecutive firmware control
+	// failures that trips the supervisor (default 3)
+	ControlFailureThreshold int
+
+	// MaxAttempts is the number of reset attempts per recovery (default 3)
+	MaxAttempts int
+
+	// NnCoreResets is the number of attempts that only reset the NN core
+	// before escalating to a soft reset of the chip (default 1)
+	NnCoreResets int
+
+	// DisableSoftReset keeps recovery to NN core resets
+	DisableSoftReset bool
+
+	// RetryBackoff is the delay before the second attempt, doubled for
+	// each further attempt (default 1s)
+	RetryBackoff time.Duration
+
+	// MaxRetries is the number of times Do re-runs an operation after a
+	// successful recovery (default 1)
+	MaxRetries int
+
+	// OnTrip is called with the failure that tripped the supervisor
+	OnTrip func(reason error)
+
+	// OnRecovered is called after the device was reset, the network
+	// groups re-activated and the recovery hooks run, before Do retries
+	OnRecovered func()
+
+	// OnRecoveryFailed is called when a recovery started by a health
+	// notification fails; Do returns the failure to its caller instead
+	OnRecoveryFailed func(err error)
+
+	// OnNotification is called for every notification the watcher reads
+	OnNotification func(n *control.Notification)
+}
+
+// DefaultSupervisorConfig returns the default supervisor configuration
+func DefaultSupervisorConfig() SupervisorConfig {
+	return SupervisorConfig{
+		TimeoutThreshold:        3,
+		ControlFailureThreshold: 3,
+		MaxAttempts:             3,
+		NnCoreResets:            1,
+		RetryBackoff:            time.Second,
+		MaxRetries:              1,
+	}
+}
+
+// SupervisorStats counts supervisor activity
+type SupervisorStats struct {
+	TransferFailures int // consecutive, reset on success
+	ControlFailures  int // consecutive, reset on success
+	Trips            int
+	Recoveries       int
+	FailedRecoveries int
+	LastError        error // failure that last tripped the supervisor
+	RecoveryError    error // failure of the last failed recovery
+}
+
+// HealthEventError is a fatal health notification from the firmware
+type HealthEventError struct {
+	Notification *control.Notification
+}
+
+// Error implements the error interface
+func (e *HealthEventError) Error() string {
+	return fmt.Sprintf("device health event: %s", e.Notification)
+}
+
+// failureKind classifies errors reported to the supervisor
+type failureKind int
+
+const (
+	failureNone failureKind = iota
+	failureTransfer
+	failureControl
+	failureHealth
+)
+
+// classify decides which counter an error feeds. An error caused by the
+// caller's context expiring or being canceled says nothing about the device,
+// even though the driver reports it as a timeout.
+func classify(err error) failureKind {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return failureNone
+	}
+
+	var healthErr *HealthEventError
+	if errors.As(err, &healthErr) {
+		return failureHealth
+	}
+
+	var chErr *driver.VdmaChannelError
+	if errors.As(err, &chErr) {
+		return failureTransfer
+	}
+
+	var hailoErr *driver.HailoError
+	if errors.As(err, &hailoErr) {
+		switch hailoErr.Status {
+		case driver.StatusTimeout, driver.StatusDriverTimeout:
+			return failureTransfer
+		case driver.StatusFirmwareControlFailure:
+			return failureControl
+		}
+	}
+	return failureNone
+}
+
+// Supervisor watches a device for signs that it is wedged: repeated
+// transfer timeouts, firmware control failures and fatal health
+// notifications. When it trips, it resets the NN core (escalating to a soft
+// reset of the chip) and loads the previously activated network groups
+// into the firmware again.
+type Supervisor struct {
+	dev *Device
+	cfg SupervisorConfig
+
+	// reset and sleep are replaced in tests
+	reset func(soft bool) error
+	sleep func(time.Duration)
+
+	mu        sync.Mutex
+	recoverMu sync.Mutex // serializes recoveries
+	stats     SupervisorStats
+	hooks     map[int]func() error
+	nextHook  int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSupervisor creates a supervisor for a device. Zero config fields take
+// their defaults.
+func NewSupervisor(dev *Device, cfg SupervisorConfig) *Supervisor {
+	def := DefaultSupervisorConfig()
+	if cfg.TimeoutThreshold <= 0 {
+		cfg.TimeoutThreshold = def.TimeoutThreshold
+	}
+	if cfg.ControlFailureThreshold <= 0 {
+		cfg.ControlFailureThreshold = def.ControlFailureThreshold
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = def.MaxAttempts
+	}
+	if cfg.NnCoreResets <= 0 {
+		cfg.NnCoreResets = def.NnCoreResets
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = def.RetryBackoff
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = def.MaxRetries
+	}
+
+	s := &Supervisor{
+		dev:   dev,
+		cfg:   cfg,
+		sleep: time.Sleep,
+	}
+	s.reset = s.resetDevice
+	return s
+}
+
+// Stats returns a snapshot of the supervisor counters
+func (s *Supervisor) Stats() SupervisorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// AddRecoveryHook registers fn to run once the device was reset and its
+// network groups re-activated. Streams on the device register a hook that
+// resets their channels; a failing hook fails the attempt. The returned
+// function unregisters fn.
+func (s *Supervisor) AddRecoveryHook(fn func() error) (remove func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hooks == nil {
+		s.hooks = make(map[int]func() error)
+	}
+	id := s.nextHook
+	s.nextHook++
+	s.hooks[id] = fn
+
+	return func() {
+		s.mu.Lock()
+		delete(s.hooks, id)
+		s.mu.Unlock()
+	}
+}
+
+// Report feeds the result of a device operation to the supervisor and
+// returns whether it tripped. A nil error resets the failure counters;
+// errors that do not indicate a wedged device are ignored.
+func (s *Supervisor) Report(err error) bool {
+	s.mu.Lock()
+	if err == nil {
+		s.stats.TransferFailures = 0
+		s.stats.ControlFailures = 0
+		s.mu.Unlock()
+		return false
+	}
+
+	tripped := false
+	switch classify(err) {
+	case failureTransfer:
+		s.stats.TransferFailures++
+		tripped = s.stats.TransferFailures >= s.cfg.TimeoutThreshold
+	case failureControl:
+		s.stats.ControlFailures++
+		tripped = s.stats.ControlFailures >= s.cfg.ControlFailureThreshold
+	case failureHealth:
+		tripped = true
+	}
+
+	if tripped {
+		s.stats.Trips++
+		s.stats.LastError = err
+	}
+	s.mu.Unlock()
+
+	if tripped && s.cfg.OnTrip != nil {
+		s.cfg.OnTrip(err)
+	}
+	return tripped
+}
+
+// ReportControlFailure counts a firmware control failure that did not come
+// back as a driver.HailoError
+func (s *Supervisor) ReportControlFailure(err error) bool {
+	return s.Report(driver.NewErrorWithCause(driver.StatusFirmwareControlFailure, "control", err))
+}
+
+// Recover resets the device and re-activates every network group that was
+// activated, retrying with exponential backoff up to MaxAttempts times
+func (s *Supervisor) Recover() error {
+	s.recoverMu.Lock()
+	defer s.recoverMu.Unlock()
+
+	var active []*ConfiguredNetworkGroup
+	for _, ng := range s.dev.NetworkGroups() {
+		if ng.State() == StateActivated {
+			active = append(active, ng)
+		}
+	}
+
+	backoff := s.cfg.RetryBackoff
+	var lastErr error
+	for attempt := 1; attempt <= s.cfg.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			s.sleep(backoff)
+			backoff *= 2
+		}
+
+		soft := attempt > s.cfg.NnCoreResets && !s.cfg.DisableSoftReset
+		lastErr = s.recoverOnce(active, soft)
+		if lastErr == nil {
+			lastErr = s.runHooks()
+		}
+		if lastErr == nil {
+			s.mu.Lock()
+			s.stats.Recoveries++
+			s.stats.TransferFailures = 0
+			s.stats.ControlFailures = 0
+			s.mu.Unlock()
+
+			if s.cfg.OnRecovered != nil {
+				s.cfg.OnRecovered()
+			}
+			return nil
+		}
+	}
+
+	err := fmt.Errorf("%w after %d attempts: %w", ErrRecoveryFailed, s.cfg.MaxAttempts, lastErr)
+	s.mu.Lock()
+	s.stats.FailedRecoveries++
+	s.stats.RecoveryError = err
+	s.mu.Unlock()
+	return err
+}
+
+// recoverOnce performs one reset and reloads the network groups
+func (s *Supervisor) recoverOnce(groups []*ConfiguredNetworkGroup, soft bool) error {
+	if err := s.reset(soft); err != nil {
+		return err
+	}
+
+	for _, ng := range groups {
+		if err := ng.reactivate(); err != nil {
+			return fmt.Errorf("failed to re-activate network group %s: %w", ng.Name(), err)
+		}
+	}
+	return nil
+}
+
+// runHooks runs the recovery hooks without holding mu
+func (s *Supervisor) runHooks() error {
+	s.mu.Lock()
+	hooks := make([]func() error, 0, len(s.hooks))
+	for _, hook := range s.hooks {
+		hooks = append(hooks, hook)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := hook(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("recovery hook failed: %w", err)
+	}
+	return nil
+}
+
+// resetDevice resets the NN core through the driver, or soft-resets the
+// chip through the firmware
+func (s *Supervisor) resetDevice(soft bool) error {
+	if !soft {
+		if err := s.dev.ResetNnCore(); err != nil {
+			return fmt.Errorf("NN core reset failed: %w", err)
+		}
+		return nil
+	}
+
+	channel := s.dev.Control()
+	if channel == nil {
+		return fmt.Errorf("soft reset failed: device has no control transport")
+	}
+	if err := channel.Reset(context.Background(), control.ResetTypeSoft); err != nil {
+		return fmt.Errorf("soft reset failed: %w", err)
+	}
+	return nil
+}
+
+// Do runs a device operation under supervision. When the operation trips
+// the supervisor, the device is recovered and the operation run again, up
+// to MaxRetries times.
+func (s *Supervisor) Do(fn func() error) error {
+	for retry := 0; ; retry++ {
+		err := fn()
+		if !s.Report(err) || retry >= s.cfg.MaxRetries {
+			return err
+		}
+		if rerr := s.Recover(); rerr != nil {
+			return fmt.Errorf("%w (after %v)", rerr, err)
+		}
+	}
+}
+
+// Start watches device notifications in the background until ctx is done
+// or Close is called. Fatal health events trip the supervisor and recover
+// the device.
+func (s *Supervisor) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done != nil {
+		return fmt.Errorf("supervisor already started")
+	}
+	if s.dev == nil || s.dev.DeviceFile() == nil {
+		return ErrDeviceClosed
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.watch(ctx, s.done)
+	return nil
+}
+
+// watch reads notifications until the driver stops delivering them
+func (s *Supervisor) watch(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	stop := context.AfterFunc(ctx, func() {
+		s.dev.DeviceFile().DisableNotification()
+	})
+	defer stop()
+
+	for ctx.Err() == nil {
+		data, err := s.dev.DeviceFile().ReadNotification()
+		if err != nil {
+			return
+		}
+
+		n, err := control.ParseNotification(data)
+		if err != nil {
+			continue
+		}
+		s.handleNotification(n)
+	}
+}
+
+// handleNotification reports fatal health events and recovers. Nobody
+// waits on the watcher, so a failed recovery goes to OnRecoveryFailed.
+func (s *Supervisor) handleNotification(n *control.Notification) {
+	if s.cfg.OnNotification != nil {
+		s.cfg.OnNotification(n)
+	}
+	if !n.IsFatal() {
+		return
+	}
+	if !s.Report(&HealthEventError{Notification: n}) {
+		return
+	}
+	if err := s.Recover(); err != nil && s.cfg.OnRecoveryFailed != nil {
+		s.cfg.OnRecoveryFailed(err)
+	}
+}
+
+// Close stops the notification watcher
+func (s *Supervisor) Close() error {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if done == nil {
+		return nil
+	}
+	cancel()
+	<-done
+	return nil
+}
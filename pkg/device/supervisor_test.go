@@ -0,0 +1,314 @@
+//go:build unit
+
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// newTestSupervisor creates a supervisor on a mock device whose resets are
+// recorded instead of sent to hardware
+func newTestSupervisor(cfg SupervisorConfig, resetErrs ...error) (*Supervisor, *[]bool, *[]time.Duration) {
+	s := NewSupervisor(&Device{}, cfg)
+
+	var resets []bool
+	var sleeps []time.Duration
+	s.reset = func(soft bool) error {
+		resets = append(resets, soft)
+		if len(resetErrs) > 0 {
+			err := resetErrs[0]
+			resetErrs = resetErrs[1:]
+			return err
+		}
+		return nil
+	}
+	s.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return s, &resets, &sleeps
+}
+
+func TestSupervisorClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want failureKind
+	}{
+		{"timeout", driver.NewError(driver.StatusTimeout, "wait"), failureTransfer},
+		{"driver timeout", fmt.Errorf("read: %w", driver.NewError(driver.StatusDriverTimeout, "ioctl")), failureTransfer},
+		{"channel error", &driver.VdmaChannelError{Kind: driver.ErrVdmaAxi}, failureTransfer},
+		{"control", driver.NewError(driver.StatusFirmwareControlFailure, "fw"), failureControl},
+		{"health", &HealthEventError{Notification: &control.Notification{EventID: control.EventCpuEccFatal}}, failureHealth},
+		{"invalid argument", driver.NewError(driver.StatusInvalidArgument, "x"), failureNone},
+		{"caller deadline", driver.NewErrorWithCause(driver.StatusTimeout, "interrupts wait", context.DeadlineExceeded), failureNone},
+		{"caller canceled", driver.NewErrorWithCause(driver.StatusDriverWaitCanceled, "firmware control", context.Canceled), failureNone},
+		{"plain", errors.New("bad frame size"), failureNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classify(tt.err); got != tt.want {
+				t.Errorf("classify() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSupervisorTripsAtThreshold(t *testing.T) {
+	s, _, _ := newTestSupervisor(SupervisorConfig{TimeoutThreshold: 3})
+	timeout := driver.NewError(driver.StatusTimeout, "wait")
+
+	for i := 0; i < 2; i++ {
+		if s.Report(timeout) {
+			t.Fatalf("tripped after %d failures", i+1)
+		}
+	}
+
+	// A success clears the streak
+	s.Report(nil)
+	if got := s.Stats().TransferFailures; got != 0 {
+		t.Fatalf("TransferFailures after success = %d, want 0", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		s.Report(timeout)
+	}
+	if !s.Report(timeout) {
+		t.Fatal("expected trip on third consecutive failure")
+	}
+
+	stats := s.Stats()
+	if stats.Trips != 1 || stats.LastError != timeout {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestSupervisorIgnoresUnrelatedErrors(t *testing.T) {
+	s, _, _ := newTestSupervisor(SupervisorConfig{TimeoutThreshold: 1, ControlFailureThreshold: 1})
+
+	if s.Report(errors.New("bad input")) {
+		t.Error("unrelated error tripped the supervisor")
+	}
+	if !s.ReportControlFailure(errors.New("bad response")) {
+		t.Error("control failure did not trip at threshold 1")
+	}
+}
+
+func TestSupervisorIgnoresCallerDeadline(t *testing.T) {
+	s, resets, _ := newTestSupervisor(SupervisorConfig{TimeoutThreshold: 1, MaxRetries: 1})
+
+	// A client that gives the device only a millisecond sees its deadline
+	// reported as a driver timeout
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	callerTimeout := driver.NewErrorWithCause(driver.StatusTimeout, "interrupts wait", ctx.Err())
+
+	for i := 0; i < 3; i++ {
+		if err := s.Do(func() error { return callerTimeout }); err != callerTimeout {
+			t.Fatalf("Do() error = %v, want %v", err, callerTimeout)
+		}
+	}
+	if len(*resets) != 0 {
+		t.Errorf("caller deadline reset the device %d times", len(*resets))
+	}
+	if stats := s.Stats(); stats.Trips != 0 || stats.TransferFailures != 0 {
+		t.Errorf("stats = %+v, want no failures", stats)
+	}
+}
+
+func TestSupervisorRecoverReactivates(t *testing.T) {
+	s, resets, _ := newTestSupervisor(SupervisorConfig{})
+
+	active := createMockConfiguredNetworkGroup("active", false)
+	active.device = s.dev
+	if _, err := active.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	idle := createMockConfiguredNetworkGroup("idle", false)
+	idle.device = s.dev
+	s.dev.groups = []*ConfiguredNetworkGroup{active, idle}
+
+	recovered := false
+	s.cfg.OnRecovered = func() { recovered = true }
+
+	if err := s.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+
+	if len(*resets) != 1 || (*resets)[0] {
+		t.Errorf("resets = %v, want a single NN core reset", *resets)
+	}
+	if active.State() != StateActivated {
+		t.Errorf("active group state = %d, want StateActivated", active.State())
+	}
+	if idle.State() != StateConfigured {
+		t.Errorf("idle group state = %d, want StateConfigured", idle.State())
+	}
+	if !recovered {
+		t.Error("OnRecovered was not called")
+	}
+	if got := s.Stats().Recoveries; got != 1 {
+		t.Errorf("Recoveries = %d, want 1", got)
+	}
+}
+
+func TestSupervisorRecoverEscalatesWithBackoff(t *testing.T) {
+	resetErr := errors.New("reset failed")
+	s, resets, sleeps := newTestSupervisor(SupervisorConfig{
+		MaxAttempts:  3,
+		NnCoreResets: 1,
+		RetryBackoff: 100 * time.Millisecond,
+	}, resetErr, resetErr, resetErr)
+
+	err := s.Recover()
+	if !errors.Is(err, ErrRecoveryFailed) || !errors.Is(err, resetErr) {
+		t.Fatalf("Recover error = %v, want ErrRecoveryFailed wrapping the reset error", err)
+	}
+
+	wantResets := []bool{false, true, true}
+	if fmt.Sprint(*resets) != fmt.Sprint(wantResets) {
+		t.Errorf("resets (soft?) = %v, want %v", *resets, wantResets)
+	}
+	wantSleeps := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}
+	if fmt.Sprint(*sleeps) != fmt.Sprint(wantSleeps) {
+		t.Errorf("sleeps = %v, want %v", *sleeps, wantSleeps)
+	}
+	if got := s.Stats().FailedRecoveries; got != 1 {
+		t.Errorf("FailedRecoveries = %d, want 1", got)
+	}
+}
+
+func TestSupervisorDisableSoftReset(t *testing.T) {
+	resetErr := errors.New("reset failed")
+	s, resets, _ := newTestSupervisor(SupervisorConfig{MaxAttempts: 2, DisableSoftReset: true}, resetErr)
+
+	if err := s.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if fmt.Sprint(*resets) != "[false false]" {
+		t.Errorf("resets = %v, want two NN core resets", *resets)
+	}
+}
+
+func TestSupervisorDoRetriesAfterRecovery(t *testing.T) {
+	s, resets, _ := newTestSupervisor(SupervisorConfig{TimeoutThreshold: 1, MaxRetries: 1})
+	timeout := driver.NewError(driver.StatusTimeout, "wait")
+
+	calls := 0
+	err := s.Do(func() error {
+		calls++
+		if calls == 1 {
+			return timeout
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if calls != 2 || len(*resets) != 1 {
+		t.Errorf("calls = %d, resets = %d, want 2 and 1", calls, len(*resets))
+	}
+
+	// Retries are bounded
+	calls = 0
+	err = s.Do(func() error {
+		calls++
+		return timeout
+	})
+	if err != timeout {
+		t.Errorf("Do error = %v, want the timeout", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+}
+
+func TestSupervisorFatalNotification(t *testing.T) {
+	s, resets, _ := newTestSupervisor(SupervisorConfig{})
+
+	var seen []uint32
+	s.cfg.OnNotification = func(n *control.Notification) { seen = append(seen, n.EventID) }
+
+	s.handleNotification(&control.Notification{EventID: control.EventTemperatureAlarm})
+	if len(*resets) != 0 {
+		t.Fatal("non-fatal notification caused a reset")
+	}
+
+	s.handleNotification(&control.Notification{EventID: control.EventContextSwitchRunTimeError})
+	if len(*resets) != 1 {
+		t.Errorf("resets = %d after fatal notification, want 1", len(*resets))
+	}
+	if len(seen) != 2 {
+		t.Errorf("OnNotification called %d times, want 2", len(seen))
+	}
+}
+
+func TestSupervisorFailedNotificationRecovery(t *testing.T) {
+	resetErr := errors.New("reset failed")
+	s, _, _ := newTestSupervisor(SupervisorConfig{MaxAttempts: 1}, resetErr)
+
+	var failed error
+	s.cfg.OnRecoveryFailed = func(err error) { failed = err }
+
+	s.handleNotification(&control.Notification{EventID: control.EventContextSwitchRunTimeError})
+	if !errors.Is(failed, ErrRecoveryFailed) || !errors.Is(failed, resetErr) {
+		t.Errorf("OnRecoveryFailed got %v, want ErrRecoveryFailed wrapping the reset error", failed)
+	}
+	stats := s.Stats()
+	if stats.FailedRecoveries != 1 || stats.RecoveryError != failed {
+		t.Errorf("stats = %+v, want one failed recovery and its error", stats)
+	}
+}
+
+func TestSupervisorRecoveryHooks(t *testing.T) {
+	s, resets, _ := newTestSupervisor(SupervisorConfig{MaxAttempts: 2})
+
+	var calls []string
+	s.cfg.OnRecovered = func() { calls = append(calls, "config") }
+	remove := s.AddRecoveryHook(func() error {
+		calls = append(calls, "hook")
+		return nil
+	})
+
+	if err := s.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if fmt.Sprint(calls) != "[hook config]" {
+		t.Errorf("calls = %v, want the hook then OnRecovered", calls)
+	}
+
+	remove()
+	calls = nil
+	if err := s.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if fmt.Sprint(calls) != "[config]" {
+		t.Errorf("calls = %v after removing the hook, want only OnRecovered", calls)
+	}
+
+	// A failing hook fails the attempt and the next one resets again
+	hookErr := errors.New("stream reset failed")
+	failures := 1
+	s.AddRecoveryHook(func() error {
+		if failures > 0 {
+			failures--
+			return hookErr
+		}
+		return nil
+	})
+	*resets = nil
+	if err := s.Recover(); err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if len(*resets) != 2 {
+		t.Errorf("resets = %d, want 2 after a failed hook", len(*resets))
+	}
+}
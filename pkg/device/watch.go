@@ -0,0 +1,158 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// DeviceEventType tells whether a device appeared or went away
+type DeviceEventType int
+
+const (
+	DeviceAdded DeviceEventType = iota
+	DeviceRemoved
+)
+
+// String returns the event type name
+func (t DeviceEventType) String() string {
+	if t == DeviceAdded {
+		return "added"
+	}
+	return "removed"
+}
+
+// DeviceEvent reports a Hailo device being added or removed
+type DeviceEvent struct {
+	Type DeviceEventType
+	Info DeviceInfo
+}
+
+// watchRescanInterval bounds how long a missed inotify event goes
+// unnoticed; sysfs does not report every change through inotify
+const watchRescanInterval = 2 * time.Second
+
+// Watch reports Hailo devices as they are added and removed, using the
+// default scanner
+func Watch(ctx context.Context) (<-chan DeviceEvent, error) {
+	return NewScanner().Watch(ctx)
+}
+
+// Watch reports Hailo devices as they are added and removed. Devices
+// present when the watch starts are reported as added first. Added events
+// carry the result of Describe. The channel is closed when ctx is done.
+func (s *DeviceScanner) Watch(ctx context.Context) (<-chan DeviceEvent, error) {
+	if _, err := s.Scan(); err != nil { // also applies path defaults
+		return nil, err
+	}
+
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init failed: %w", err)
+	}
+
+	// /dev must be watchable; sysfs is best effort
+	const mask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_ATTRIB | unix.IN_MOVED_TO | unix.IN_MOVED_FROM
+	if _, err := unix.InotifyAddWatch(fd, s.devPath, mask); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to watch %s: %w", s.devPath, err)
+	}
+	// The sysfs class is missing on some drivers; the periodic rescan
+	// notices changes without the watch
+	_, _ = unix.InotifyAddWatch(fd, s.sysfsPath, mask)
+
+	events := make(chan DeviceEvent)
+	go s.watch(ctx, fd, events)
+	return events, nil
+}
+
+// watch rescans on every inotify wake-up and on a timer, reporting the
+// difference to the last scan
+func (s *DeviceScanner) watch(ctx context.Context, fd int, events chan<- DeviceEvent) {
+	defer close(events)
+	defer unix.Close(fd)
+
+	describe := s.describe
+	if describe == nil {
+		describe = s.Describe
+	}
+
+	known := make(map[string]DeviceInfo)
+	buf := make([]byte, 4096)
+	rescan := true
+	var nextScan time.Time
+
+	for {
+		if rescan || !time.Now().Before(nextScan) {
+			if devices, err := s.Scan(); err == nil {
+				for _, ev := range diffDevices(known, devices, describe) {
+					select {
+					case events <- ev:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+			rescan = false
+			nextScan = time.Now().Add(watchRescanInterval)
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Short poll timeouts keep cancellation responsive
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		n, err := unix.Poll(fds, 200)
+		if err != nil && !errors.Is(err, unix.EINTR) {
+			return
+		}
+		if n > 0 {
+			// Drain the queue; any change triggers a rescan, so the event
+			// names do not matter
+			for {
+				if _, err := unix.Read(fd, buf); err != nil {
+					break
+				}
+			}
+			rescan = true
+		}
+	}
+}
+
+// diffDevices updates known with a fresh scan and returns the events that
+// turn the old set into the new one, removals first
+func diffDevices(known map[string]DeviceInfo, scanned []DeviceInfo, describe func(DeviceInfo) DeviceInfo) []DeviceEvent {
+	present := make(map[string]bool, len(scanned))
+	for _, info := range scanned {
+		present[info.Path] = true
+	}
+
+	var removed []string
+	for path := range known {
+		if !present[path] {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+
+	var events []DeviceEvent
+	for _, path := range removed {
+		events = append(events, DeviceEvent{Type: DeviceRemoved, Info: known[path]})
+		delete(known, path)
+	}
+
+	for _, info := range scanned {
+		if _, ok := known[info.Path]; ok {
+			continue
+		}
+		info = describe(info)
+		known[info.Path] = info
+		events = append(events, DeviceEvent{Type: DeviceAdded, Info: info})
+	}
+	return events
+}
@@ -1,5 +1,7 @@
 package driver
 
+import "fmt"
+
 // IOCTL Magic Values - must match hailo_ioctl_common.h
 const (
 	HailoGeneralIoctlMagic = 'g' // 0x67
@@ -54,6 +56,23 @@ const (
 	BoardTypeMars          BoardType = 5
 )
 
+var boardTypeNames = map[BoardType]string{
+	BoardTypeHailo8:        "Hailo-8",
+	BoardTypeHailo15:       "Hailo-15",
+	BoardTypeHailo15L:      "Hailo-15L",
+	BoardTypeHailo10H:      "Hailo-10H",
+	BoardTypeHailo10Legacy: "Hailo-10 (legacy)",
+	BoardTypeMars:          "Mars",
+}
+
+// String returns the board name
+func (b BoardType) String() string {
+	if name, ok := boardTypeNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("BoardType(%d)", uint32(b))
+}
+
 // DmaType represents the DMA interface type
 type DmaType uint32
 
@@ -2,6 +2,7 @@ package driver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -121,6 +122,17 @@ func (d *DeviceFile) ioctlWithTimeout(ctx context.Context, cmd uint32, arg unsaf
 	}
 }
 
+// contextError converts the error of a done context into a HailoError:
+// an expired deadline is a timeout, a cancellation an aborted wait. The
+// context error stays reachable with errors.Is.
+func contextError(ctx context.Context, op string) error {
+	status := StatusDriverWaitCanceled
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		status = StatusTimeout
+	}
+	return NewErrorWithCause(status, op, ctx.Err())
+}
+
 // IOCTL command codes (calculated from type and size)
 // Note: Hailo driver uses _IOW_ for query operations (counterintuitive but matches driver source)
 // IMPORTANT: Use packed struct sizes to match C structs with #pragma pack(1)
@@ -140,9 +152,16 @@ var (
 	ioctlDescListProgram     = IoR(int(HailoVdmaIoctlMagic), IoctlDescListProgram, SizeOfPackedDescListProgramParams)
 	ioctlVdmaLaunchTransfer  = IoWR(int(HailoVdmaIoctlMagic), IoctlVdmaLaunchTransfer, SizeOfPackedVdmaLaunchTransferParams) // _IOWR_ in 4.20.0
 
+	ioctlVdmaLowMemoryBufferAlloc  = IoWR(int(HailoVdmaIoctlMagic), IoctlVdmaLowMemoryBufferAlloc, SizeOfAllocateLowMemoryBufferParams)
+	ioctlVdmaLowMemoryBufferFree   = IoR(int(HailoVdmaIoctlMagic), IoctlVdmaLowMemoryBufferFree, SizeOfFreeLowMemoryBufferParams)
+	ioctlVdmaMarkAsInUse           = IoW(int(HailoVdmaIoctlMagic), IoctlMarkAsInUse, SizeOfMarkAsInUseParams)
+	ioctlVdmaContinuousBufferAlloc = IoWR(int(HailoVdmaIoctlMagic), IoctlVdmaContinuousBufferAlloc, SizeOfAllocateContinuousBufferParams)
+	ioctlVdmaContinuousBufferFree  = IoR(int(HailoVdmaIoctlMagic), IoctlVdmaContinuousBufferFree, SizeOfFreeContinuousBufferParams)
+
 	ioctlFwControl         = IoWR(int(HailoNncIoctlMagic), IoctlFwControl, SizeOfFwControl)
 	ioctlReadNotification  = IoW(int(HailoNncIoctlMagic), IoctlReadNotification, SizeOfD2hNotification)
 	ioctlResetNnCore       = Io(int(HailoNncIoctlMagic), IoctlResetNnCore)
+	ioctlDisableNotification = Io(int(HailoNncIoctlMagic), IoctlDisableNotification)
 	ioctlWriteActionList   = IoWR(int(HailoNncIoctlMagic), IoctlWriteActionList, SizeOfPackedWriteActionListParams)
 )
 
@@ -219,6 +238,35 @@ func (d *DeviceFile) VdmaInterruptsWaitWithTimeout(channelsBitmap [MaxVdmaEngine
 	return params, nil
 }
 
+// VdmaInterruptsWaitContext waits for VDMA interrupts until ctx is done.
+// On cancellation the channels in the bitmap are disabled, which makes the
+// driver abort the wait, so no ioctl outlives the call; the caller must
+// enable the channels again before launching new transfers.
+func (d *DeviceFile) VdmaInterruptsWaitContext(ctx context.Context, channelsBitmap [MaxVdmaEngines]uint32) (*PackedVdmaInterruptsWaitParams, error) {
+	if ctx.Err() != nil {
+		return nil, contextError(ctx, "interrupts wait")
+	}
+
+	params := NewPackedVdmaInterruptsWaitParams(channelsBitmap)
+	done := make(chan error, 1)
+	go func() {
+		done <- d.ioctl(ioctlVdmaInterruptsWait, unsafe.Pointer(params))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, err
+		}
+		return params, nil
+	case <-ctx.Done():
+	}
+
+	d.VdmaDisableChannels(channelsBitmap)
+	<-done
+	return nil, contextError(ctx, "interrupts wait")
+}
+
 // VdmaBufferMap maps a user buffer for DMA
 func (d *DeviceFile) VdmaBufferMap(userAddr uintptr, size uint64, direction DmaDataDirection, bufferType DmaBufferType) (uint64, error) {
 	params := NewPackedVdmaBufferMapParams(userAddr, size, direction, bufferType, ^uintptr(0))
@@ -229,6 +277,17 @@ func (d *DeviceFile) VdmaBufferMap(userAddr uintptr, size uint64, direction DmaD
 	return params.MappedHandle(), nil
 }
 
+// VdmaBufferMapAllocated maps a buffer obtained from VdmaLowMemoryBufferAlloc
+// and mmapped with MmapBuffer
+func (d *DeviceFile) VdmaBufferMapAllocated(userAddr uintptr, size uint64, direction DmaDataDirection, allocHandle uintptr) (uint64, error) {
+	params := NewPackedVdmaBufferMapParams(userAddr, size, direction, DmaUserPtrBuffer, allocHandle)
+	err := d.ioctl(ioctlVdmaBufferMap, unsafe.Pointer(params))
+	if err != nil {
+		return 0, err
+	}
+	return params.MappedHandle(), nil
+}
+
 // VdmaBufferUnmap unmaps a previously mapped buffer
 func (d *DeviceFile) VdmaBufferUnmap(handle uint64) error {
 	params := NewPackedVdmaBufferUnmapParams(handle)
@@ -241,6 +300,61 @@ func (d *DeviceFile) VdmaBufferSync(handle uint64, syncType BufferSyncType, offs
 	return d.ioctl(ioctlVdmaBufferSync, unsafe.Pointer(params))
 }
 
+// VdmaLowMemoryBufferAlloc allocates a DMA buffer from driver memory, for
+// hosts whose scatter-gather mappings fail (AllocationModeDriver)
+func (d *DeviceFile) VdmaLowMemoryBufferAlloc(size uint64) (uintptr, error) {
+	params := AllocateLowMemoryBufferParams{BufferSize: size}
+	err := d.ioctl(ioctlVdmaLowMemoryBufferAlloc, unsafe.Pointer(&params))
+	if err != nil {
+		return 0, err
+	}
+	return params.BufferHandle, nil
+}
+
+// VdmaLowMemoryBufferFree frees a buffer from VdmaLowMemoryBufferAlloc
+func (d *DeviceFile) VdmaLowMemoryBufferFree(handle uintptr) error {
+	params := FreeLowMemoryBufferParams{BufferHandle: handle}
+	return d.ioctl(ioctlVdmaLowMemoryBufferFree, unsafe.Pointer(&params))
+}
+
+// VdmaContinuousBufferAlloc allocates a physically contiguous DMA buffer
+// and returns its handle and device address
+func (d *DeviceFile) VdmaContinuousBufferAlloc(size uint64) (uintptr, uint64, error) {
+	params := AllocateContinuousBufferParams{BufferSize: size}
+	err := d.ioctl(ioctlVdmaContinuousBufferAlloc, unsafe.Pointer(&params))
+	if err != nil {
+		return 0, 0, err
+	}
+	return params.BufferHandle, params.DmaAddress, nil
+}
+
+// VdmaContinuousBufferFree frees a buffer from VdmaContinuousBufferAlloc
+func (d *DeviceFile) VdmaContinuousBufferFree(handle uintptr) error {
+	params := FreeContinuousBufferParams{BufferHandle: handle}
+	return d.ioctl(ioctlVdmaContinuousBufferFree, unsafe.Pointer(&params))
+}
+
+// MmapBuffer maps a driver-allocated buffer into the process. The driver
+// uses the buffer handle as the mmap offset on the device file.
+func (d *DeviceFile) MmapBuffer(handle uintptr, size uint64) ([]byte, error) {
+	data, err := unix.Mmap(d.fd, int64(handle), int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, NewErrorWithCause(StatusDriverOperationFailed, "mmap driver buffer", err)
+	}
+	return data, nil
+}
+
+// MarkAsInUse claims the device for this file descriptor and reports
+// whether another descriptor already held it. The claim is released when
+// the descriptor is closed.
+func (d *DeviceFile) MarkAsInUse() (alreadyInUse bool, err error) {
+	var params MarkAsInUseParams
+	if err := d.ioctl(ioctlVdmaMarkAsInUse, unsafe.Pointer(&params)); err != nil {
+		return false, err
+	}
+	return params.InUse, nil
+}
+
 // DescListCreate creates a descriptor list
 func (d *DeviceFile) DescListCreate(descCount uint64, pageSize uint16, isCircular bool) (uintptr, uint64, error) {
 	params := NewPackedDescListCreateParams(descCount, pageSize, isCircular)
@@ -282,6 +396,47 @@ func (d *DeviceFile) FwControl(request []byte, md5 [16]byte, timeoutMs uint32, c
 	return response, params.ExpectedMd5, nil
 }
 
+// FwControlContext sends a firmware control message, giving up when ctx is
+// done. The firmware timeout is shortened to the context deadline. A
+// control cannot be aborted once sent: after cancellation it completes in
+// the background within the timeout, and the driver holds later controls
+// until then.
+func (d *DeviceFile) FwControlContext(ctx context.Context, request []byte, md5 [16]byte, timeoutMs uint32, cpuId CpuId) ([]byte, [16]byte, error) {
+	if ctx.Done() == nil {
+		return d.FwControl(request, md5, timeoutMs, cpuId)
+	}
+	if ctx.Err() != nil {
+		return nil, [16]byte{}, contextError(ctx, "firmware control")
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline).Milliseconds()
+		if remaining < 1 {
+			remaining = 1
+		}
+		if remaining < int64(timeoutMs) {
+			timeoutMs = uint32(remaining)
+		}
+	}
+
+	type result struct {
+		response []byte
+		md5      [16]byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		response, respMd5, err := d.FwControl(request, md5, timeoutMs, cpuId)
+		done <- result{response, respMd5, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.response, r.md5, r.err
+	case <-ctx.Done():
+		return nil, [16]byte{}, contextError(ctx, "firmware control")
+	}
+}
+
 // ResetNnCore resets the neural network core
 func (d *DeviceFile) ResetNnCore() error {
 	return d.ioctl(ioctlResetNnCore, nil)
@@ -332,6 +487,12 @@ func (d *DeviceFile) ReadNotification() ([]byte, error) {
 	return result, nil
 }
 
+// DisableNotification wakes any reader blocked in ReadNotification, which
+// then fails; used to stop notification watchers
+func (d *DeviceFile) DisableNotification() error {
+	return d.ioctl(ioctlDisableNotification, nil)
+}
+
 // ScanDevices scans for available Hailo devices
 func ScanDevices() ([]string, error) {
 	// Check specific device paths /dev/hailo0 through /dev/hailo15
@@ -3,7 +3,10 @@
 package driver
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 )
 
 func TestIoctlQueryDevicePropertiesCode(t *testing.T) {
@@ -229,3 +232,66 @@ func TestIoctlSizesAreReasonable(t *testing.T) {
 		})
 	}
 }
+
+func TestDriverBufferIoctlCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  uint32
+		nr   uint32
+		dir  uint32
+	}{
+		{"LowMemoryBufferAlloc", ioctlVdmaLowMemoryBufferAlloc, IoctlVdmaLowMemoryBufferAlloc, IocRead | IocWrite},
+		{"LowMemoryBufferFree", ioctlVdmaLowMemoryBufferFree, IoctlVdmaLowMemoryBufferFree, IocRead},
+		{"ContinuousBufferAlloc", ioctlVdmaContinuousBufferAlloc, IoctlVdmaContinuousBufferAlloc, IocRead | IocWrite},
+		{"ContinuousBufferFree", ioctlVdmaContinuousBufferFree, IoctlVdmaContinuousBufferFree, IocRead},
+		{"MarkAsInUse", ioctlVdmaMarkAsInUse, IoctlMarkAsInUse, IocWrite},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if typ := (tt.cmd >> IocTypeShift) & 0xff; typ != uint32(HailoVdmaIoctlMagic) {
+				t.Errorf("type = 0x%02x, expected 0x%02x", typ, HailoVdmaIoctlMagic)
+			}
+			if nr := (tt.cmd >> IocNrShift) & 0xff; nr != tt.nr {
+				t.Errorf("nr = %d, expected %d", nr, tt.nr)
+			}
+			if dir := (tt.cmd >> IocDirShift) & 0x3; dir != tt.dir {
+				t.Errorf("direction = %d, expected %d", dir, tt.dir)
+			}
+		})
+	}
+}
+
+func TestMarkAsInUseIoctlSize(t *testing.T) {
+	// sizeof(struct hailo_mark_as_in_use_params) is 1
+	if size := (ioctlVdmaMarkAsInUse >> IocSizeShift) & 0x3fff; size != 1 {
+		t.Errorf("size = %d, expected 1", size)
+	}
+}
+
+func TestContextWaitsFailFastWhenDone(t *testing.T) {
+	// No real device is needed: a done context returns before any ioctl
+	d := &DeviceFile{fd: -1}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := d.VdmaInterruptsWaitContext(ctx, [MaxVdmaEngines]uint32{1})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("VdmaInterruptsWaitContext error = %v, want context.Canceled", err)
+	}
+	if !errors.Is(err, NewError(StatusDriverWaitCanceled, "")) {
+		t.Errorf("VdmaInterruptsWaitContext error = %v, want StatusDriverWaitCanceled", err)
+	}
+
+	expired, cancel2 := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel2()
+
+	_, _, err = d.FwControlContext(expired, []byte{0}, [16]byte{}, 1000, 0)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FwControlContext error = %v, want context.DeadlineExceeded", err)
+	}
+	if !errors.Is(err, NewError(StatusTimeout, "")) {
+		t.Errorf("FwControlContext error = %v, want StatusTimeout", err)
+	}
+}
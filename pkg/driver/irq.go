@@ -0,0 +1,126 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Bits of the vDMA channel error register, reported per side of the
+// channel in hailo_vdma_interrupts_channel_data.host_error/device_error
+const (
+	VdmaErrorDescriptor uint8 = 1 << 0 // descriptor fetch or descriptor content error
+	VdmaErrorAxi        uint8 = 1 << 1 // AXI bus error while moving data
+)
+
+// Kinds of vDMA channel errors, matched with errors.Is
+var (
+	ErrVdmaDescriptor     = errors.New("vDMA descriptor error")
+	ErrVdmaAxi            = errors.New("vDMA AXI error")
+	ErrVdmaChannelAborted = errors.New("vDMA channel aborted")
+	ErrVdmaValidation     = errors.New("vDMA transfer validation failed")
+	ErrVdmaChannel        = errors.New("vDMA channel error")
+)
+
+// ChannelIrq is the interrupt data the driver reports for one channel
+type ChannelIrq struct {
+	Engine             uint8
+	Channel            uint8
+	IsActive           bool
+	TransfersCompleted uint8
+	HostError          uint8
+	DeviceError        uint8
+	ValidationSuccess  bool
+}
+
+// Channels returns the interrupt data of every channel that fired
+func (p *PackedVdmaInterruptsWaitParams) Channels() []ChannelIrq {
+	count := int(p.ChannelsCount())
+	if max := MaxVdmaChannelsPerEngine * MaxVdmaEngines; count > max {
+		count = max
+	}
+
+	result := make([]ChannelIrq, count)
+	for i := range result {
+		engine, channel, active, transfers, hostErr, deviceErr, valid := p.IrqData(i)
+		result[i] = ChannelIrq{
+			Engine:             engine,
+			Channel:            channel,
+			IsActive:           active,
+			TransfersCompleted: transfers,
+			HostError:          hostErr,
+			DeviceError:        deviceErr,
+			ValidationSuccess:  valid,
+		}
+	}
+	return result
+}
+
+// Err decodes the channel status into a *VdmaChannelError, or nil when the
+// channel completed normally. Error bits take precedence over validation,
+// and validation over an inactive (aborted) channel, as in HailoRT.
+func (c ChannelIrq) Err() error {
+	var kind error
+	switch {
+	case (c.HostError|c.DeviceError)&VdmaErrorDescriptor != 0:
+		kind = ErrVdmaDescriptor
+	case (c.HostError|c.DeviceError)&VdmaErrorAxi != 0:
+		kind = ErrVdmaAxi
+	case c.HostError != 0 || c.DeviceError != 0:
+		kind = ErrVdmaChannel
+	case !c.ValidationSuccess:
+		kind = ErrVdmaValidation
+	case !c.IsActive:
+		kind = ErrVdmaChannelAborted
+	default:
+		return nil
+	}
+
+	return &VdmaChannelError{
+		Engine:      c.Engine,
+		Channel:     c.Channel,
+		HostError:   c.HostError,
+		DeviceError: c.DeviceError,
+		Kind:        kind,
+	}
+}
+
+// VdmaChannelError is a DMA error reported by a channel interrupt
+type VdmaChannelError struct {
+	Engine      uint8
+	Channel     uint8
+	HostError   uint8
+	DeviceError uint8
+	Kind        error
+}
+
+// Error implements the error interface
+func (e *VdmaChannelError) Error() string {
+	msg := fmt.Sprintf("engine %d channel %d: %v", e.Engine, e.Channel, e.Kind)
+	if e.HostError != 0 || e.DeviceError != 0 {
+		msg += fmt.Sprintf(" (host_error=0x%02x device_error=0x%02x)", e.HostError, e.DeviceError)
+	}
+	return msg
+}
+
+// Unwrap returns the error kind
+func (e *VdmaChannelError) Unwrap() error {
+	return e.Kind
+}
+
+// Is matches HailoErrors by the status HailoRT reports for the same
+// condition: stream abort for aborted channels, internal failure otherwise
+func (e *VdmaChannelError) Is(target error) bool {
+	var hailoErr *HailoError
+	if !errors.As(target, &hailoErr) {
+		return false
+	}
+	return hailoErr.Status == e.Status()
+}
+
+// Status returns the HailoRT status equivalent to the error
+func (e *VdmaChannelError) Status() Status {
+	if e.Kind == ErrVdmaChannelAborted {
+		return StatusStreamAbort
+	}
+	return StatusInternalFailure
+}
@@ -0,0 +1,90 @@
+//go:build unit
+
+package driver
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChannelIrqErr(t *testing.T) {
+	ok := ChannelIrq{Engine: 1, Channel: 17, IsActive: true, TransfersCompleted: 1, ValidationSuccess: true}
+
+	tests := []struct {
+		name     string
+		modify   func(*ChannelIrq)
+		expected error
+	}{
+		{"success", func(*ChannelIrq) {}, nil},
+		{"host descriptor error", func(c *ChannelIrq) { c.HostError = VdmaErrorDescriptor }, ErrVdmaDescriptor},
+		{"device AXI error", func(c *ChannelIrq) { c.DeviceError = VdmaErrorAxi }, ErrVdmaAxi},
+		{"unknown error bits", func(c *ChannelIrq) { c.HostError = 0x80 }, ErrVdmaChannel},
+		{"validation failure", func(c *ChannelIrq) { c.ValidationSuccess = false }, ErrVdmaValidation},
+		{"aborted", func(c *ChannelIrq) { c.IsActive = false }, ErrVdmaChannelAborted},
+		{"error bits win over abort", func(c *ChannelIrq) {
+			c.IsActive = false
+			c.DeviceError = VdmaErrorAxi
+		}, ErrVdmaAxi},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			irq := ok
+			tt.modify(&irq)
+			err := irq.Err()
+
+			if tt.expected == nil {
+				if err != nil {
+					t.Fatalf("Err() = %v, expected nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Fatalf("Err() = %v, expected %v", err, tt.expected)
+			}
+
+			var chErr *VdmaChannelError
+			if !errors.As(err, &chErr) || chErr.Engine != 1 || chErr.Channel != 17 {
+				t.Errorf("Err() = %#v, expected engine 1 channel 17 attached", err)
+			}
+		})
+	}
+}
+
+func TestVdmaChannelErrorStatus(t *testing.T) {
+	aborted := ChannelIrq{ValidationSuccess: true}.Err()
+	if !errors.Is(aborted, NewError(StatusStreamAbort, "")) {
+		t.Errorf("aborted channel should match StatusStreamAbort: %v", aborted)
+	}
+
+	axi := ChannelIrq{IsActive: true, ValidationSuccess: true, HostError: VdmaErrorAxi}.Err()
+	if !errors.Is(axi, NewError(StatusInternalFailure, "")) {
+		t.Errorf("AXI error should match StatusInternalFailure: %v", axi)
+	}
+	if errors.Is(axi, NewError(StatusStreamAbort, "")) {
+		t.Error("AXI error should not match StatusStreamAbort")
+	}
+	if !strings.Contains(axi.Error(), "host_error=0x02") {
+		t.Errorf("Error() = %q, expected raw error bits", axi.Error())
+	}
+}
+
+func TestInterruptsWaitParamsChannels(t *testing.T) {
+	var bitmap [MaxVdmaEngines]uint32
+	p := NewPackedVdmaInterruptsWaitParams(bitmap)
+	p[12] = 2
+	copy(p[13:20], []byte{0, 3, 1, 2, 0, 0, 1})
+	copy(p[20:27], []byte{2, 16, 1, 0, 0, VdmaErrorDescriptor, 1})
+
+	channels := p.Channels()
+	if len(channels) != 2 {
+		t.Fatalf("Channels() returned %d entries, expected 2", len(channels))
+	}
+	if channels[0].Channel != 3 || channels[0].TransfersCompleted != 2 || channels[0].Err() != nil {
+		t.Errorf("channel 0 = %+v", channels[0])
+	}
+	if channels[1].Engine != 2 || !errors.Is(channels[1].Err(), ErrVdmaDescriptor) {
+		t.Errorf("channel 1 = %+v, expected device descriptor error on engine 2", channels[1])
+	}
+}
@@ -260,4 +260,11 @@ const (
 	SizeOfD2hNotification                = int(unsafe.Sizeof(D2hNotification{}))
 	SizeOfChannelInterruptTimestamp      = int(unsafe.Sizeof(ChannelInterruptTimestamp{}))
 	SizeOfVdmaTransferBuffer             = int(unsafe.Sizeof(VdmaTransferBuffer{}))
+	SizeOfAllocateContinuousBufferParams = int(unsafe.Sizeof(AllocateContinuousBufferParams{}))
+	SizeOfFreeContinuousBufferParams     = int(unsafe.Sizeof(FreeContinuousBufferParams{}))
+	SizeOfAllocateLowMemoryBufferParams  = int(unsafe.Sizeof(AllocateLowMemoryBufferParams{}))
+	SizeOfFreeLowMemoryBufferParams      = int(unsafe.Sizeof(FreeLowMemoryBufferParams{}))
+
+	// The C struct is a single bool; the Go padding is not part of the ioctl size
+	SizeOfMarkAsInUseParams = 1
 )
@@ -339,3 +339,23 @@ func TestVdmaLaunchTransferParamsSize(t *testing.T) {
 	}
 	t.Logf("VdmaLaunchTransferParams size = %d", got)
 }
+
+func TestDriverBufferParamsSizes(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      int
+		expected int
+	}{
+		// size_t buffer_size + uintptr_t buffer_handle + uint64_t dma_address
+		{"AllocateContinuousBufferParams", SizeOfAllocateContinuousBufferParams, 24},
+		{"FreeContinuousBufferParams", SizeOfFreeContinuousBufferParams, 8},
+		// size_t buffer_size + uintptr_t buffer_handle
+		{"AllocateLowMemoryBufferParams", SizeOfAllocateLowMemoryBufferParams, 16},
+		{"FreeLowMemoryBufferParams", SizeOfFreeLowMemoryBufferParams, 8},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%s size = %d, expected %d", tt.name, tt.got, tt.expected)
+		}
+	}
+}
@@ -80,6 +80,7 @@ func extractNetworkGroupInfo(ng *hefpb.ProtoHEFNetworkGroup) NetworkGroupInfo {
 		if info.Name == "" {
 			info.Name = ng.NetworkGroupMetadata.NetworkGroupName
 		}
+		info.CfgChannels = extractCfgChannels(ng.NetworkGroupMetadata)
 	}
 
 	// Extract streams from ops
@@ -89,6 +90,10 @@ func extractNetworkGroupInfo(ng *hefpb.ProtoHEFNetworkGroup) NetworkGroupInfo {
 			coreOp := op.GetCoreOp()
 			extractStreamsFromCoreOp(coreOp, &info)
 
+			if len(info.CfgChannels) == 0 && coreOp.NetworkGroupMetadata != nil {
+				info.CfgChannels = extractCfgChannels(coreOp.NetworkGroupMetadata)
+			}
+
 			// Also extract configuration from core op
 			if coreOp.PreliminaryConfig != nil {
 				info.PreliminaryConfig = extractPreliminaryConfig(coreOp.PreliminaryConfig)
@@ -118,6 +123,8 @@ func extractNetworkGroupInfo(ng *hefpb.ProtoHEFNetworkGroup) NetworkGroupInfo {
 		}
 	}
 
+	info.IsMultiContext = len(info.Contexts) > 1
+
 	// Extract NMS op info if present
 	for _, op := range ng.Ops {
 		if nmsOp := op.GetNmsOp(); nmsOp != nil {
@@ -151,9 +158,61 @@ func extractContextConfig(ctx *hefpb.ProtoHEFContext) ContextConfig {
 	for _, op := range ctx.Operations {
 		config.Operations = append(config.Operations, extractConfigOperation(op))
 	}
+	if ctx.Metadata != nil {
+		for _, edgeLayer := range ctx.Metadata.EdgeLayers {
+			if layer, ok := extractEdgeLayer(edgeLayer); ok {
+				config.EdgeLayers = append(config.EdgeLayers, layer)
+			}
+		}
+	}
 	return config
 }
 
+// extractEdgeLayer extracts the context switch placement of an edge layer.
+// Only plain layers are supported; mux and planes layers are skipped.
+func extractEdgeLayer(edgeLayer *hefpb.ProtoHEFEdgeLayer) (EdgeLayer, bool) {
+	layerInfo := edgeLayer.GetLayerInfo()
+	if layerInfo == nil {
+		return EdgeLayer{}, false
+	}
+
+	layer := EdgeLayer{
+		Name:         layerInfo.Name,
+		Direction:    StreamDirectionOutput,
+		NetworkIndex: edgeLayer.NetworkIndex,
+	}
+	if edgeLayer.Direction == hefpb.ProtoHEFEdgeLayerDirection_PROTO__EDGE_LAYER_DIRECTION__HOST_TO_DEVICE {
+		layer.Direction = StreamDirectionInput
+	}
+
+	if base := layerInfo.EdgeLayerBase; base != nil {
+		layer.EngineIndex = base.EngineId
+		layer.SysIndex = base.SysIndex
+		layer.CoreBytesPerBuffer = base.CoreBytesPerBuffer
+		layer.CoreBuffersPerFrame = base.CoreBuffersPerFrame
+	}
+
+	if csInfo := edgeLayer.ContextSwitchInfo; csInfo != nil {
+		layer.ConnectionType = EdgeConnectionType(csInfo.EdgeConnectionType)
+		layer.ConnectedContextIndex = csInfo.ConnectedContextIndex
+		layer.ConnectedSysIndex = csInfo.ConnectedSysIndex
+		layer.Buffers = csInfo.Buffers
+	}
+	return layer, true
+}
+
+// extractCfgChannels extracts the config channel placement
+func extractCfgChannels(meta *hefpb.ProtoHEFNetworkGroupMetadata) []CfgChannelInfo {
+	var channels []CfgChannelInfo
+	for _, cfg := range meta.CfgChannelsConfig {
+		channels = append(channels, CfgChannelInfo{
+			Index:       cfg.CfgChannelIndex,
+			EngineIndex: cfg.EngineId,
+		})
+	}
+	return channels
+}
+
 // extractConfigOperation extracts a configuration operation from protobuf
 func extractConfigOperation(op *hefpb.ProtoHEFOperation) ConfigOperation {
 	config := ConfigOperation{}
@@ -237,6 +296,10 @@ func extractConfigAction(action *hefpb.ProtoHEFAction) ConfigAction {
 		ca.Type = ActionTypeAllowInputDataflow
 		if a.AllowInputDataflow != nil {
 			ca.Address = uint64(a.AllowInputDataflow.SysIndex)
+			ca.AllowInputDataflow = &AllowInputDataflowParams{
+				SysIndex:       a.AllowInputDataflow.SysIndex,
+				ConnectionType: EdgeConnectionType(a.AllowInputDataflow.ConnectionType),
+			}
 		}
 	case *hefpb.ProtoHEFAction_WaitForModuleConfigDone:
 		ca.Type = ActionTypeWaitForModuleConfigDone
@@ -356,6 +419,11 @@ func extractStreamInfo(layer *hefpb.ProtoHEFEdgeLayerInfo, dir hefpb.ProtoHEFEdg
 			Features: base.PaddedFeatures,
 		}
 		stream.HwFrameSize = uint64(base.CoreBytesPerBuffer) * uint64(base.CoreBuffersPerFrame)
+		stream.EngineIndex = base.EngineId
+		stream.SysIndex = base.SysIndex
+		stream.CoreBytesPerBuffer = base.CoreBytesPerBuffer
+		stream.CoreBuffersPerFrame = base.CoreBuffersPerFrame
+		stream.HasDmaInfo = true
 
 		// Extract format
 		stream.Format = extractFormat(base)
@@ -369,11 +437,27 @@ func extractStreamInfo(layer *hefpb.ProtoHEFEdgeLayerInfo, dir hefpb.ProtoHEFEdg
 			LimMin:    layer.NumericInfo.LimvalsMin,
 			LimMax:    layer.NumericInfo.LimvalsMax,
 		}
+		if len(layer.NumericInfo.QpScales) > 1 {
+			stream.QuantInfo.Scales = toFloat32s(layer.NumericInfo.QpScales)
+			stream.QuantInfo.ZeroPoints = toFloat32s(layer.NumericInfo.QpZps)
+		}
 	}
 
 	return stream
 }
 
+// toFloat32s narrows per-channel quantization arrays stored as doubles
+func toFloat32s(values []float64) []float32 {
+	if len(values) == 0 {
+		return nil
+	}
+	result := make([]float32, len(values))
+	for i, v := range values {
+		result[i] = float32(v)
+	}
+	return result
+}
+
 // extractFormat extracts format information from edge layer base
 func extractFormat(base *hefpb.ProtoHEFEdgeLayerBase) Format {
 	format := Format{}
@@ -392,6 +476,16 @@ func extractFormat(base *hefpb.ProtoHEFEdgeLayerBase) Format {
 	switch base.Format {
 	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NHWC):
 		format.Order = FormatOrderNHWC
+	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NHCW):
+		format.Order = FormatOrderNHCW
+	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__FCR):
+		format.Order = FormatOrderFCR
+	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__F8CR):
+		format.Order = FormatOrderF8CR
+	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NHW):
+		format.Order = FormatOrderNHW
+	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NC):
+		format.Order = FormatOrderNC
 	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NCHW):
 		format.Order = FormatOrderNCHW
 	case uint32(hefpb.ProtoHEFFormatOrder_PROTO__FORMAT__ORDER__NV12):
@@ -525,11 +619,8 @@ func (ng *NetworkGroupInfo) InputFrameSize() int {
 	for _, stream := range ng.InputStreams {
 		size := int(stream.Shape.Height * stream.Shape.Width * stream.Shape.Features)
 		// Adjust for data type
-		switch stream.Format.Type {
-		case FormatTypeUint16:
-			size *= 2
-		case FormatTypeFloat32:
-			size *= 4
+		if elemSize := stream.Format.Type.Size(); elemSize > 1 {
+			size *= elemSize
 		}
 		total += size
 	}
@@ -542,11 +633,8 @@ func (ng *NetworkGroupInfo) OutputFrameSize() int {
 	for _, stream := range ng.OutputStreams {
 		size := int(stream.Shape.Height * stream.Shape.Width * stream.Shape.Features)
 		// Adjust for data type
-		switch stream.Format.Type {
-		case FormatTypeUint16:
-			size *= 2
-		case FormatTypeFloat32:
-			size *= 4
+		if elemSize := stream.Format.Type.Size(); elemSize > 1 {
+			size *= elemSize
 		}
 		total += size
 	}
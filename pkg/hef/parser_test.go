@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+
+	hefpb "github.com/anthropics/purple-hailo/pkg/hef/proto"
 )
 
 func createTestHefFile(t *testing.T, version uint32, protoSize uint32) string {
@@ -314,6 +316,9 @@ func TestFormatTypeStrings(t *testing.T) {
 		{FormatTypeUint8, "uint8"},
 		{FormatTypeUint16, "uint16"},
 		{FormatTypeFloat32, "float32"},
+		{FormatTypeInt8, "int8"},
+		{FormatTypeInt16, "int16"},
+		{FormatTypeFloat16, "float16"},
 		{FormatType(99), "Unknown(99)"},
 	}
 
@@ -327,6 +332,57 @@ func TestFormatTypeStrings(t *testing.T) {
 	}
 }
 
+func TestFormatTypeSize(t *testing.T) {
+	tests := []struct {
+		format    FormatType
+		size      int
+		quantized bool
+	}{
+		{FormatTypeAuto, 0, false},
+		{FormatTypeUint8, 1, true},
+		{FormatTypeInt8, 1, true},
+		{FormatTypeUint16, 2, true},
+		{FormatTypeInt16, 2, true},
+		{FormatTypeFloat16, 2, false},
+		{FormatTypeFloat32, 4, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.format.Size(); got != tt.size {
+			t.Errorf("%s.Size() = %d, expected %d", tt.format, got, tt.size)
+		}
+		if got := tt.format.IsQuantized(); got != tt.quantized {
+			t.Errorf("%s.IsQuantized() = %v, expected %v", tt.format, got, tt.quantized)
+		}
+	}
+}
+
+func TestQuantInfoPerChannel(t *testing.T) {
+	perTensor := QuantInfo{ZeroPoint: 3, Scale: 0.5}
+	if perTensor.IsPerChannel() {
+		t.Error("single-scale QuantInfo should not be per-channel")
+	}
+	if c := perTensor.Channel(7); c.ZeroPoint != 3 || c.Scale != 0.5 {
+		t.Errorf("Channel(7) = %+v, expected zp 3 scale 0.5", c)
+	}
+
+	perChannel := QuantInfo{
+		ZeroPoint:  3,
+		Scale:      0.5,
+		ZeroPoints: []float32{1, 2},
+		Scales:     []float32{0.1, 0.2},
+	}
+	if !perChannel.IsPerChannel() {
+		t.Error("QuantInfo with two scales should be per-channel")
+	}
+	if c := perChannel.Channel(1); c.ZeroPoint != 2 || c.Scale != 0.2 {
+		t.Errorf("Channel(1) = %+v, expected zp 2 scale 0.2", c)
+	}
+	if c := perChannel.Channel(5); c.ZeroPoint != 3 || c.Scale != 0.5 || c.IsPerChannel() {
+		t.Errorf("Channel(5) out of range = %+v, expected per-tensor fallback", c)
+	}
+}
+
 func TestFormatOrderStrings(t *testing.T) {
 	tests := []struct {
 		order    FormatOrder
@@ -335,6 +391,11 @@ func TestFormatOrderStrings(t *testing.T) {
 		{FormatOrderNHWC, "NHWC"},
 		{FormatOrderNCHW, "NCHW"},
 		{FormatOrderNV12, "NV12"},
+		{FormatOrderNHCW, "NHCW"},
+		{FormatOrderFCR, "FCR"},
+		{FormatOrderF8CR, "F8CR"},
+		{FormatOrderNHW, "NHW"},
+		{FormatOrderNC, "NC"},
 		{FormatOrderHailoNmsByClass, "NMS_BY_CLASS"},
 		{FormatOrderHailoNmsByScore, "NMS_BY_SCORE"},
 		{FormatOrder(999), "Unknown(999)"},
@@ -392,3 +453,46 @@ func TestStreamInfoHwShape(t *testing.T) {
 		t.Errorf("HwFrameSize = %d, expected %d", stream.HwFrameSize, expectedSize)
 	}
 }
+
+func TestExtractContextEdgeLayers(t *testing.T) {
+	ctx := &hefpb.ProtoHEFContext{
+		ContextIndex: 1,
+		Metadata: &hefpb.ProtoHEFContextMetadata{
+			EdgeLayers: []*hefpb.ProtoHEFEdgeLayer{
+				{
+					Direction:    hefpb.ProtoHEFEdgeLayerDirection_PROTO__EDGE_LAYER_DIRECTION__HOST_TO_DEVICE,
+					NetworkIndex: 2,
+					Edge: &hefpb.ProtoHEFEdgeLayer_LayerInfo{LayerInfo: &hefpb.ProtoHEFEdgeLayerInfo{
+						Name: "ctx1_in",
+						EdgeLayerBase: &hefpb.ProtoHEFEdgeLayerBase{
+							SysIndex: 3, EngineId: 1, CoreBytesPerBuffer: 512, CoreBuffersPerFrame: 16,
+						},
+					}},
+					ContextSwitchInfo: &hefpb.ProtoHEFContextSwitchInformation{
+						EdgeConnectionType:    hefpb.ProtoHEFEdgeConnectionType_PROTO__EDGE_CONNECTION_TYPE__INTERMEDIATE,
+						ConnectedContextIndex: 0,
+						ConnectedSysIndex:     5,
+					},
+				},
+				// Mux layers carry no plain layer info and are skipped
+				{Edge: &hefpb.ProtoHEFEdgeLayer_LayerMux{LayerMux: &hefpb.ProtoHEFEdgeLayerMux{}}},
+			},
+		},
+	}
+
+	config := extractContextConfig(ctx)
+	if len(config.EdgeLayers) != 1 {
+		t.Fatalf("got %d edge layers, want 1", len(config.EdgeLayers))
+	}
+
+	layer := config.EdgeLayers[0]
+	if layer.Name != "ctx1_in" || layer.Direction != StreamDirectionInput || layer.NetworkIndex != 2 {
+		t.Errorf("layer = %+v", layer)
+	}
+	if layer.ConnectionType != EdgeConnectionInterContext || layer.ConnectedSysIndex != 5 {
+		t.Errorf("connection = %s to sys index %d", layer.ConnectionType, layer.ConnectedSysIndex)
+	}
+	if layer.EngineIndex != 1 || layer.SysIndex != 3 || layer.FrameSize() != 512*16 {
+		t.Errorf("placement = engine %d sys %d frame %d", layer.EngineIndex, layer.SysIndex, layer.FrameSize())
+	}
+}
@@ -111,6 +111,9 @@ const (
 	FormatTypeUint8   FormatType = 1
 	FormatTypeUint16  FormatType = 2
 	FormatTypeFloat32 FormatType = 3
+	FormatTypeInt8    FormatType = 4
+	FormatTypeInt16   FormatType = 5
+	FormatTypeFloat16 FormatType = 6
 )
 
 func (f FormatType) String() string {
@@ -123,26 +126,74 @@ func (f FormatType) String() string {
 		return "uint16"
 	case FormatTypeFloat32:
 		return "float32"
+	case FormatTypeInt8:
+		return "int8"
+	case FormatTypeInt16:
+		return "int16"
+	case FormatTypeFloat16:
+		return "float16"
 	default:
 		return fmt.Sprintf("Unknown(%d)", f)
 	}
 }
 
+// Size returns the element size in bytes, or 0 for FormatTypeAuto
+func (f FormatType) Size() int {
+	switch f {
+	case FormatTypeUint8, FormatTypeInt8:
+		return 1
+	case FormatTypeUint16, FormatTypeInt16, FormatTypeFloat16:
+		return 2
+	case FormatTypeFloat32:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// IsQuantized returns true for integer types that carry quantized values
+func (f FormatType) IsQuantized() bool {
+	switch f {
+	case FormatTypeUint8, FormatTypeUint16, FormatTypeInt8, FormatTypeInt16:
+		return true
+	default:
+		return false
+	}
+}
+
 // FormatOrder represents tensor memory layout
 type FormatOrder uint32
 
 const (
-	FormatOrderNHWC           FormatOrder = 1
-	FormatOrderNCHW           FormatOrder = 11
-	FormatOrderNV12           FormatOrder = 13
+	FormatOrderAuto            FormatOrder = 0
+	FormatOrderNHWC            FormatOrder = 1
+	FormatOrderNHCW            FormatOrder = 2 // hardware: each row holds one W-run per feature
+	FormatOrderFCR             FormatOrder = 3 // hardware: NHWC with features padded to 8
+	FormatOrderF8CR            FormatOrder = 4 // hardware: each row holds W-runs of 8-feature groups
+	FormatOrderNHW             FormatOrder = 5 // single-feature (e.g. argmax) output
+	FormatOrderNC              FormatOrder = 6 // 1D features (e.g. fully connected) output
+	FormatOrderNCHW            FormatOrder = 11
+	FormatOrderNV12            FormatOrder = 13
 	FormatOrderHailoNmsByClass FormatOrder = 100
 	FormatOrderHailoNmsByScore FormatOrder = 101
 )
 
 func (f FormatOrder) String() string {
 	switch f {
+	case FormatOrderAuto:
+		return "AUTO"
 	case FormatOrderNHWC:
 		return "NHWC"
+	case FormatOrderNHCW:
+		return "NHCW"
+	case FormatOrderFCR:
+		return "FCR"
+	case FormatOrderF8CR:
+		return "F8CR"
+	case FormatOrderNHW:
+		return "NHW"
+	case FormatOrderNC:
+		return "NC"
 	case FormatOrderNCHW:
 		return "NCHW"
 	case FormatOrderNV12:
@@ -183,6 +234,29 @@ type QuantInfo struct {
 	Scale     float32
 	LimMin    float32
 	LimMax    float32
+
+	// Per-channel (per-feature) parameters, set only when the HEF provides
+	// more than one scale for the layer
+	ZeroPoints []float32
+	Scales     []float32
+}
+
+// IsPerChannel returns true if the layer is quantized per channel
+func (q QuantInfo) IsPerChannel() bool {
+	return len(q.Scales) > 1
+}
+
+// Channel returns the per-tensor parameters to use for the given channel,
+// falling back to the layer values when it is not quantized per channel
+func (q QuantInfo) Channel(c int) QuantInfo {
+	result := QuantInfo{ZeroPoint: q.ZeroPoint, Scale: q.Scale, LimMin: q.LimMin, LimMax: q.LimMax}
+	if c >= 0 && c < len(q.Scales) {
+		result.Scale = q.Scales[c]
+	}
+	if c >= 0 && c < len(q.ZeroPoints) {
+		result.ZeroPoint = q.ZeroPoints[c]
+	}
+	return result
 }
 
 // NmsShape represents NMS layer shape information
@@ -201,6 +275,14 @@ type StreamInfo struct {
 	HwShape     ImageShape3D
 	HwFrameSize uint64
 	QuantInfo   QuantInfo
+
+	// DMA placement from the edge layer: the vDMA engine and the core-side
+	// stream index. HasDmaInfo is false when the HEF has no edge layer.
+	EngineIndex         uint32
+	SysIndex            uint32
+	CoreBytesPerBuffer  uint32
+	CoreBuffersPerFrame uint32
+	HasDmaInfo          bool
 }
 
 // VStreamInfo represents high-level virtual stream information
@@ -226,6 +308,14 @@ type NetworkGroupInfo struct {
 	IsMultiContext     bool
 	PreliminaryConfig  *PreliminaryConfig
 	Contexts           []ContextConfig
+	CfgChannels        []CfgChannelInfo
+}
+
+// CfgChannelInfo places a config channel, which carries CCW writes to the
+// device, on a vDMA engine
+type CfgChannelInfo struct {
+	Index       uint32
+	EngineIndex uint32
 }
 
 // NetworkInfo represents information about a single network
@@ -252,6 +342,37 @@ const (
 	ActionTypeSwitchLcuBatch
 )
 
+// String returns the action type name
+func (t ActionType) String() string {
+	switch t {
+	case ActionTypeWriteData:
+		return "write_data"
+	case ActionTypeWriteDataCcw:
+		return "write_data_ccw"
+	case ActionTypeEnableSequencer:
+		return "enable_sequencer"
+	case ActionTypeWaitForSequencer:
+		return "wait_for_sequencer"
+	case ActionTypeDisableLcu:
+		return "disable_lcu"
+	case ActionTypeEnableLcu:
+		return "enable_lcu"
+	case ActionTypeNone:
+		return "none"
+	case ActionTypeAllowInputDataflow:
+		return "allow_input_dataflow"
+	case ActionTypeWaitForModuleConfigDone:
+		return "wait_for_module_config_done"
+	case ActionTypeEnableNms:
+		return "enable_nms"
+	case ActionTypeWriteDataByType:
+		return "write_data_by_type"
+	case ActionTypeSwitchLcuBatch:
+		return "switch_lcu_batch"
+	}
+	return fmt.Sprintf("action(%d)", int(t))
+}
+
 // EnableLcuParams contains parameters for EnableLcu actions
 type EnableLcuParams struct {
 	LcuIndex           uint32
@@ -310,6 +431,12 @@ type SwitchLcuBatchParams struct {
 	NetworkIndex uint32
 }
 
+// AllowInputDataflowParams contains parameters for AllowInputDataflow actions
+type AllowInputDataflowParams struct {
+	SysIndex       uint32
+	ConnectionType EdgeConnectionType
+}
+
 // ConfigAction represents a single configuration action
 type ConfigAction struct {
 	Type    ActionType
@@ -323,6 +450,8 @@ type ConfigAction struct {
 	EnableNms       *EnableNmsParams
 	WriteDataByType *WriteDataByTypeParams
 	SwitchLcuBatch  *SwitchLcuBatchParams
+
+	AllowInputDataflow *AllowInputDataflowParams
 }
 
 // ConfigOperation represents a configuration operation with multiple actions
@@ -339,6 +468,58 @@ type PreliminaryConfig struct {
 type ContextConfig struct {
 	Index      uint32
 	Operations []ConfigOperation
+	EdgeLayers []EdgeLayer
+}
+
+// EdgeConnectionType tells where the other end of an edge layer is
+type EdgeConnectionType uint32
+
+const (
+	EdgeConnectionBoundary     EdgeConnectionType = 0 // the host
+	EdgeConnectionInterContext EdgeConnectionType = 1 // another context, through host memory
+	EdgeConnectionDdr          EdgeConnectionType = 2 // the same context, buffered in host memory
+	EdgeConnectionCache        EdgeConnectionType = 3
+)
+
+// String returns the connection type name
+func (t EdgeConnectionType) String() string {
+	switch t {
+	case EdgeConnectionBoundary:
+		return "boundary"
+	case EdgeConnectionInterContext:
+		return "inter-context"
+	case EdgeConnectionDdr:
+		return "ddr"
+	case EdgeConnectionCache:
+		return "cache"
+	}
+	return fmt.Sprintf("connection(%d)", uint32(t))
+}
+
+// EdgeLayer is a vDMA stream endpoint of one context
+type EdgeLayer struct {
+	Name           string
+	Direction      StreamDirection
+	ConnectionType EdgeConnectionType
+	NetworkIndex   uint32
+
+	EngineIndex         uint32
+	SysIndex            uint32
+	CoreBytesPerBuffer  uint32
+	CoreBuffersPerFrame uint32
+
+	// ConnectedContextIndex and ConnectedSysIndex locate the other end of
+	// inter-context and DDR edges
+	ConnectedContextIndex uint32
+	ConnectedSysIndex     uint32
+
+	// Buffers is the number of rows a DDR edge buffers
+	Buffers uint32
+}
+
+// FrameSize returns the bytes one frame occupies on the edge
+func (e *EdgeLayer) FrameSize() uint64 {
+	return uint64(e.CoreBytesPerBuffer) * uint64(e.CoreBuffersPerFrame)
 }
 
 // Hef represents a parsed HEF file
@@ -161,19 +161,5 @@ func (p *InferencePipeline) Run(input []byte) (interface{}, error) {
 
 // InferWithContext runs inference with context for cancellation
 func InferWithContext(ctx context.Context, session *Session, inputs map[string][]byte) (map[string][]byte, error) {
-	done := make(chan struct{})
-	var outputs map[string][]byte
-	var err error
-
-	go func() {
-		outputs, err = session.Infer(inputs)
-		close(done)
-	}()
-
-	select {
-	case <-ctx.Done():
-		return nil, ctx.Err()
-	case <-done:
-		return outputs, err
-	}
+	return session.InferContext(ctx, inputs)
 }
@@ -315,10 +315,33 @@ func TestInferWithContextCancellation(t *testing.T) {
 
 	_, err := InferWithContext(ctx, session, inputs)
 	if err != context.Canceled {
-		t.Logf("Result: %v", err)
+		t.Errorf("InferWithContext error = %v, want context.Canceled", err)
 	}
 }
 
+func TestInferWithContextWhileBusy(t *testing.T) {
+	model := &Model{
+		inputs:        []StreamInfo{{Name: "input", Shape: Shape{2, 2, 1}}},
+		outputs:       []StreamInfo{{Name: "output", Shape: Shape{1, 1, 4}}},
+		networkGroups: []string{"default"},
+	}
+
+	session, _ := model.NewSession()
+	defer session.Close()
+
+	// Another inference holds the session
+	session.busy <- struct{}{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := InferWithContext(ctx, session, map[string][]byte{"input": make([]byte, 4)})
+	if err != context.DeadlineExceeded {
+		t.Errorf("InferWithContext error = %v, want context.DeadlineExceeded", err)
+	}
+	session.unlock()
+}
+
 func BenchmarkAsyncInfer(b *testing.B) {
 	model := &Model{
 		inputs:        []StreamInfo{{Name: "input", Shape: Shape{224, 224, 3}}},
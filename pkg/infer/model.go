@@ -1,11 +1,13 @@
 package infer
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	"github.com/anthropics/purple-hailo/pkg/device"
 	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/stream"
 )
 
 // Model represents a loaded inference model
@@ -55,7 +57,7 @@ func NewModel(dev *device.Device, h *hef.Hef) (*Model, error) {
 				Width:    int(is.Shape.Width),
 				Channels: int(is.Shape.Features),
 			},
-			DataType: DataTypeUint8, // Default, may need to determine from HEF
+			DataType: inputDataType(is.Format.Type),
 			Format:   FormatNHWC,
 		})
 	}
@@ -77,6 +79,19 @@ func NewModel(dev *device.Device, h *hef.Hef) (*Model, error) {
 	return model, nil
 }
 
+// inputDataType maps an input's HEF element type to the type Infer takes.
+// Every two-byte type is sized as DataTypeUint16.
+func inputDataType(t hef.FormatType) DataType {
+	switch t {
+	case hef.FormatTypeUint16, hef.FormatTypeInt16, hef.FormatTypeFloat16:
+		return DataTypeUint16
+	case hef.FormatTypeFloat32:
+		return DataTypeFloat32
+	default:
+		return DataTypeUint8
+	}
+}
+
 // InputInfo returns information about input streams
 func (m *Model) InputInfo() []StreamInfo {
 	return m.inputs
@@ -141,20 +156,73 @@ func (m *Model) NewSession(opts ...SessionOption) (*Session, error) {
 	s := &Session{
 		model:   m,
 		timeout: 5 * time.Second,
+		busy:    make(chan struct{}, 1),
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
+	if m.device != nil {
+		if err := s.open(); err != nil {
+			return nil, err
+		}
+	}
+
 	return s, nil
 }
 
+// open configures and activates the default network group on the model's
+// device and builds the VStreams the session infers through
+func (s *Session) open() error {
+	batchSize := uint32(s.BatchSize())
+
+	ng, err := s.model.device.ConfigureDefaultNetworkGroup(s.model.hef)
+	if err != nil {
+		return fmt.Errorf("failed to configure network group: %w", err)
+	}
+	s.networkGroup = ng
+	if batchSize > 1 {
+		if err := ng.SetBatchSize(uint16(batchSize)); err != nil {
+			s.Close()
+			return err
+		}
+	}
+	if ng.IsMultiContext() {
+		if s.resources, err = stream.PrepareContextResources(ng, batchSize); err != nil {
+			s.Close()
+			return fmt.Errorf("failed to prepare context resources: %w", err)
+		}
+	}
+
+	if s.activated, err = ng.Activate(); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to activate network group: %w", err)
+	}
+
+	params := stream.DefaultVStreamParams()
+	params.BatchSize = batchSize
+	params.Timeout = s.timeout
+	if s.vstreams, err = stream.BuildVStreams(ng, params); err != nil {
+		s.Close()
+		return fmt.Errorf("failed to build vstreams: %w", err)
+	}
+	if s.supervisor != nil {
+		s.unhook = s.supervisor.AddRecoveryHook(s.vstreams.Reset)
+	}
+	return nil
+}
+
 // Session represents an active inference session
 type Session struct {
 	model          *Model
 	networkGroup   *device.ConfiguredNetworkGroup
 	activated      *device.ActivatedNetworkGroup
+	resources      *stream.ContextResources // multi-context models only
+	vstreams       *stream.VStreamSet
+	supervisor     *device.Supervisor
+	unhook         func() // removes the stream reset from the supervisor
+	busy           chan struct{} // holds a token while an inference or Close runs
 	timeout        time.Duration
 	closed         bool
 	batchSize      int
@@ -178,6 +246,14 @@ func WithBatchSize(size int) SessionOption {
 	}
 }
 
+// WithSupervisor runs inferences under sup, which recovers the device when
+// it wedges and resets the session's streams before the inference is retried
+func WithSupervisor(sup *device.Supervisor) SessionOption {
+	return func(s *Session) {
+		s.supervisor = sup
+	}
+}
+
 // WithPriority sets the scheduling priority
 func WithPriority(priority int) SessionOption {
 	return func(s *Session) {
@@ -185,43 +261,138 @@ func WithPriority(priority int) SessionOption {
 	}
 }
 
-// Infer runs inference on the provided inputs
+// Infer runs inference on the provided inputs. Each input holds
+// BatchSize frames and each output is the raw device output for the batch.
+// The session timeout bounds the whole inference.
 func (s *Session) Infer(inputs map[string][]byte) (map[string][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.InferContext(ctx, inputs)
+}
+
+// InferContext runs inference until ctx is done. Cancellation also ends
+// the wait for an inference already running on the session, and aborts the
+// device waits rather than abandoning them.
+func (s *Session) InferContext(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.unlock()
 	if s.closed {
 		return nil, ErrSessionClosed
 	}
 
-	// TODO: Implement real inference using VStreams
-	// For now, return a mock error
-	return nil, ErrNotImplemented
+	// Sessions on a model without a device have nothing to run on
+	if s.vstreams == nil {
+		return nil, ErrNotImplemented
+	}
+	if s.supervisor == nil {
+		return s.vstreams.Infer(ctx, inputs)
+	}
+
+	var outputs map[string][]byte
+	err := s.supervisor.Do(func() error {
+		// Do not retry after recovery once the caller gave up
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		outputs, err = s.vstreams.Infer(ctx, inputs)
+		return err
+	})
+	return outputs, err
+}
+
+// lock takes the session for one inference, giving up when ctx is done
+func (s *Session) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case s.busy <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlock hands the session to the next inference
+func (s *Session) unlock() {
+	<-s.busy
+}
+
+// isClosed reports whether Close has run, waiting out a running inference
+func (s *Session) isClosed() bool {
+	s.busy <- struct{}{}
+	defer s.unlock()
+	return s.closed
 }
 
 // InferBatch runs inference on a batch of inputs
 func (s *Session) InferBatch(inputs []map[string][]byte) ([]map[string][]byte, error) {
-	if s.closed {
+	if s.isClosed() {
 		return nil, ErrSessionClosed
 	}
 
-	// Process each input
-	results := make([]map[string][]byte, len(inputs))
-	for i, input := range inputs {
-		output, err := s.Infer(input)
+	// Run the frames BatchSize at a time; a short last batch is padded
+	// with its last frame and the padding outputs dropped
+	batchSize := s.BatchSize()
+	results := make([]map[string][]byte, 0, len(inputs))
+	for start := 0; start < len(inputs); start += batchSize {
+		end := min(start+batchSize, len(inputs))
+		batch := make(map[string][]byte)
+		for i := start; i < start+batchSize; i++ {
+			for name, data := range inputs[min(i, end-1)] {
+				batch[name] = append(batch[name], data...)
+			}
+		}
+
+		output, err := s.Infer(batch)
 		if err != nil {
 			return nil, err
 		}
-		results[i] = output
+		for i := start; i < end; i++ {
+			results = append(results, make(map[string][]byte, len(output)))
+		}
+		for name, data := range output {
+			frames := stream.SplitFrames(data, uint64(len(data)/batchSize))
+			if len(frames) != batchSize {
+				return nil, fmt.Errorf("output %s: %d bytes do not split into %d frames", name, len(data), batchSize)
+			}
+			for i := start; i < end; i++ {
+				results[i][name] = frames[i-start]
+			}
+		}
 	}
 
 	return results, nil
 }
 
+// BatchSize returns the number of frames each Infer call runs
+func (s *Session) BatchSize() int {
+	if s.batchSize < 1 {
+		return 1
+	}
+	return s.batchSize
+}
+
 // Close closes the session
 func (s *Session) Close() error {
+	s.busy <- struct{}{}
+	defer s.unlock()
 	if s.closed {
 		return nil
 	}
 	s.closed = true
 
+	if s.unhook != nil {
+		s.unhook()
+	}
+
+	if s.vstreams != nil {
+		s.vstreams.Close()
+	}
+
 	if s.activated != nil {
 		s.activated.Deactivate()
 	}
@@ -230,12 +401,16 @@ func (s *Session) Close() error {
 		s.networkGroup.Close()
 	}
 
+	if s.resources != nil {
+		s.resources.Close()
+	}
+
 	return nil
 }
 
 // ValidateInputs validates input data before inference
 func (s *Session) ValidateInputs(inputs map[string][]byte) error {
-	if s.closed {
+	if s.isClosed() {
 		return ErrSessionClosed
 	}
 
@@ -297,7 +472,7 @@ func (s *Session) GetStats() SessionStats {
 
 // Reset clears session state
 func (s *Session) Reset() error {
-	if s.closed {
+	if s.isClosed() {
 		return ErrSessionClosed
 	}
 	return nil
@@ -305,7 +480,7 @@ func (s *Session) Reset() error {
 
 // Warmup runs dummy inferences to warm up the pipeline
 func (s *Session) Warmup(numIterations int) error {
-	if s.closed {
+	if s.isClosed() {
 		return ErrSessionClosed
 	}
 
@@ -139,6 +139,42 @@ func TestSessionConcurrentInference(t *testing.T) {
 	}
 }
 
+func TestSessionCloseDuringCalls(t *testing.T) {
+	model := &Model{
+		inputs:        []StreamInfo{{Name: "input", Shape: Shape{2, 2, 1}}},
+		outputs:       []StreamInfo{{Name: "output", Shape: Shape{1, 1, 4}}},
+		networkGroups: []string{"default"},
+	}
+	session, _ := model.NewSession()
+	inputs := map[string][]byte{"input": make([]byte, 4)}
+
+	// Run under -race: every call reads closed while Close sets it
+	calls := []func() error{
+		func() error { _, err := session.InferBatch([]map[string][]byte{inputs}); return err },
+		func() error { return session.ValidateInputs(inputs) },
+		session.Reset,
+		func() error { return session.Warmup(1) },
+	}
+	var wg sync.WaitGroup
+	for _, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := call(); err != nil && err != ErrNotImplemented && err != ErrSessionClosed {
+				t.Errorf("call during Close: %v", err)
+			}
+		}()
+	}
+	session.Close()
+	wg.Wait()
+
+	for _, call := range calls {
+		if err := call(); err != ErrSessionClosed {
+			t.Errorf("call after Close = %v, want ErrSessionClosed", err)
+		}
+	}
+}
+
 func TestSessionStatistics(t *testing.T) {
 	model := &Model{
 		inputs:        []StreamInfo{{Name: "input", Shape: Shape{2, 2, 1}}},
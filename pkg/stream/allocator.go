@@ -0,0 +1,191 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// MinDescPageSize is the smallest descriptor page size the vDMA engine accepts
+const MinDescPageSize = 64
+
+// Errors for channel allocation
+var (
+	ErrChannelConflict = errors.New("vDMA channel already in use")
+	ErrInvalidEngine   = errors.New("vDMA engine index out of range")
+	ErrNoFreeChannel   = errors.New("no free vDMA channel")
+)
+
+// ChannelAssignment describes where a stream's data moves over vDMA
+type ChannelAssignment struct {
+	StreamName string
+	Direction  hef.StreamDirection
+	Engine     uint8
+	Channel    uint8
+	PageSize   uint16 // descriptor page size
+	DescCount  uint64 // descriptors needed for one batch
+	FromHef    bool   // false when the HEF had no engine and engine 0 was used
+}
+
+type channelKey struct {
+	engine  uint8
+	channel uint8
+}
+
+// ChannelAllocator assigns vDMA channels to streams the way libhailort
+// does: the HEF names the engine, and each stream takes the first free
+// channel of its direction's range on that engine
+type ChannelAllocator struct {
+	engines     int
+	maxPageSize uint16
+	used        map[channelKey]string
+	assignments []ChannelAssignment
+}
+
+// NewChannelAllocator creates an allocator for a device
+func NewChannelAllocator(props driver.DeviceProperties) *ChannelAllocator {
+	engines := int(props.DmaEnginesCount)
+	if engines <= 0 {
+		engines = 1
+	}
+	if engines > driver.MaxVdmaEngines {
+		engines = driver.MaxVdmaEngines
+	}
+
+	maxPageSize := props.DescMaxPageSize
+	if maxPageSize == 0 {
+		maxPageSize = PageSize
+	}
+
+	return &ChannelAllocator{
+		engines:     engines,
+		maxPageSize: maxPageSize,
+		used:        make(map[channelKey]string),
+	}
+}
+
+// channelRange returns the channel index range for a direction
+func channelRange(dir hef.StreamDirection) (first, last uint8) {
+	if dir == hef.StreamDirectionInput {
+		return 0, driver.VdmaDestChannelsStart - 1
+	}
+	return driver.VdmaDestChannelsStart, driver.MaxVdmaChannelsPerEngine - 1
+}
+
+// Allocate assigns a channel to a stream: the first free channel of the
+// direction's range on the engine the HEF specifies, or on engine 0 when
+// the HEF has no placement. The HEF stream index is the core-side index
+// and does not select the vDMA channel.
+func (a *ChannelAllocator) Allocate(info device.StreamInfo, dir hef.StreamDirection, batchSize uint32) (ChannelAssignment, error) {
+	if batchSize == 0 {
+		batchSize = 1
+	}
+
+	if owner, ok := a.Lookup(info.Name); ok && owner.Direction == dir {
+		return ChannelAssignment{}, fmt.Errorf("stream %s: %w: already assigned engine %d channel %d",
+			info.Name, ErrChannelConflict, owner.Engine, owner.Channel)
+	}
+
+	assignment := ChannelAssignment{
+		StreamName: info.Name,
+		Direction:  dir,
+		FromHef:    info.HasDmaInfo,
+	}
+	if info.HasDmaInfo {
+		if int(info.EngineIndex) >= a.engines {
+			return ChannelAssignment{}, fmt.Errorf("stream %s: %w: engine %d, device has %d",
+				info.Name, ErrInvalidEngine, info.EngineIndex, a.engines)
+		}
+		assignment.Engine = uint8(info.EngineIndex)
+	}
+
+	first, last := channelRange(dir)
+	found := false
+	for ch := int(first); ch <= int(last); ch++ {
+		if _, ok := a.used[channelKey{assignment.Engine, uint8(ch)}]; !ok {
+			assignment.Channel = uint8(ch)
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ChannelAssignment{}, fmt.Errorf("stream %s: %w on engine %d", info.Name, ErrNoFreeChannel, assignment.Engine)
+	}
+
+	assignment.PageSize = a.pageSize(info)
+	assignment.DescCount = CalculateDescCount(streamBufferSize(info)*uint64(batchSize), assignment.PageSize)
+
+	a.reserve(assignment)
+	return assignment, nil
+}
+
+// reserve records an assignment made elsewhere so its channel is not
+// handed out again
+func (a *ChannelAllocator) reserve(assignment ChannelAssignment) {
+	a.used[channelKey{assignment.Engine, assignment.Channel}] = assignment.StreamName
+	a.assignments = append(a.assignments, assignment)
+}
+
+// pageSize picks the smallest power-of-two page that holds one core buffer
+func (a *ChannelAllocator) pageSize(info device.StreamInfo) uint16 {
+	if info.CoreBytesPerBuffer == 0 {
+		return a.maxPageSize
+	}
+
+	size := uint32(MinDescPageSize)
+	for size < info.CoreBytesPerBuffer && size < uint32(a.maxPageSize) {
+		size <<= 1
+	}
+	if size > uint32(a.maxPageSize) {
+		size = uint32(a.maxPageSize)
+	}
+	return uint16(size)
+}
+
+// streamBufferSize returns the bytes one frame occupies on the channel
+func streamBufferSize(info device.StreamInfo) uint64 {
+	size := info.FrameSize
+	if hw := uint64(info.CoreBytesPerBuffer) * uint64(info.CoreBuffersPerFrame); hw > size {
+		size = hw
+	}
+	return size
+}
+
+// Assignments returns all channels allocated so far
+func (a *ChannelAllocator) Assignments() []ChannelAssignment {
+	result := make([]ChannelAssignment, len(a.assignments))
+	copy(result, a.assignments)
+	return result
+}
+
+// Lookup returns the assignment of a stream
+func (a *ChannelAllocator) Lookup(streamName string) (ChannelAssignment, bool) {
+	for _, as := range a.assignments {
+		if as.StreamName == streamName {
+			return as, true
+		}
+	}
+	return ChannelAssignment{}, false
+}
+
+// AllocateNetworkGroup assigns channels to every input and then every
+// output of a network group, in order, so the same streams always get
+// the same channels
+func (a *ChannelAllocator) AllocateNetworkGroup(inputs, outputs []device.StreamInfo, batchSize uint32) (in, out []ChannelAssignment, err error) {
+	in = make([]ChannelAssignment, len(inputs))
+	for i, info := range inputs {
+		if in[i], err = a.Allocate(info, hef.StreamDirectionInput, batchSize); err != nil {
+			return nil, nil, err
+		}
+	}
+	out = make([]ChannelAssignment, len(outputs))
+	for i, info := range outputs {
+		if out[i], err = a.Allocate(info, hef.StreamDirectionOutput, batchSize); err != nil {
+			return nil, nil, err
+		}
+	}
+	return in, out, nil
+}
@@ -0,0 +1,175 @@
+//go:build unit
+
+package stream
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+func testProps() driver.DeviceProperties {
+	return driver.DeviceProperties{DescMaxPageSize: 4096, DmaEnginesCount: 3}
+}
+
+func hefStream(name string, engine, sysIndex, bytesPerBuffer, buffersPerFrame uint32) device.StreamInfo {
+	return device.StreamInfo{
+		Name:                name,
+		FrameSize:           uint64(bytesPerBuffer) * uint64(buffersPerFrame),
+		EngineIndex:         engine,
+		SysIndex:            sysIndex,
+		CoreBytesPerBuffer:  bytesPerBuffer,
+		CoreBuffersPerFrame: buffersPerFrame,
+		HasDmaInfo:          true,
+	}
+}
+
+func TestAllocatorDirectionRanges(t *testing.T) {
+	a := NewChannelAllocator(testProps())
+
+	// Channels come from the direction ranges of the HEF engine; the
+	// stream index does not pick the channel
+	inputs := []device.StreamInfo{hefStream("in_b", 0, 3, 1920, 640), hefStream("in_a", 1, 0, 1920, 640)}
+	outputs := []device.StreamInfo{hefStream("out_0", 0, 5, 960, 80), hefStream("out_1", 0, 18, 960, 80)}
+
+	in, out, err := a.AllocateNetworkGroup(inputs, outputs, 1)
+	if err != nil {
+		t.Fatalf("AllocateNetworkGroup() error = %v", err)
+	}
+
+	tests := []struct {
+		got             ChannelAssignment
+		engine, channel uint8
+	}{
+		{in[0], 0, 0},
+		{in[1], 1, 0},
+		{out[0], 0, driver.VdmaDestChannelsStart},
+		{out[1], 0, driver.VdmaDestChannelsStart + 1},
+	}
+	for _, tt := range tests {
+		if tt.got.Engine != tt.engine || tt.got.Channel != tt.channel {
+			t.Errorf("%s: engine %d channel %d, expected engine %d channel %d",
+				tt.got.StreamName, tt.got.Engine, tt.got.Channel, tt.engine, tt.channel)
+		}
+		if !tt.got.FromHef {
+			t.Errorf("%s: FromHef = false", tt.got.StreamName)
+		}
+	}
+
+	// The same streams get the same channels from a fresh allocator
+	again, _, err := NewChannelAllocator(testProps()).AllocateNetworkGroup(inputs, outputs, 1)
+	if err != nil || again[0] != in[0] || again[1] != in[1] {
+		t.Errorf("second allocation = %+v, %v, expected %+v", again, err, in)
+	}
+}
+
+func TestAllocatorPageSize(t *testing.T) {
+	tests := []struct {
+		bytesPerBuffer uint32
+		expected       uint16
+	}{
+		{0, 4096},     // no HEF info: device maximum
+		{10, 64},      // minimum page
+		{1920, 2048},  // next power of two
+		{2048, 2048},  // exact fit
+		{20000, 4096}, // clamped to device maximum
+	}
+
+	for _, tt := range tests {
+		a := NewChannelAllocator(testProps())
+		info := hefStream("s", 0, 0, tt.bytesPerBuffer, 10)
+		as, err := a.Allocate(info, hef.StreamDirectionInput, 1)
+		if err != nil {
+			t.Fatalf("Allocate() error = %v", err)
+		}
+		if as.PageSize != tt.expected {
+			t.Errorf("bytesPerBuffer %d: PageSize = %d, expected %d", tt.bytesPerBuffer, as.PageSize, tt.expected)
+		}
+	}
+}
+
+func TestAllocatorDescCount(t *testing.T) {
+	a := NewChannelAllocator(testProps())
+
+	// 640 rows of 1920 bytes in 2048-byte pages, batch of 2
+	as, err := a.Allocate(hefStream("s", 0, 0, 1920, 640), hef.StreamDirectionInput, 2)
+	if err != nil {
+		t.Fatalf("Allocate() error = %v", err)
+	}
+	expected := CalculateDescCount(1920*640*2, 2048)
+	if as.DescCount != expected {
+		t.Errorf("DescCount = %d, expected %d", as.DescCount, expected)
+	}
+}
+
+func TestAllocatorConflict(t *testing.T) {
+	a := NewChannelAllocator(testProps())
+
+	if _, err := a.Allocate(hefStream("first", 0, 2, 64, 1), hef.StreamDirectionInput, 1); err != nil {
+		t.Fatalf("first Allocate() error = %v", err)
+	}
+	_, err := a.Allocate(hefStream("first", 0, 2, 64, 1), hef.StreamDirectionInput, 1)
+	if !errors.Is(err, ErrChannelConflict) {
+		t.Errorf("stream allocated twice: expected ErrChannelConflict, got %v", err)
+	}
+
+	// Reserved channels are skipped
+	a.reserve(ChannelAssignment{StreamName: "boundary", Engine: 0, Channel: 1})
+	as, err := a.Allocate(hefStream("second", 0, 2, 64, 1), hef.StreamDirectionInput, 1)
+	if err != nil || as.Channel != 2 {
+		t.Errorf("Allocate() after reserve = %+v, %v, expected channel 2", as, err)
+	}
+}
+
+func TestAllocatorValidation(t *testing.T) {
+	props := testProps()
+	props.DmaEnginesCount = 1
+	a := NewChannelAllocator(props)
+
+	_, err := a.Allocate(hefStream("s", 1, 0, 64, 1), hef.StreamDirectionInput, 1)
+	if !errors.Is(err, ErrInvalidEngine) {
+		t.Errorf("engine beyond DmaEnginesCount: expected ErrInvalidEngine, got %v", err)
+	}
+}
+
+func TestAllocatorWithoutHefPlacement(t *testing.T) {
+	a := NewChannelAllocator(testProps())
+
+	inputs := []device.StreamInfo{{Name: "legacy", FrameSize: 100}, hefStream("placed", 0, 0, 64, 1)}
+	in, _, err := a.AllocateNetworkGroup(inputs, nil, 1)
+	if err != nil {
+		t.Fatalf("AllocateNetworkGroup() error = %v", err)
+	}
+
+	if in[0].Engine != 0 || in[0].Channel != 0 || in[0].FromHef {
+		t.Errorf("legacy stream = %+v, expected engine 0 channel 0 picked by allocator", in[0])
+	}
+	if in[1].Channel != 1 || !in[1].FromHef {
+		t.Errorf("placed stream = %+v, expected channel 1", in[1])
+	}
+
+	if as, ok := a.Lookup("legacy"); !ok || as.Channel != 0 {
+		t.Errorf("Lookup(legacy) = %+v, %v", as, ok)
+	}
+	if len(a.Assignments()) != 2 {
+		t.Errorf("Assignments() = %d entries, expected 2", len(a.Assignments()))
+	}
+}
+
+func TestAllocatorExhaustion(t *testing.T) {
+	a := NewChannelAllocator(testProps())
+
+	for i := 0; i < driver.MaxVdmaChannelsPerEngine-driver.VdmaDestChannelsStart; i++ {
+		if _, err := a.Allocate(device.StreamInfo{Name: fmt.Sprintf("out%d", i)}, hef.StreamDirectionOutput, 1); err != nil {
+			t.Fatalf("Allocate() %d error = %v", i, err)
+		}
+	}
+	_, err := a.Allocate(device.StreamInfo{Name: "extra"}, hef.StreamDirectionOutput, 1)
+	if !errors.Is(err, ErrNoFreeChannel) {
+		t.Errorf("expected ErrNoFreeChannel, got %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package stream
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"unsafe"
@@ -23,14 +24,72 @@ type Buffer struct {
 	mapped        bool
 	pageAligned   bool
 	allocatedSize uint64 // includes alignment padding
+	dmabuf        bool   // imported dma-buf; no CPU mapping
+	allocMode     driver.AllocationMode
+	driverHandle  uintptr // low-memory buffer handle for AllocationModeDriver
+	inFlight      bool    // owned by a pending transfer
 }
 
-// AllocateBuffer allocates a page-aligned buffer for DMA
+// AllocateBuffer allocates a page-aligned buffer for DMA using the
+// allocation mode the driver reports for the device
 func AllocateBuffer(dev *driver.DeviceFile, size uint64, direction driver.DmaDataDirection) (*Buffer, error) {
+	return AllocateBufferWithMode(dev, size, direction, deviceAllocationMode(dev))
+}
+
+// AllocateBufferWithMode allocates a page-aligned buffer for DMA, trying
+// the given allocation mode first and falling back to the other one.
+// AllocationModeUserspace maps anonymous memory through scatter-gather;
+// AllocationModeDriver uses driver low-memory buffers, which work on
+// hosts without an IOMMU.
+func AllocateBufferWithMode(dev *driver.DeviceFile, size uint64, direction driver.DmaDataDirection, mode driver.AllocationMode) (*Buffer, error) {
 	if size == 0 {
 		return nil, fmt.Errorf("buffer size cannot be zero")
 	}
 
+	var errs []error
+	for _, m := range allocationOrder(mode) {
+		var buf *Buffer
+		var err error
+		if m == driver.AllocationModeDriver {
+			buf, err = allocateDriverBuffer(dev, size, direction)
+		} else {
+			buf, err = allocateUserspaceBuffer(dev, size, direction)
+		}
+		if err == nil {
+			return buf, nil
+		}
+		errs = append(errs, fmt.Errorf("%s allocation: %w", allocationModeName(m), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// allocationOrder returns the modes to try, preferred mode first
+func allocationOrder(preferred driver.AllocationMode) []driver.AllocationMode {
+	if preferred == driver.AllocationModeDriver {
+		return []driver.AllocationMode{driver.AllocationModeDriver, driver.AllocationModeUserspace}
+	}
+	return []driver.AllocationMode{driver.AllocationModeUserspace, driver.AllocationModeDriver}
+}
+
+func allocationModeName(mode driver.AllocationMode) string {
+	if mode == driver.AllocationModeDriver {
+		return "driver"
+	}
+	return "userspace"
+}
+
+// deviceAllocationMode returns the mode the driver was loaded with,
+// defaulting to userspace when properties cannot be queried
+func deviceAllocationMode(dev *driver.DeviceFile) driver.AllocationMode {
+	props, err := dev.QueryDeviceProperties()
+	if err != nil {
+		return driver.AllocationModeUserspace
+	}
+	return props.AllocationMode
+}
+
+// allocateUserspaceBuffer mmaps anonymous memory and maps it for DMA
+func allocateUserspaceBuffer(dev *driver.DeviceFile, size uint64, direction driver.DmaDataDirection) (*Buffer, error) {
 	// Round up to page size for alignment
 	alignedSize := ((size + PageSize - 1) / PageSize) * PageSize
 
@@ -49,6 +108,7 @@ func AllocateBuffer(dev *driver.DeviceFile, size uint64, direction driver.DmaDat
 		device:        dev,
 		pageAligned:   true,
 		allocatedSize: alignedSize,
+		allocMode:     driver.AllocationModeUserspace,
 	}
 
 	// Map the buffer for DMA
@@ -69,6 +129,48 @@ func AllocateBuffer(dev *driver.DeviceFile, size uint64, direction driver.DmaDat
 	return buf, nil
 }
 
+// allocateDriverBuffer allocates a driver low-memory buffer, mmaps it from
+// the device file and maps it for DMA
+func allocateDriverBuffer(dev *driver.DeviceFile, size uint64, direction driver.DmaDataDirection) (*Buffer, error) {
+	alignedSize := ((size + PageSize - 1) / PageSize) * PageSize
+
+	driverHandle, err := dev.VdmaLowMemoryBufferAlloc(alignedSize)
+	if err != nil {
+		return nil, fmt.Errorf("VdmaLowMemoryBufferAlloc failed: %w", err)
+	}
+
+	data, err := dev.MmapBuffer(driverHandle, alignedSize)
+	if err != nil {
+		dev.VdmaLowMemoryBufferFree(driverHandle)
+		return nil, err
+	}
+
+	handle, err := dev.VdmaBufferMapAllocated(
+		uintptr(unsafe.Pointer(&data[0])),
+		alignedSize,
+		direction,
+		driverHandle,
+	)
+	if err != nil {
+		unix.Munmap(data)
+		dev.VdmaLowMemoryBufferFree(driverHandle)
+		return nil, fmt.Errorf("VdmaBufferMap failed: %w", err)
+	}
+
+	return &Buffer{
+		data:          data[:size],
+		size:          size,
+		mappedHandle:  handle,
+		direction:     direction,
+		device:        dev,
+		mapped:        true,
+		pageAligned:   true,
+		allocatedSize: alignedSize,
+		allocMode:     driver.AllocationModeDriver,
+		driverHandle:  driverHandle,
+	}, nil
+}
+
 // WrapBuffer wraps an existing byte slice for DMA
 // The slice must be page-aligned for proper DMA operation
 func WrapBuffer(dev *driver.DeviceFile, data []byte, direction driver.DmaDataDirection) (*Buffer, error) {
@@ -103,6 +205,36 @@ func WrapBuffer(dev *driver.DeviceFile, data []byte, direction driver.DmaDataDir
 	}, nil
 }
 
+// ImportDmabuf maps a dma-buf file descriptor exported by another driver
+// (V4L2, libcamera, DRM) so transfers can DMA directly into or out of it.
+// The fd stays owned by the caller and must remain open until Close.
+// Cache coherency is the exporter's responsibility: bracket CPU access with
+// DMA_BUF_IOCTL_SYNC on the fd rather than SyncForDevice/SyncForCPU.
+func ImportDmabuf(dev *driver.DeviceFile, fd int, size uint64, direction driver.DmaDataDirection) (*Buffer, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid dma-buf fd %d", fd)
+	}
+	if size == 0 {
+		return nil, fmt.Errorf("buffer size cannot be zero")
+	}
+
+	// For dma-buf buffers the driver takes the fd in place of the user address
+	handle, err := dev.VdmaBufferMap(uintptr(fd), size, direction, driver.DmaDmabufBuffer)
+	if err != nil {
+		return nil, fmt.Errorf("VdmaBufferMap failed: %w", err)
+	}
+
+	return &Buffer{
+		size:          size,
+		mappedHandle:  handle,
+		direction:     direction,
+		device:        dev,
+		mapped:        true,
+		allocatedSize: size,
+		dmabuf:        true,
+	}, nil
+}
+
 // Data returns the buffer data
 func (b *Buffer) Data() []byte {
 	return b.data
@@ -132,6 +264,46 @@ func (b *Buffer) Direction() driver.DmaDataDirection {
 	return b.direction
 }
 
+// AllocationMode returns how the buffer memory was allocated
+func (b *Buffer) AllocationMode() driver.AllocationMode {
+	return b.allocMode
+}
+
+// IsDmabuf returns whether the buffer was imported from a dma-buf fd
+func (b *Buffer) IsDmabuf() bool {
+	return b.dmabuf
+}
+
+// InFlight returns whether a pending transfer owns the buffer
+func (b *Buffer) InFlight() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.inFlight
+}
+
+// acquire hands the buffer to a transfer; a buffer can only back one
+// transfer at a time
+func (b *Buffer) acquire() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.mapped {
+		return fmt.Errorf("buffer not mapped")
+	}
+	if b.inFlight {
+		return ErrBufferInFlight
+	}
+	b.inFlight = true
+	return nil
+}
+
+// release returns the buffer to the caller once its transfer completed
+func (b *Buffer) release() {
+	b.mu.Lock()
+	b.inFlight = false
+	b.mu.Unlock()
+}
+
 // SyncForDevice synchronizes buffer for device access (before DMA to device)
 func (b *Buffer) SyncForDevice() error {
 	b.mu.Lock()
@@ -140,6 +312,9 @@ func (b *Buffer) SyncForDevice() error {
 	if !b.mapped {
 		return fmt.Errorf("buffer not mapped")
 	}
+	if b.dmabuf {
+		return nil
+	}
 
 	return b.device.VdmaBufferSync(b.mappedHandle, driver.SyncForDevice, 0, b.allocatedSize)
 }
@@ -152,15 +327,37 @@ func (b *Buffer) SyncForCPU() error {
 	if !b.mapped {
 		return fmt.Errorf("buffer not mapped")
 	}
+	if b.dmabuf {
+		return nil
+	}
 
 	return b.device.VdmaBufferSync(b.mappedHandle, driver.SyncForCpu, 0, b.allocatedSize)
 }
 
+// SyncRangeForCPU synchronizes part of the buffer for CPU access
+func (b *Buffer) SyncRangeForCPU(offset, size uint64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.mapped {
+		return fmt.Errorf("buffer not mapped")
+	}
+	if b.dmabuf {
+		return nil
+	}
+
+	return b.device.VdmaBufferSync(b.mappedHandle, driver.SyncForCpu, offset, size)
+}
+
 // Close releases the buffer resources
 func (b *Buffer) Close() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if b.inFlight {
+		return ErrBufferInFlight
+	}
+
 	if b.mapped {
 		err := b.device.VdmaBufferUnmap(b.mappedHandle)
 		if err != nil {
@@ -179,6 +376,13 @@ func (b *Buffer) Close() error {
 		b.data = nil
 	}
 
+	if b.allocMode == driver.AllocationModeDriver && b.driverHandle != 0 {
+		if err := b.device.VdmaLowMemoryBufferFree(b.driverHandle); err != nil {
+			return fmt.Errorf("VdmaLowMemoryBufferFree failed: %w", err)
+		}
+		b.driverHandle = 0
+	}
+
 	return nil
 }
 
@@ -187,13 +391,20 @@ type BufferPool struct {
 	device    *driver.DeviceFile
 	bufSize   uint64
 	direction driver.DmaDataDirection
+	mode      driver.AllocationMode
 	pool      chan *Buffer
 	mu        sync.Mutex
 	closed    bool
 }
 
-// NewBufferPool creates a new buffer pool
+// NewBufferPool creates a new buffer pool using the device's allocation mode
 func NewBufferPool(dev *driver.DeviceFile, bufSize uint64, poolSize int, direction driver.DmaDataDirection) (*BufferPool, error) {
+	return NewBufferPoolWithMode(dev, bufSize, poolSize, direction, deviceAllocationMode(dev))
+}
+
+// NewBufferPoolWithMode creates a new buffer pool with a preferred
+// allocation mode; see AllocateBufferWithMode
+func NewBufferPoolWithMode(dev *driver.DeviceFile, bufSize uint64, poolSize int, direction driver.DmaDataDirection, mode driver.AllocationMode) (*BufferPool, error) {
 	if poolSize <= 0 {
 		return nil, fmt.Errorf("pool size must be positive")
 	}
@@ -202,12 +413,13 @@ func NewBufferPool(dev *driver.DeviceFile, bufSize uint64, poolSize int, directi
 		device:    dev,
 		bufSize:   bufSize,
 		direction: direction,
+		mode:      mode,
 		pool:      make(chan *Buffer, poolSize),
 	}
 
 	// Pre-allocate buffers
 	for i := 0; i < poolSize; i++ {
-		buf, err := AllocateBuffer(dev, bufSize, direction)
+		buf, err := AllocateBufferWithMode(dev, bufSize, direction, mode)
 		if err != nil {
 			// Clean up already allocated buffers
 			bp.Close()
@@ -282,6 +494,11 @@ func (bp *BufferPool) Close() error {
 	return lastErr
 }
 
+// Mode returns the pool's preferred allocation mode
+func (bp *BufferPool) Mode() driver.AllocationMode {
+	return bp.mode
+}
+
 // Available returns the number of available buffers in the pool
 func (bp *BufferPool) Available() int {
 	return len(bp.pool)
@@ -4,6 +4,8 @@ package stream
 
 import (
 	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
 )
 
 func TestPageSizeConstant(t *testing.T) {
@@ -121,3 +123,81 @@ func TestBufferPoolTryGet(t *testing.T) {
 		t.Error("TryGet() returned wrong buffer")
 	}
 }
+
+func TestBufferOwnership(t *testing.T) {
+	buf := &Buffer{data: make([]byte, PageSize), size: PageSize, mapped: true}
+
+	if err := buf.acquire(); err != nil {
+		t.Fatalf("acquire() error = %v", err)
+	}
+	if !buf.InFlight() {
+		t.Error("InFlight() = false after acquire")
+	}
+
+	// A second transfer cannot take the same buffer, and it cannot be closed
+	if err := buf.acquire(); err != ErrBufferInFlight {
+		t.Errorf("second acquire() = %v, expected ErrBufferInFlight", err)
+	}
+	if err := buf.Close(); err != ErrBufferInFlight {
+		t.Errorf("Close() while in flight = %v, expected ErrBufferInFlight", err)
+	}
+
+	buf.release()
+	if buf.InFlight() {
+		t.Error("InFlight() = true after release")
+	}
+	if err := buf.acquire(); err != nil {
+		t.Errorf("acquire() after release error = %v", err)
+	}
+}
+
+func TestBufferAcquireUnmapped(t *testing.T) {
+	buf := &Buffer{data: make([]byte, PageSize), size: PageSize}
+	if err := buf.acquire(); err == nil {
+		t.Error("acquire() of unmapped buffer should fail")
+	}
+}
+
+func TestImportDmabufInvalidFd(t *testing.T) {
+	if _, err := ImportDmabuf(nil, -1, PageSize, driver.DmaToDevice); err == nil {
+		t.Error("ImportDmabuf() with negative fd should fail")
+	}
+}
+
+func TestDmabufSkipsCPUSync(t *testing.T) {
+	// Coherency of imported buffers is handled by the exporter, so syncing
+	// must not reach the (absent) device
+	buf := &Buffer{size: PageSize, mapped: true, dmabuf: true}
+	if err := buf.SyncForDevice(); err != nil {
+		t.Errorf("SyncForDevice() error = %v", err)
+	}
+	if err := buf.SyncForCPU(); err != nil {
+		t.Errorf("SyncForCPU() error = %v", err)
+	}
+}
+
+func TestAllocationOrder(t *testing.T) {
+	tests := []struct {
+		preferred driver.AllocationMode
+		expected  []driver.AllocationMode
+	}{
+		{driver.AllocationModeUserspace, []driver.AllocationMode{driver.AllocationModeUserspace, driver.AllocationModeDriver}},
+		{driver.AllocationModeDriver, []driver.AllocationMode{driver.AllocationModeDriver, driver.AllocationModeUserspace}},
+	}
+
+	for _, tt := range tests {
+		got := allocationOrder(tt.preferred)
+		if len(got) != len(tt.expected) || got[0] != tt.expected[0] || got[1] != tt.expected[1] {
+			t.Errorf("allocationOrder(%d) = %v, expected %v", tt.preferred, got, tt.expected)
+		}
+	}
+}
+
+func TestAllocateBufferWithModeZeroSize(t *testing.T) {
+	if _, err := AllocateBufferWithMode(nil, 0, driver.DmaToDevice, driver.AllocationModeDriver); err == nil {
+		t.Error("AllocateBufferWithMode() with zero size should fail")
+	}
+	if _, err := AllocateContinuousBuffer(nil, 0); err == nil {
+		t.Error("AllocateContinuousBuffer() with zero size should fail")
+	}
+}
@@ -1,6 +1,8 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,6 +17,11 @@ type VStreamParams struct {
 	Timeout    time.Duration
 	QueueDepth int
 	BatchSize  uint32
+	Recovery   RecoveryOptions
+
+	// RingDepth reads outputs through rings of that many pre-armed
+	// batches; zero arms one read at a time
+	RingDepth int
 }
 
 // DefaultVStreamParams returns default VStream parameters
@@ -60,6 +67,27 @@ func (vs *VStreamSet) Close() error {
 	return lastErr
 }
 
+// Reset drops every transfer in flight, for use after the device was
+// reset under the streams. Pending writes and reads are aborted and their
+// caller buffers handed back; started rings re-arm.
+func (vs *VStreamSet) Reset() error {
+	var errs []error
+
+	for _, input := range vs.Inputs {
+		if err := input.reset(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reset %s: %w", input.info.Name, err))
+		}
+	}
+
+	for _, output := range vs.Outputs {
+		if err := output.reset(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to reset %s: %w", output.info.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // InputByName returns the input VStream with the given name
 func (vs *VStreamSet) InputByName(name string) *InputVStream {
 	for _, input := range vs.Inputs {
@@ -80,89 +108,100 @@ func (vs *VStreamSet) OutputByName(name string) *OutputVStream {
 	return nil
 }
 
-// BuildVStreams creates VStreams from a configured network group
-func BuildVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams) (*VStreamSet, error) {
-	dev := ng.Device().DeviceFile()
+// Infer runs one inference over the whole set: it arms every output read,
+// writes each input, waits for the inputs to drain and collects the
+// outputs. inputs must hold a full batch for every input stream, keyed by
+// stream name. The result is keyed by output stream name. Inputs are
+// validated before anything is armed; a failure after that aborts every
+// transfer, so the next inference starts clean.
+func (vs *VStreamSet) Infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	for _, input := range vs.Inputs {
+		data, ok := inputs[input.info.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing input %q", ErrInvalidData, input.info.Name)
+		}
+		if want := input.info.FrameSize * uint64(input.batchSize); uint64(len(data)) != want {
+			return nil, fmt.Errorf("%w: input %q: expected %d bytes for a batch of %d, got %d",
+				ErrInvalidData, input.info.Name, want, input.batchSize, len(data))
+		}
+	}
+	for name := range inputs {
+		if vs.InputByName(name) == nil {
+			return nil, fmt.Errorf("%w: unknown input %q", ErrInvalidData, name)
+		}
+	}
 
-	inputInfos := ng.InputStreamInfos()
-	outputInfos := ng.OutputStreamInfos()
+	outputs, err := vs.infer(ctx, inputs)
+	if err != nil {
+		if rerr := vs.Reset(); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+		return nil, err
+	}
+	return outputs, nil
+}
 
-	channels := NewChannelSet(dev)
+// infer runs a validated inference
+func (vs *VStreamSet) infer(ctx context.Context, inputs map[string][]byte) (map[string][]byte, error) {
+	// Outputs must be posted before the inputs start the network
+	for _, output := range vs.Outputs {
+		if err := output.StartRead(); err != nil {
+			return nil, fmt.Errorf("failed to start read on %s: %w", output.info.Name, err)
+		}
+	}
 
-	inputs := make([]*InputVStream, len(inputInfos))
-	outputs := make([]*OutputVStream, len(outputInfos))
-
-	// Create input VStreams
-	// Input channels use engine 0, channels 0-15
-	for i, info := range inputInfos {
-		channelIdx := uint8(i % 16)
-		channel := channels.AddChannel(0, channelIdx)
-
-		vsInfo := VStreamInfo{
-			Name:      info.Name,
-			FrameSize: info.FrameSize,
-			Height:    info.Height,
-			Width:     info.Width,
-			Channels:  info.Channels,
-			QuantInfo: info.QuantInfo,
+	for _, input := range vs.Inputs {
+		if err := input.WriteContext(ctx, inputs[input.info.Name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", input.info.Name, err)
 		}
+	}
+	for _, input := range vs.Inputs {
+		if err := input.FlushContext(ctx); err != nil {
+			return nil, fmt.Errorf("failed to flush %s: %w", input.info.Name, err)
+		}
+	}
 
-		input, err := NewInputVStream(InputVStreamConfig{
-			Info:       vsInfo,
-			Device:     dev,
-			Channel:    channel,
-			Timeout:    params.Timeout,
-			BatchSize:  params.BatchSize,
-			QueueDepth: params.QueueDepth,
-		})
+	outputs := make(map[string][]byte, len(vs.Outputs))
+	for _, output := range vs.Outputs {
+		data, err := output.ReadContext(ctx)
 		if err != nil {
-			// Clean up already created streams
-			for j := 0; j < i; j++ {
-				inputs[j].Close()
-			}
-			return nil, fmt.Errorf("failed to create input VStream %s: %w", info.Name, err)
+			return nil, fmt.Errorf("failed to read %s: %w", output.info.Name, err)
 		}
+		outputs[output.info.Name] = data
+	}
+	return outputs, nil
+}
 
-		inputs[i] = input
+// BuildVStreams creates VStreams from a configured network group. Each
+// stream runs on the vDMA engine its HEF edge layer specifies, on a channel
+// the ChannelAllocator picks from its direction's range. The edge layer's
+// stream index is the core-side index the context switch connects to that
+// channel, not a vDMA channel number, so it does not select the channel.
+func BuildVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams) (*VStreamSet, error) {
+	dev := ng.Device().DeviceFile()
+
+	inputInfos := ng.InputStreamInfos()
+	outputInfos := ng.OutputStreamInfos()
+
+	allocator := NewChannelAllocator(ng.Device().Properties())
+	inAssign, outAssign, err := allocator.AllocateNetworkGroup(inputInfos, outputInfos, params.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate vDMA channels: %w", err)
 	}
 
-	// Create output VStreams
-	// Output channels use engine 0, channels 16-31
-	for i, info := range outputInfos {
-		channelIdx := uint8(16 + (i % 16))
-		channel := channels.AddChannel(0, channelIdx)
-
-		vsInfo := VStreamInfo{
-			Name:      info.Name,
-			FrameSize: info.FrameSize,
-			Height:    info.Height,
-			Width:     info.Width,
-			Channels:  info.Channels,
-			QuantInfo: info.QuantInfo,
-		}
+	channels := NewChannelSet(dev)
 
-		output, err := NewOutputVStream(OutputVStreamConfig{
-			Info:       vsInfo,
-			Device:     dev,
-			Channel:    channel,
-			Timeout:    params.Timeout,
-			BatchSize:  params.BatchSize,
-			QueueDepth: params.QueueDepth,
-		})
-		if err != nil {
-			// Clean up
-			for _, in := range inputs {
-				if in != nil {
-					in.Close()
-				}
-			}
-			for j := 0; j < i; j++ {
-				outputs[j].Close()
-			}
-			return nil, fmt.Errorf("failed to create output VStream %s: %w", info.Name, err)
-		}
+	inputs, err := buildInputs(dev, channels, inputInfos, inAssign, params)
+	if err != nil {
+		return nil, err
+	}
 
-		outputs[i] = output
+	outputs, err := buildOutputs(dev, channels, outputInfos, outAssign, params)
+	if err != nil {
+		for _, in := range inputs {
+			in.Close()
+		}
+		return nil, err
 	}
 
 	// Enable all channels
@@ -190,31 +229,88 @@ func BuildInputVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams)
 	dev := ng.Device().DeviceFile()
 	inputInfos := ng.InputStreamInfos()
 
-	inputs := make([]*InputVStream, len(inputInfos))
+	allocator := NewChannelAllocator(ng.Device().Properties())
+	assignments, _, err := allocator.AllocateNetworkGroup(inputInfos, nil, params.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate vDMA channels: %w", err)
+	}
+
 	channels := NewChannelSet(dev)
+	inputs, err := buildInputs(dev, channels, inputInfos, assignments, params)
+	if err != nil {
+		return nil, err
+	}
 
-	for i, info := range inputInfos {
-		channelIdx := uint8(i % 16)
-		channel := channels.AddChannel(0, channelIdx)
-
-		vsInfo := VStreamInfo{
-			Name:      info.Name,
-			FrameSize: info.FrameSize,
-			Height:    info.Height,
-			Width:     info.Width,
-			Channels:  info.Channels,
-			QuantInfo: info.QuantInfo,
+	if err := channels.EnableAll(false); err != nil {
+		for _, in := range inputs {
+			in.Close()
 		}
+		return nil, fmt.Errorf("failed to enable channels: %w", err)
+	}
+
+	return inputs, nil
+}
+
+// BuildOutputVStreams creates only output VStreams
+func BuildOutputVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams) ([]*OutputVStream, error) {
+	dev := ng.Device().DeviceFile()
+	outputInfos := ng.OutputStreamInfos()
+
+	allocator := NewChannelAllocator(ng.Device().Properties())
+	_, assignments, err := allocator.AllocateNetworkGroup(nil, outputInfos, params.BatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate vDMA channels: %w", err)
+	}
+
+	channels := NewChannelSet(dev)
+	outputs, err := buildOutputs(dev, channels, outputInfos, assignments, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := channels.EnableAll(false); err != nil {
+		for _, out := range outputs {
+			out.Close()
+		}
+		return nil, fmt.Errorf("failed to enable channels: %w", err)
+	}
+
+	return outputs, nil
+}
+
+// vstreamInfo converts device stream information
+func vstreamInfo(info device.StreamInfo) VStreamInfo {
+	return VStreamInfo{
+		Name:      info.Name,
+		FrameSize: info.FrameSize,
+		Height:    info.Height,
+		Width:     info.Width,
+		Channels:  info.Channels,
+		Format:    info.Format.Type,
+		QuantInfo: info.QuantInfo,
+	}
+}
+
+// buildInputs creates input VStreams on their assigned channels
+func buildInputs(dev *driver.DeviceFile, channels *ChannelSet, infos []device.StreamInfo, assignments []ChannelAssignment, params VStreamParams) ([]*InputVStream, error) {
+	inputs := make([]*InputVStream, len(infos))
+	for i, info := range infos {
+		as := assignments[i]
+		channel := channels.AddChannel(as.Engine, as.Channel)
 
 		input, err := NewInputVStream(InputVStreamConfig{
-			Info:       vsInfo,
+			Info:       vstreamInfo(info),
 			Device:     dev,
 			Channel:    channel,
 			Timeout:    params.Timeout,
 			BatchSize:  params.BatchSize,
 			QueueDepth: params.QueueDepth,
+			PageSize:   as.PageSize,
+			DescCount:  as.DescCount,
+			Recovery:   params.Recovery,
 		})
 		if err != nil {
+			// Clean up already created streams
 			for j := 0; j < i; j++ {
 				inputs[j].Close()
 			}
@@ -223,45 +319,27 @@ func BuildInputVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams)
 
 		inputs[i] = input
 	}
-
-	if err := channels.EnableAll(false); err != nil {
-		for _, in := range inputs {
-			in.Close()
-		}
-		return nil, fmt.Errorf("failed to enable channels: %w", err)
-	}
-
 	return inputs, nil
 }
 
-// BuildOutputVStreams creates only output VStreams
-func BuildOutputVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams) ([]*OutputVStream, error) {
-	dev := ng.Device().DeviceFile()
-	outputInfos := ng.OutputStreamInfos()
-
-	outputs := make([]*OutputVStream, len(outputInfos))
-	channels := NewChannelSet(dev)
-
-	for i, info := range outputInfos {
-		channelIdx := uint8(16 + (i % 16))
-		channel := channels.AddChannel(0, channelIdx)
-
-		vsInfo := VStreamInfo{
-			Name:      info.Name,
-			FrameSize: info.FrameSize,
-			Height:    info.Height,
-			Width:     info.Width,
-			Channels:  info.Channels,
-			QuantInfo: info.QuantInfo,
-		}
+// buildOutputs creates output VStreams on their assigned channels
+func buildOutputs(dev *driver.DeviceFile, channels *ChannelSet, infos []device.StreamInfo, assignments []ChannelAssignment, params VStreamParams) ([]*OutputVStream, error) {
+	outputs := make([]*OutputVStream, len(infos))
+	for i, info := range infos {
+		as := assignments[i]
+		channel := channels.AddChannel(as.Engine, as.Channel)
 
 		output, err := NewOutputVStream(OutputVStreamConfig{
-			Info:       vsInfo,
+			Info:       vstreamInfo(info),
 			Device:     dev,
 			Channel:    channel,
 			Timeout:    params.Timeout,
 			BatchSize:  params.BatchSize,
 			QueueDepth: params.QueueDepth,
+			PageSize:   as.PageSize,
+			DescCount:  as.DescCount,
+			Recovery:   params.Recovery,
+			RingDepth:  params.RingDepth,
 		})
 		if err != nil {
 			for j := 0; j < i; j++ {
@@ -272,13 +350,5 @@ func BuildOutputVStreams(ng *device.ConfiguredNetworkGroup, params VStreamParams
 
 		outputs[i] = output
 	}
-
-	if err := channels.EnableAll(false); err != nil {
-		for _, out := range outputs {
-			out.Close()
-		}
-		return nil, fmt.Errorf("failed to enable channels: %w", err)
-	}
-
 	return outputs, nil
 }
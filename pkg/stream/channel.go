@@ -1,6 +1,8 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -88,6 +90,11 @@ func (c *VdmaChannel) Disable() error {
 
 // LaunchTransfer launches a DMA transfer on this channel
 func (c *VdmaChannel) LaunchTransfer(descList *DescriptorList, buffer *Buffer, startingDesc uint32, shouldBind bool, firstInterruptsDomain, lastInterruptsDomain driver.InterruptsDomain) error {
+	return c.LaunchTransferRange(descList, buffer, 0, uint32(buffer.Size()), startingDesc, shouldBind, firstInterruptsDomain, lastInterruptsDomain)
+}
+
+// LaunchTransferRange launches a DMA transfer covering part of a buffer
+func (c *VdmaChannel) LaunchTransferRange(descList *DescriptorList, buffer *Buffer, offset, size uint32, startingDesc uint32, shouldBind bool, firstInterruptsDomain, lastInterruptsDomain driver.InterruptsDomain) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -97,7 +104,7 @@ func (c *VdmaChannel) LaunchTransfer(descList *DescriptorList, buffer *Buffer, s
 
 	// Create packed transfer buffer with 4.20.0 layout:
 	// mapped_buffer_handle (8 bytes), offset (4 bytes), size (4 bytes)
-	transferBuf := driver.NewPackedVdmaTransferBuffer(buffer.Handle(), 0, uint32(buffer.Size()))
+	transferBuf := driver.NewPackedVdmaTransferBuffer(buffer.Handle(), offset, size)
 	buffers := []driver.PackedVdmaTransferBuffer{*transferBuf}
 
 	descsProgramed, launchStatus, err := c.device.VdmaLaunchTransfer(
@@ -128,15 +135,96 @@ func (c *VdmaChannel) WaitForInterrupt() error {
 	return c.WaitForInterruptWithTimeout(driver.InferenceTimeout)
 }
 
-// WaitForInterruptWithTimeout waits for transfer completion with a custom timeout
+// WaitForInterruptWithTimeout waits for transfer completion with a custom
+// timeout. DMA errors reported for the channel are returned as
+// *driver.VdmaChannelError.
 func (c *VdmaChannel) WaitForInterruptWithTimeout(timeout time.Duration) error {
+	_, err := c.WaitForTransfers(timeout)
+	return err
+}
+
+// WaitForTransfers waits for an interrupt and returns how many transfers
+// completed on this channel. Zero means the wake-up was for another reason.
+func (c *VdmaChannel) WaitForTransfers(timeout time.Duration) (int, error) {
 	var bitmap [driver.MaxVdmaEngines]uint32
 	bitmap[c.engineIndex] = 1 << c.channelIndex
 
-	_, err := c.device.VdmaInterruptsWaitWithTimeout(bitmap, timeout)
+	params, err := c.device.VdmaInterruptsWaitWithTimeout(bitmap, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return c.completed(params)
+}
+
+// WaitForInterruptContext waits for transfer completion until ctx is done,
+// at most driver.InferenceTimeout
+func (c *VdmaChannel) WaitForInterruptContext(ctx context.Context) error {
+	_, err := c.WaitForTransfersContext(ctx)
 	return err
 }
 
+// WaitForTransfersContext is like WaitForTransfers but waits until ctx is
+// done, at most driver.InferenceTimeout. Giving up aborts the wait by
+// disabling the channel, which drops its pending transfers; the channel is
+// enabled again before returning. Running out of InferenceTimeout is a
+// device timeout and, unlike the caller's own deadline, does not wrap a
+// context error.
+func (c *VdmaChannel) WaitForTransfersContext(ctx context.Context) (int, error) {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, driver.InferenceTimeout)
+	defer cancel()
+
+	var bitmap [driver.MaxVdmaEngines]uint32
+	bitmap[c.engineIndex] = 1 << c.channelIndex
+
+	params, err := c.device.VdmaInterruptsWaitContext(ctx, bitmap)
+	if err != nil {
+		if ctx.Err() != nil {
+			if parent.Err() == nil {
+				err = driver.NewError(driver.StatusTimeout,
+					fmt.Sprintf("transfers on channel %d:%d did not complete within %v", c.engineIndex, c.channelIndex, driver.InferenceTimeout))
+			}
+			if rerr := c.reenable(); rerr != nil {
+				err = errors.Join(err, rerr)
+			}
+		}
+		return 0, err
+	}
+	return c.completed(params)
+}
+
+// completed counts the transfers finished on this channel
+func (c *VdmaChannel) completed(params *driver.PackedVdmaInterruptsWaitParams) (int, error) {
+	completed := 0
+	for _, irq := range params.Channels() {
+		if irq.Engine != c.engineIndex || irq.Channel != c.channelIndex {
+			continue
+		}
+		if err := irq.Err(); err != nil {
+			return completed, err
+		}
+		completed += int(irq.TransfersCompleted)
+	}
+	return completed, nil
+}
+
+// reenable enables the channel after the driver disabled it behind our back
+func (c *VdmaChannel) reenable() error {
+	c.mu.Lock()
+	c.enabled = false
+	c.mu.Unlock()
+	return c.Enable(false)
+}
+
+// Reset disables and re-enables the channel, clearing its error state and
+// returning its descriptor position to the start of the list
+func (c *VdmaChannel) Reset() error {
+	if err := c.Disable(); err != nil {
+		return err
+	}
+	return c.Enable(false)
+}
+
 // ChannelSet manages a set of VDMA channels
 type ChannelSet struct {
 	channels []*VdmaChannel
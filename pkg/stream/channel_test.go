@@ -3,6 +3,7 @@
 package stream
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/anthropics/purple-hailo/pkg/driver"
@@ -134,3 +135,13 @@ func TestNewChannelSet(t *testing.T) {
 		t.Errorf("New ChannelSet should have 0 channels, got %d", cs.Count())
 	}
 }
+
+func TestIsChannelError(t *testing.T) {
+	chErr := driver.ChannelIrq{IsActive: true, ValidationSuccess: true, HostError: driver.VdmaErrorAxi}.Err()
+	if !IsChannelError(fmt.Errorf("wait failed: %w", chErr)) {
+		t.Error("wrapped channel error not recognized")
+	}
+	if IsChannelError(driver.NewError(driver.StatusTimeout, "wait")) {
+		t.Error("timeouts must not be treated as channel errors")
+	}
+}
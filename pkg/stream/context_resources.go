@@ -0,0 +1,368 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/control"
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// CfgDescPageSize is the descriptor page size of config channels
+const CfgDescPageSize = 512
+
+// ContextResources owns the host memory the context switch moves data
+// through between contexts: one buffer per inter-context edge, shared by
+// the output that fills it and the inputs that drain it, and one per DDR
+// edge. Boundary edges get their channel only; the VStreams bind their
+// own descriptor lists to those channels. It also owns the config buffers
+// that carry each context's CCW writes, the network's weights and
+// configuration, to the config channels.
+type ContextResources struct {
+	resources  *control.ContextResources
+	buffers    []*Buffer
+	descLists  []*DescriptorList
+	continuous []*ContinuousBuffer
+}
+
+// producerKey names a shared buffer by the output that fills it
+type producerKey struct {
+	context  uint32
+	sysIndex uint32
+}
+
+// PrepareContextResources allocates a vDMA channel for every edge layer of
+// every context of a network group, host buffers for the edges that stay
+// on the device side and config buffers loaded with the CCW writes of the
+// preliminary and dynamic contexts, then hands the allocation to the
+// network group for its next activation. Close the resources after
+// deactivating.
+func PrepareContextResources(ng *device.ConfiguredNetworkGroup, batchSize uint32) (*ContextResources, error) {
+	if batchSize == 0 {
+		batchSize = 1
+	}
+
+	r := &ContextResources{resources: control.NewContextResources()}
+	dev := ng.Device().DeviceFile()
+	props := ng.Device().Properties()
+	interContext := make(map[producerKey]control.HostBufferInfo)
+
+	// Boundary edges use the channels the VStreams bind to, allocated the
+	// way BuildVStreams allocates them, in every context
+	boundary := NewChannelAllocator(props)
+	if _, _, err := boundary.AllocateNetworkGroup(ng.InputStreamInfos(), ng.OutputStreamInfos(), batchSize); err != nil {
+		return nil, fmt.Errorf("boundary streams: %w", err)
+	}
+
+	// Channels used by any context are not free for config channels
+	used := make(map[channelKey]bool)
+
+	for _, cfg := range ng.Contexts() {
+		// Edges of one context run at the same time and must not share a
+		// channel; different contexts reuse the non-boundary channels
+		allocator := NewChannelAllocator(props)
+		for _, as := range boundary.Assignments() {
+			allocator.reserve(as)
+		}
+		ddr := make(map[uint32]control.HostBufferInfo) // by output stream index
+
+		for i := range cfg.EdgeLayers {
+			edge := &cfg.EdgeLayers[i]
+			assignment, err := edgeAssignment(allocator, boundary, edge, batchSize)
+			if err != nil {
+				r.Close()
+				return nil, fmt.Errorf("context %d: %w", cfg.Index, err)
+			}
+			used[channelKey{assignment.Engine, assignment.Channel}] = true
+
+			ch := control.EdgeChannel{
+				Engine:  assignment.Engine,
+				Channel: assignment.Channel,
+				Buffer: control.HostBufferInfo{
+					BufferType:     control.HostBufferTypeExternalDesc,
+					DescPageSize:   assignment.PageSize,
+					TotalDescCount: uint32(assignment.DescCount),
+					BytesInPattern: uint32(edge.FrameSize()),
+				},
+			}
+
+			switch edge.ConnectionType {
+			case hef.EdgeConnectionInterContext:
+				key := producerKey{cfg.Index, edge.SysIndex}
+				if edge.Direction == hef.StreamDirectionInput {
+					key = producerKey{edge.ConnectedContextIndex, edge.ConnectedSysIndex}
+				}
+				info, ok := interContext[key]
+				if !ok {
+					info, err = r.allocate(dev, edge.FrameSize()*uint64(batchSize), assignment)
+					if err != nil {
+						r.Close()
+						return nil, fmt.Errorf("context %d: inter-context edge %s: %w", cfg.Index, edge.Name, err)
+					}
+					interContext[key] = info
+				}
+				ch.Buffer = info
+
+			case hef.EdgeConnectionDdr:
+				// The output of a DDR pair allocates; its input reads the
+				// same memory back
+				if edge.Direction == hef.StreamDirectionOutput {
+					info, err := r.allocate(dev, ddrBufferSize(edge), assignment)
+					if err != nil {
+						r.Close()
+						return nil, fmt.Errorf("context %d: DDR edge %s: %w", cfg.Index, edge.Name, err)
+					}
+					ddr[edge.SysIndex] = info
+					ch.Buffer = info
+				}
+			}
+
+			ch.InitialCredit = ch.Buffer.BytesInPattern
+			key := control.EdgeKey{Context: cfg.Index, Name: edge.Name, Direction: edge.Direction}
+			r.resources.Edges[key] = ch
+		}
+
+		// DDR inputs resolve after their outputs have allocated
+		for i := range cfg.EdgeLayers {
+			edge := &cfg.EdgeLayers[i]
+			if edge.ConnectionType != hef.EdgeConnectionDdr || edge.Direction != hef.StreamDirectionInput {
+				continue
+			}
+			info, ok := ddr[edge.ConnectedSysIndex]
+			if !ok {
+				r.Close()
+				return nil, fmt.Errorf("context %d: DDR input %s: no output with stream index %d",
+					cfg.Index, edge.Name, edge.ConnectedSysIndex)
+			}
+			key := control.EdgeKey{Context: cfg.Index, Name: edge.Name, Direction: edge.Direction}
+			ch := r.resources.Edges[key]
+			ch.Buffer = info
+			ch.InitialCredit = info.BytesInPattern
+			r.resources.Edges[key] = ch
+		}
+	}
+
+	if err := r.allocateCfgChannels(ng.CfgChannels(), used); err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	// Hosts without scatter-gather mappings fetch config from contiguous
+	// memory
+	continuous := deviceAllocationMode(dev) == driver.AllocationModeDriver
+	if err := r.loadConfig(dev, control.PreliminaryContextIndex, ng.PreliminaryOperations(), continuous); err != nil {
+		r.Close()
+		return nil, fmt.Errorf("preliminary context: %w", err)
+	}
+	for _, cfg := range ng.Contexts() {
+		if err := r.loadConfig(dev, cfg.Index, cfg.Operations, continuous); err != nil {
+			r.Close()
+			return nil, fmt.Errorf("context %d: %w", cfg.Index, err)
+		}
+	}
+
+	ng.SetContextResources(r.resources)
+	return r, nil
+}
+
+// allocateCfgChannels places each config channel on the first host-to-
+// device channel of its engine that no edge uses
+func (r *ContextResources) allocateCfgChannels(cfgs []hef.CfgChannelInfo, used map[channelKey]bool) error {
+	for _, cfg := range cfgs {
+		engine := uint8(cfg.EngineIndex)
+		first, last := channelRange(hef.StreamDirectionInput)
+
+		found := false
+		for ch := first; ch <= last; ch++ {
+			key := channelKey{engine, ch}
+			if used[key] {
+				continue
+			}
+			used[key] = true
+			r.resources.CfgChannels[cfg.Index] = control.CfgChannel{
+				Engine:       engine,
+				Channel:      ch,
+				DescPageSize: CfgDescPageSize,
+			}
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("config channel %d: %w on engine %d", cfg.Index, ErrNoFreeChannel, engine)
+		}
+	}
+	return nil
+}
+
+// loadConfig places the CCW writes of one context in a config buffer per
+// config channel
+func (r *ContextResources) loadConfig(dev *driver.DeviceFile, contextIndex uint32, ops []hef.ConfigOperation, continuous bool) error {
+	images, err := control.BuildConfigImages(ops, CfgDescPageSize)
+	if err != nil {
+		return err
+	}
+
+	for index, image := range images {
+		cfg, ok := r.resources.CfgChannels[index]
+		if !ok {
+			return fmt.Errorf("CCW writes to config channel %d, which the HEF does not place", index)
+		}
+		info, err := r.loadConfigBuffer(dev, cfg, image, continuous)
+		if err != nil {
+			return fmt.Errorf("config channel %d: %w", index, err)
+		}
+		r.resources.CfgBuffers[control.CfgBufferKey{Context: contextIndex, Index: index}] = info
+	}
+	return nil
+}
+
+// loadConfigBuffer copies a config image into device-visible memory,
+// preferring a continuous buffer when asked and falling back to a buffer
+// mapped by a descriptor list
+func (r *ContextResources) loadConfigBuffer(dev *driver.DeviceFile, cfg control.CfgChannel, image []byte, continuous bool) (control.HostBufferInfo, error) {
+	size := uint64(len(image))
+	pages := uint32(size / uint64(cfg.DescPageSize))
+
+	if continuous {
+		buf, err := AllocateContinuousBuffer(dev, size)
+		if err == nil {
+			r.continuous = append(r.continuous, buf)
+			copy(buf.Data(), image)
+			return control.HostBufferInfo{
+				BufferType:     control.HostBufferTypeCcb,
+				DmaAddress:     buf.DmaAddress(),
+				DescPageSize:   cfg.DescPageSize,
+				TotalDescCount: pages,
+				BytesInPattern: uint32(size),
+			}, nil
+		}
+		// Contiguous memory runs out easily; a descriptor list works too
+	}
+
+	buf, err := AllocateBuffer(dev, size, driver.DmaToDevice)
+	if err != nil {
+		return control.HostBufferInfo{}, err
+	}
+	r.buffers = append(r.buffers, buf)
+
+	copy(buf.Data(), image)
+	if err := buf.SyncForDevice(); err != nil {
+		return control.HostBufferInfo{}, err
+	}
+
+	descList, err := CreateDescriptorList(dev, uint64(pages), cfg.DescPageSize, false)
+	if err != nil {
+		return control.HostBufferInfo{}, err
+	}
+	r.descLists = append(r.descLists, descList)
+
+	// Every context has its own config buffer on the channel, so none is
+	// bound; the activate config channel action hands the firmware its
+	// descriptor list
+	if err := descList.Program(buf, cfg.Channel, 0, false, driver.InterruptsDomainNone); err != nil {
+		return control.HostBufferInfo{}, err
+	}
+
+	return control.HostBufferInfo{
+		BufferType:     control.HostBufferTypeExternalDesc,
+		DmaAddress:     descList.DmaAddress(),
+		DescPageSize:   cfg.DescPageSize,
+		TotalDescCount: pages,
+		BytesInPattern: uint32(size),
+	}, nil
+}
+
+// allocate creates a host buffer mapped by a descriptor list
+func (r *ContextResources) allocate(dev *driver.DeviceFile, size uint64, assignment ChannelAssignment) (control.HostBufferInfo, error) {
+	buf, err := AllocateBuffer(dev, size, driver.DmaBidirectional)
+	if err != nil {
+		return control.HostBufferInfo{}, err
+	}
+	r.buffers = append(r.buffers, buf)
+
+	descCount := CalculateDescCount(size, assignment.PageSize)
+	descList, err := CreateDescriptorList(dev, descCount, assignment.PageSize, false)
+	if err != nil {
+		return control.HostBufferInfo{}, err
+	}
+	r.descLists = append(r.descLists, descList)
+
+	// The firmware drives these transfers, so the host wants no interrupts
+	if err := descList.Program(buf, assignment.Channel, 0, true, driver.InterruptsDomainNone); err != nil {
+		return control.HostBufferInfo{}, err
+	}
+
+	return control.HostBufferInfo{
+		BufferType:     control.HostBufferTypeExternalDesc,
+		DmaAddress:     descList.DmaAddress(),
+		DescPageSize:   assignment.PageSize,
+		TotalDescCount: uint32(descCount),
+		BytesInPattern: uint32(size),
+	}, nil
+}
+
+// Resources returns the channel allocation for the action list builder
+func (r *ContextResources) Resources() *control.ContextResources {
+	return r.resources
+}
+
+// Close releases the descriptor lists and buffers
+func (r *ContextResources) Close() error {
+	var lastErr error
+	for _, buf := range r.continuous {
+		if err := buf.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	for _, dl := range r.descLists {
+		if err := dl.Release(); err != nil {
+			lastErr = err
+		}
+	}
+	for _, buf := range r.buffers {
+		if err := buf.Close(); err != nil {
+			lastErr = err
+		}
+	}
+	r.continuous = nil
+	r.descLists = nil
+	r.buffers = nil
+	return lastErr
+}
+
+// edgeAssignment returns the channel of an edge layer: the boundary
+// stream's channel for boundary edges, a free one of its engine otherwise
+func edgeAssignment(allocator, boundary *ChannelAllocator, edge *hef.EdgeLayer, batchSize uint32) (ChannelAssignment, error) {
+	if edge.ConnectionType != hef.EdgeConnectionBoundary {
+		return allocator.Allocate(edgeStreamInfo(edge), edge.Direction, batchSize)
+	}
+	as, ok := boundary.Lookup(edge.Name)
+	if !ok || as.Direction != edge.Direction {
+		return ChannelAssignment{}, fmt.Errorf("boundary edge %s has no matching stream", edge.Name)
+	}
+	return as, nil
+}
+
+// edgeStreamInfo describes an edge layer to the channel allocator. Edge
+// layers always name their engine.
+func edgeStreamInfo(edge *hef.EdgeLayer) device.StreamInfo {
+	return device.StreamInfo{
+		Name:                edge.Name,
+		FrameSize:           edge.FrameSize(),
+		EngineIndex:         edge.EngineIndex,
+		SysIndex:            edge.SysIndex,
+		CoreBytesPerBuffer:  edge.CoreBytesPerBuffer,
+		CoreBuffersPerFrame: edge.CoreBuffersPerFrame,
+		HasDmaInfo:          true,
+	}
+}
+
+// ddrBufferSize returns the bytes a DDR edge buffers: Buffers rows of one
+// core buffer each, or a whole frame when the HEF does not say
+func ddrBufferSize(edge *hef.EdgeLayer) uint64 {
+	if edge.Buffers == 0 {
+		return edge.FrameSize()
+	}
+	return uint64(edge.CoreBytesPerBuffer) * uint64(edge.Buffers)
+}
@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+	"golang.org/x/sys/unix"
+)
+
+// ContinuousBuffer is a physically contiguous DMA buffer allocated by the
+// driver. Unlike Buffer it is addressed by the device directly through its
+// DMA address instead of a descriptor list, which is what config channels
+// and circular buffers on hosts without an IOMMU need.
+type ContinuousBuffer struct {
+	data       []byte
+	size       uint64
+	handle     uintptr
+	dmaAddress uint64
+	device     *driver.DeviceFile
+	mu         sync.Mutex
+	closed     bool
+}
+
+// AllocateContinuousBuffer allocates a contiguous buffer and maps it into
+// the process. Contiguous memory is scarce, so keep these small.
+func AllocateContinuousBuffer(dev *driver.DeviceFile, size uint64) (*ContinuousBuffer, error) {
+	if size == 0 {
+		return nil, fmt.Errorf("buffer size cannot be zero")
+	}
+
+	alignedSize := ((size + PageSize - 1) / PageSize) * PageSize
+
+	handle, dmaAddress, err := dev.VdmaContinuousBufferAlloc(alignedSize)
+	if err != nil {
+		return nil, fmt.Errorf("VdmaContinuousBufferAlloc failed: %w", err)
+	}
+
+	data, err := dev.MmapBuffer(handle, alignedSize)
+	if err != nil {
+		dev.VdmaContinuousBufferFree(handle)
+		return nil, err
+	}
+
+	return &ContinuousBuffer{
+		data:       data,
+		size:       size,
+		handle:     handle,
+		dmaAddress: dmaAddress,
+		device:     dev,
+	}, nil
+}
+
+// Data returns the buffer data
+func (b *ContinuousBuffer) Data() []byte {
+	return b.data[:b.size]
+}
+
+// Size returns the usable buffer size
+func (b *ContinuousBuffer) Size() uint64 {
+	return b.size
+}
+
+// DmaAddress returns the device-visible address of the buffer
+func (b *ContinuousBuffer) DmaAddress() uint64 {
+	return b.dmaAddress
+}
+
+// Close unmaps and frees the buffer
+func (b *ContinuousBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+
+	var lastErr error
+	if b.data != nil {
+		if err := unix.Munmap(b.data); err != nil {
+			lastErr = fmt.Errorf("munmap failed: %w", err)
+		}
+		b.data = nil
+	}
+	if err := b.device.VdmaContinuousBufferFree(b.handle); err != nil {
+		lastErr = fmt.Errorf("VdmaContinuousBufferFree failed: %w", err)
+	}
+	return lastErr
+}
@@ -0,0 +1,76 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// ErrNnCoreReset is returned after recovery escalated to a neural network
+// core reset; the network group must be activated again before use
+var ErrNnCoreReset = fmt.Errorf("neural network core was reset")
+
+// RecoveryOptions controls how a VStream handles DMA errors reported by
+// its channel. Timeouts and driver failures are never retried.
+type RecoveryOptions struct {
+	// Enabled turns on automatic recovery; otherwise errors are returned as is
+	Enabled bool
+	// MaxAttempts is the number of channel resets per transfer (default 1)
+	MaxAttempts int
+	// ResetNnCore escalates to ResetNnCore once channel resets are exhausted
+	ResetNnCore bool
+}
+
+// transfer remembers a launch so it can be re-armed after a channel reset
+type transfer struct {
+	buf    *Buffer
+	size   uint64
+	domain driver.InterruptsDomain
+}
+
+// IsChannelError reports whether err is a DMA error decoded from a channel
+// interrupt
+func IsChannelError(err error) bool {
+	var chErr *driver.VdmaChannelError
+	return errors.As(err, &chErr)
+}
+
+// waitWithRecovery waits for a transfer on a channel. On a channel error
+// it resets the channel, reprograms the descriptors and re-launches the
+// transfer, up to MaxAttempts times, then optionally resets the NN core.
+// Waiting stops when ctx is done.
+func waitWithRecovery(ctx context.Context, dev *driver.DeviceFile, channel *VdmaChannel, descList *DescriptorList, last transfer, opts RecoveryOptions) error {
+	err := channel.WaitForInterruptContext(ctx)
+	if err == nil || !opts.Enabled || !IsChannelError(err) || last.buf == nil {
+		return err
+	}
+
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	for i := 0; i < attempts; i++ {
+		if rerr := channel.Reset(); rerr != nil {
+			return fmt.Errorf("channel reset after %w failed: %v", err, rerr)
+		}
+		if rerr := launch(descList, channel, last.buf, last.size, last.domain); rerr != nil {
+			return fmt.Errorf("re-arm after %w failed: %v", err, rerr)
+		}
+
+		err = channel.WaitForInterruptContext(ctx)
+		if err == nil || !IsChannelError(err) {
+			return err
+		}
+	}
+
+	if !opts.ResetNnCore || dev == nil {
+		return err
+	}
+	if rerr := dev.ResetNnCore(); rerr != nil {
+		return fmt.Errorf("NN core reset after %w failed: %v", err, rerr)
+	}
+	return fmt.Errorf("%w: %w", ErrNnCoreReset, err)
+}
@@ -0,0 +1,440 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+// DefaultRingDepth is the number of pre-armed output buffers
+const DefaultRingDepth = 4
+
+// ErrFrameReleased is returned when a ring frame is released twice
+var ErrFrameReleased = fmt.Errorf("ring frame already released")
+
+// slotState tracks a ring slot through its lifecycle:
+// free -> armed (device owns it) -> ready (completed) -> held (caller owns it) -> free
+type slotState int
+
+const (
+	slotFree slotState = iota
+	slotArmed
+	slotReady
+	slotHeld
+)
+
+// ringState is the slot bookkeeping of an OutputRing. The device fills a
+// circular descriptor list strictly in order, so slots are armed,
+// completed and delivered in ring order; only releases may come out of
+// order.
+type ringState struct {
+	slots    []slotState
+	armHead  int // next slot to arm
+	doneHead int // oldest armed slot
+	readHead int // oldest ready slot
+	armed    int
+	ready    int
+}
+
+func newRingState(depth int) ringState {
+	return ringState{slots: make([]slotState, depth)}
+}
+
+// nextToArm returns the next slot to hand to the device, if it is free
+func (r *ringState) nextToArm() (int, bool) {
+	if r.slots[r.armHead] != slotFree {
+		return 0, false
+	}
+	return r.armHead, true
+}
+
+// arm records that the slot returned by nextToArm was launched
+func (r *ringState) arm() {
+	r.slots[r.armHead] = slotArmed
+	r.armHead = (r.armHead + 1) % len(r.slots)
+	r.armed++
+}
+
+// complete marks the n oldest armed slots as ready
+func (r *ringState) complete(n int) {
+	if n > r.armed {
+		n = r.armed
+	}
+	for i := 0; i < n; i++ {
+		r.slots[r.doneHead] = slotReady
+		r.doneHead = (r.doneHead + 1) % len(r.slots)
+	}
+	r.armed -= n
+	r.ready += n
+}
+
+// take hands the oldest ready slot to the caller
+func (r *ringState) take() (int, bool) {
+	if r.ready == 0 {
+		return 0, false
+	}
+	slot := r.readHead
+	r.slots[slot] = slotHeld
+	r.readHead = (r.readHead + 1) % len(r.slots)
+	r.ready--
+	return slot, true
+}
+
+// release returns a held slot so it can be armed again
+func (r *ringState) release(slot int) error {
+	if r.slots[slot] != slotHeld {
+		return ErrFrameReleased
+	}
+	r.slots[slot] = slotFree
+	return nil
+}
+
+// reset forgets every transfer after the channel dropped them. Held slots
+// stay with the caller; arming restarts at slot 0, where the channel
+// resumes.
+func (r *ringState) reset() {
+	for i, s := range r.slots {
+		if s != slotHeld {
+			r.slots[i] = slotFree
+		}
+	}
+	r.armHead, r.doneHead, r.readHead = 0, 0, 0
+	r.armed, r.ready = 0, 0
+}
+
+// OutputRingConfig holds configuration for creating an OutputRing
+type OutputRingConfig struct {
+	Info    VStreamInfo
+	Device  *driver.DeviceFile
+	Channel *VdmaChannel
+	Timeout time.Duration
+
+	// Depth is the number of pre-armed buffers, rounded up to a power of
+	// two (default DefaultRingDepth)
+	Depth int
+
+	// PageSize is the descriptor page size; zero uses the device maximum
+	PageSize uint16
+}
+
+// OutputRing reads an output stream through a ring of buffers that stay
+// armed on a circular descriptor list, so the device always has somewhere
+// to write while the host processes earlier frames. Frames are returned
+// in order by Read and must be handed back with Release.
+type OutputRing struct {
+	info     VStreamInfo
+	device   *driver.DeviceFile
+	channel  *VdmaChannel
+	timeout  time.Duration
+	buffer   *Buffer
+	descList *DescriptorList
+
+	slotStride   uint64 // bytes between slots, a whole number of pages
+	descsPerSlot uint32
+
+	mu      sync.Mutex
+	readMu  sync.Mutex // serializes readers while waiting without mu
+	state   ringState
+	started bool
+	closed  bool
+}
+
+// RingFrame is a completed frame owned by the caller until Release
+type RingFrame struct {
+	ring     *OutputRing
+	slot     int
+	data     []byte
+	released bool
+}
+
+// Data returns the frame bytes; they are only valid until Release
+func (f *RingFrame) Data() []byte {
+	return f.data
+}
+
+// Release hands the frame's buffer back to the ring
+func (f *RingFrame) Release() error {
+	return f.ring.Release(f)
+}
+
+// NewOutputRing creates an output ring. Call Start once the channel is
+// enabled, or let the first Read start it.
+func NewOutputRing(cfg OutputRingConfig) (*OutputRing, error) {
+	if cfg.Info.FrameSize == 0 {
+		return nil, fmt.Errorf("%w: frame size cannot be zero", ErrInvalidData)
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	pageSize := cfg.PageSize
+	if pageSize == 0 {
+		props, err := cfg.Device.QueryDeviceProperties()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query device properties: %w", err)
+		}
+		pageSize = props.DescMaxPageSize
+	}
+
+	depth, descsPerSlot := ringGeometry(cfg.Depth, cfg.Info.FrameSize, pageSize)
+	slotStride := uint64(descsPerSlot) * uint64(pageSize)
+
+	buffer, err := AllocateBuffer(cfg.Device, uint64(depth)*slotStride, driver.DmaFromDevice)
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate buffer: %w", err)
+	}
+
+	descList, err := CreateDescriptorList(cfg.Device, uint64(depth)*uint64(descsPerSlot), pageSize, true)
+	if err != nil {
+		buffer.Close()
+		return nil, fmt.Errorf("failed to create descriptor list: %w", err)
+	}
+
+	// Bind the whole ring once; launches then only move the descriptor window
+	err = descList.Program(buffer, cfg.Channel.ChannelIndex(), 0, true, driver.InterruptsDomainHost)
+	if err != nil {
+		descList.Release()
+		buffer.Close()
+		return nil, fmt.Errorf("failed to program descriptor list: %w", err)
+	}
+
+	return &OutputRing{
+		info:         cfg.Info,
+		device:       cfg.Device,
+		channel:      cfg.Channel,
+		timeout:      cfg.Timeout,
+		buffer:       buffer,
+		descList:     descList,
+		slotStride:   slotStride,
+		descsPerSlot: descsPerSlot,
+		state:        newRingState(depth),
+	}, nil
+}
+
+// ringGeometry picks power-of-two slot and descriptor counts, since a
+// circular descriptor list wraps at a power of two
+func ringGeometry(depth int, frameSize uint64, pageSize uint16) (slots int, descsPerSlot uint32) {
+	if depth <= 0 {
+		depth = DefaultRingDepth
+	}
+	slots = 1
+	for slots < depth {
+		slots <<= 1
+	}
+
+	descs := CalculateDescCount(frameSize, pageSize)
+	descsPerSlot = 1
+	for uint64(descsPerSlot) < descs {
+		descsPerSlot <<= 1
+	}
+	return slots, descsPerSlot
+}
+
+// Info returns the stream information
+func (r *OutputRing) Info() VStreamInfo {
+	return r.info
+}
+
+// Depth returns the number of ring slots
+func (r *OutputRing) Depth() int {
+	return len(r.state.slots)
+}
+
+// Start arms every slot
+func (r *OutputRing) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return ErrStreamClosed
+	}
+	if r.started {
+		return nil
+	}
+
+	r.started = true
+	return r.armLocked()
+}
+
+// armLocked launches transfers for free slots in ring order
+func (r *OutputRing) armLocked() error {
+	for {
+		slot, ok := r.state.nextToArm()
+		if !ok {
+			return nil
+		}
+
+		err := r.channel.LaunchTransferRange(
+			r.descList,
+			r.buffer,
+			uint32(uint64(slot)*r.slotStride),
+			uint32(r.info.FrameSize),
+			uint32(slot)*r.descsPerSlot,
+			false, // bound at creation
+			driver.InterruptsDomainNone,
+			driver.InterruptsDomainHost,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to arm ring slot %d: %w", slot, err)
+		}
+		r.state.arm()
+	}
+}
+
+// Read returns the next completed frame in order, blocking until the
+// device delivers it. The frame must be released before its slot can
+// receive data again.
+func (r *OutputRing) Read() (*RingFrame, error) {
+	return r.read(nil, func() (int, error) {
+		return r.channel.WaitForTransfers(r.timeout)
+	})
+}
+
+// ReadContext is like Read but gives up when ctx is done. Giving up drops
+// the armed transfers, so frames the device was writing are lost and the
+// ring re-arms from its first free slot.
+func (r *OutputRing) ReadContext(ctx context.Context) (*RingFrame, error) {
+	return r.read(ctx, func() (int, error) {
+		return r.channel.WaitForTransfersContext(ctx)
+	})
+}
+
+// read waits for frames with wait until one is ready
+func (r *OutputRing) read(ctx context.Context, wait func() (int, error)) (*RingFrame, error) {
+	r.readMu.Lock()
+	defer r.readMu.Unlock()
+
+	if err := r.Start(); err != nil {
+		return nil, err
+	}
+
+	for {
+		r.mu.Lock()
+		if r.closed {
+			r.mu.Unlock()
+			return nil, ErrStreamClosed
+		}
+		if slot, ok := r.state.take(); ok {
+			r.mu.Unlock()
+			return r.frame(slot)
+		}
+		if r.state.armed == 0 {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("%w: all ring frames are held, release one first", ErrBufferNotReady)
+		}
+		r.mu.Unlock()
+
+		// Wait without mu so Release can re-arm slots meanwhile
+		completed, err := wait()
+		if err != nil {
+			if ctx != nil && ctx.Err() != nil {
+				// The channel was disabled and enabled again
+				r.mu.Lock()
+				r.state.reset()
+				if !r.closed {
+					if aerr := r.armLocked(); aerr != nil {
+						err = fmt.Errorf("%w (re-arm: %v)", err, aerr)
+					}
+				}
+				r.mu.Unlock()
+			}
+			return nil, fmt.Errorf("wait for interrupt failed: %w", err)
+		}
+
+		r.mu.Lock()
+		r.state.complete(completed)
+		r.mu.Unlock()
+	}
+}
+
+// frame syncs a delivered slot and wraps it
+func (r *OutputRing) frame(slot int) (*RingFrame, error) {
+	offset := uint64(slot) * r.slotStride
+	if err := r.buffer.SyncRangeForCPU(offset, r.info.FrameSize); err != nil {
+		r.mu.Lock()
+		r.state.release(slot)
+		r.mu.Unlock()
+		return nil, fmt.Errorf("buffer sync failed: %w", err)
+	}
+
+	return &RingFrame{
+		ring: r,
+		slot: slot,
+		data: r.buffer.Data()[offset : offset+r.info.FrameSize : offset+r.info.FrameSize],
+	}, nil
+}
+
+// Release hands a frame back and re-arms free slots
+func (r *OutputRing) Release(f *RingFrame) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if f.ring != r {
+		return fmt.Errorf("frame belongs to a different ring")
+	}
+	// The slot may already be held again by a newer frame
+	if f.released {
+		return ErrFrameReleased
+	}
+	if err := r.state.release(f.slot); err != nil {
+		return err
+	}
+	f.released = true
+	f.data = nil
+
+	if r.closed || !r.started {
+		return nil
+	}
+	return r.armLocked()
+}
+
+// reset drops the armed transfers and, once started, arms the free slots
+// again. Held frames stay valid.
+func (r *OutputRing) reset() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	if err := abortTransfer(r.channel); err != nil {
+		return err
+	}
+	r.state.reset()
+	if !r.started {
+		return nil
+	}
+	return r.armLocked()
+}
+
+// Close stops the channel and releases the ring. Frames still held
+// become invalid.
+func (r *OutputRing) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	// Armed slots belong to the device until the channel stops
+	if err := disableChannel(r.channel); err != nil {
+		return err
+	}
+	r.closed = true
+
+	var lastErr error
+
+	if err := r.descList.Release(); err != nil {
+		lastErr = err
+	}
+
+	if err := r.buffer.Close(); err != nil {
+		lastErr = err
+	}
+
+	return lastErr
+}
@@ -0,0 +1,175 @@
+//go:build unit
+
+package stream
+
+import (
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/driver"
+)
+
+func TestRingGeometry(t *testing.T) {
+	tests := []struct {
+		depth        int
+		frameSize    uint64
+		pageSize     uint16
+		slots        int
+		descsPerSlot uint32
+	}{
+		{0, 4096, 4096, DefaultRingDepth, 1},
+		{3, 4096, 4096, 4, 1},
+		{4, 640 * 640 * 3, 4096, 4, 512}, // 300 pages rounded up
+		{1, 100, 512, 1, 1},
+		{5, 8192, 4096, 8, 2},
+	}
+
+	for _, tt := range tests {
+		slots, descs := ringGeometry(tt.depth, tt.frameSize, tt.pageSize)
+		if slots != tt.slots || descs != tt.descsPerSlot {
+			t.Errorf("ringGeometry(%d, %d, %d) = %d, %d, expected %d, %d",
+				tt.depth, tt.frameSize, tt.pageSize, slots, descs, tt.slots, tt.descsPerSlot)
+		}
+	}
+}
+
+func TestRingStateInOrderDelivery(t *testing.T) {
+	r := newRingState(4)
+	for {
+		if _, ok := r.nextToArm(); !ok {
+			break
+		}
+		r.arm()
+	}
+	if r.armed != 4 {
+		t.Fatalf("armed = %d, expected 4", r.armed)
+	}
+
+	r.complete(2)
+	for want := 0; want < 2; want++ {
+		slot, ok := r.take()
+		if !ok || slot != want {
+			t.Fatalf("take() = %d, %v, expected %d", slot, ok, want)
+		}
+	}
+	if _, ok := r.take(); ok {
+		t.Error("take() returned a slot that has not completed")
+	}
+
+	// More completions than armed slots are clamped
+	r.complete(10)
+	if r.armed != 0 || r.ready != 2 {
+		t.Errorf("armed = %d ready = %d, expected 0 and 2", r.armed, r.ready)
+	}
+}
+
+func TestRingStateRearmsInRingOrder(t *testing.T) {
+	r := newRingState(4)
+	for i := 0; i < 4; i++ {
+		r.arm()
+	}
+	r.complete(4)
+	for i := 0; i < 4; i++ {
+		r.take()
+	}
+
+	// Releasing slot 1 before slot 0 must not let slot 1 be armed first:
+	// the device writes the circular list strictly in order
+	if err := r.release(1); err != nil {
+		t.Fatalf("release(1) error = %v", err)
+	}
+	if _, ok := r.nextToArm(); ok {
+		t.Error("slot 1 armed ahead of held slot 0")
+	}
+
+	r.release(0)
+	for want := 0; want < 2; want++ {
+		slot, ok := r.nextToArm()
+		if !ok || slot != want {
+			t.Fatalf("nextToArm() = %d, %v, expected %d", slot, ok, want)
+		}
+		r.arm()
+	}
+	if _, ok := r.nextToArm(); ok {
+		t.Error("slot 2 is still held and must not be armed")
+	}
+}
+
+func TestRingStateDoubleRelease(t *testing.T) {
+	r := newRingState(2)
+	r.arm()
+	r.complete(1)
+	slot, _ := r.take()
+
+	if err := r.release(slot); err != nil {
+		t.Fatalf("release() error = %v", err)
+	}
+	if err := r.release(slot); err != ErrFrameReleased {
+		t.Errorf("second release() = %v, expected ErrFrameReleased", err)
+	}
+}
+
+func TestRingFrameStaleRelease(t *testing.T) {
+	ring := &OutputRing{state: newRingState(1), closed: true}
+	ring.state.arm()
+	ring.state.complete(1)
+	slot, _ := ring.state.take()
+	first := &RingFrame{ring: ring, slot: slot}
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+
+	// The slot comes around again as a new frame
+	ring.state.arm()
+	ring.state.complete(1)
+	slot, _ = ring.state.take()
+	second := &RingFrame{ring: ring, slot: slot}
+
+	if err := first.Release(); err != ErrFrameReleased {
+		t.Errorf("stale Release() = %v, expected ErrFrameReleased", err)
+	}
+	if err := second.Release(); err != nil {
+		t.Errorf("Release() of current frame error = %v", err)
+	}
+}
+
+func TestRingStateReset(t *testing.T) {
+	r := newRingState(4)
+	for i := 0; i < 4; i++ {
+		r.arm()
+	}
+	r.complete(3)
+	held, _ := r.take()
+
+	// The channel dropped its transfers: only the held slot survives and
+	// arming restarts at slot 0, which is still held
+	r.reset()
+	if r.armed != 0 || r.ready != 0 {
+		t.Errorf("armed = %d ready = %d after reset, expected 0 and 0", r.armed, r.ready)
+	}
+	if _, ok := r.nextToArm(); ok {
+		t.Error("held slot 0 armed after reset")
+	}
+	r.release(held)
+	if slot, ok := r.nextToArm(); !ok || slot != 0 {
+		t.Errorf("nextToArm() = %d, %v, expected slot 0", slot, ok)
+	}
+}
+
+func TestOutputVStreamRingRejectsCallerBuffers(t *testing.T) {
+	vs := &OutputVStream{
+		info:      VStreamInfo{FrameSize: 100},
+		batchSize: 1,
+		ring:      &OutputRing{state: newRingState(2)},
+	}
+
+	buf := &Buffer{size: 100, mapped: true, direction: driver.DmaFromDevice}
+	if err := vs.StartReadBuffer(buf); err == nil {
+		t.Error("StartReadBuffer() on a ring stream should fail")
+	}
+	if err := vs.ReadBuffer(buf); err == nil {
+		t.Error("ReadBuffer() on a ring stream should fail")
+	}
+	if buf.InFlight() {
+		t.Error("rejected buffer left in flight")
+	}
+}
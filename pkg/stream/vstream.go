@@ -1,12 +1,15 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/anthropics/purple-hailo/pkg/driver"
 	"github.com/anthropics/purple-hailo/pkg/hef"
+	"github.com/anthropics/purple-hailo/pkg/transform"
 )
 
 // Errors for stream operations
@@ -15,8 +18,17 @@ var (
 	ErrTimeout        = fmt.Errorf("operation timed out")
 	ErrInvalidData    = fmt.Errorf("invalid data size")
 	ErrBufferNotReady = fmt.Errorf("buffer not ready")
+	ErrBufferInFlight = fmt.Errorf("buffer is owned by a pending transfer")
 )
 
+// errRingStream rejects caller buffer reads on a ring stream, whose slots
+// are bound to the ring's own buffer
+var errRingStream = fmt.Errorf("caller buffers cannot be read into on a ring stream")
+
+// errWritePending rejects a write launched before the previous one was
+// flushed; relaunching would reprogram the descriptors it is using
+var errWritePending = fmt.Errorf("%w: a write is pending, Flush it first", ErrBufferInFlight)
+
 // VStreamInfo contains stream configuration
 type VStreamInfo struct {
 	Name      string
@@ -40,6 +52,10 @@ type InputVStream struct {
 	timeout    time.Duration
 	batchSize  uint32
 	queueDepth int
+	pending    bool      // whether a launched transfer has not been flushed
+	userBufs   []*Buffer // caller buffers owned by launched transfers
+	recovery   RecoveryOptions
+	last       transfer
 }
 
 // InputVStreamConfig holds configuration for creating an input VStream
@@ -50,6 +66,14 @@ type InputVStreamConfig struct {
 	Timeout    time.Duration
 	BatchSize  uint32
 	QueueDepth int
+
+	// Descriptor geometry from the channel allocator; zero uses the
+	// device's maximum page size and the minimum descriptor count
+	PageSize  uint16
+	DescCount uint64
+
+	// Recovery controls handling of DMA errors reported by the channel
+	Recovery RecoveryOptions
 }
 
 // NewInputVStream creates a new input VStream
@@ -80,10 +104,17 @@ func NewInputVStream(cfg InputVStreamConfig) (*InputVStream, error) {
 	}
 
 	// Calculate descriptor count
-	descCount := CalculateDescCount(bufferSize, props.DescMaxPageSize)
+	pageSize := props.DescMaxPageSize
+	if cfg.PageSize != 0 {
+		pageSize = cfg.PageSize
+	}
+	descCount := CalculateDescCount(bufferSize, pageSize)
+	if cfg.DescCount > descCount {
+		descCount = cfg.DescCount
+	}
 
 	// Create descriptor list
-	descList, err := CreateDescriptorList(cfg.Device, descCount, props.DescMaxPageSize, false)
+	descList, err := CreateDescriptorList(cfg.Device, descCount, pageSize, false)
 	if err != nil {
 		buffer.Close()
 		return nil, fmt.Errorf("failed to create descriptor list: %w", err)
@@ -98,6 +129,7 @@ func NewInputVStream(cfg InputVStreamConfig) (*InputVStream, error) {
 		timeout:    cfg.Timeout,
 		batchSize:  cfg.BatchSize,
 		queueDepth: cfg.QueueDepth,
+		recovery:   cfg.Recovery,
 	}, nil
 }
 
@@ -119,6 +151,9 @@ func (vs *InputVStream) Write(data []byte) error {
 	if vs.closed {
 		return ErrStreamClosed
 	}
+	if vs.pending {
+		return errWritePending
+	}
 
 	expectedSize := vs.info.FrameSize * uint64(vs.batchSize)
 	if uint64(len(data)) != expectedSize {
@@ -128,39 +163,83 @@ func (vs *InputVStream) Write(data []byte) error {
 	// Copy data to buffer
 	copy(vs.buffer.Data(), data)
 
+	return vs.transferLocked(expectedSize)
+}
+
+// WriteContext is like Write but fails without launching a transfer when
+// ctx is already done. ctx is only checked on entry: Write copies the data
+// and launches the transfer without blocking on the device, so there is
+// nothing to cancel afterwards. Use FlushContext to wait for completion.
+func (vs *InputVStream) WriteContext(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return vs.Write(data)
+}
+
+// WriteBatch writes a full batch of frames as a single transfer. The
+// frames are gathered into the stream buffer back to back and the device
+// is interrupted once, after the last frame.
+func (vs *InputVStream) WriteBatch(frames [][]byte) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return ErrStreamClosed
+	}
+	if vs.pending {
+		return errWritePending
+	}
+
+	if len(frames) != int(vs.batchSize) {
+		return fmt.Errorf("%w: expected %d frames, got %d", ErrInvalidData, vs.batchSize, len(frames))
+	}
+
+	frameSize := vs.info.FrameSize
+	for i, frame := range frames {
+		if uint64(len(frame)) != frameSize {
+			return fmt.Errorf("%w: frame %d: expected %d bytes, got %d", ErrInvalidData, i, frameSize, len(frame))
+		}
+	}
+
+	dst := vs.buffer.Data()
+	for i, frame := range frames {
+		copy(dst[uint64(i)*frameSize:], frame)
+	}
+
+	return vs.transferLocked(frameSize * uint64(vs.batchSize))
+}
+
+// transferLocked sends the first size bytes of the stream buffer
+func (vs *InputVStream) transferLocked(size uint64) error {
 	// Sync buffer to device
 	if err := vs.buffer.SyncForDevice(); err != nil {
 		return fmt.Errorf("buffer sync failed: %w", err)
 	}
 
-	// Program descriptor list (4.20.0: no batchSize parameter)
-	err := vs.descList.Program(
-		vs.buffer,
-		vs.channel.ChannelIndex(),
-		0,    // startingDesc
-		true, // shouldBind
-		driver.InterruptsDomainDevice,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to program descriptor list: %w", err)
-	}
+	return vs.launch(vs.buffer, size)
+}
 
-	// Launch transfer
-	err = vs.channel.LaunchTransfer(
-		vs.descList,
-		vs.buffer,
-		0,
-		true,
-		driver.InterruptsDomainNone,
-		driver.InterruptsDomainDevice,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to launch transfer: %w", err)
-	}
+// BatchSize returns the number of frames moved per transfer
+func (vs *InputVStream) BatchSize() uint32 {
+	return vs.batchSize
+}
 
+// launch starts a transfer to the device and remembers it for recovery
+func (vs *InputVStream) launch(buf *Buffer, size uint64) error {
+	vs.last = transfer{buf: buf, size: size, domain: driver.InterruptsDomainDevice}
+	if err := launch(vs.descList, vs.channel, buf, size, driver.InterruptsDomainDevice); err != nil {
+		return err
+	}
+	vs.pending = true
 	return nil
 }
 
+// wait waits for the last transfer, recovering from channel errors
+func (vs *InputVStream) wait(ctx context.Context) error {
+	return waitWithRecovery(ctx, vs.device, vs.channel, vs.descList, vs.last, vs.recovery)
+}
+
 // WriteAsync writes data asynchronously
 func (vs *InputVStream) WriteAsync(data []byte) error {
 	// For now, async write is same as sync write
@@ -170,14 +249,96 @@ func (vs *InputVStream) WriteAsync(data []byte) error {
 
 // Flush waits for pending writes to complete
 func (vs *InputVStream) Flush() error {
+	return vs.FlushContext(context.Background())
+}
+
+// FlushContext waits for pending writes until ctx is done. Giving up
+// aborts the pending transfers and hands caller buffers back. If the
+// transfer cannot be aborted the buffers stay owned by it.
+func (vs *InputVStream) FlushContext(ctx context.Context) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return ErrStreamClosed
+	}
+	if !vs.pending {
+		return nil
+	}
+
+	if err := vs.wait(ctx); err != nil {
+		if aerr := abortTransfer(vs.channel); aerr != nil {
+			return errors.Join(err, aerr)
+		}
+		vs.pending = false
+		vs.releaseUserBuffers()
+		return err
+	}
+	vs.pending = false
+	vs.releaseUserBuffers()
+	return nil
+}
+
+// WriteBuffer transfers a frame straight from a caller buffer registered
+// with WrapBuffer or ImportDmabuf, skipping the copy Write makes. The
+// buffer belongs to the transfer until Flush returns and must not be
+// modified or closed before then. One write can be pending at a time.
+func (vs *InputVStream) WriteBuffer(buf *Buffer) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
 	if vs.closed {
 		return ErrStreamClosed
 	}
+	if vs.pending {
+		return errWritePending
+	}
+
+	expectedSize := vs.info.FrameSize * uint64(vs.batchSize)
+	if err := checkUserBuffer(buf, driver.DmaToDevice, expectedSize); err != nil {
+		return err
+	}
+
+	if err := buf.acquire(); err != nil {
+		return err
+	}
 
-	return vs.channel.WaitForInterrupt()
+	if err := buf.SyncForDevice(); err != nil {
+		buf.release()
+		return fmt.Errorf("buffer sync failed: %w", err)
+	}
+
+	if err := vs.launch(buf, expectedSize); err != nil {
+		buf.release()
+		return err
+	}
+
+	vs.userBufs = append(vs.userBufs, buf)
+	return nil
+}
+
+// releaseUserBuffers hands caller buffers back after their transfers completed
+func (vs *InputVStream) releaseUserBuffers() {
+	for _, buf := range vs.userBufs {
+		buf.release()
+	}
+	vs.userBufs = nil
+}
+
+// reset aborts a pending write and hands its caller buffers back
+func (vs *InputVStream) reset() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return nil
+	}
+	if err := abortTransfer(vs.channel); err != nil {
+		return err
+	}
+	vs.pending = false
+	vs.releaseUserBuffers()
+	return nil
 }
 
 // Close closes the input stream
@@ -189,7 +350,13 @@ func (vs *InputVStream) Close() error {
 		return nil
 	}
 
+	// Stop the channel before its buffers go away
+	if err := disableChannel(vs.channel); err != nil {
+		return err
+	}
 	vs.closed = true
+	vs.pending = false
+	vs.releaseUserBuffers()
 
 	var lastErr error
 
@@ -216,7 +383,11 @@ type OutputVStream struct {
 	timeout    time.Duration
 	batchSize  uint32
 	queueDepth int
-	pending    bool // whether a read is pending
+	pending    bool    // whether a read is pending
+	target     *Buffer // caller buffer of the pending read, nil for the internal one
+	recovery   RecoveryOptions
+	last       transfer
+	ring       *OutputRing // pre-armed reads, in place of buffer and descList
 }
 
 // OutputVStreamConfig holds configuration for creating an output VStream
@@ -227,6 +398,18 @@ type OutputVStreamConfig struct {
 	Timeout    time.Duration
 	BatchSize  uint32
 	QueueDepth int
+
+	// Descriptor geometry from the channel allocator; zero uses the
+	// device's maximum page size and the minimum descriptor count
+	PageSize  uint16
+	DescCount uint64
+
+	// Recovery controls handling of DMA errors reported by the channel
+	Recovery RecoveryOptions
+
+	// RingDepth, when set, reads through an OutputRing of that many
+	// pre-armed batches instead of arming one read at a time
+	RingDepth int
 }
 
 // NewOutputVStream creates a new output VStream
@@ -243,6 +426,35 @@ func NewOutputVStream(cfg OutputVStreamConfig) (*OutputVStream, error) {
 
 	bufferSize := cfg.Info.FrameSize * uint64(cfg.BatchSize)
 
+	vs := &OutputVStream{
+		info:       cfg.Info,
+		channel:    cfg.Channel,
+		device:     cfg.Device,
+		timeout:    cfg.Timeout,
+		batchSize:  cfg.BatchSize,
+		queueDepth: cfg.QueueDepth,
+		recovery:   cfg.Recovery,
+	}
+
+	if cfg.RingDepth > 0 {
+		// Each ring slot holds one batch
+		info := cfg.Info
+		info.FrameSize = bufferSize
+		ring, err := NewOutputRing(OutputRingConfig{
+			Info:     info,
+			Device:   cfg.Device,
+			Channel:  cfg.Channel,
+			Timeout:  cfg.Timeout,
+			Depth:    cfg.RingDepth,
+			PageSize: cfg.PageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		vs.ring = ring
+		return vs, nil
+	}
+
 	// Allocate buffer for output (device to host)
 	buffer, err := AllocateBuffer(cfg.Device, bufferSize, driver.DmaFromDevice)
 	if err != nil {
@@ -257,25 +469,25 @@ func NewOutputVStream(cfg OutputVStreamConfig) (*OutputVStream, error) {
 	}
 
 	// Calculate descriptor count
-	descCount := CalculateDescCount(bufferSize, props.DescMaxPageSize)
+	pageSize := props.DescMaxPageSize
+	if cfg.PageSize != 0 {
+		pageSize = cfg.PageSize
+	}
+	descCount := CalculateDescCount(bufferSize, pageSize)
+	if cfg.DescCount > descCount {
+		descCount = cfg.DescCount
+	}
 
 	// Create descriptor list
-	descList, err := CreateDescriptorList(cfg.Device, descCount, props.DescMaxPageSize, false)
+	descList, err := CreateDescriptorList(cfg.Device, descCount, pageSize, false)
 	if err != nil {
 		buffer.Close()
 		return nil, fmt.Errorf("failed to create descriptor list: %w", err)
 	}
 
-	return &OutputVStream{
-		info:       cfg.Info,
-		buffer:     buffer,
-		descList:   descList,
-		channel:    cfg.Channel,
-		device:     cfg.Device,
-		timeout:    cfg.Timeout,
-		batchSize:  cfg.BatchSize,
-		queueDepth: cfg.QueueDepth,
-	}, nil
+	vs.buffer = buffer
+	vs.descList = descList
+	return vs, nil
 }
 
 // Info returns the stream information
@@ -288,6 +500,25 @@ func (vs *OutputVStream) FrameSize() uint64 {
 	return vs.info.FrameSize
 }
 
+// Dequantize converts data read from the stream, one or more NHWC frames,
+// to float32 values using the stream's per-tensor or per-channel
+// quantization parameters
+func (vs *OutputVStream) Dequantize(data []byte) ([]float32, error) {
+	format := hef.Format{Type: vs.info.Format, Order: hef.FormatOrderNHWC}
+	if format.Type == hef.FormatTypeAuto {
+		format.Type = hef.FormatTypeUint8
+	}
+	frameBytes := uint64(vs.info.Height*vs.info.Width*vs.info.Channels) * uint64(format.Type.Size())
+	if frameBytes == 0 || uint64(len(data))%frameBytes != 0 {
+		return nil, fmt.Errorf("%w: %d bytes is not a whole number of %s frames", ErrInvalidData, len(data), vs.info.Name)
+	}
+
+	// Frames stack along the height; channels stay innermost
+	frames := uint32(uint64(len(data)) / frameBytes)
+	shape := hef.ImageShape3D{Height: vs.info.Height * frames, Width: vs.info.Width, Features: vs.info.Channels}
+	return transform.DequantizeTensor(data, format, shape, transform.QuantInfoFromHef(vs.info.QuantInfo))
+}
+
 // StartRead prepares for reading output
 func (vs *OutputVStream) StartRead() error {
 	vs.mu.Lock()
@@ -297,33 +528,18 @@ func (vs *OutputVStream) StartRead() error {
 		return ErrStreamClosed
 	}
 
-	if vs.pending {
-		return fmt.Errorf("read already pending")
+	// A ring keeps every slot armed once started
+	if vs.ring != nil {
+		return vs.ring.Start()
 	}
 
-	// Program descriptor list (4.20.0: no batchSize parameter)
-	err := vs.descList.Program(
-		vs.buffer,
-		vs.channel.ChannelIndex(),
-		0,    // startingDesc
-		true, // shouldBind
-		driver.InterruptsDomainHost,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to program descriptor list: %w", err)
+	if vs.pending {
+		return fmt.Errorf("read already pending")
 	}
 
-	// Launch transfer
-	err = vs.channel.LaunchTransfer(
-		vs.descList,
-		vs.buffer,
-		0,
-		true,
-		driver.InterruptsDomainNone,
-		driver.InterruptsDomainHost,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to launch transfer: %w", err)
+	dataSize := vs.info.FrameSize * uint64(vs.batchSize)
+	if err := vs.launch(vs.buffer, dataSize); err != nil {
+		return err
 	}
 
 	vs.pending = true
@@ -332,6 +548,12 @@ func (vs *OutputVStream) StartRead() error {
 
 // Read reads a frame from the device (blocking)
 func (vs *OutputVStream) Read() ([]byte, error) {
+	return vs.ReadContext(context.Background())
+}
+
+// ReadContext is like Read but gives up when ctx is done, aborting the
+// pending transfer
+func (vs *OutputVStream) ReadContext(ctx context.Context) ([]byte, error) {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -339,57 +561,120 @@ func (vs *OutputVStream) Read() ([]byte, error) {
 		return nil, ErrStreamClosed
 	}
 
+	dataSize := vs.info.FrameSize * uint64(vs.batchSize)
+	if vs.ring != nil {
+		result := make([]byte, dataSize)
+		return result, vs.readRing(ctx, result)
+	}
+
+	if vs.target != nil {
+		return nil, fmt.Errorf("pending read targets a caller buffer, use ReadBuffer")
+	}
+
 	// If no read is pending, start one
 	if !vs.pending {
-		// Program descriptor list (4.20.0: no batchSize parameter)
-		err := vs.descList.Program(
-			vs.buffer,
-			vs.channel.ChannelIndex(),
-			0,    // startingDesc
-			true, // shouldBind
-			driver.InterruptsDomainHost,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to program descriptor list: %w", err)
-		}
-
-		// Launch transfer
-		err = vs.channel.LaunchTransfer(
-			vs.descList,
-			vs.buffer,
-			0,
-			true,
-			driver.InterruptsDomainNone,
-			driver.InterruptsDomainHost,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to launch transfer: %w", err)
+		if err := vs.launch(vs.buffer, dataSize); err != nil {
+			return nil, err
 		}
 	}
 
 	// Wait for transfer completion
-	if err := vs.channel.WaitForInterrupt(); err != nil {
-		vs.pending = false
-		return nil, fmt.Errorf("wait for interrupt failed: %w", err)
+	if err := vs.waitPending(ctx); err != nil {
+		return nil, err
 	}
 
-	vs.pending = false
-
 	// Sync buffer from device
 	if err := vs.buffer.SyncForCPU(); err != nil {
 		return nil, fmt.Errorf("buffer sync failed: %w", err)
 	}
 
 	// Copy data from buffer
-	dataSize := vs.info.FrameSize * uint64(vs.batchSize)
 	result := make([]byte, dataSize)
 	copy(result, vs.buffer.Data()[:dataSize])
 
 	return result, nil
 }
 
+// ReadBatch reads one batch and returns its frames. The frames share a
+// single allocation.
+func (vs *OutputVStream) ReadBatch() ([][]byte, error) {
+	return vs.ReadBatchContext(context.Background())
+}
+
+// ReadBatchContext is like ReadBatch but gives up when ctx is done
+func (vs *OutputVStream) ReadBatchContext(ctx context.Context) ([][]byte, error) {
+	data, err := vs.ReadContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return SplitFrames(data, vs.info.FrameSize), nil
+}
+
+// BatchSize returns the number of frames moved per transfer
+func (vs *OutputVStream) BatchSize() uint32 {
+	return vs.batchSize
+}
+
+// launch arms a transfer from the device and remembers it for recovery
+func (vs *OutputVStream) launch(buf *Buffer, size uint64) error {
+	vs.last = transfer{buf: buf, size: size, domain: driver.InterruptsDomainHost}
+	return launch(vs.descList, vs.channel, buf, size, driver.InterruptsDomainHost)
+}
+
+// wait waits for the pending read, recovering from channel errors
+func (vs *OutputVStream) wait(ctx context.Context) error {
+	return waitWithRecovery(ctx, vs.device, vs.channel, vs.descList, vs.last, vs.recovery)
+}
+
+// waitPending waits for the pending read and clears it. A failed wait
+// aborts the transfer first; if that fails too the read stays pending,
+// since the device may still write to its buffer.
+func (vs *OutputVStream) waitPending(ctx context.Context) error {
+	if err := vs.wait(ctx); err != nil {
+		err = fmt.Errorf("wait for interrupt failed: %w", err)
+		if aerr := abortTransfer(vs.channel); aerr != nil {
+			return errors.Join(err, aerr)
+		}
+		vs.pending = false
+		return err
+	}
+	vs.pending = false
+	return nil
+}
+
+// readRing copies the next ring batch into dst and hands its slot back
+func (vs *OutputVStream) readRing(ctx context.Context, dst []byte) error {
+	frame, err := vs.ring.ReadContext(ctx)
+	if err != nil {
+		return err
+	}
+	copy(dst, frame.Data())
+	return frame.Release()
+}
+
+// ReadFrame returns the next batch of a ring stream without copying it.
+// The frame must be released before its slot can receive data again.
+func (vs *OutputVStream) ReadFrame(ctx context.Context) (*RingFrame, error) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return nil, ErrStreamClosed
+	}
+	if vs.ring == nil {
+		return nil, fmt.Errorf("stream %s has no ring, set RingDepth", vs.info.Name)
+	}
+	return vs.ring.ReadContext(ctx)
+}
+
 // ReadInto reads a frame into the provided buffer
 func (vs *OutputVStream) ReadInto(dst []byte) error {
+	return vs.ReadIntoContext(context.Background(), dst)
+}
+
+// ReadIntoContext is like ReadInto but gives up when ctx is done, aborting
+// the pending transfer
+func (vs *OutputVStream) ReadIntoContext(ctx context.Context, dst []byte) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -397,18 +682,23 @@ func (vs *OutputVStream) ReadInto(dst []byte) error {
 		return ErrStreamClosed
 	}
 
+	if vs.target != nil {
+		return fmt.Errorf("pending read targets a caller buffer, use ReadBuffer")
+	}
+
 	expectedSize := vs.info.FrameSize * uint64(vs.batchSize)
 	if uint64(len(dst)) < expectedSize {
 		return fmt.Errorf("%w: buffer too small, need %d bytes", ErrInvalidData, expectedSize)
 	}
+	if vs.ring != nil {
+		return vs.readRing(ctx, dst)
+	}
 
 	// Wait for transfer completion if pending
 	if vs.pending {
-		if err := vs.channel.WaitForInterrupt(); err != nil {
-			vs.pending = false
-			return fmt.Errorf("wait for interrupt failed: %w", err)
+		if err := vs.waitPending(ctx); err != nil {
+			return err
 		}
-		vs.pending = false
 	}
 
 	// Sync buffer from device
@@ -422,6 +712,113 @@ func (vs *OutputVStream) ReadInto(dst []byte) error {
 	return nil
 }
 
+// StartReadBuffer arms a transfer that DMAs the next frame directly into a
+// caller buffer registered with WrapBuffer or ImportDmabuf. The buffer
+// belongs to the transfer until ReadBuffer returns.
+func (vs *OutputVStream) StartReadBuffer(buf *Buffer) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return ErrStreamClosed
+	}
+
+	if vs.pending {
+		return fmt.Errorf("read already pending")
+	}
+
+	return vs.armUserBuffer(buf)
+}
+
+// ReadBuffer waits for a frame to land in a caller buffer, arming the
+// transfer first if StartReadBuffer was not called
+func (vs *OutputVStream) ReadBuffer(buf *Buffer) error {
+	return vs.ReadBufferContext(context.Background(), buf)
+}
+
+// ReadBufferContext is like ReadBuffer but gives up when ctx is done,
+// aborting the transfer and handing the buffer back
+func (vs *OutputVStream) ReadBufferContext(ctx context.Context, buf *Buffer) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return ErrStreamClosed
+	}
+
+	if !vs.pending {
+		if err := vs.armUserBuffer(buf); err != nil {
+			return err
+		}
+	} else if vs.target != buf {
+		return fmt.Errorf("pending read targets a different buffer")
+	}
+
+	err := vs.waitPending(ctx)
+	if vs.pending {
+		// The aborted transfer still owns the buffer
+		return err
+	}
+	vs.target = nil
+	buf.release()
+	if err != nil {
+		return err
+	}
+
+	if err := buf.SyncForCPU(); err != nil {
+		return fmt.Errorf("buffer sync failed: %w", err)
+	}
+
+	return nil
+}
+
+// armUserBuffer launches a read into a caller buffer
+func (vs *OutputVStream) armUserBuffer(buf *Buffer) error {
+	if vs.ring != nil {
+		return errRingStream
+	}
+	expectedSize := vs.info.FrameSize * uint64(vs.batchSize)
+	if err := checkUserBuffer(buf, driver.DmaFromDevice, expectedSize); err != nil {
+		return err
+	}
+
+	if err := buf.acquire(); err != nil {
+		return err
+	}
+
+	if err := vs.launch(buf, expectedSize); err != nil {
+		buf.release()
+		return err
+	}
+
+	vs.pending = true
+	vs.target = buf
+	return nil
+}
+
+// reset aborts a pending read, handing a caller buffer back, or restarts
+// the ring
+func (vs *OutputVStream) reset() error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	if vs.closed {
+		return nil
+	}
+	if vs.ring != nil {
+		return vs.ring.reset()
+	}
+	if err := abortTransfer(vs.channel); err != nil {
+		return err
+	}
+	vs.pending = false
+	if vs.target != nil {
+		vs.target.release()
+		vs.target = nil
+	}
+	return nil
+}
+
 // Close closes the output stream
 func (vs *OutputVStream) Close() error {
 	vs.mu.Lock()
@@ -431,7 +828,24 @@ func (vs *OutputVStream) Close() error {
 		return nil
 	}
 
+	if vs.ring != nil {
+		if err := vs.ring.Close(); err != nil {
+			return err
+		}
+		vs.closed = true
+		return nil
+	}
+
+	// Stop the channel before its buffers go away
+	if err := disableChannel(vs.channel); err != nil {
+		return err
+	}
 	vs.closed = true
+	vs.pending = false
+	if vs.target != nil {
+		vs.target.release()
+		vs.target = nil
+	}
 
 	var lastErr error
 
@@ -445,3 +859,88 @@ func (vs *OutputVStream) Close() error {
 
 	return lastErr
 }
+
+// abortTransfer drops the transfers pending on a channel after a failed
+// wait by disabling and enabling it again. Waits that ctx ended have
+// already done this; a driver timeout has not.
+func abortTransfer(channel *VdmaChannel) error {
+	if channel == nil {
+		return nil
+	}
+	if err := channel.Reset(); err != nil {
+		return fmt.Errorf("failed to abort transfer: %w", err)
+	}
+	return nil
+}
+
+// disableChannel stops a closing stream's channel; streams not bound to a
+// device have none
+func disableChannel(channel *VdmaChannel) error {
+	if channel == nil {
+		return nil
+	}
+	return channel.Disable()
+}
+
+// checkUserBuffer validates a caller buffer for a transfer of size bytes
+func checkUserBuffer(buf *Buffer, direction driver.DmaDataDirection, size uint64) error {
+	if buf == nil {
+		return fmt.Errorf("%w: nil buffer", ErrInvalidData)
+	}
+	if buf.Direction() != direction && buf.Direction() != driver.DmaBidirectional {
+		return fmt.Errorf("buffer mapped for direction %d, stream needs %d", buf.Direction(), direction)
+	}
+	if buf.Size() < size {
+		return fmt.Errorf("%w: buffer too small, need %d bytes, got %d", ErrInvalidData, size, buf.Size())
+	}
+	return nil
+}
+
+// launch programs the descriptor list over the first size bytes of buf and
+// starts the transfer
+func launch(descList *DescriptorList, channel *VdmaChannel, buf *Buffer, size uint64, domain driver.InterruptsDomain) error {
+	// 4.20.0: no batchSize parameter
+	err := descList.ProgramWithOffset(
+		buf,
+		0,
+		size,
+		channel.ChannelIndex(),
+		0,    // startingDesc
+		true, // shouldBind
+		domain,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to program descriptor list: %w", err)
+	}
+
+	err = channel.LaunchTransferRange(
+		descList,
+		buf,
+		0,
+		uint32(size),
+		0,
+		true,
+		driver.InterruptsDomainNone,
+		domain,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to launch transfer: %w", err)
+	}
+
+	return nil
+}
+
+// SplitFrames slices batched data into frames of frameSize bytes without
+// copying; a trailing partial frame is dropped
+func SplitFrames(data []byte, frameSize uint64) [][]byte {
+	if frameSize == 0 {
+		return nil
+	}
+	count := uint64(len(data)) / frameSize
+	frames := make([][]byte, count)
+	for i := range frames {
+		start := uint64(i) * frameSize
+		frames[i] = data[start : start+frameSize : start+frameSize]
+	}
+	return frames
+}
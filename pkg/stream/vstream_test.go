@@ -3,9 +3,13 @@
 package stream
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/anthropics/purple-hailo/pkg/device"
+	"github.com/anthropics/purple-hailo/pkg/driver"
 	"github.com/anthropics/purple-hailo/pkg/hef"
 )
 
@@ -113,6 +117,74 @@ func TestOutputVStreamInfoAccess(t *testing.T) {
 	}
 }
 
+func TestOutputVStreamDequantize(t *testing.T) {
+	vs := &OutputVStream{
+		info: VStreamInfo{
+			Name:      "scores",
+			FrameSize: 4,
+			Height:    1,
+			Width:     2,
+			Channels:  2,
+			QuantInfo: hef.QuantInfo{ZeroPoints: []float32{0, 10}, Scales: []float32{1, 0.5}},
+		},
+		batchSize: 2,
+	}
+
+	// Two frames of two pixels, channel 1 has its own zero point and scale
+	got, err := vs.Dequantize([]byte{1, 12, 2, 14, 3, 10, 4, 20})
+	if err != nil {
+		t.Fatalf("Dequantize() error = %v", err)
+	}
+	want := []float32{1, 1, 2, 2, 3, 0, 4, 5}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Dequantize() = %v, want %v", got, want)
+		}
+	}
+
+	if _, err := vs.Dequantize(make([]byte, 5)); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("Dequantize(5 bytes) error = %v, want ErrInvalidData", err)
+	}
+}
+
+func TestOutputVStreamDequantizeUint16PerChannel(t *testing.T) {
+	// Built the way BuildVStreams builds it, from the device stream info
+	info := vstreamInfo(device.StreamInfo{
+		Name:      "boxes",
+		FrameSize: 1 * 2 * 2 * 2,
+		Height:    1,
+		Width:     2,
+		Channels:  2,
+		Format:    hef.Format{Type: hef.FormatTypeUint16, Order: hef.FormatOrderNHWC},
+		QuantInfo: hef.QuantInfo{ZeroPoints: []float32{0, 1000}, Scales: []float32{1, 0.01}},
+	})
+	if info.Format != hef.FormatTypeUint16 {
+		t.Fatalf("vstreamInfo() Format = %s, want UINT16", info.Format)
+	}
+	vs := &OutputVStream{info: info, batchSize: 1}
+
+	// Little-endian elements: (300, 1100), (2, 900)
+	data := []byte{0x2c, 0x01, 0x4c, 0x04, 0x02, 0x00, 0x84, 0x03}
+	got, err := vs.Dequantize(data)
+	if err != nil {
+		t.Fatalf("Dequantize() error = %v", err)
+	}
+	want := []float32{300, 1, 2, -1}
+	if len(got) != len(want) {
+		t.Fatalf("Dequantize() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if diff := got[i] - want[i]; diff > 1e-4 || diff < -1e-4 {
+			t.Fatalf("Dequantize() = %v, want %v", got, want)
+		}
+	}
+
+	// Half a frame of uint16 elements is not a whole frame
+	if _, err := vs.Dequantize(data[:4]); !errors.Is(err, ErrInvalidData) {
+		t.Errorf("Dequantize(4 bytes) error = %v, want ErrInvalidData", err)
+	}
+}
+
 func TestInputVStreamClosedError(t *testing.T) {
 	vs := &InputVStream{
 		info:      VStreamInfo{FrameSize: 100},
@@ -162,6 +234,151 @@ func TestInputVStreamInvalidDataSize(t *testing.T) {
 	}
 }
 
+func TestInputVStreamWriteBufferValidation(t *testing.T) {
+	vs := &InputVStream{
+		info:      VStreamInfo{FrameSize: 100},
+		batchSize: 1,
+	}
+
+	tests := []struct {
+		name string
+		buf  *Buffer
+	}{
+		{"nil", nil},
+		{"wrong direction", &Buffer{size: 100, mapped: true, direction: driver.DmaFromDevice}},
+		{"too small", &Buffer{size: 50, mapped: true, direction: driver.DmaToDevice}},
+	}
+	for _, tt := range tests {
+		if err := vs.WriteBuffer(tt.buf); err == nil {
+			t.Errorf("%s: WriteBuffer() should return error", tt.name)
+		}
+		if tt.buf != nil && tt.buf.InFlight() {
+			t.Errorf("%s: rejected buffer left in flight", tt.name)
+		}
+	}
+
+	// A buffer still owned by an earlier transfer is refused
+	busy := &Buffer{size: 100, mapped: true, direction: driver.DmaToDevice, inFlight: true}
+	if err := vs.WriteBuffer(busy); err != ErrBufferInFlight {
+		t.Errorf("WriteBuffer() with busy buffer = %v, expected ErrBufferInFlight", err)
+	}
+
+	vs.closed = true
+	if err := vs.WriteBuffer(&Buffer{size: 100, mapped: true}); err != ErrStreamClosed {
+		t.Errorf("WriteBuffer() on closed stream = %v, expected ErrStreamClosed", err)
+	}
+}
+
+func TestInputVStreamCloseReleasesUserBuffers(t *testing.T) {
+	buf := &Buffer{size: 100, mapped: true, direction: driver.DmaToDevice, inFlight: true}
+	vs := &InputVStream{
+		info:     VStreamInfo{FrameSize: 100},
+		userBufs: []*Buffer{buf},
+		descList: &DescriptorList{released: true},
+		buffer:   &Buffer{},
+	}
+
+	vs.Close()
+	if buf.InFlight() {
+		t.Error("Close() should hand caller buffers back")
+	}
+}
+
+func TestInputVStreamRejectsSecondLaunch(t *testing.T) {
+	vs := &InputVStream{
+		info:      VStreamInfo{FrameSize: 100},
+		batchSize: 1,
+		pending:   true,
+	}
+
+	// A second launch would reprogram the descriptors of the pending one
+	buf := &Buffer{size: 100, mapped: true, direction: driver.DmaToDevice}
+	if err := vs.WriteBuffer(buf); !errors.Is(err, ErrBufferInFlight) {
+		t.Errorf("WriteBuffer() while pending = %v, expected ErrBufferInFlight", err)
+	}
+	if buf.InFlight() {
+		t.Error("rejected buffer left in flight")
+	}
+	if err := vs.Write(make([]byte, 100)); !errors.Is(err, ErrBufferInFlight) {
+		t.Errorf("Write() while pending = %v, expected ErrBufferInFlight", err)
+	}
+	if err := vs.WriteBatch([][]byte{make([]byte, 100)}); !errors.Is(err, ErrBufferInFlight) {
+		t.Errorf("WriteBatch() while pending = %v, expected ErrBufferInFlight", err)
+	}
+
+	vs.pending = false
+	if err := vs.Flush(); err != nil {
+		t.Errorf("Flush() with nothing pending = %v, expected nil", err)
+	}
+}
+
+func TestOutputVStreamReadBufferTargetMismatch(t *testing.T) {
+	pendingBuf := &Buffer{size: 100, mapped: true, direction: driver.DmaFromDevice, inFlight: true}
+	vs := &OutputVStream{
+		info:      VStreamInfo{FrameSize: 100},
+		batchSize: 1,
+		pending:   true,
+		target:    pendingBuf,
+	}
+
+	other := &Buffer{size: 100, mapped: true, direction: driver.DmaFromDevice}
+	if err := vs.ReadBuffer(other); err == nil {
+		t.Error("ReadBuffer() with a different buffer should fail")
+	}
+	if _, err := vs.Read(); err == nil {
+		t.Error("Read() while a caller buffer is pending should fail")
+	}
+	if err := vs.ReadInto(make([]byte, 100)); err == nil {
+		t.Error("ReadInto() while a caller buffer is pending should fail")
+	}
+	if err := vs.StartReadBuffer(other); err == nil {
+		t.Error("StartReadBuffer() with a read pending should fail")
+	}
+}
+
+func TestInputVStreamWriteBatchValidation(t *testing.T) {
+	vs := &InputVStream{
+		info:      VStreamInfo{FrameSize: 10},
+		batchSize: 4,
+	}
+
+	if err := vs.WriteBatch(make([][]byte, 3)); err == nil {
+		t.Error("WriteBatch() with a partial batch should return error")
+	}
+
+	frames := [][]byte{make([]byte, 10), make([]byte, 10), make([]byte, 9), make([]byte, 10)}
+	if err := vs.WriteBatch(frames); err == nil {
+		t.Error("WriteBatch() with a short frame should return error")
+	}
+
+	vs.closed = true
+	if err := vs.WriteBatch(frames); err != ErrStreamClosed {
+		t.Errorf("WriteBatch() on closed stream = %v, expected ErrStreamClosed", err)
+	}
+}
+
+func TestSplitFrames(t *testing.T) {
+	data := []byte{0, 1, 2, 3, 4, 5, 6}
+	frames := SplitFrames(data, 3)
+
+	if len(frames) != 2 {
+		t.Fatalf("SplitFrames() returned %d frames, expected 2", len(frames))
+	}
+	if frames[1][0] != 3 || len(frames[1]) != 3 {
+		t.Errorf("frame 1 = %v, expected [3 4 5]", frames[1])
+	}
+
+	// Appending to one frame must not overwrite the next
+	_ = append(frames[0], 99)
+	if frames[1][0] != 3 {
+		t.Error("append to frame 0 clobbered frame 1")
+	}
+
+	if SplitFrames(data, 0) != nil {
+		t.Error("SplitFrames() with zero frame size should return nil")
+	}
+}
+
 func TestVStreamSetByName(t *testing.T) {
 	set := &VStreamSet{
 		Inputs: []*InputVStream{
@@ -198,3 +415,52 @@ func TestVStreamSetByName(t *testing.T) {
 		t.Errorf("OutputByName(output0) returned %s", output.info.Name)
 	}
 }
+
+func TestVStreamSetResetDropsPendingTransfers(t *testing.T) {
+	in := &InputVStream{info: VStreamInfo{Name: "in", FrameSize: 100}, batchSize: 1, pending: true}
+	inBuf := &Buffer{size: 100, mapped: true, direction: driver.DmaToDevice, inFlight: true}
+	in.userBufs = []*Buffer{inBuf}
+	outBuf := &Buffer{size: 100, mapped: true, direction: driver.DmaFromDevice, inFlight: true}
+	out := &OutputVStream{info: VStreamInfo{Name: "out", FrameSize: 100}, batchSize: 1, pending: true, target: outBuf}
+	set := &VStreamSet{Inputs: []*InputVStream{in}, Outputs: []*OutputVStream{out}}
+
+	if err := set.Reset(); err != nil {
+		t.Fatalf("Reset() error = %v", err)
+	}
+	if in.pending || out.pending || out.target != nil {
+		t.Error("Reset() left a transfer pending")
+	}
+	if inBuf.InFlight() || outBuf.InFlight() {
+		t.Error("Reset() did not hand caller buffers back")
+	}
+}
+
+func TestVStreamSetInferAbortsAfterFailure(t *testing.T) {
+	held := &Buffer{size: 8, mapped: true, direction: driver.DmaFromDevice}
+	out := &OutputVStream{info: VStreamInfo{Name: "out", FrameSize: 8}, batchSize: 1}
+	set := &VStreamSet{
+		Inputs:  []*InputVStream{{info: VStreamInfo{Name: "in", FrameSize: 4}, batchSize: 2}},
+		Outputs: []*OutputVStream{out},
+	}
+
+	// A short input is refused before any output is armed
+	_, err := set.Infer(context.Background(), map[string][]byte{"in": make([]byte, 4)})
+	if !errors.Is(err, ErrInvalidData) {
+		t.Fatalf("Infer(short input) error = %v, want ErrInvalidData", err)
+	}
+	if out.pending {
+		t.Fatal("Infer(short input) armed an output")
+	}
+
+	// A full batch gets to the outputs; the read left pending there makes
+	// it fail, and the failure aborts that read
+	out.pending, out.target = true, held
+	held.inFlight = true
+	_, err = set.Infer(context.Background(), map[string][]byte{"in": make([]byte, 8)})
+	if err == nil || errors.Is(err, ErrInvalidData) {
+		t.Fatalf("Infer(full batch) error = %v, want the start read failure", err)
+	}
+	if out.pending || out.target != nil || held.InFlight() {
+		t.Error("failed Infer() left the output read armed")
+	}
+}
@@ -0,0 +1,164 @@
+package track
+
+import (
+	"math"
+
+	"github.com/anthropics/purple-hailo/pkg/transform"
+)
+
+// infeasibleCost marks pairs that must never be matched
+const infeasibleCost = 1e6
+
+// Match pairs a track index with a detection index
+type Match struct {
+	Track     int
+	Detection int
+}
+
+// Hungarian solves the rectangular minimum-cost assignment problem and
+// returns, for each row, the assigned column or -1 when there are more rows
+// than columns
+func Hungarian(cost [][]float64) []int {
+	rows := len(cost)
+	if rows == 0 {
+		return nil
+	}
+	cols := len(cost[0])
+	if cols == 0 {
+		result := make([]int, rows)
+		for i := range result {
+			result[i] = -1
+		}
+		return result
+	}
+
+	if rows > cols {
+		// Solve the transpose and invert the mapping
+		t := make([][]float64, cols)
+		for j := range t {
+			t[j] = make([]float64, rows)
+			for i := 0; i < rows; i++ {
+				t[j][i] = cost[i][j]
+			}
+		}
+		colToRow := Hungarian(t)
+		result := make([]int, rows)
+		for i := range result {
+			result[i] = -1
+		}
+		for j, i := range colToRow {
+			result[i] = j
+		}
+		return result
+	}
+
+	// Shortest augmenting path with potentials (Kuhn-Munkres), O(rows^2 * cols).
+	// Arrays are 1-indexed; column 0 is a virtual source.
+	u := make([]float64, rows+1)
+	v := make([]float64, cols+1)
+	p := make([]int, cols+1) // p[j] is the row matched to column j
+	way := make([]int, cols+1)
+	minv := make([]float64, cols+1)
+	used := make([]bool, cols+1)
+
+	for i := 1; i <= rows; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= cols; j++ {
+				if used[j] {
+					continue
+				}
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= cols; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	result := make([]int, rows)
+	for j := 1; j <= cols; j++ {
+		if p[j] != 0 {
+			result[p[j]-1] = j - 1
+		}
+	}
+	return result
+}
+
+// linearAssignment matches rows to columns minimizing total cost, rejecting
+// any pair whose cost exceeds maxCost
+func linearAssignment(cost [][]float64, numCols int, maxCost float64) (matches []Match, unmatchedRows, unmatchedCols []int) {
+	colMatched := make([]bool, numCols)
+	if len(cost) > 0 && numCols > 0 {
+		for row, col := range Hungarian(cost) {
+			if col >= 0 && cost[row][col] <= maxCost {
+				matches = append(matches, Match{Track: row, Detection: col})
+				colMatched[col] = true
+				continue
+			}
+			unmatchedRows = append(unmatchedRows, row)
+		}
+	} else {
+		for row := range cost {
+			unmatchedRows = append(unmatchedRows, row)
+		}
+	}
+
+	for col, matched := range colMatched {
+		if !matched {
+			unmatchedCols = append(unmatchedCols, col)
+		}
+	}
+	return matches, unmatchedRows, unmatchedCols
+}
+
+// iouCost builds a 1-IoU cost matrix between track predictions and
+// detections. Pairs of different classes are infeasible when classAware.
+func iouCost(tracks []*Track, detections []transform.Detection, classAware bool) [][]float64 {
+	cost := make([][]float64, len(tracks))
+	for i, t := range tracks {
+		cost[i] = make([]float64, len(detections))
+		predicted := t.kf.bbox()
+		for j, d := range detections {
+			if classAware && t.ClassId != d.ClassId {
+				cost[i][j] = infeasibleCost
+				continue
+			}
+			cost[i][j] = 1 - float64(transform.CalculateIou(predicted, d.BBox))
+		}
+	}
+	return cost
+}
@@ -0,0 +1,85 @@
+package track
+
+import "github.com/anthropics/purple-hailo/pkg/transform"
+
+// ByteTrack implements ByteTrack (Zhang et al. 2022). High-confidence
+// detections are matched first; low-confidence detections are then used to
+// keep existing tracks alive through occlusion instead of being discarded.
+type ByteTrack struct {
+	tracker
+}
+
+var _ Tracker = (*ByteTrack)(nil)
+
+// NewByteTrack creates a ByteTrack tracker
+func NewByteTrack(config Config) *ByteTrack {
+	return &ByteTrack{tracker: newTracker(config)}
+}
+
+// Update consumes one frame of detections and returns the confirmed tracks
+// matched in this frame
+func (b *ByteTrack) Update(detections []transform.Detection) []TrackedDetection {
+	var high, low []transform.Detection
+	for _, d := range detections {
+		switch {
+		case d.Score >= b.config.ScoreThreshold:
+			high = append(high, d)
+		case d.Score >= b.config.LowScoreThreshold:
+			low = append(low, d)
+		}
+	}
+
+	b.predict()
+
+	pool := filterTracks(b.tracks, func(t *Track) bool {
+		return t.State == StateConfirmed || t.State == StateLost
+	})
+	tentative := filterTracks(b.tracks, func(t *Track) bool {
+		return t.State == StateTentative
+	})
+
+	// First association: confirmed and lost tracks against high-score detections
+	matches, unmatchedPool, unmatchedHigh := b.associate(pool, high, b.config.IoUThreshold)
+	for _, m := range matches {
+		b.matchTrack(pool[m.Track], high[m.Detection])
+	}
+
+	// Second association: tracks that were active last frame against
+	// low-score detections; lost tracks only recover from high scores
+	var active []*Track
+	for _, i := range unmatchedPool {
+		if pool[i].State == StateConfirmed {
+			active = append(active, pool[i])
+		} else {
+			b.missTrack(pool[i])
+		}
+	}
+	matches, unmatchedActive, _ := b.associate(active, low, b.config.LowIoUThreshold)
+	for _, m := range matches {
+		b.matchTrack(active[m.Track], low[m.Detection])
+	}
+	for _, i := range unmatchedActive {
+		b.missTrack(active[i])
+	}
+
+	// Third association: tentative tracks against the leftover high-score detections
+	remaining := make([]transform.Detection, len(unmatchedHigh))
+	for i, j := range unmatchedHigh {
+		remaining[i] = high[j]
+	}
+	matches, unmatchedTentative, unmatchedRemaining := b.associate(tentative, remaining, b.config.IoUThreshold)
+	for _, m := range matches {
+		b.matchTrack(tentative[m.Track], remaining[m.Detection])
+	}
+	for _, i := range unmatchedTentative {
+		b.missTrack(tentative[i])
+	}
+
+	for _, i := range unmatchedRemaining {
+		if remaining[i].Score >= b.config.NewTrackThreshold {
+			b.startTrack(remaining[i])
+		}
+	}
+
+	return b.finish()
+}
@@ -0,0 +1,212 @@
+package track
+
+import "github.com/anthropics/purple-hailo/pkg/transform"
+
+// Noise weights relative to the box size, as used by DeepSORT and ByteTrack.
+// Scaling the noise with the box keeps the filter valid for both normalized
+// and pixel coordinates.
+const (
+	stdWeightPosition = 1.0 / 20
+	stdWeightVelocity = 1.0 / 160
+)
+
+// kalmanFilter is a constant-velocity filter over the box state
+// [cx, cy, w, h, vcx, vcy, vw, vh] observing [cx, cy, w, h]
+type kalmanFilter struct {
+	mean [8]float64
+	cov  [8][8]float64
+}
+
+// bboxToMeasurement converts a box to [cx, cy, w, h]
+func bboxToMeasurement(b transform.BBox) [4]float64 {
+	cx, cy := b.Center()
+	return [4]float64{float64(cx), float64(cy), float64(b.Width()), float64(b.Height())}
+}
+
+// measurementToBBox converts [cx, cy, w, h] back to a box
+func measurementToBBox(m [4]float64) transform.BBox {
+	return transform.BBox{
+		YMin: float32(m[1] - m[3]/2),
+		XMin: float32(m[0] - m[2]/2),
+		YMax: float32(m[1] + m[3]/2),
+		XMax: float32(m[0] + m[2]/2),
+	}
+}
+
+// newKalmanFilter initializes the filter from a first observation
+func newKalmanFilter(b transform.BBox) *kalmanFilter {
+	m := bboxToMeasurement(b)
+	kf := &kalmanFilter{}
+	copy(kf.mean[:4], m[:])
+
+	w, h := m[2], m[3]
+	std := [8]float64{
+		2 * stdWeightPosition * w,
+		2 * stdWeightPosition * h,
+		2 * stdWeightPosition * w,
+		2 * stdWeightPosition * h,
+		10 * stdWeightVelocity * w,
+		10 * stdWeightVelocity * h,
+		10 * stdWeightVelocity * w,
+		10 * stdWeightVelocity * h,
+	}
+	for i, s := range std {
+		kf.cov[i][i] = s * s
+	}
+	return kf
+}
+
+// predict advances the state by one frame
+func (kf *kalmanFilter) predict() {
+	w, h := kf.mean[2], kf.mean[3]
+
+	// x' = F x, where F adds each velocity to its position
+	for i := 0; i < 4; i++ {
+		kf.mean[i] += kf.mean[i+4]
+	}
+
+	// P' = F P F^T
+	var fp [8][8]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			fp[i][j] = kf.cov[i][j]
+			if i < 4 {
+				fp[i][j] += kf.cov[i+4][j]
+			}
+		}
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			kf.cov[i][j] = fp[i][j]
+			if j < 4 {
+				kf.cov[i][j] += fp[i][j+4]
+			}
+		}
+	}
+
+	// + Q
+	q := [8]float64{
+		stdWeightPosition * w,
+		stdWeightPosition * h,
+		stdWeightPosition * w,
+		stdWeightPosition * h,
+		stdWeightVelocity * w,
+		stdWeightVelocity * h,
+		stdWeightVelocity * w,
+		stdWeightVelocity * h,
+	}
+	for i, s := range q {
+		kf.cov[i][i] += s * s
+	}
+}
+
+// update corrects the state with an observed box
+func (kf *kalmanFilter) update(b transform.BBox) {
+	z := bboxToMeasurement(b)
+	w, h := kf.mean[2], kf.mean[3]
+	r := [4]float64{
+		stdWeightPosition * w,
+		stdWeightPosition * h,
+		stdWeightPosition * w,
+		stdWeightPosition * h,
+	}
+
+	// S = H P H^T + R is the top-left 4x4 block of P plus R
+	var s [4][4]float64
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			s[i][j] = kf.cov[i][j]
+		}
+		s[i][i] += r[i] * r[i]
+	}
+	sInv, ok := invert4(s)
+	if !ok {
+		return
+	}
+
+	// K = P H^T S^-1, where P H^T is the first four columns of P
+	var k [8][4]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			for n := 0; n < 4; n++ {
+				k[i][j] += kf.cov[i][n] * sInv[n][j]
+			}
+		}
+	}
+
+	var innovation [4]float64
+	for i := 0; i < 4; i++ {
+		innovation[i] = z[i] - kf.mean[i]
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 4; j++ {
+			kf.mean[i] += k[i][j] * innovation[j]
+		}
+	}
+
+	// P = P - K H P, where H P is the first four rows of P
+	var khp [8][8]float64
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			for n := 0; n < 4; n++ {
+				khp[i][j] += k[i][n] * kf.cov[n][j]
+			}
+		}
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			kf.cov[i][j] -= khp[i][j]
+		}
+	}
+}
+
+// bbox returns the current box estimate
+func (kf *kalmanFilter) bbox() transform.BBox {
+	return measurementToBBox([4]float64{kf.mean[0], kf.mean[1], kf.mean[2], kf.mean[3]})
+}
+
+// invert4 inverts a 4x4 matrix with Gauss-Jordan elimination
+func invert4(m [4][4]float64) ([4][4]float64, bool) {
+	var inv [4][4]float64
+	for i := 0; i < 4; i++ {
+		inv[i][i] = 1
+	}
+
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if abs64(m[row][col]) > abs64(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if abs64(m[pivot][col]) < 1e-12 {
+			return inv, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		inv[col], inv[pivot] = inv[pivot], inv[col]
+
+		p := m[col][col]
+		for j := 0; j < 4; j++ {
+			m[col][j] /= p
+			inv[col][j] /= p
+		}
+		for row := 0; row < 4; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col]
+			for j := 0; j < 4; j++ {
+				m[row][j] -= f * m[col][j]
+				inv[row][j] -= f * inv[col][j]
+			}
+		}
+	}
+	return inv, true
+}
+
+func abs64(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,44 @@
+package track
+
+import "github.com/anthropics/purple-hailo/pkg/transform"
+
+// SORT implements Simple Online and Realtime Tracking (Bewley et al. 2016):
+// Kalman prediction followed by a single IoU assignment per frame
+type SORT struct {
+	tracker
+}
+
+var _ Tracker = (*SORT)(nil)
+
+// NewSORT creates a SORT tracker
+func NewSORT(config Config) *SORT {
+	return &SORT{tracker: newTracker(config)}
+}
+
+// Update consumes one frame of detections and returns the confirmed tracks
+// matched in this frame
+func (s *SORT) Update(detections []transform.Detection) []TrackedDetection {
+	s.predict()
+
+	var candidates []transform.Detection
+	for _, d := range detections {
+		if d.Score >= s.config.ScoreThreshold {
+			candidates = append(candidates, d)
+		}
+	}
+
+	tracks := s.tracks
+	matches, unmatchedTracks, unmatchedDets := s.associate(tracks, candidates, s.config.IoUThreshold)
+
+	for _, m := range matches {
+		s.matchTrack(tracks[m.Track], candidates[m.Detection])
+	}
+	for _, i := range unmatchedTracks {
+		s.missTrack(tracks[i])
+	}
+	for _, i := range unmatchedDets {
+		s.startTrack(candidates[i])
+	}
+
+	return s.finish()
+}
@@ -0,0 +1,255 @@
+// Package track assigns stable identities to detections across video frames.
+// It provides SORT and ByteTrack trackers built on a Kalman filter motion
+// model and IoU-based Hungarian assignment.
+package track
+
+import (
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/transform"
+)
+
+// State is the lifecycle state of a track
+type State int
+
+const (
+	// StateTentative tracks have not been matched often enough to be reported
+	StateTentative State = iota
+	// StateConfirmed tracks were matched in the most recent frames
+	StateConfirmed
+	// StateLost tracks are confirmed tracks that missed recent frames; they
+	// keep their ID and can be recovered until MaxAge is exceeded
+	StateLost
+	// StateRemoved tracks are dropped and will not be matched again
+	StateRemoved
+)
+
+func (s State) String() string {
+	switch s {
+	case StateTentative:
+		return "tentative"
+	case StateConfirmed:
+		return "confirmed"
+	case StateLost:
+		return "lost"
+	case StateRemoved:
+		return "removed"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(s))
+	}
+}
+
+// Config contains tracker thresholds
+type Config struct {
+	// MinHits is the number of matched frames before a track is confirmed
+	MinHits int
+	// MaxAge is the number of frames a track survives without a match
+	MaxAge int
+	// IoUThreshold is the minimum IoU for matching a track to a detection
+	IoUThreshold float32
+	// ScoreThreshold drops detections below this score (SORT), or is the
+	// high-confidence split point (ByteTrack)
+	ScoreThreshold float32
+	// LowScoreThreshold is the lowest score ByteTrack uses in its second
+	// association pass; ignored by SORT
+	LowScoreThreshold float32
+	// LowIoUThreshold is the minimum IoU for the ByteTrack second pass
+	LowIoUThreshold float32
+	// NewTrackThreshold is the minimum score to start a new ByteTrack track
+	NewTrackThreshold float32
+	// ClassAware prevents matching detections of a different class
+	ClassAware bool
+}
+
+// DefaultSORTConfig returns the thresholds from the SORT paper
+func DefaultSORTConfig() Config {
+	return Config{
+		MinHits:        3,
+		MaxAge:         1,
+		IoUThreshold:   0.3,
+		ScoreThreshold: 0.5,
+		ClassAware:     true,
+	}
+}
+
+// DefaultByteTrackConfig returns the thresholds from the ByteTrack reference
+// implementation at 30 FPS
+func DefaultByteTrackConfig() Config {
+	return Config{
+		MinHits:           2,
+		MaxAge:            30,
+		IoUThreshold:      0.2,
+		ScoreThreshold:    0.5,
+		LowScoreThreshold: 0.1,
+		LowIoUThreshold:   0.5,
+		NewTrackThreshold: 0.6,
+		ClassAware:        true,
+	}
+}
+
+// Track is a single tracked object
+type Track struct {
+	ID              int
+	ClassId         int
+	State           State
+	Score           float32
+	Hits            int // total matched frames
+	Age             int // frames since the track was created
+	TimeSinceUpdate int // frames since the last match
+
+	detection transform.Detection
+	kf        *kalmanFilter
+}
+
+// BBox returns the filtered box estimate
+func (t *Track) BBox() transform.BBox {
+	return t.kf.bbox()
+}
+
+// Detection returns the most recent detection matched to the track
+func (t *Track) Detection() transform.Detection {
+	return t.detection
+}
+
+// TrackedDetection is a detection with a stable identity
+type TrackedDetection struct {
+	transform.Detection
+	TrackID  int
+	State    State
+	Smoothed transform.BBox // Kalman-filtered box
+}
+
+// Tracker assigns track IDs to per-frame detections
+type Tracker interface {
+	// Update consumes one frame of detections and returns the confirmed
+	// tracks matched in this frame
+	Update(detections []transform.Detection) []TrackedDetection
+	// Tracks returns all live tracks, including tentative and lost ones
+	Tracks() []*Track
+	// Reset drops all tracks and restarts IDs
+	Reset()
+}
+
+// tracker holds the state shared by SORT and ByteTrack
+type tracker struct {
+	config Config
+	tracks []*Track
+	nextID int
+}
+
+func newTracker(config Config) tracker {
+	if config.MinHits < 1 {
+		config.MinHits = 1
+	}
+	if config.MaxAge < 0 {
+		config.MaxAge = 0
+	}
+	return tracker{config: config, nextID: 1}
+}
+
+// Tracks returns all live tracks
+func (tr *tracker) Tracks() []*Track {
+	result := make([]*Track, len(tr.tracks))
+	copy(result, tr.tracks)
+	return result
+}
+
+// Reset drops all tracks and restarts IDs
+func (tr *tracker) Reset() {
+	tr.tracks = nil
+	tr.nextID = 1
+}
+
+// predict advances every track by one frame
+func (tr *tracker) predict() {
+	for _, t := range tr.tracks {
+		t.kf.predict()
+		t.Age++
+		t.TimeSinceUpdate++
+	}
+}
+
+// startTrack creates a track from an unmatched detection
+func (tr *tracker) startTrack(d transform.Detection) {
+	t := &Track{
+		ID:        tr.nextID,
+		ClassId:   d.ClassId,
+		State:     StateTentative,
+		Score:     d.Score,
+		Hits:      1,
+		detection: d,
+		kf:        newKalmanFilter(d.BBox),
+	}
+	if tr.config.MinHits <= 1 {
+		t.State = StateConfirmed
+	}
+	tr.nextID++
+	tr.tracks = append(tr.tracks, t)
+}
+
+// matchTrack applies a matched detection to a track
+func (tr *tracker) matchTrack(t *Track, d transform.Detection) {
+	t.kf.update(d.BBox)
+	t.detection = d
+	t.Score = d.Score
+	t.Hits++
+	t.TimeSinceUpdate = 0
+	if t.State == StateLost || t.Hits >= tr.config.MinHits {
+		t.State = StateConfirmed
+	}
+}
+
+// missTrack handles a track that found no detection this frame
+func (tr *tracker) missTrack(t *Track) {
+	switch t.State {
+	case StateTentative:
+		t.State = StateRemoved
+	case StateConfirmed:
+		t.State = StateLost
+	}
+	if t.TimeSinceUpdate > tr.config.MaxAge {
+		t.State = StateRemoved
+	}
+}
+
+// associate matches tracks to detections by IoU
+func (tr *tracker) associate(tracks []*Track, detections []transform.Detection, minIoU float32) ([]Match, []int, []int) {
+	cost := iouCost(tracks, detections, tr.config.ClassAware)
+	return linearAssignment(cost, len(detections), 1-float64(minIoU))
+}
+
+// finish drops removed tracks and reports confirmed tracks matched this frame
+func (tr *tracker) finish() []TrackedDetection {
+	live := tr.tracks[:0]
+	var results []TrackedDetection
+	for _, t := range tr.tracks {
+		if t.State == StateRemoved {
+			continue
+		}
+		live = append(live, t)
+		if t.State == StateConfirmed && t.TimeSinceUpdate == 0 {
+			results = append(results, TrackedDetection{
+				Detection: t.detection,
+				TrackID:   t.ID,
+				State:     t.State,
+				Smoothed:  t.kf.bbox(),
+			})
+		}
+	}
+	for i := len(live); i < len(tr.tracks); i++ {
+		tr.tracks[i] = nil
+	}
+	tr.tracks = live
+	return results
+}
+
+// filterTracks returns the tracks for which keep is true
+func filterTracks(tracks []*Track, keep func(*Track) bool) []*Track {
+	var result []*Track
+	for _, t := range tracks {
+		if keep(t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
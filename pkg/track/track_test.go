@@ -0,0 +1,293 @@
+//go:build unit
+
+package track
+
+import (
+	"math"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/transform"
+)
+
+func box(x, y, w, h float32) transform.BBox {
+	return transform.BBox{XMin: x, YMin: y, XMax: x + w, YMax: y + h}
+}
+
+func det(b transform.BBox, score float32) transform.Detection {
+	return transform.Detection{BBox: b, Score: score}
+}
+
+func TestHungarian(t *testing.T) {
+	tests := []struct {
+		name     string
+		cost     [][]float64
+		expected []int
+	}{
+		{
+			name:     "square",
+			cost:     [][]float64{{4, 1, 3}, {2, 0, 5}, {3, 2, 2}},
+			expected: []int{1, 0, 2},
+		},
+		{
+			name:     "more columns",
+			cost:     [][]float64{{9, 1, 9, 9}, {9, 9, 9, 2}},
+			expected: []int{1, 3},
+		},
+		{
+			name:     "more rows",
+			cost:     [][]float64{{5}, {1}, {3}},
+			expected: []int{-1, 0, -1},
+		},
+		{
+			name:     "greedy would be wrong",
+			cost:     [][]float64{{1, 2}, {2, 100}},
+			expected: []int{1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Hungarian(tt.cost)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("Hungarian() = %v, expected %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Fatalf("Hungarian() = %v, expected %v", got, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestLinearAssignmentRejectsHighCost(t *testing.T) {
+	cost := [][]float64{{0.1, 0.95}, {0.9, 0.99}}
+	matches, unmatchedRows, unmatchedCols := linearAssignment(cost, 2, 0.5)
+
+	if len(matches) != 1 || matches[0] != (Match{Track: 0, Detection: 0}) {
+		t.Errorf("matches = %v, expected [{0 0}]", matches)
+	}
+	if len(unmatchedRows) != 1 || unmatchedRows[0] != 1 {
+		t.Errorf("unmatchedRows = %v, expected [1]", unmatchedRows)
+	}
+	if len(unmatchedCols) != 1 || unmatchedCols[0] != 1 {
+		t.Errorf("unmatchedCols = %v, expected [1]", unmatchedCols)
+	}
+}
+
+func TestKalmanTracksConstantVelocity(t *testing.T) {
+	kf := newKalmanFilter(box(0.1, 0.2, 0.1, 0.1))
+	for i := 1; i <= 20; i++ {
+		kf.predict()
+		kf.update(box(0.1+0.01*float32(i), 0.2, 0.1, 0.1))
+	}
+
+	// After convergence one prediction should land close to the next position
+	kf.predict()
+	cx, _ := kf.bbox().Center()
+	if math.Abs(float64(cx)-0.36) > 0.005 {
+		t.Errorf("predicted center x = %f, expected about 0.36", cx)
+	}
+}
+
+func TestInvert4(t *testing.T) {
+	m := [4][4]float64{{4, 1, 0, 0}, {1, 3, 0, 0}, {0, 0, 2, 0}, {0, 0, 0, 5}}
+	inv, ok := invert4(m)
+	if !ok {
+		t.Fatal("invert4() reported singular matrix")
+	}
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			var sum float64
+			for k := 0; k < 4; k++ {
+				sum += m[i][k] * inv[k][j]
+			}
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if math.Abs(sum-want) > 1e-9 {
+				t.Fatalf("(M * M^-1)[%d][%d] = %f, expected %f", i, j, sum, want)
+			}
+		}
+	}
+
+	if _, ok := invert4([4][4]float64{}); ok {
+		t.Error("invert4() of zero matrix should fail")
+	}
+}
+
+func TestSORTLifecycle(t *testing.T) {
+	s := NewSORT(DefaultSORTConfig())
+	b := box(0.1, 0.1, 0.2, 0.2)
+
+	// Tentative for the first MinHits-1 frames
+	for frame := 1; frame < 3; frame++ {
+		if out := s.Update([]transform.Detection{det(b, 0.9)}); len(out) != 0 {
+			t.Fatalf("frame %d: got %d results before confirmation", frame, len(out))
+		}
+	}
+
+	out := s.Update([]transform.Detection{det(b, 0.9)})
+	if len(out) != 1 || out[0].TrackID != 1 || out[0].State != StateConfirmed {
+		t.Fatalf("confirmation frame = %+v, expected track 1 confirmed", out)
+	}
+
+	// One missed frame is tolerated with MaxAge 1
+	s.Update(nil)
+	if tracks := s.Tracks(); len(tracks) != 1 || tracks[0].State != StateLost {
+		t.Fatalf("after miss: tracks = %v, expected one lost track", tracks)
+	}
+
+	// A second miss removes it
+	s.Update(nil)
+	if len(s.Tracks()) != 0 {
+		t.Errorf("after MaxAge: %d tracks remain", len(s.Tracks()))
+	}
+}
+
+func TestSORTStableIDsForMovingObjects(t *testing.T) {
+	config := DefaultSORTConfig()
+	config.MinHits = 1
+	s := NewSORT(config)
+
+	ids := map[int]bool{}
+	for frame := 0; frame < 30; frame++ {
+		step := float32(frame) * 0.01
+		out := s.Update([]transform.Detection{
+			det(box(0.05+step, 0.1, 0.1, 0.2), 0.9),
+			det(box(0.85-step, 0.6, 0.1, 0.2), 0.8),
+		})
+		if len(out) != 2 {
+			t.Fatalf("frame %d: got %d results, expected 2", frame, len(out))
+		}
+		for _, r := range out {
+			ids[r.TrackID] = true
+		}
+	}
+
+	if len(ids) != 2 {
+		t.Errorf("saw track IDs %v, expected exactly two", ids)
+	}
+}
+
+func TestSORTClassAware(t *testing.T) {
+	config := DefaultSORTConfig()
+	config.MinHits = 1
+	s := NewSORT(config)
+
+	b := box(0.2, 0.2, 0.2, 0.2)
+	first := s.Update([]transform.Detection{{BBox: b, Score: 0.9, ClassId: 0}})
+	second := s.Update([]transform.Detection{{BBox: b, Score: 0.9, ClassId: 1}})
+
+	if len(first) != 1 || len(second) != 1 || first[0].TrackID == second[0].TrackID {
+		t.Errorf("class change should start a new track: first=%v second=%v", first, second)
+	}
+}
+
+func TestByteTrackRecoversWithLowScore(t *testing.T) {
+	bt := NewByteTrack(DefaultByteTrackConfig())
+	b := box(0.3, 0.3, 0.2, 0.3)
+
+	bt.Update([]transform.Detection{det(b, 0.9)})
+	out := bt.Update([]transform.Detection{det(b, 0.9)})
+	if len(out) != 1 {
+		t.Fatalf("expected confirmed track on second frame, got %v", out)
+	}
+	id := out[0].TrackID
+
+	// Occlusion: the detector's confidence drops below the high threshold
+	out = bt.Update([]transform.Detection{det(b, 0.2)})
+	if len(out) != 1 || out[0].TrackID != id {
+		t.Fatalf("low-score detection should extend track %d, got %v", id, out)
+	}
+
+	// SORT would drop the same detection
+	config := DefaultSORTConfig()
+	config.MinHits = 1
+	s := NewSORT(config)
+	s.Update([]transform.Detection{det(b, 0.9)})
+	if out := s.Update([]transform.Detection{det(b, 0.2)}); len(out) != 0 {
+		t.Errorf("SORT should ignore low-score detections, got %v", out)
+	}
+}
+
+func TestByteTrackLostTrackRecovery(t *testing.T) {
+	bt := NewByteTrack(DefaultByteTrackConfig())
+	b := box(0.4, 0.4, 0.1, 0.2)
+
+	bt.Update([]transform.Detection{det(b, 0.9)})
+	out := bt.Update([]transform.Detection{det(b, 0.9)})
+	id := out[0].TrackID
+
+	for i := 0; i < 5; i++ {
+		bt.Update(nil)
+	}
+	if tracks := bt.Tracks(); len(tracks) != 1 || tracks[0].State != StateLost {
+		t.Fatalf("expected one lost track, got %v", tracks)
+	}
+
+	out = bt.Update([]transform.Detection{det(b, 0.9)})
+	if len(out) != 1 || out[0].TrackID != id {
+		t.Errorf("lost track should be recovered with ID %d, got %v", id, out)
+	}
+}
+
+func TestByteTrackNewTrackThreshold(t *testing.T) {
+	bt := NewByteTrack(DefaultByteTrackConfig())
+
+	// Above ScoreThreshold but below NewTrackThreshold: no track is started
+	bt.Update([]transform.Detection{det(box(0.1, 0.1, 0.1, 0.1), 0.55)})
+	if len(bt.Tracks()) != 0 {
+		t.Errorf("got %d tracks, expected none", len(bt.Tracks()))
+	}
+}
+
+func TestTrackerReset(t *testing.T) {
+	config := DefaultSORTConfig()
+	config.MinHits = 1
+	s := NewSORT(config)
+
+	s.Update([]transform.Detection{det(box(0.1, 0.1, 0.1, 0.1), 0.9)})
+	s.Reset()
+	out := s.Update([]transform.Detection{det(box(0.5, 0.5, 0.1, 0.1), 0.9)})
+
+	if len(out) != 1 || out[0].TrackID != 1 {
+		t.Errorf("after Reset IDs should restart at 1, got %v", out)
+	}
+}
+
+func TestStateStrings(t *testing.T) {
+	tests := []struct {
+		state    State
+		expected string
+	}{
+		{StateTentative, "tentative"},
+		{StateConfirmed, "confirmed"},
+		{StateLost, "lost"},
+		{StateRemoved, "removed"},
+		{State(9), "Unknown(9)"},
+	}
+	for _, tt := range tests {
+		if got := tt.state.String(); got != tt.expected {
+			t.Errorf("String() = %s, expected %s", got, tt.expected)
+		}
+	}
+}
+
+// Benchmarks
+
+func BenchmarkByteTrack50Objects(b *testing.B) {
+	bt := NewByteTrack(DefaultByteTrackConfig())
+	dets := make([]transform.Detection, 50)
+	for i := range dets {
+		x := float32(i%10) * 0.1
+		y := float32(i/10) * 0.2
+		dets[i] = det(box(x, y, 0.08, 0.15), 0.9)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bt.Update(dets)
+	}
+}
@@ -0,0 +1,312 @@
+package transform
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// Float16ToFloat32 converts an IEEE 754 half-precision value to float32
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch exp {
+	case 0:
+		if mant == 0 {
+			return math.Float32frombits(sign)
+		}
+		// Subnormal: shift until the implicit bit appears
+		e := uint32(127 - 15 + 1)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+		mant &= 0x3ff
+		return math.Float32frombits(sign | e<<23 | mant<<13)
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp+127-15)<<23 | mant<<13)
+}
+
+// Float32ToFloat16 converts a float32 to IEEE 754 half precision, rounding
+// to nearest even
+func Float32ToFloat16(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16(bits>>16) & 0x8000
+	rawExp := int32(bits>>23) & 0xff
+	mant := bits & 0x7fffff
+
+	if rawExp == 0xff {
+		if mant != 0 {
+			return sign | 0x7e00 // NaN
+		}
+		return sign | 0x7c00 // Inf
+	}
+	if bits&0x7fffffff == 0 {
+		return sign
+	}
+
+	exp := rawExp - 127 + 15
+	if exp >= 0x1f {
+		return sign | 0x7c00 // overflow to Inf
+	}
+	if exp <= 0 {
+		if exp < -10 {
+			return sign // underflow to zero
+		}
+		mant |= 0x800000
+		shift := uint32(14 - exp)
+		half := uint16(mant >> shift)
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++
+		}
+		return sign | half
+	}
+
+	half := sign | uint16(exp)<<10 | uint16(mant>>13)
+	rem := mant & 0x1fff
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++ // a carry into the exponent is the correct result
+	}
+	return half
+}
+
+// QuantizeI8 converts a float32 to int8 using quantization parameters
+func QuantizeI8(value float32, qi QuantInfo) int8 {
+	return int8(clampRound(value/qi.Scale+qi.ZeroPoint, math.MinInt8, math.MaxInt8))
+}
+
+// DequantizeI8 converts an int8 to float32 using quantization parameters
+func DequantizeI8(value int8, qi QuantInfo) float32 {
+	return (float32(value) - qi.ZeroPoint) * qi.Scale
+}
+
+// QuantizeI16 converts a float32 to int16 using quantization parameters
+func QuantizeI16(value float32, qi QuantInfo) int16 {
+	return int16(clampRound(value/qi.Scale+qi.ZeroPoint, math.MinInt16, math.MaxInt16))
+}
+
+// DequantizeI16 converts an int16 to float32 using quantization parameters
+func DequantizeI16(value int16, qi QuantInfo) float32 {
+	return (float32(value) - qi.ZeroPoint) * qi.Scale
+}
+
+// clampRound clips v to [lo, hi] and rounds half away from zero
+func clampRound(v, lo, hi float32) float32 {
+	if v != v { // NaN
+		return 0
+	}
+	if v <= lo {
+		return lo
+	}
+	if v >= hi {
+		return hi
+	}
+	return float32(math.Round(float64(v)))
+}
+
+// DequantizeBatchPerChannel dequantizes interleaved (NHWC) uint8 data where
+// each of the channels has its own scale and zero point. The input must hold
+// whole pixels of the given channel count.
+func DequantizeBatchPerChannel(input []uint8, output []float32, channels int, qi QuantInfo) error {
+	if len(output) < len(input) {
+		return fmt.Errorf("output too small: need %d values, got %d", len(input), len(output))
+	}
+	if !qi.IsPerChannel() {
+		DequantizeBatch(input, output, qi)
+		return nil
+	}
+	if channels <= 0 || len(input)%channels != 0 {
+		return fmt.Errorf("%d values are not a whole number of %d-channel pixels", len(input), channels)
+	}
+
+	zps := make([]float32, channels)
+	scales := make([]float32, channels)
+	for c := 0; c < channels; c++ {
+		cq := qi.Channel(c)
+		zps[c], scales[c] = cq.ZeroPoint, cq.Scale
+	}
+
+	for i := 0; i < len(input); i += channels {
+		in := input[i : i+channels]
+		out := output[i : i+channels]
+		for c, v := range in {
+			out[c] = (float32(v) - zps[c]) * scales[c]
+		}
+	}
+	return nil
+}
+
+// readElement decodes element i of a little-endian buffer as float32
+func readElement(t hef.FormatType, b []byte, i int) float32 {
+	switch t {
+	case hef.FormatTypeUint8:
+		return float32(b[i])
+	case hef.FormatTypeInt8:
+		return float32(int8(b[i]))
+	case hef.FormatTypeUint16:
+		return float32(binary.LittleEndian.Uint16(b[i*2:]))
+	case hef.FormatTypeInt16:
+		return float32(int16(binary.LittleEndian.Uint16(b[i*2:])))
+	case hef.FormatTypeFloat16:
+		return Float16ToFloat32(binary.LittleEndian.Uint16(b[i*2:]))
+	default: // Float32
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+}
+
+// writeElement encodes v as element i of a little-endian buffer, rounding and
+// saturating for integer types
+func writeElement(t hef.FormatType, b []byte, i int, v float32) {
+	switch t {
+	case hef.FormatTypeUint8:
+		b[i] = uint8(clampRound(v, 0, math.MaxUint8))
+	case hef.FormatTypeInt8:
+		b[i] = uint8(int8(clampRound(v, math.MinInt8, math.MaxInt8)))
+	case hef.FormatTypeUint16:
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(clampRound(v, 0, math.MaxUint16)))
+	case hef.FormatTypeInt16:
+		binary.LittleEndian.PutUint16(b[i*2:], uint16(int16(clampRound(v, math.MinInt16, math.MaxInt16))))
+	case hef.FormatTypeFloat16:
+		binary.LittleEndian.PutUint16(b[i*2:], Float32ToFloat16(v))
+	default: // Float32
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+}
+
+// transposeElements reorders an h x w x c tensor of elemSize-byte elements
+// between NHWC and NCHW
+func transposeElements(src, dst []byte, h, w, c, elemSize int, toNCHW bool) {
+	plane := h * w
+	for p := 0; p < plane; p++ {
+		for ch := 0; ch < c; ch++ {
+			nhwc := (p*c + ch) * elemSize
+			nchw := (ch*plane + p) * elemSize
+			if toNCHW {
+				copy(dst[nchw:nchw+elemSize], src[nhwc:nhwc+elemSize])
+			} else {
+				copy(dst[nhwc:nhwc+elemSize], src[nchw:nchw+elemSize])
+			}
+		}
+	}
+}
+
+// ConvertFormat converts a tensor between HEF formats. Quantized integer
+// types are dequantized when converted to a float type and quantized when
+// converted from one, using per-channel parameters when qi has them.
+// Integer to integer and float to float conversions are plain casts.
+// NHWC and NCHW orders can be converted into each other; any other order
+// must match on both sides. Per-channel parameters need the channel of each
+// element, so they are only applied to NHWC and NCHW tensors.
+func ConvertFormat(src []byte, srcFormat hef.Format, dst []byte, dstFormat hef.Format, shape hef.ImageShape3D, qi QuantInfo) error {
+	if dstFormat.Type == hef.FormatTypeAuto {
+		dstFormat.Type = srcFormat.Type
+	}
+	srcSize, dstSize := srcFormat.Type.Size(), dstFormat.Type.Size()
+	if srcSize == 0 || dstSize == 0 {
+		return fmt.Errorf("cannot convert %s to %s", srcFormat.Type, dstFormat.Type)
+	}
+
+	h, w, c := int(shape.Height), int(shape.Width), int(shape.Features)
+	n := h * w * c
+	if len(src) < n*srcSize {
+		return fmt.Errorf("source too small: need %d bytes, got %d", n*srcSize, len(src))
+	}
+	if len(dst) < n*dstSize {
+		return fmt.Errorf("destination too small: need %d bytes, got %d", n*dstSize, len(dst))
+	}
+
+	reorder := srcFormat.Order != dstFormat.Order
+	if reorder && !isNHWCOrNCHW(srcFormat.Order, dstFormat.Order) {
+		return fmt.Errorf("cannot convert order %s to %s", srcFormat.Order, dstFormat.Order)
+	}
+
+	dequant := srcFormat.Type.IsQuantized() && !dstFormat.Type.IsQuantized()
+	quant := !srcFormat.Type.IsQuantized() && dstFormat.Type.IsQuantized()
+	if (dequant || quant) && qi.Scale == 0 && !qi.IsPerChannel() {
+		return fmt.Errorf("converting %s to %s requires a quantization scale", srcFormat.Type, dstFormat.Type)
+	}
+	if (dequant || quant) && qi.IsPerChannel() && !isNHWCOrNCHW(srcFormat.Order, srcFormat.Order) {
+		return fmt.Errorf("per-channel quantization is not supported for order %s", srcFormat.Order)
+	}
+
+	if !reorder && srcFormat.Type == dstFormat.Type {
+		copy(dst, src[:n*srcSize])
+		return nil
+	}
+
+	// Fast path for the common uint8 to float32 case, per tensor in any
+	// order or per channel when channels are interleaved
+	interleaved := !qi.IsPerChannel() || srcFormat.Order == hef.FormatOrderNHWC
+	if !reorder && interleaved && srcFormat.Type == hef.FormatTypeUint8 && dstFormat.Type == hef.FormatTypeFloat32 {
+		out := make([]float32, n)
+		if err := DequantizeBatchPerChannel(src[:n], out, c, qi); err != nil {
+			return err
+		}
+		for i, v := range out {
+			binary.LittleEndian.PutUint32(dst[i*4:], math.Float32bits(v))
+		}
+		return nil
+	}
+
+	// Convert the element type in source order, then reorder if needed
+	converted := dst
+	if reorder {
+		converted = make([]byte, n*dstSize)
+	}
+
+	nchw := srcFormat.Order == hef.FormatOrderNCHW
+	plane := h * w
+	for i := 0; i < n; i++ {
+		v := readElement(srcFormat.Type, src, i)
+		if dequant || quant {
+			ch := i % c
+			if nchw {
+				ch = i / plane
+			}
+			cq := qi.Channel(ch)
+			if dequant {
+				v = (v - cq.ZeroPoint) * cq.Scale
+			} else {
+				v = v/cq.Scale + cq.ZeroPoint
+			}
+		}
+		writeElement(dstFormat.Type, converted, i, v)
+	}
+
+	if reorder {
+		transposeElements(converted, dst, h, w, c, dstSize, dstFormat.Order == hef.FormatOrderNCHW)
+	}
+	return nil
+}
+
+func isNHWCOrNCHW(a, b hef.FormatOrder) bool {
+	valid := func(o hef.FormatOrder) bool {
+		return o == hef.FormatOrderNHWC || o == hef.FormatOrderNCHW
+	}
+	return valid(a) && valid(b)
+}
+
+// DequantizeTensor converts a raw device output to float32 values in the
+// same order, honoring the element type and any per-channel parameters
+func DequantizeTensor(src []byte, format hef.Format, shape hef.ImageShape3D, qi QuantInfo) ([]float32, error) {
+	n := int(shape.Height * shape.Width * shape.Features)
+	raw := make([]byte, n*4)
+	dstFormat := hef.Format{Type: hef.FormatTypeFloat32, Order: format.Order}
+	if err := ConvertFormat(src, format, raw, dstFormat, shape, qi); err != nil {
+		return nil, err
+	}
+
+	result := make([]float32, n)
+	for i := range result {
+		result[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+	}
+	return result, nil
+}
@@ -0,0 +1,270 @@
+//go:build unit
+
+package transform
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+func TestFloat16RoundTrip(t *testing.T) {
+	tests := []struct {
+		value float32
+		bits  uint16
+	}{
+		{0, 0x0000},
+		{1, 0x3c00},
+		{-2, 0xc000},
+		{0.5, 0x3800},
+		{65504, 0x7bff},                     // max half
+		{float32(math.Pow(2, -24)), 1},      // smallest subnormal
+		{float32(math.Pow(2, -14)), 0x0400}, // smallest normal
+	}
+
+	for _, tt := range tests {
+		if got := Float32ToFloat16(tt.value); got != tt.bits {
+			t.Errorf("Float32ToFloat16(%g) = 0x%04x, expected 0x%04x", tt.value, got, tt.bits)
+		}
+		if got := Float16ToFloat32(tt.bits); got != tt.value {
+			t.Errorf("Float16ToFloat32(0x%04x) = %g, expected %g", tt.bits, got, tt.value)
+		}
+	}
+}
+
+func TestFloat16Special(t *testing.T) {
+	if got := Float32ToFloat16(1e6); got != 0x7c00 {
+		t.Errorf("overflow = 0x%04x, expected +Inf 0x7c00", got)
+	}
+	if got := Float32ToFloat16(float32(math.NaN())); got&0x7c00 != 0x7c00 || got&0x3ff == 0 {
+		t.Errorf("NaN = 0x%04x, expected a NaN encoding", got)
+	}
+	if got := Float16ToFloat32(0xfc00); !math.IsInf(float64(got), -1) {
+		t.Errorf("Float16ToFloat32(0xfc00) = %g, expected -Inf", got)
+	}
+	// 1 + 2^-11 is exactly halfway between 1 and the next half; ties to even
+	if got := Float32ToFloat16(1 + float32(math.Pow(2, -11))); got != 0x3c00 {
+		t.Errorf("tie rounding = 0x%04x, expected 0x3c00", got)
+	}
+}
+
+func TestQuantizeSigned(t *testing.T) {
+	qi := QuantInfo{ZeroPoint: 0, Scale: 0.5}
+
+	tests := []struct {
+		value float32
+		i8    int8
+		i16   int16
+	}{
+		{0, 0, 0},
+		{1, 2, 2},
+		{-1, -2, -2},
+		{100, 127, 200},
+		{-100, -128, -200},
+		{-20000, -128, -32768},
+	}
+
+	for _, tt := range tests {
+		if got := QuantizeI8(tt.value, qi); got != tt.i8 {
+			t.Errorf("QuantizeI8(%f) = %d, expected %d", tt.value, got, tt.i8)
+		}
+		if got := QuantizeI16(tt.value, qi); got != tt.i16 {
+			t.Errorf("QuantizeI16(%f) = %d, expected %d", tt.value, got, tt.i16)
+		}
+	}
+
+	if got := DequantizeI8(-4, qi); got != -2 {
+		t.Errorf("DequantizeI8(-4) = %f, expected -2", got)
+	}
+	if got := DequantizeI16(300, QuantInfo{ZeroPoint: 100, Scale: 0.25}); got != 50 {
+		t.Errorf("DequantizeI16(300) = %f, expected 50", got)
+	}
+}
+
+func TestQuantInfoFromHef(t *testing.T) {
+	hq := hef.QuantInfo{ZeroPoint: 1, Scale: 2, Scales: []float32{0.1, 0.2}, ZeroPoints: []float32{5, 6}}
+	qi := QuantInfoFromHef(hq)
+
+	if !qi.IsPerChannel() {
+		t.Fatal("expected per-channel QuantInfo")
+	}
+	if c := qi.Channel(1); c.Scale != 0.2 || c.ZeroPoint != 6 {
+		t.Errorf("Channel(1) = %+v, expected scale 0.2 zp 6", c)
+	}
+}
+
+func TestDequantizeBatchPerChannel(t *testing.T) {
+	qi := QuantInfo{
+		ZeroPoints: []float32{0, 10, 100},
+		Scales:     []float32{1, 0.5, 0.25},
+	}
+	input := []uint8{10, 20, 104, 0, 10, 100}
+	output := make([]float32, len(input))
+	expected := []float32{10, 5, 1, 0, 0, 0}
+
+	if err := DequantizeBatchPerChannel(input, output, 3, qi); err != nil {
+		t.Fatalf("DequantizeBatchPerChannel() error = %v", err)
+	}
+
+	for i := range expected {
+		if output[i] != expected[i] {
+			t.Errorf("output[%d] = %f, expected %f", i, output[i], expected[i])
+		}
+	}
+
+	// A partial pixel at the end is an error, not silently skipped
+	if err := DequantizeBatchPerChannel(input[:5], output, 3, qi); err == nil {
+		t.Error("expected error for a partial trailing pixel")
+	}
+	if err := DequantizeBatchPerChannel(input, output[:5], 3, qi); err == nil {
+		t.Error("expected error for a short output")
+	}
+}
+
+func TestConvertFormatPerChannelOrders(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 1, Width: 2, Features: 2}
+	qi := QuantInfo{ZeroPoints: []float32{0, 0}, Scales: []float32{1, 2}}
+	f32 := func(o hef.FormatOrder) hef.Format { return hef.Format{Type: hef.FormatTypeFloat32, Order: o} }
+	u8 := func(o hef.FormatOrder) hef.Format { return hef.Format{Type: hef.FormatTypeUint8, Order: o} }
+
+	tests := []struct {
+		order hef.FormatOrder
+		ok    bool
+	}{
+		{hef.FormatOrderNHWC, true},
+		{hef.FormatOrderNCHW, true},
+		{hef.FormatOrderNHCW, false},
+		{hef.FormatOrderFCR, false},
+		{hef.FormatOrderF8CR, false},
+		{hef.FormatOrderNC, false},
+	}
+	for _, tt := range tests {
+		err := ConvertFormat(make([]byte, 4), u8(tt.order), make([]byte, 16), f32(tt.order), shape, qi)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: dequantize error = %v, want ok = %v", tt.order, err, tt.ok)
+		}
+		err = ConvertFormat(make([]byte, 16), f32(tt.order), make([]byte, 4), u8(tt.order), shape, qi)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: quantize error = %v, want ok = %v", tt.order, err, tt.ok)
+		}
+
+		// Per-tensor parameters do not depend on the layout
+		if err := ConvertFormat(make([]byte, 4), u8(tt.order), make([]byte, 16), f32(tt.order), shape, QuantInfo{Scale: 1}); err != nil {
+			t.Errorf("%s: per-tensor dequantize error = %v", tt.order, err)
+		}
+	}
+}
+
+func TestConvertFormatU16PerChannelNCHW(t *testing.T) {
+	// 1x2 spatial, 2 channels, stored planar as the device emits it
+	shape := hef.ImageShape3D{Height: 1, Width: 2, Features: 2}
+	qi := QuantInfo{
+		ZeroPoints: []float32{1000, 0},
+		Scales:     []float32{0.01, 2},
+	}
+	src := make([]byte, 8)
+	for i, v := range []uint16{1100, 900, 3, 4} {
+		binary.LittleEndian.PutUint16(src[i*2:], v)
+	}
+
+	result, err := DequantizeTensor(src, hef.Format{Type: hef.FormatTypeUint16, Order: hef.FormatOrderNCHW}, shape, qi)
+	if err != nil {
+		t.Fatalf("DequantizeTensor() error = %v", err)
+	}
+
+	expected := []float32{1, -1, 6, 8}
+	for i := range expected {
+		if math.Abs(float64(result[i]-expected[i])) > 1e-4 {
+			t.Errorf("result[%d] = %f, expected %f", i, result[i], expected[i])
+		}
+	}
+}
+
+func TestConvertFormatReorderAndQuantize(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 1, Width: 2, Features: 3}
+	qi := QuantInfo{ZeroPoint: 10, Scale: 0.5}
+
+	// NHWC float32 pixels (R,G,B),(R,G,B)
+	values := []float32{0, 1, 2, 3, 4, 5}
+	src := make([]byte, len(values)*4)
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(src[i*4:], math.Float32bits(v))
+	}
+
+	dst := make([]byte, len(values))
+	err := ConvertFormat(src, hef.Format{Type: hef.FormatTypeFloat32, Order: hef.FormatOrderNHWC},
+		dst, hef.Format{Type: hef.FormatTypeInt8, Order: hef.FormatOrderNCHW}, shape, qi)
+	if err != nil {
+		t.Fatalf("ConvertFormat() error = %v", err)
+	}
+
+	// Planar: R0 R1 G0 G1 B0 B1, each quantized as v/0.5 + 10
+	expected := []int8{10, 16, 12, 18, 14, 20}
+	for i := range expected {
+		if int8(dst[i]) != expected[i] {
+			t.Errorf("dst[%d] = %d, expected %d", i, int8(dst[i]), expected[i])
+		}
+	}
+}
+
+func TestConvertFormatFloat16(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 1, Width: 1, Features: 2}
+	src := make([]byte, 4)
+	binary.LittleEndian.PutUint16(src, Float32ToFloat16(1.5))
+	binary.LittleEndian.PutUint16(src[2:], Float32ToFloat16(-3))
+
+	result, err := DequantizeTensor(src, hef.Format{Type: hef.FormatTypeFloat16, Order: hef.FormatOrderNHWC}, shape, QuantInfo{})
+	if err != nil {
+		t.Fatalf("DequantizeTensor() error = %v", err)
+	}
+	if result[0] != 1.5 || result[1] != -3 {
+		t.Errorf("result = %v, expected [1.5 -3]", result)
+	}
+}
+
+func TestConvertFormatIntegerCast(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 1, Width: 1, Features: 3}
+	src := make([]byte, 6)
+	for i, v := range []uint16{5, 300, 65535} {
+		binary.LittleEndian.PutUint16(src[i*2:], v)
+	}
+	dst := make([]byte, 3)
+
+	err := ConvertFormat(src, hef.Format{Type: hef.FormatTypeUint16, Order: hef.FormatOrderNHWC},
+		dst, hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNHWC}, shape, QuantInfo{})
+	if err != nil {
+		t.Fatalf("ConvertFormat() error = %v", err)
+	}
+	if dst[0] != 5 || dst[1] != 255 || dst[2] != 255 {
+		t.Errorf("dst = %v, expected saturating cast [5 255 255]", dst)
+	}
+}
+
+func TestConvertFormatErrors(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 2, Width: 2, Features: 1}
+	u8 := hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNHWC}
+	f32 := hef.Format{Type: hef.FormatTypeFloat32, Order: hef.FormatOrderNHWC}
+
+	tests := []struct {
+		name string
+		src  []byte
+		sf   hef.Format
+		dst  []byte
+		df   hef.Format
+		qi   QuantInfo
+	}{
+		{"missing scale", make([]byte, 4), u8, make([]byte, 16), f32, QuantInfo{}},
+		{"short source", make([]byte, 2), u8, make([]byte, 16), f32, QuantInfo{Scale: 1}},
+		{"short destination", make([]byte, 4), u8, make([]byte, 8), f32, QuantInfo{Scale: 1}},
+		{"auto source", make([]byte, 4), hef.Format{Order: hef.FormatOrderNHWC}, make([]byte, 16), f32, QuantInfo{Scale: 1}},
+		{"nv12 reorder", make([]byte, 4), u8, make([]byte, 4), hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNV12}, QuantInfo{}},
+	}
+
+	for _, tt := range tests {
+		if err := ConvertFormat(tt.src, tt.sf, tt.dst, tt.df, shape, tt.qi); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
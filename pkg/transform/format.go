@@ -2,6 +2,15 @@ package transform
 
 // ConvertNHWCtoNCHW converts from NHWC to NCHW format
 func ConvertNHWCtoNCHW(src, dst []uint8, height, width, channels int) {
+	if useParallel(height * width * channels) {
+		convertNHWCtoNCHWParallel(src, dst, height, width, channels)
+		return
+	}
+	ConvertNHWCtoNCHWScalar(src, dst, height, width, channels)
+}
+
+// ConvertNHWCtoNCHWScalar is the single-threaded reference for ConvertNHWCtoNCHW
+func ConvertNHWCtoNCHWScalar(src, dst []uint8, height, width, channels int) {
 	for y := 0; y < height; y++ {
 		for x := 0; x < width; x++ {
 			for c := 0; c < channels; c++ {
@@ -122,6 +131,15 @@ func RemovePadding(src, dst []uint8, srcH, srcW, dstH, dstW, channels int) {
 
 // ResizeBilinear resizes an image using bilinear interpolation
 func ResizeBilinear(src, dst []uint8, srcH, srcW, dstH, dstW, channels int) {
+	if useParallel(dstH * dstW * channels) {
+		resizeBilinearParallel(src, dst, srcH, srcW, dstH, dstW, channels)
+		return
+	}
+	ResizeBilinearScalar(src, dst, srcH, srcW, dstH, dstW, channels)
+}
+
+// ResizeBilinearScalar is the single-threaded reference for ResizeBilinear
+func ResizeBilinearScalar(src, dst []uint8, srcH, srcW, dstH, dstW, channels int) {
 	xRatio := float32(srcW) / float32(dstW)
 	yRatio := float32(srcH) / float32(dstH)
 
@@ -0,0 +1,235 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// TensorLayout describes how a 3D tensor is laid out in memory. Device
+// streams use hardware orders with padded shapes; host buffers use NHWC or
+// NCHW with the logical shape.
+type TensorLayout struct {
+	Order     hef.FormatOrder
+	Shape     hef.ImageShape3D // allocated (possibly padded) shape
+	ElemSize  int              // bytes per element
+	RowStride int              // bytes per row for row-major orders; 0 means tightly packed
+}
+
+// HostLayout returns the packed layout of a user buffer
+func HostLayout(order hef.FormatOrder, shape hef.ImageShape3D, elemSize int) TensorLayout {
+	return TensorLayout{Order: order, Shape: shape, ElemSize: elemSize}
+}
+
+// StreamLayout returns the hardware layout of a device stream. When the
+// stream frame is larger than its padded shape (periph row padding), the
+// extra bytes are attributed to the end of each row.
+func StreamLayout(info hef.StreamInfo) TensorLayout {
+	elemSize := info.Format.Type.Size()
+	if elemSize == 0 {
+		elemSize = 1
+	}
+
+	shape := info.HwShape
+	if shape.Height == 0 || shape.Width == 0 || shape.Features == 0 {
+		shape = info.Shape
+	}
+
+	l := TensorLayout{Order: info.Format.Order, Shape: shape, ElemSize: elemSize}
+	if l.Order == hef.FormatOrderNCHW || l.Order == hef.FormatOrderNC || shape.Height == 0 {
+		return l
+	}
+	natural := l.naturalRowBytes()
+	if info.HwFrameSize > 0 && info.HwFrameSize%uint64(shape.Height) == 0 {
+		if stride := int(info.HwFrameSize / uint64(shape.Height)); stride > natural {
+			l.RowStride = stride
+		}
+	}
+	return l
+}
+
+// naturalRowBytes returns the bytes in one row without periph padding
+func (l TensorLayout) naturalRowBytes() int {
+	w, f := int(l.Shape.Width), int(l.Shape.Features)
+	switch l.Order {
+	case hef.FormatOrderNHW:
+		return w * l.ElemSize
+	case hef.FormatOrderF8CR:
+		return w * alignUp(f, 8) * l.ElemSize
+	default:
+		return w * f * l.ElemSize
+	}
+}
+
+func (l TensorLayout) rowStride() int {
+	if l.RowStride > 0 {
+		return l.RowStride
+	}
+	return l.naturalRowBytes()
+}
+
+// Size returns the number of bytes the layout occupies
+func (l TensorLayout) Size() int {
+	h, w, f := int(l.Shape.Height), int(l.Shape.Width), int(l.Shape.Features)
+	switch l.Order {
+	case hef.FormatOrderNCHW:
+		return h * w * f * l.ElemSize
+	case hef.FormatOrderNC:
+		return f * l.ElemSize
+	default:
+		return h * l.rowStride()
+	}
+}
+
+// supported reports whether the layout can be addressed element by element
+func (l TensorLayout) supported() bool {
+	switch l.Order {
+	case hef.FormatOrderNHWC, hef.FormatOrderNCHW, hef.FormatOrderNHCW,
+		hef.FormatOrderFCR, hef.FormatOrderF8CR, hef.FormatOrderNHW, hef.FormatOrderNC:
+		return l.ElemSize > 0
+	default:
+		return false
+	}
+}
+
+// Offset returns the byte offset of element (h, w, c)
+func (l TensorLayout) Offset(h, w, c int) int {
+	width, features := int(l.Shape.Width), int(l.Shape.Features)
+	switch l.Order {
+	case hef.FormatOrderNCHW:
+		return ((c*int(l.Shape.Height)+h)*width + w) * l.ElemSize
+	case hef.FormatOrderNHCW:
+		return h*l.rowStride() + (c*width+w)*l.ElemSize
+	case hef.FormatOrderF8CR:
+		return h*l.rowStride() + ((c/8)*width*8+w*8+c%8)*l.ElemSize
+	case hef.FormatOrderNHW:
+		return h*l.rowStride() + w*l.ElemSize
+	case hef.FormatOrderNC:
+		return c * l.ElemSize
+	default: // NHWC, FCR
+		return h*l.rowStride() + (w*features+c)*l.ElemSize
+	}
+}
+
+// ConvertLayout copies the logical shape out of src into dst, translating
+// between layouts and dropping (or, towards the device, zero-filling) any
+// padding. Both layouts must use the same element size.
+func ConvertLayout(src []byte, srcLayout TensorLayout, dst []byte, dstLayout TensorLayout, shape hef.ImageShape3D) error {
+	if !srcLayout.supported() {
+		return fmt.Errorf("unsupported source layout %s", srcLayout.Order)
+	}
+	if !dstLayout.supported() {
+		return fmt.Errorf("unsupported destination layout %s", dstLayout.Order)
+	}
+	if srcLayout.ElemSize != dstLayout.ElemSize {
+		return fmt.Errorf("element size mismatch: %d vs %d", srcLayout.ElemSize, dstLayout.ElemSize)
+	}
+	if err := checkLogicalShape(srcLayout, shape); err != nil {
+		return err
+	}
+	if err := checkLogicalShape(dstLayout, shape); err != nil {
+		return err
+	}
+	if len(src) < srcLayout.Size() {
+		return fmt.Errorf("source too small: need %d bytes, got %d", srcLayout.Size(), len(src))
+	}
+	if len(dst) < dstLayout.Size() {
+		return fmt.Errorf("destination too small: need %d bytes, got %d", dstLayout.Size(), len(dst))
+	}
+
+	h, w, f := int(shape.Height), int(shape.Width), int(shape.Features)
+	if dstLayout.Order == hef.FormatOrderNC || dstLayout.Order == hef.FormatOrderNHW {
+		h, w, f = effectiveDims(dstLayout.Order, h, w, f)
+	} else {
+		h, w, f = effectiveDims(srcLayout.Order, h, w, f)
+	}
+
+	// Padding bytes in a device buffer must not carry stale data
+	if dstLayout.Size() != h*w*f*dstLayout.ElemSize {
+		clear(dst[:dstLayout.Size()])
+	}
+
+	elem := srcLayout.ElemSize
+
+	// Row-contiguous fast path: both sides store each row as W*F packed elements
+	if rowMajorInterleaved(srcLayout.Order) && rowMajorInterleaved(dstLayout.Order) &&
+		int(srcLayout.Shape.Features) == f && int(dstLayout.Shape.Features) == f {
+		rowBytes := w * f * elem
+		for y := 0; y < h; y++ {
+			s := srcLayout.Offset(y, 0, 0)
+			d := dstLayout.Offset(y, 0, 0)
+			copy(dst[d:d+rowBytes], src[s:s+rowBytes])
+		}
+		return nil
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for c := 0; c < f; c++ {
+				s := srcLayout.Offset(y, x, c)
+				d := dstLayout.Offset(y, x, c)
+				copy(dst[d:d+elem], src[s:s+elem])
+			}
+		}
+	}
+	return nil
+}
+
+// rowMajorInterleaved reports whether the order stores each row as W
+// consecutive pixels of all features
+func rowMajorInterleaved(order hef.FormatOrder) bool {
+	return order == hef.FormatOrderNHWC || order == hef.FormatOrderFCR
+}
+
+// effectiveDims collapses the logical shape for orders that drop a dimension
+func effectiveDims(order hef.FormatOrder, h, w, f int) (int, int, int) {
+	switch order {
+	case hef.FormatOrderNHW:
+		return h, w, 1
+	case hef.FormatOrderNC:
+		return 1, 1, f
+	default:
+		return h, w, f
+	}
+}
+
+// checkLogicalShape verifies the logical shape fits inside the layout
+func checkLogicalShape(l TensorLayout, shape hef.ImageShape3D) error {
+	h, w, f := effectiveDims(l.Order, int(shape.Height), int(shape.Width), int(shape.Features))
+	lh, lw, lf := effectiveDims(l.Order, int(l.Shape.Height), int(l.Shape.Width), int(l.Shape.Features))
+	if h > lh || w > lw || f > lf {
+		return fmt.Errorf("shape %dx%dx%d does not fit %s layout %dx%dx%d",
+			shape.Height, shape.Width, shape.Features, l.Order, l.Shape.Height, l.Shape.Width, l.Shape.Features)
+	}
+	return nil
+}
+
+func alignUp(v, alignment int) int {
+	return (v + alignment - 1) / alignment * alignment
+}
+
+// StreamToHost converts a raw frame read from a device stream into a packed
+// host buffer in the given order (NHWC or NCHW), removing hardware padding.
+// Element values are left untouched; use ConvertFormat to dequantize.
+func StreamToHost(raw []byte, info hef.StreamInfo, order hef.FormatOrder) ([]byte, error) {
+	src := StreamLayout(info)
+	dst := HostLayout(order, info.Shape, src.ElemSize)
+	out := make([]byte, dst.Size())
+	if err := ConvertLayout(raw, src, out, dst, info.Shape); err != nil {
+		return nil, fmt.Errorf("stream %s: %w", info.Name, err)
+	}
+	return out, nil
+}
+
+// HostToStream converts a packed host buffer in the given order (NHWC or
+// NCHW) into the hardware layout expected by a device input stream,
+// zero-filling any padding
+func HostToStream(data []byte, order hef.FormatOrder, info hef.StreamInfo) ([]byte, error) {
+	dst := StreamLayout(info)
+	src := HostLayout(order, info.Shape, dst.ElemSize)
+	out := make([]byte, dst.Size())
+	if err := ConvertLayout(data, src, out, dst, info.Shape); err != nil {
+		return nil, fmt.Errorf("stream %s: %w", info.Name, err)
+	}
+	return out, nil
+}
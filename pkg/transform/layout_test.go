@@ -0,0 +1,225 @@
+//go:build unit
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// logicalValue gives every element of a test tensor a distinct non-zero value
+func logicalValue(h, w, c int) byte {
+	return byte(1 + h*37 + w*11 + c)
+}
+
+// fillLayout writes the test tensor into a buffer with the given layout
+func fillLayout(l TensorLayout, shape hef.ImageShape3D) []byte {
+	buf := make([]byte, l.Size())
+	for h := 0; h < int(shape.Height); h++ {
+		for w := 0; w < int(shape.Width); w++ {
+			for c := 0; c < int(shape.Features); c++ {
+				buf[l.Offset(h, w, c)] = logicalValue(h, w, c)
+			}
+		}
+	}
+	return buf
+}
+
+// checkNHWC verifies a packed NHWC buffer holds the test tensor
+func checkNHWC(t *testing.T, buf []byte, shape hef.ImageShape3D) {
+	t.Helper()
+	H, W, C := int(shape.Height), int(shape.Width), int(shape.Features)
+	if len(buf) != H*W*C {
+		t.Fatalf("len = %d, expected %d", len(buf), H*W*C)
+	}
+	for h := 0; h < H; h++ {
+		for w := 0; w < W; w++ {
+			for c := 0; c < C; c++ {
+				if got := buf[(h*W+w)*C+c]; got != logicalValue(h, w, c) {
+					t.Fatalf("(%d,%d,%d) = %d, expected %d", h, w, c, got, logicalValue(h, w, c))
+				}
+			}
+		}
+	}
+}
+
+func TestStreamToHostOrders(t *testing.T) {
+	tests := []struct {
+		name  string
+		order hef.FormatOrder
+		shape hef.ImageShape3D
+		hw    hef.ImageShape3D
+	}{
+		{"NHWC padded width", hef.FormatOrderNHWC, hef.ImageShape3D{Height: 4, Width: 5, Features: 3}, hef.ImageShape3D{Height: 4, Width: 8, Features: 3}},
+		{"FCR padded features", hef.FormatOrderFCR, hef.ImageShape3D{Height: 3, Width: 4, Features: 5}, hef.ImageShape3D{Height: 3, Width: 4, Features: 8}},
+		{"NHCW", hef.FormatOrderNHCW, hef.ImageShape3D{Height: 3, Width: 6, Features: 4}, hef.ImageShape3D{Height: 3, Width: 8, Features: 4}},
+		{"F8CR", hef.FormatOrderF8CR, hef.ImageShape3D{Height: 2, Width: 3, Features: 10}, hef.ImageShape3D{Height: 2, Width: 3, Features: 16}},
+		{"NHW", hef.FormatOrderNHW, hef.ImageShape3D{Height: 4, Width: 6, Features: 1}, hef.ImageShape3D{Height: 4, Width: 8, Features: 1}},
+		{"NC", hef.FormatOrderNC, hef.ImageShape3D{Height: 1, Width: 1, Features: 10}, hef.ImageShape3D{Height: 1, Width: 1, Features: 16}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := hef.StreamInfo{
+				Name:    tt.name,
+				Format:  hef.Format{Type: hef.FormatTypeUint8, Order: tt.order},
+				Shape:   tt.shape,
+				HwShape: tt.hw,
+			}
+			raw := fillLayout(StreamLayout(info), tt.shape)
+
+			out, err := StreamToHost(raw, info, hef.FormatOrderNHWC)
+			if err != nil {
+				t.Fatalf("StreamToHost() error = %v", err)
+			}
+			checkNHWC(t, out, tt.shape)
+		})
+	}
+}
+
+func TestF8CRByteLayout(t *testing.T) {
+	// One row, two columns, 10 features padded to 16: [g0: w0 f0-7, w1 f0-7][g1: w0 f8-15, w1 f8-15]
+	l := TensorLayout{Order: hef.FormatOrderF8CR, Shape: hef.ImageShape3D{Height: 1, Width: 2, Features: 16}, ElemSize: 1}
+
+	tests := []struct {
+		w, c   int
+		offset int
+	}{
+		{0, 0, 0},
+		{0, 7, 7},
+		{1, 0, 8},
+		{0, 8, 16},
+		{1, 9, 25},
+	}
+	for _, tt := range tests {
+		if got := l.Offset(0, tt.w, tt.c); got != tt.offset {
+			t.Errorf("Offset(0,%d,%d) = %d, expected %d", tt.w, tt.c, got, tt.offset)
+		}
+	}
+}
+
+func TestStreamLayoutPeriphPadding(t *testing.T) {
+	// 5x3 uint16 rows are 30 bytes, but the device writes 32-byte rows
+	info := hef.StreamInfo{
+		Format:      hef.Format{Type: hef.FormatTypeUint16, Order: hef.FormatOrderNHWC},
+		Shape:       hef.ImageShape3D{Height: 2, Width: 5, Features: 3},
+		HwShape:     hef.ImageShape3D{Height: 2, Width: 5, Features: 3},
+		HwFrameSize: 64,
+	}
+	l := StreamLayout(info)
+	if l.RowStride != 32 {
+		t.Fatalf("RowStride = %d, expected 32", l.RowStride)
+	}
+	if l.Size() != 64 {
+		t.Errorf("Size() = %d, expected 64", l.Size())
+	}
+
+	raw := make([]byte, 64)
+	raw[32] = 0xAB // first element of the second row
+	raw[30] = 0xFF // periph padding, must be dropped
+	out, err := StreamToHost(raw, info, hef.FormatOrderNHWC)
+	if err != nil {
+		t.Fatalf("StreamToHost() error = %v", err)
+	}
+	if len(out) != 60 || out[30] != 0xAB {
+		t.Errorf("len = %d, out[30] = %#x; expected 60 and 0xab", len(out), out[30])
+	}
+	for i := 0; i < 30; i++ {
+		if out[i] != 0 {
+			t.Fatalf("out[%d] = %#x, padding leaked into the first row", i, out[i])
+		}
+	}
+}
+
+func TestStreamToHostNCHW(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 2, Width: 3, Features: 2}
+	info := hef.StreamInfo{
+		Format:  hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNHCW},
+		Shape:   shape,
+		HwShape: hef.ImageShape3D{Height: 2, Width: 4, Features: 2},
+	}
+	raw := fillLayout(StreamLayout(info), shape)
+
+	out, err := StreamToHost(raw, info, hef.FormatOrderNCHW)
+	if err != nil {
+		t.Fatalf("StreamToHost() error = %v", err)
+	}
+
+	host := HostLayout(hef.FormatOrderNCHW, shape, 1)
+	for h := 0; h < 2; h++ {
+		for w := 0; w < 3; w++ {
+			for c := 0; c < 2; c++ {
+				if got := out[host.Offset(h, w, c)]; got != logicalValue(h, w, c) {
+					t.Fatalf("(%d,%d,%d) = %d, expected %d", h, w, c, got, logicalValue(h, w, c))
+				}
+			}
+		}
+	}
+}
+
+func TestHostToStreamRoundTrip(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 3, Width: 5, Features: 3}
+	info := hef.StreamInfo{
+		Format:  hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderF8CR},
+		Shape:   shape,
+		HwShape: hef.ImageShape3D{Height: 3, Width: 5, Features: 8},
+	}
+	host := fillLayout(HostLayout(hef.FormatOrderNHWC, shape, 1), shape)
+
+	device, err := HostToStream(host, hef.FormatOrderNHWC, info)
+	if err != nil {
+		t.Fatalf("HostToStream() error = %v", err)
+	}
+	if len(device) != 3*5*8 {
+		t.Fatalf("device frame = %d bytes, expected %d", len(device), 3*5*8)
+	}
+	// Padded features must be zero
+	if device[StreamLayout(info).Offset(0, 0, 5)] != 0 {
+		t.Error("padding feature should be zero-filled")
+	}
+
+	back, err := StreamToHost(device, info, hef.FormatOrderNHWC)
+	if err != nil {
+		t.Fatalf("StreamToHost() error = %v", err)
+	}
+	checkNHWC(t, back, shape)
+}
+
+func TestConvertLayoutErrors(t *testing.T) {
+	shape := hef.ImageShape3D{Height: 2, Width: 2, Features: 2}
+	nhwc := HostLayout(hef.FormatOrderNHWC, shape, 1)
+
+	nms := TensorLayout{Order: hef.FormatOrderHailoNmsByClass, Shape: shape, ElemSize: 1}
+	if err := ConvertLayout(make([]byte, 8), nms, make([]byte, 8), nhwc, shape); err == nil {
+		t.Error("expected error for NMS layout")
+	}
+
+	small := HostLayout(hef.FormatOrderNHWC, hef.ImageShape3D{Height: 1, Width: 2, Features: 2}, 1)
+	if err := ConvertLayout(make([]byte, 8), small, make([]byte, 8), nhwc, shape); err == nil {
+		t.Error("expected error when shape exceeds layout")
+	}
+
+	if err := ConvertLayout(make([]byte, 4), nhwc, make([]byte, 8), nhwc, shape); err == nil {
+		t.Error("expected error for short source")
+	}
+
+	wide := HostLayout(hef.FormatOrderNHWC, shape, 2)
+	if err := ConvertLayout(make([]byte, 16), wide, make([]byte, 8), nhwc, shape); err == nil {
+		t.Error("expected error for element size mismatch")
+	}
+}
+
+func BenchmarkStreamToHostNHCW(b *testing.B) {
+	info := hef.StreamInfo{
+		Format:  hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNHCW},
+		Shape:   hef.ImageShape3D{Height: 80, Width: 80, Features: 85},
+		HwShape: hef.ImageShape3D{Height: 80, Width: 80, Features: 88},
+	}
+	raw := make([]byte, StreamLayout(info).Size())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		StreamToHost(raw, info, hef.FormatOrderNHWC)
+	}
+}
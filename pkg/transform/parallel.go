@@ -0,0 +1,196 @@
+package transform
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// Kernel selection. The exported batch functions pick a goroutine-tiled
+// implementation when the input is large enough to amortize the fan-out and
+// more than one worker is available; otherwise they run the *Scalar reference.
+const (
+	// parallelMinElements is the smallest input that is worth splitting
+	parallelMinElements = 64 * 1024
+
+	// parallelMinChunk keeps each worker's share large enough to stay cache friendly
+	parallelMinChunk = 16 * 1024
+
+	// dequantLUTMinElements is the point where building a 256-entry table pays off
+	dequantLUTMinElements = 1024
+)
+
+var maxWorkers atomic.Int32
+
+func init() {
+	maxWorkers.Store(int32(runtime.GOMAXPROCS(0)))
+}
+
+// SetParallelism sets the number of goroutines the transform kernels may use.
+// A value of 1 forces the scalar implementations; values below 1 restore the
+// default of GOMAXPROCS.
+func SetParallelism(n int) {
+	if n < 1 {
+		n = runtime.GOMAXPROCS(0)
+	}
+	maxWorkers.Store(int32(n))
+}
+
+// Parallelism returns the number of goroutines the transform kernels may use
+func Parallelism() int {
+	return int(maxWorkers.Load())
+}
+
+// useParallel reports whether a kernel over n elements should be tiled
+func useParallel(n int) bool {
+	return n >= parallelMinElements && Parallelism() > 1
+}
+
+// parallelFor splits [0, n) into contiguous ranges and runs fn on each in its
+// own goroutine. unit is the cost in elements of one index, used so that
+// row-based kernels get the same chunk sizing as flat ones.
+func parallelFor(n, unit int, fn func(start, end int)) {
+	if unit < 1 {
+		unit = 1
+	}
+
+	workers := Parallelism()
+	if maxByWork := (n * unit) / parallelMinChunk; maxByWork < workers {
+		workers = maxByWork
+	}
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+
+	chunk := (n + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunk {
+		end := start + chunk
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			fn(start, end)
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+func quantizeBatchParallel(input []float32, output []uint8, qi QuantInfo) {
+	output = output[:len(input)]
+	parallelFor(len(input), 1, func(start, end int) {
+		QuantizeBatchScalar(input[start:end], output[start:end], qi)
+	})
+}
+
+// dequantizeBatchLUT replaces the per-element subtract and multiply with a
+// table lookup; there are only 256 possible inputs
+func dequantizeBatchLUT(input []uint8, output []float32, qi QuantInfo) {
+	var lut [256]float32
+	for i := range lut {
+		lut[i] = Dequantize(uint8(i), qi)
+	}
+
+	output = output[:len(input)]
+	kernel := func(start, end int) {
+		in := input[start:end]
+		out := output[start:end]
+		out = out[:len(in)]
+		for i, v := range in {
+			out[i] = lut[v]
+		}
+	}
+
+	if useParallel(len(input)) {
+		parallelFor(len(input), 1, kernel)
+		return
+	}
+	kernel(0, len(input))
+}
+
+func convertNHWCtoNCHWParallel(src, dst []uint8, height, width, channels int) {
+	plane := height * width
+	parallelFor(height, width*channels, func(y0, y1 int) {
+		if channels == 3 {
+			// Common RGB case: write three planes from one pass over the pixels
+			in := src[y0*width*3 : y1*width*3]
+			p0 := dst[y0*width : y1*width]
+			p1 := dst[plane+y0*width : plane+y1*width]
+			p2 := dst[2*plane+y0*width : 2*plane+y1*width]
+			for i := range p0 {
+				p0[i] = in[i*3]
+				p1[i] = in[i*3+1]
+				p2[i] = in[i*3+2]
+			}
+			return
+		}
+
+		for y := y0; y < y1; y++ {
+			for x := 0; x < width; x++ {
+				srcIdx := (y*width + x) * channels
+				dstIdx := y*width + x
+				for c := 0; c < channels; c++ {
+					dst[c*plane+dstIdx] = src[srcIdx+c]
+				}
+			}
+		}
+	})
+}
+
+func resizeBilinearParallel(src, dst []uint8, srcH, srcW, dstH, dstW, channels int) {
+	xRatio := float32(srcW) / float32(dstW)
+	yRatio := float32(srcH) / float32(dstH)
+
+	// Column taps are shared by every row
+	x0s := make([]int, dstW)
+	x1s := make([]int, dstW)
+	xFracs := make([]float32, dstW)
+	for x := 0; x < dstW; x++ {
+		srcX := float32(x) * xRatio
+		x0 := int(srcX)
+		x1 := x0 + 1
+		if x1 >= srcW {
+			x1 = srcW - 1
+		}
+		x0s[x] = x0 * channels
+		x1s[x] = x1 * channels
+		xFracs[x] = srcX - float32(x0)
+	}
+
+	parallelFor(dstH, dstW*channels, func(yStart, yEnd int) {
+		for y := yStart; y < yEnd; y++ {
+			srcY := float32(y) * yRatio
+			y0 := int(srcY)
+			y1 := y0 + 1
+			if y1 >= srcH {
+				y1 = srcH - 1
+			}
+			yFrac := srcY - float32(y0)
+
+			row0 := src[y0*srcW*channels : (y0+1)*srcW*channels]
+			row1 := src[y1*srcW*channels : (y1+1)*srcW*channels]
+			out := dst[y*dstW*channels : (y+1)*dstW*channels]
+
+			for x := 0; x < dstW; x++ {
+				i0, i1, xFrac := x0s[x], x1s[x], xFracs[x]
+				o := x * channels
+				for c := 0; c < channels; c++ {
+					v00 := float32(row0[i0+c])
+					v01 := float32(row0[i1+c])
+					v10 := float32(row1[i0+c])
+					v11 := float32(row1[i1+c])
+
+					v0 := v00*(1-xFrac) + v01*xFrac
+					v1 := v10*(1-xFrac) + v11*xFrac
+					out[o+c] = uint8(v0*(1-yFrac) + v1*yFrac)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+//go:build unit
+
+package transform
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// withParallelism runs fn with the kernel worker count forced to n
+func withParallelism(t testing.TB, n int, fn func()) {
+	prev := Parallelism()
+	SetParallelism(n)
+	defer SetParallelism(prev)
+	fn()
+}
+
+func randomBytes(n int, seed int64) []uint8 {
+	r := rand.New(rand.NewSource(seed))
+	data := make([]uint8, n)
+	r.Read(data)
+	return data
+}
+
+func TestSetParallelism(t *testing.T) {
+	prev := Parallelism()
+	defer SetParallelism(prev)
+
+	SetParallelism(3)
+	if Parallelism() != 3 {
+		t.Errorf("Parallelism() = %d, expected 3", Parallelism())
+	}
+
+	SetParallelism(0)
+	if Parallelism() < 1 {
+		t.Errorf("Parallelism() = %d after reset, expected >= 1", Parallelism())
+	}
+}
+
+func TestParallelForCoversRange(t *testing.T) {
+	withParallelism(t, 4, func() {
+		for _, n := range []int{0, 1, 7, 100, 1000} {
+			seen := make([]int, n)
+			parallelFor(n, parallelMinChunk, func(start, end int) {
+				for i := start; i < end; i++ {
+					seen[i]++
+				}
+			})
+			for i, v := range seen {
+				if v != 1 {
+					t.Fatalf("n=%d: index %d visited %d times", n, i, v)
+				}
+			}
+		}
+	})
+}
+
+func TestDequantizeBatchMatchesScalar(t *testing.T) {
+	qi := QuantInfo{ZeroPoint: 12, Scale: 0.0371}
+	input := randomBytes(640*640*3, 1)
+
+	withParallelism(t, 4, func() {
+		got := make([]float32, len(input))
+		want := make([]float32, len(input))
+		DequantizeBatch(input, got, qi)
+		DequantizeBatchScalar(input, want, qi)
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("index %d: got %f, expected %f", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+func TestQuantizeBatchMatchesScalar(t *testing.T) {
+	qi := QuantInfo{ZeroPoint: 128, Scale: 0.0078}
+	r := rand.New(rand.NewSource(2))
+	input := make([]float32, 640*640*3)
+	for i := range input {
+		input[i] = r.Float32()*2.4 - 1.2 // includes out-of-range values
+	}
+
+	withParallelism(t, 4, func() {
+		got := make([]uint8, len(input))
+		want := make([]uint8, len(input))
+		QuantizeBatch(input, got, qi)
+		QuantizeBatchScalar(input, want, qi)
+
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("index %d: got %d, expected %d", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+func TestConvertNHWCtoNCHWMatchesScalar(t *testing.T) {
+	tests := []struct {
+		h, w, c int
+	}{
+		{640, 640, 3},
+		{160, 160, 4},
+		{80, 80, 255},
+	}
+
+	withParallelism(t, 4, func() {
+		for _, tt := range tests {
+			src := randomBytes(tt.h*tt.w*tt.c, 3)
+			got := make([]uint8, len(src))
+			want := make([]uint8, len(src))
+			ConvertNHWCtoNCHW(src, got, tt.h, tt.w, tt.c)
+			ConvertNHWCtoNCHWScalar(src, want, tt.h, tt.w, tt.c)
+
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("%dx%dx%d: index %d got %d, expected %d", tt.h, tt.w, tt.c, i, got[i], want[i])
+				}
+			}
+		}
+	})
+}
+
+func TestResizeBilinearMatchesScalar(t *testing.T) {
+	tests := []struct {
+		srcH, srcW, dstH, dstW, c int
+	}{
+		{720, 1280, 640, 640, 3},
+		{480, 640, 320, 320, 3},
+		{300, 300, 600, 600, 1},
+	}
+
+	withParallelism(t, 4, func() {
+		for _, tt := range tests {
+			src := randomBytes(tt.srcH*tt.srcW*tt.c, 4)
+			got := make([]uint8, tt.dstH*tt.dstW*tt.c)
+			want := make([]uint8, len(got))
+			ResizeBilinear(src, got, tt.srcH, tt.srcW, tt.dstH, tt.dstW, tt.c)
+			ResizeBilinearScalar(src, want, tt.srcH, tt.srcW, tt.dstH, tt.dstW, tt.c)
+
+			for i := range want {
+				if absDiff(got[i], want[i]) > 1 {
+					t.Fatalf("%dx%d->%dx%d: index %d got %d, expected %d",
+						tt.srcW, tt.srcH, tt.dstW, tt.dstH, i, got[i], want[i])
+				}
+			}
+		}
+	})
+}
+
+// Benchmarks
+
+func BenchmarkDequantizeBatch640(b *testing.B) {
+	input := make([]uint8, 640*640*3)
+	output := make([]float32, len(input))
+	qi := QuantInfo{ZeroPoint: 0, Scale: 1.0 / 255.0}
+
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		DequantizeBatch(input, output, qi)
+	}
+}
+
+func BenchmarkResizeBilinear720to640(b *testing.B) {
+	src := make([]uint8, 1280*720*3)
+	dst := make([]uint8, 640*640*3)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ResizeBilinear(src, dst, 720, 1280, 640, 640, 3)
+	}
+}
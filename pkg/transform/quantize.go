@@ -1,11 +1,47 @@
 package transform
 
+import "github.com/anthropics/purple-hailo/pkg/hef"
+
 // QuantInfo contains quantization parameters
 type QuantInfo struct {
 	ZeroPoint float32
 	Scale     float32
 	LimMin    float32
 	LimMax    float32
+
+	// Per-channel parameters; when Scales has more than one entry the
+	// single ZeroPoint/Scale pair is ignored by the per-channel aware functions
+	ZeroPoints []float32
+	Scales     []float32
+}
+
+// QuantInfoFromHef converts HEF quantization parameters
+func QuantInfoFromHef(q hef.QuantInfo) QuantInfo {
+	return QuantInfo{
+		ZeroPoint:  q.ZeroPoint,
+		Scale:      q.Scale,
+		LimMin:     q.LimMin,
+		LimMax:     q.LimMax,
+		ZeroPoints: q.ZeroPoints,
+		Scales:     q.Scales,
+	}
+}
+
+// IsPerChannel returns true if separate parameters exist for each channel
+func (qi QuantInfo) IsPerChannel() bool {
+	return len(qi.Scales) > 1
+}
+
+// Channel returns the per-tensor parameters to use for channel c
+func (qi QuantInfo) Channel(c int) QuantInfo {
+	result := QuantInfo{ZeroPoint: qi.ZeroPoint, Scale: qi.Scale, LimMin: qi.LimMin, LimMax: qi.LimMax}
+	if c >= 0 && c < len(qi.Scales) {
+		result.Scale = qi.Scales[c]
+	}
+	if c >= 0 && c < len(qi.ZeroPoints) {
+		result.ZeroPoint = qi.ZeroPoints[c]
+	}
+	return result
 }
 
 // Quantize converts a float32 to uint8 using quantization parameters
@@ -29,15 +65,35 @@ func Dequantize(value uint8, qi QuantInfo) float32 {
 	return (float32(value) - qi.ZeroPoint) * qi.Scale
 }
 
-// QuantizeBatch quantizes a batch of float32 values
+// QuantizeBatch quantizes a batch of float32 values, splitting large
+// batches across goroutines
 func QuantizeBatch(input []float32, output []uint8, qi QuantInfo) {
+	if useParallel(len(input)) {
+		quantizeBatchParallel(input, output, qi)
+		return
+	}
+	QuantizeBatchScalar(input, output, qi)
+}
+
+// QuantizeBatchScalar is the single-threaded reference for QuantizeBatch
+func QuantizeBatchScalar(input []float32, output []uint8, qi QuantInfo) {
 	for i, v := range input {
 		output[i] = Quantize(v, qi)
 	}
 }
 
-// DequantizeBatch dequantizes a batch of uint8 values
+// DequantizeBatch dequantizes a batch of uint8 values using a lookup table,
+// splitting large batches across goroutines
 func DequantizeBatch(input []uint8, output []float32, qi QuantInfo) {
+	if len(input) < dequantLUTMinElements {
+		DequantizeBatchScalar(input, output, qi)
+		return
+	}
+	dequantizeBatchLUT(input, output, qi)
+}
+
+// DequantizeBatchScalar is the single-threaded reference for DequantizeBatch
+func DequantizeBatchScalar(input []uint8, output []float32, qi QuantInfo) {
 	for i, v := range input {
 		output[i] = Dequantize(v, qi)
 	}
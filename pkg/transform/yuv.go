@@ -0,0 +1,598 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// YUVFormat identifies the memory layout of a YUV frame
+type YUVFormat int
+
+const (
+	YUVFormatNV12 YUVFormat = iota // Y plane followed by interleaved UV plane (4:2:0)
+	YUVFormatI420                  // Y plane, U plane, V plane (4:2:0)
+	YUVFormatYUYV                  // Packed Y0 U Y1 V (4:2:2), as produced by most V4L2 webcams
+)
+
+func (f YUVFormat) String() string {
+	switch f {
+	case YUVFormatNV12:
+		return "NV12"
+	case YUVFormatI420:
+		return "I420"
+	case YUVFormatYUYV:
+		return "YUYV"
+	default:
+		return fmt.Sprintf("Unknown(%d)", int(f))
+	}
+}
+
+// ColorStandard selects the YUV to RGB conversion matrix
+type ColorStandard int
+
+const (
+	ColorStandardBT601 ColorStandard = iota // SD video, most USB cameras
+	ColorStandardBT709                      // HD video, Pi camera (libcamera default for HD modes)
+)
+
+// YUVColorSpace describes how YUV samples map to RGB
+type YUVColorSpace struct {
+	Standard  ColorStandard
+	FullRange bool // true for JPEG-style 0-255 luma, false for 16-235 "studio" range
+}
+
+// Common color spaces
+var (
+	ColorSpaceBT601Limited = YUVColorSpace{Standard: ColorStandardBT601}
+	ColorSpaceBT601Full    = YUVColorSpace{Standard: ColorStandardBT601, FullRange: true}
+	ColorSpaceBT709Limited = YUVColorSpace{Standard: ColorStandardBT709}
+	ColorSpaceBT709Full    = YUVColorSpace{Standard: ColorStandardBT709, FullRange: true}
+)
+
+// yuvFixedShift is the fixed-point precision used by the converters
+const yuvFixedShift = 16
+
+// yuvCoeffs holds the conversion matrix in 16.16 fixed point
+type yuvCoeffs struct {
+	yOffset int32
+	yMul    int32
+	rv      int32
+	gu      int32
+	gv      int32
+	bu      int32
+}
+
+// coeffs derives the fixed-point conversion matrix for the color space
+func (cs YUVColorSpace) coeffs() yuvCoeffs {
+	kr, kb := 0.299, 0.114
+	if cs.Standard == ColorStandardBT709 {
+		kr, kb = 0.2126, 0.0722
+	}
+	kg := 1 - kr - kb
+
+	yScale, cScale, yOffset := 1.0, 1.0, int32(0)
+	if !cs.FullRange {
+		yScale = 255.0 / 219.0
+		cScale = 255.0 / 224.0
+		yOffset = 16
+	}
+
+	one := float64(int32(1) << yuvFixedShift)
+	return yuvCoeffs{
+		yOffset: yOffset,
+		yMul:    int32(yScale*one + 0.5),
+		rv:      int32(2*(1-kr)*cScale*one + 0.5),
+		gu:      int32(2*kb*(1-kb)/kg*cScale*one + 0.5),
+		gv:      int32(2*kr*(1-kr)/kg*cScale*one + 0.5),
+		bu:      int32(2*(1-kb)*cScale*one + 0.5),
+	}
+}
+
+// rgb converts one YUV sample to RGB and writes it to dst[0:3]
+func (c *yuvCoeffs) rgb(dst []uint8, y, u, v int32) {
+	yy := (y-c.yOffset)*c.yMul + 1<<(yuvFixedShift-1)
+	u -= 128
+	v -= 128
+	dst[0] = clampFixedU8(yy + c.rv*v)
+	dst[1] = clampFixedU8(yy - c.gu*u - c.gv*v)
+	dst[2] = clampFixedU8(yy + c.bu*u)
+}
+
+func clampFixedU8(v int32) uint8 {
+	v >>= yuvFixedShift
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// YUVFrameSize returns the number of bytes in a frame of the given format
+func YUVFrameSize(format YUVFormat, width, height int) int {
+	cw, ch := (width+1)/2, (height+1)/2
+	switch format {
+	case YUVFormatNV12, YUVFormatI420:
+		return width*height + 2*cw*ch
+	case YUVFormatYUYV:
+		return cw * 4 * height
+	default:
+		return 0
+	}
+}
+
+// yuvFrame gives indexed access to the planes of a YUV frame
+type yuvFrame struct {
+	data   []uint8
+	format YUVFormat
+	width  int
+	height int
+	cw     int // chroma width
+	ch     int // chroma height
+}
+
+func newYUVFrame(data []uint8, format YUVFormat, width, height int) (*yuvFrame, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid %s frame size %dx%d", format, width, height)
+	}
+	if need := YUVFrameSize(format, width, height); need == 0 || len(data) < need {
+		return nil, fmt.Errorf("%s frame %dx%d needs %d bytes, got %d", format, width, height, need, len(data))
+	}
+	return &yuvFrame{
+		data:   data,
+		format: format,
+		width:  width,
+		height: height,
+		cw:     (width + 1) / 2,
+		ch:     (height + 1) / 2,
+	}, nil
+}
+
+// at returns the Y, U and V samples at pixel (x, y)
+func (f *yuvFrame) at(x, y int) (int32, int32, int32) {
+	switch f.format {
+	case YUVFormatNV12:
+		uv := f.width*f.height + (y/2)*f.cw*2 + (x/2)*2
+		return int32(f.data[y*f.width+x]), int32(f.data[uv]), int32(f.data[uv+1])
+	case YUVFormatI420:
+		c := (y/2)*f.cw + x/2
+		u := f.width*f.height + c
+		v := u + f.cw*f.ch
+		return int32(f.data[y*f.width+x]), int32(f.data[u]), int32(f.data[v])
+	default: // YUYV
+		base := y*f.cw*4 + (x/2)*4
+		return int32(f.data[base+(x&1)*2]), int32(f.data[base+1]), int32(f.data[base+3])
+	}
+}
+
+// checkYUVBuffers validates the source frame size and that dst holds at
+// least dstSize bytes
+func checkYUVBuffers(src, dst []uint8, format YUVFormat, width, height, dstSize int) error {
+	if _, err := newYUVFrame(src, format, width, height); err != nil {
+		return err
+	}
+	if len(dst) < dstSize {
+		return fmt.Errorf("destination too small: need %d bytes, got %d", dstSize, len(dst))
+	}
+	return nil
+}
+
+// ConvertNV12toRGB converts an NV12 frame to packed RGB888
+func ConvertNV12toRGB(src, dst []uint8, width, height int, cs YUVColorSpace) error {
+	if err := checkYUVBuffers(src, dst, YUVFormatNV12, width, height, width*height*3); err != nil {
+		return err
+	}
+	c := cs.coeffs()
+	cw := (width + 1) / 2
+	uvPlane := src[width*height:]
+
+	for y := 0; y < height; y++ {
+		yRow := src[y*width : (y+1)*width]
+		uvRow := uvPlane[(y/2)*cw*2:]
+		out := dst[y*width*3:]
+		for x := 0; x < width; x++ {
+			uvIdx := (x / 2) * 2
+			c.rgb(out[x*3:], int32(yRow[x]), int32(uvRow[uvIdx]), int32(uvRow[uvIdx+1]))
+		}
+	}
+	return nil
+}
+
+// ConvertI420toRGB converts a planar I420 frame to packed RGB888
+func ConvertI420toRGB(src, dst []uint8, width, height int, cs YUVColorSpace) error {
+	if err := checkYUVBuffers(src, dst, YUVFormatI420, width, height, width*height*3); err != nil {
+		return err
+	}
+	c := cs.coeffs()
+	cw, ch := (width+1)/2, (height+1)/2
+	uPlane := src[width*height:]
+	vPlane := uPlane[cw*ch:]
+
+	for y := 0; y < height; y++ {
+		yRow := src[y*width : (y+1)*width]
+		uRow := uPlane[(y/2)*cw:]
+		vRow := vPlane[(y/2)*cw:]
+		out := dst[y*width*3:]
+		for x := 0; x < width; x++ {
+			c.rgb(out[x*3:], int32(yRow[x]), int32(uRow[x/2]), int32(vRow[x/2]))
+		}
+	}
+	return nil
+}
+
+// ConvertYUYVtoRGB converts a packed YUYV (YUY2) frame to packed RGB888
+func ConvertYUYVtoRGB(src, dst []uint8, width, height int, cs YUVColorSpace) error {
+	if err := checkYUVBuffers(src, dst, YUVFormatYUYV, width, height, width*height*3); err != nil {
+		return err
+	}
+	c := cs.coeffs()
+	stride := ((width + 1) / 2) * 4
+
+	for y := 0; y < height; y++ {
+		row := src[y*stride:]
+		out := dst[y*width*3:]
+		for x := 0; x < width; x += 2 {
+			u, v := int32(row[x*2+1]), int32(row[x*2+3])
+			c.rgb(out[x*3:], int32(row[x*2]), u, v)
+			if x+1 < width {
+				c.rgb(out[(x+1)*3:], int32(row[x*2+2]), u, v)
+			}
+		}
+	}
+	return nil
+}
+
+// ConvertYUVtoRGB converts a YUV frame of any supported format to packed RGB888
+func ConvertYUVtoRGB(src, dst []uint8, format YUVFormat, width, height int, cs YUVColorSpace) error {
+	switch format {
+	case YUVFormatNV12:
+		return ConvertNV12toRGB(src, dst, width, height, cs)
+	case YUVFormatI420:
+		return ConvertI420toRGB(src, dst, width, height, cs)
+	case YUVFormatYUYV:
+		return ConvertYUYVtoRGB(src, dst, width, height, cs)
+	default:
+		return fmt.Errorf("unsupported YUV format %s", format)
+	}
+}
+
+// resizeTaps holds precomputed source indices and 8-bit weights for one axis
+type resizeTaps struct {
+	i0, i1 []int
+	frac   []int32 // weight of i1, 0-256
+}
+
+func newResizeTaps(srcLen, dstLen int) resizeTaps {
+	t := resizeTaps{
+		i0:   make([]int, dstLen),
+		i1:   make([]int, dstLen),
+		frac: make([]int32, dstLen),
+	}
+	ratio := float32(srcLen) / float32(dstLen)
+	for i := 0; i < dstLen; i++ {
+		s := float32(i) * ratio
+		i0 := int(s)
+		if i0 >= srcLen {
+			i0 = srcLen - 1
+		}
+		i1 := i0 + 1
+		if i1 >= srcLen {
+			i1 = srcLen - 1
+		}
+		t.i0[i] = i0
+		t.i1[i] = i1
+		t.frac[i] = int32((s - float32(i0)) * 256)
+	}
+	return t
+}
+
+// lerp2D blends four samples with 8-bit weights
+func lerp2D(v00, v01, v10, v11, fx, fy int32) int32 {
+	top := v00*(256-fx) + v01*fx
+	bottom := v10*(256-fx) + v11*fx
+	return (top*(256-fy) + bottom*fy + 1<<15) >> 16
+}
+
+// resizeYUVtoRGBRegion samples src bilinearly into a dstW x dstH region of dst
+// starting at (offX, offY), where dst rows are dstStride pixels wide
+func resizeYUVtoRGBRegion(f *yuvFrame, dst []uint8, dstStride, offX, offY, dstW, dstH int, c *yuvCoeffs) {
+	xs := newResizeTaps(f.width, dstW)
+	ys := newResizeTaps(f.height, dstH)
+
+	for y := 0; y < dstH; y++ {
+		y0, y1, fy := ys.i0[y], ys.i1[y], ys.frac[y]
+		out := dst[((offY+y)*dstStride+offX)*3:]
+		for x := 0; x < dstW; x++ {
+			x0, x1, fx := xs.i0[x], xs.i1[x], xs.frac[x]
+
+			y00, u00, v00 := f.at(x0, y0)
+			y01, u01, v01 := f.at(x1, y0)
+			y10, u10, v10 := f.at(x0, y1)
+			y11, u11, v11 := f.at(x1, y1)
+
+			c.rgb(out[x*3:],
+				lerp2D(y00, y01, y10, y11, fx, fy),
+				lerp2D(u00, u01, u10, u11, fx, fy),
+				lerp2D(v00, v01, v10, v11, fx, fy))
+		}
+	}
+}
+
+// ResizeYUVtoRGB converts a YUV frame to packed RGB888 while resizing it
+// with bilinear interpolation, in a single pass over the output
+func ResizeYUVtoRGB(src []uint8, format YUVFormat, srcW, srcH int, dst []uint8, dstW, dstH int, cs YUVColorSpace) error {
+	f, err := newYUVFrame(src, format, srcW, srcH)
+	if err != nil {
+		return err
+	}
+	if len(dst) < dstW*dstH*3 {
+		return fmt.Errorf("destination too small: need %d bytes, got %d", dstW*dstH*3, len(dst))
+	}
+
+	c := cs.coeffs()
+	resizeYUVtoRGBRegion(f, dst, dstW, 0, 0, dstW, dstH, &c)
+	return nil
+}
+
+// LetterboxInfo describes where a letterboxed image sits inside the model input
+type LetterboxInfo struct {
+	Scale     float32 // destination pixels per source pixel
+	OffsetX   int     // left padding in destination pixels
+	OffsetY   int     // top padding in destination pixels
+	Width     int     // scaled image width inside the destination
+	Height    int     // scaled image height inside the destination
+	DstWidth  int
+	DstHeight int
+}
+
+// ComputeLetterbox calculates the aspect-preserving placement of a
+// srcW x srcH image centered inside a dstW x dstH canvas
+func ComputeLetterbox(srcW, srcH, dstW, dstH int) LetterboxInfo {
+	scale := float32(dstW) / float32(srcW)
+	if s := float32(dstH) / float32(srcH); s < scale {
+		scale = s
+	}
+
+	w := int(float32(srcW)*scale + 0.5)
+	h := int(float32(srcH)*scale + 0.5)
+	if w > dstW {
+		w = dstW
+	}
+	if h > dstH {
+		h = dstH
+	}
+
+	return LetterboxInfo{
+		Scale:     scale,
+		OffsetX:   (dstW - w) / 2,
+		OffsetY:   (dstH - h) / 2,
+		Width:     w,
+		Height:    h,
+		DstWidth:  dstW,
+		DstHeight: dstH,
+	}
+}
+
+// UnletterboxBBox maps a box normalized to the model input back to
+// coordinates normalized to the original source image
+func (l LetterboxInfo) UnletterboxBBox(b BBox) BBox {
+	if l.Width == 0 || l.Height == 0 {
+		return b
+	}
+	sx := float32(l.DstWidth) / float32(l.Width)
+	sy := float32(l.DstHeight) / float32(l.Height)
+	ox := float32(l.OffsetX) / float32(l.DstWidth)
+	oy := float32(l.OffsetY) / float32(l.DstHeight)
+
+	return BBox{
+		YMin: clampUnit((b.YMin - oy) * sy),
+		XMin: clampUnit((b.XMin - ox) * sx),
+		YMax: clampUnit((b.YMax - oy) * sy),
+		XMax: clampUnit((b.XMax - ox) * sx),
+	}
+}
+
+// UnletterboxDetections applies UnletterboxBBox to every detection
+func (l LetterboxInfo) UnletterboxDetections(detections []Detection) []Detection {
+	result := make([]Detection, len(detections))
+	for i, d := range detections {
+		result[i] = d
+		result[i].BBox = l.UnletterboxBBox(d.BBox)
+	}
+	return result
+}
+
+func clampUnit(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// LetterboxYUVtoRGB converts a YUV frame to packed RGB888, scaling it to fit
+// dstW x dstH while preserving aspect ratio and filling the borders with padValue
+func LetterboxYUVtoRGB(src []uint8, format YUVFormat, srcW, srcH int, dst []uint8, dstW, dstH int, cs YUVColorSpace, padValue uint8) (LetterboxInfo, error) {
+	f, err := newYUVFrame(src, format, srcW, srcH)
+	if err != nil {
+		return LetterboxInfo{}, err
+	}
+	if len(dst) < dstW*dstH*3 {
+		return LetterboxInfo{}, fmt.Errorf("destination too small: need %d bytes, got %d", dstW*dstH*3, len(dst))
+	}
+
+	lb := ComputeLetterbox(srcW, srcH, dstW, dstH)
+
+	// Only the borders need padding; the image region is fully overwritten
+	fillRows := func(from, to int) {
+		for i := from * dstW * 3; i < to*dstW*3; i++ {
+			dst[i] = padValue
+		}
+	}
+	fillRows(0, lb.OffsetY)
+	fillRows(lb.OffsetY+lb.Height, dstH)
+	for y := lb.OffsetY; y < lb.OffsetY+lb.Height; y++ {
+		row := dst[y*dstW*3 : (y+1)*dstW*3]
+		for i := 0; i < lb.OffsetX*3; i++ {
+			row[i] = padValue
+		}
+		for i := (lb.OffsetX + lb.Width) * 3; i < len(row); i++ {
+			row[i] = padValue
+		}
+	}
+
+	c := cs.coeffs()
+	resizeYUVtoRGBRegion(f, dst, dstW, lb.OffsetX, lb.OffsetY, lb.Width, lb.Height, &c)
+	return lb, nil
+}
+
+// ConvertI420toNV12 interleaves the U and V planes of an I420 frame
+func ConvertI420toNV12(src, dst []uint8, width, height int) error {
+	if err := checkYUVBuffers(src, dst, YUVFormatI420, width, height, YUVFrameSize(YUVFormatNV12, width, height)); err != nil {
+		return err
+	}
+
+	cw, ch := (width+1)/2, (height+1)/2
+	ySize := width * height
+	copy(dst[:ySize], src[:ySize])
+
+	uPlane := src[ySize:]
+	vPlane := uPlane[cw*ch:]
+	uv := dst[ySize:]
+	for i := 0; i < cw*ch; i++ {
+		uv[i*2] = uPlane[i]
+		uv[i*2+1] = vPlane[i]
+	}
+	return nil
+}
+
+// ConvertYUYVtoNV12 unpacks a YUYV frame to NV12, averaging chroma of row pairs
+func ConvertYUYVtoNV12(src, dst []uint8, width, height int) error {
+	if err := checkYUVBuffers(src, dst, YUVFormatYUYV, width, height, YUVFrameSize(YUVFormatNV12, width, height)); err != nil {
+		return err
+	}
+
+	cw := (width + 1) / 2
+	stride := cw * 4
+	uv := dst[width*height:]
+
+	for y := 0; y < height; y++ {
+		row := src[y*stride:]
+		for x := 0; x < width; x++ {
+			dst[y*width+x] = row[(x/2)*4+(x&1)*2]
+		}
+	}
+
+	for cy := 0; cy < (height+1)/2; cy++ {
+		r0 := src[(cy*2)*stride:]
+		r1 := r0
+		if cy*2+1 < height {
+			r1 = src[(cy*2+1)*stride:]
+		}
+		out := uv[cy*cw*2:]
+		for cx := 0; cx < cw; cx++ {
+			out[cx*2] = uint8((uint16(r0[cx*4+1]) + uint16(r1[cx*4+1]) + 1) / 2)
+			out[cx*2+1] = uint8((uint16(r0[cx*4+3]) + uint16(r1[cx*4+3]) + 1) / 2)
+		}
+	}
+	return nil
+}
+
+// ResizeNV12 resizes an NV12 frame without leaving the YUV domain
+func ResizeNV12(src, dst []uint8, srcW, srcH, dstW, dstH int) error {
+	if dstW <= 0 || dstH <= 0 {
+		return fmt.Errorf("invalid destination dimensions %dx%d", dstW, dstH)
+	}
+	if err := checkYUVBuffers(src, dst, YUVFormatNV12, srcW, srcH, YUVFrameSize(YUVFormatNV12, dstW, dstH)); err != nil {
+		return err
+	}
+
+	srcCW, srcCH := (srcW+1)/2, (srcH+1)/2
+	dstCW, dstCH := (dstW+1)/2, (dstH+1)/2
+
+	ResizeBilinear(src[:srcW*srcH], dst[:dstW*dstH], srcH, srcW, dstH, dstW, 1)
+	ResizeBilinear(src[srcW*srcH:srcW*srcH+srcCW*srcCH*2], dst[dstW*dstH:dstW*dstH+dstCW*dstCH*2],
+		srcCH, srcCW, dstCH, dstCW, 2)
+	return nil
+}
+
+// YUVInputOptions controls how a camera frame is prepared for a model input
+type YUVInputOptions struct {
+	ColorSpace YUVColorSpace
+	Letterbox  bool  // preserve aspect ratio instead of stretching
+	PadValue   uint8 // border fill when letterboxing (114 for YOLO-family models)
+}
+
+// PrepareYUVInput converts a camera frame into the layout expected by a model
+// input with the given HEF format. Inputs whose format order is NV12 receive
+// the frame in NV12 directly so the color conversion happens on the device;
+// NHWC and NCHW inputs receive RGB888. The returned LetterboxInfo maps
+// detections back to the source frame.
+func PrepareYUVInput(src []uint8, format YUVFormat, srcW, srcH int, dst []uint8, target hef.Format, dstW, dstH int, opts YUVInputOptions) (LetterboxInfo, error) {
+	if _, err := newYUVFrame(src, format, srcW, srcH); err != nil {
+		return LetterboxInfo{}, err
+	}
+
+	switch target.Order {
+	case hef.FormatOrderNV12:
+		if opts.Letterbox {
+			return LetterboxInfo{}, fmt.Errorf("letterboxing is not supported for NV12 model inputs")
+		}
+		if need := YUVFrameSize(YUVFormatNV12, dstW, dstH); len(dst) < need {
+			return LetterboxInfo{}, fmt.Errorf("destination too small: need %d bytes, got %d", need, len(dst))
+		}
+
+		nv12 := src
+		if format != YUVFormatNV12 {
+			nv12 = make([]uint8, YUVFrameSize(YUVFormatNV12, srcW, srcH))
+			var err error
+			if format == YUVFormatI420 {
+				err = ConvertI420toNV12(src, nv12, srcW, srcH)
+			} else {
+				err = ConvertYUYVtoNV12(src, nv12, srcW, srcH)
+			}
+			if err != nil {
+				return LetterboxInfo{}, err
+			}
+		}
+		if srcW == dstW && srcH == dstH {
+			copy(dst, nv12[:YUVFrameSize(YUVFormatNV12, dstW, dstH)])
+		} else if err := ResizeNV12(nv12, dst, srcW, srcH, dstW, dstH); err != nil {
+			return LetterboxInfo{}, err
+		}
+		return ComputeLetterbox(dstW, dstH, dstW, dstH), nil
+
+	case hef.FormatOrderNHWC, hef.FormatOrderNCHW:
+		rgb := dst
+		if target.Order == hef.FormatOrderNCHW {
+			rgb = make([]uint8, dstW*dstH*3)
+		}
+
+		var lb LetterboxInfo
+		var err error
+		if opts.Letterbox {
+			lb, err = LetterboxYUVtoRGB(src, format, srcW, srcH, rgb, dstW, dstH, opts.ColorSpace, opts.PadValue)
+		} else {
+			lb = ComputeLetterbox(dstW, dstH, dstW, dstH)
+			err = ResizeYUVtoRGB(src, format, srcW, srcH, rgb, dstW, dstH, opts.ColorSpace)
+		}
+		if err != nil {
+			return LetterboxInfo{}, err
+		}
+
+		if target.Order == hef.FormatOrderNCHW {
+			if len(dst) < len(rgb) {
+				return LetterboxInfo{}, fmt.Errorf("destination too small: need %d bytes, got %d", len(rgb), len(dst))
+			}
+			ConvertNHWCtoNCHW(rgb, dst, dstH, dstW, 3)
+		}
+		return lb, nil
+
+	default:
+		return LetterboxInfo{}, fmt.Errorf("unsupported model input format order %s", target.Order)
+	}
+}
@@ -0,0 +1,372 @@
+//go:build unit
+
+package transform
+
+import (
+	"testing"
+
+	"github.com/anthropics/purple-hailo/pkg/hef"
+)
+
+// makeUniformNV12 creates an NV12 frame where every pixel has the same YUV value
+func makeUniformNV12(width, height int, y, u, v uint8) []uint8 {
+	frame := make([]uint8, YUVFrameSize(YUVFormatNV12, width, height))
+	for i := 0; i < width*height; i++ {
+		frame[i] = y
+	}
+	for i := width * height; i < len(frame); i += 2 {
+		frame[i] = u
+		frame[i+1] = v
+	}
+	return frame
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func TestYUVFrameSize(t *testing.T) {
+	tests := []struct {
+		format   YUVFormat
+		w, h     int
+		expected int
+	}{
+		{YUVFormatNV12, 4, 2, 12},
+		{YUVFormatI420, 4, 2, 12},
+		{YUVFormatYUYV, 4, 2, 16},
+		{YUVFormatNV12, 640, 480, 640 * 480 * 3 / 2},
+		{YUVFormatNV12, 3, 3, 9 + 8},
+		{YUVFormatYUYV, 3, 1, 8},
+	}
+
+	for _, tt := range tests {
+		result := YUVFrameSize(tt.format, tt.w, tt.h)
+		if result != tt.expected {
+			t.Errorf("YUVFrameSize(%s, %d, %d) = %d, expected %d", tt.format, tt.w, tt.h, result, tt.expected)
+		}
+	}
+}
+
+func TestYUVToRGBReferenceColors(t *testing.T) {
+	tests := []struct {
+		name    string
+		cs      YUVColorSpace
+		y, u, v uint8
+		r, g, b uint8
+	}{
+		{"limited black", ColorSpaceBT601Limited, 16, 128, 128, 0, 0, 0},
+		{"limited white", ColorSpaceBT601Limited, 235, 128, 128, 255, 255, 255},
+		{"full black", ColorSpaceBT601Full, 0, 128, 128, 0, 0, 0},
+		{"full white", ColorSpaceBT601Full, 255, 128, 128, 255, 255, 255},
+		{"bt601 limited red", ColorSpaceBT601Limited, 81, 90, 240, 255, 0, 0},
+		{"bt601 limited green", ColorSpaceBT601Limited, 145, 54, 34, 0, 255, 0},
+		{"bt601 limited blue", ColorSpaceBT601Limited, 41, 240, 110, 0, 0, 255},
+		{"bt709 limited red", ColorSpaceBT709Limited, 63, 102, 240, 255, 0, 0},
+		{"bt709 full gray", ColorSpaceBT709Full, 128, 128, 128, 128, 128, 128},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := makeUniformNV12(2, 2, tt.y, tt.u, tt.v)
+			dst := make([]uint8, 2*2*3)
+			if err := ConvertNV12toRGB(src, dst, 2, 2, tt.cs); err != nil {
+				t.Fatal(err)
+			}
+
+			if absDiff(dst[0], tt.r) > 2 || absDiff(dst[1], tt.g) > 2 || absDiff(dst[2], tt.b) > 2 {
+				t.Errorf("got RGB(%d,%d,%d), expected RGB(%d,%d,%d)", dst[0], dst[1], dst[2], tt.r, tt.g, tt.b)
+			}
+		})
+	}
+}
+
+func TestYUVFormatsAgree(t *testing.T) {
+	width, height := 4, 2
+	ys := []uint8{16, 60, 100, 140, 180, 200, 220, 235}
+	us := []uint8{90, 160} // one per 2x2 block
+	vs := []uint8{240, 100}
+
+	nv12 := make([]uint8, YUVFrameSize(YUVFormatNV12, width, height))
+	i420 := make([]uint8, YUVFrameSize(YUVFormatI420, width, height))
+	yuyv := make([]uint8, YUVFrameSize(YUVFormatYUYV, width, height))
+
+	copy(nv12, ys)
+	copy(i420, ys)
+	for i := range us {
+		nv12[8+i*2] = us[i]
+		nv12[8+i*2+1] = vs[i]
+		i420[8+i] = us[i]
+		i420[10+i] = vs[i]
+	}
+	// YUYV carries chroma per row; repeat the 4:2:0 chroma on both rows
+	for y := 0; y < height; y++ {
+		for cx := 0; cx < 2; cx++ {
+			base := y*8 + cx*4
+			yuyv[base] = ys[y*width+cx*2]
+			yuyv[base+1] = us[cx]
+			yuyv[base+2] = ys[y*width+cx*2+1]
+			yuyv[base+3] = vs[cx]
+		}
+	}
+
+	ref := make([]uint8, width*height*3)
+	if err := ConvertNV12toRGB(nv12, ref, width, height, ColorSpaceBT601Limited); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []YUVFormat{YUVFormatI420, YUVFormatYUYV} {
+		src := i420
+		if format == YUVFormatYUYV {
+			src = yuyv
+		}
+		dst := make([]uint8, width*height*3)
+		if err := ConvertYUVtoRGB(src, dst, format, width, height, ColorSpaceBT601Limited); err != nil {
+			t.Fatalf("ConvertYUVtoRGB(%s) error = %v", format, err)
+		}
+		for i := range ref {
+			if dst[i] != ref[i] {
+				t.Errorf("%s byte %d = %d, NV12 reference = %d", format, i, dst[i], ref[i])
+				break
+			}
+		}
+	}
+}
+
+func TestConvertYUVtoRGBShortBuffer(t *testing.T) {
+	err := ConvertYUVtoRGB(make([]uint8, 10), make([]uint8, 48), YUVFormatNV12, 4, 4, ColorSpaceBT601Limited)
+	if err == nil {
+		t.Error("expected error for undersized source frame")
+	}
+
+	err = ConvertYUVtoRGB(make([]uint8, 24), make([]uint8, 10), YUVFormatNV12, 4, 4, ColorSpaceBT601Limited)
+	if err == nil {
+		t.Error("expected error for undersized destination")
+	}
+
+	// The per-format converters validate on their own rather than panicking
+	rgb := make([]uint8, 48)
+	if err := ConvertNV12toRGB(make([]uint8, 23), rgb, 4, 4, ColorSpaceBT601Limited); err == nil {
+		t.Error("ConvertNV12toRGB: expected error for undersized source frame")
+	}
+	if err := ConvertI420toRGB(make([]uint8, 23), rgb, 4, 4, ColorSpaceBT601Limited); err == nil {
+		t.Error("ConvertI420toRGB: expected error for undersized source frame")
+	}
+	if err := ConvertYUYVtoRGB(make([]uint8, 31), rgb, 4, 4, ColorSpaceBT601Limited); err == nil {
+		t.Error("ConvertYUYVtoRGB: expected error for undersized source frame")
+	}
+	if err := ConvertYUYVtoRGB(make([]uint8, 32), rgb[:47], 4, 4, ColorSpaceBT601Limited); err == nil {
+		t.Error("ConvertYUYVtoRGB: expected error for undersized destination")
+	}
+}
+
+func TestResizeYUVtoRGBUniform(t *testing.T) {
+	src := makeUniformNV12(64, 48, 81, 90, 240) // BT.601 red
+	dst := make([]uint8, 20*20*3)
+
+	if err := ResizeYUVtoRGB(src, YUVFormatNV12, 64, 48, dst, 20, 20, ColorSpaceBT601Limited); err != nil {
+		t.Fatalf("ResizeYUVtoRGB() error = %v", err)
+	}
+
+	for i := 0; i < len(dst); i += 3 {
+		if dst[i] < 250 || dst[i+1] > 5 || dst[i+2] > 5 {
+			t.Fatalf("pixel %d = RGB(%d,%d,%d), expected red", i/3, dst[i], dst[i+1], dst[i+2])
+		}
+	}
+}
+
+func TestComputeLetterbox(t *testing.T) {
+	lb := ComputeLetterbox(1280, 720, 640, 640)
+
+	if lb.Width != 640 || lb.Height != 360 {
+		t.Errorf("scaled size = %dx%d, expected 640x360", lb.Width, lb.Height)
+	}
+	if lb.OffsetX != 0 || lb.OffsetY != 140 {
+		t.Errorf("offset = (%d,%d), expected (0,140)", lb.OffsetX, lb.OffsetY)
+	}
+	if lb.Scale != 0.5 {
+		t.Errorf("Scale = %f, expected 0.5", lb.Scale)
+	}
+}
+
+func TestLetterboxYUVtoRGB(t *testing.T) {
+	src := makeUniformNV12(64, 32, 235, 128, 128) // white
+	dst := make([]uint8, 32*32*3)
+
+	lb, err := LetterboxYUVtoRGB(src, YUVFormatNV12, 64, 32, dst, 32, 32, ColorSpaceBT601Limited, 114)
+	if err != nil {
+		t.Fatalf("LetterboxYUVtoRGB() error = %v", err)
+	}
+
+	if lb.OffsetY != 8 || lb.Height != 16 {
+		t.Fatalf("letterbox = %+v, expected OffsetY=8 Height=16", lb)
+	}
+
+	for y := 0; y < 32; y++ {
+		inside := y >= lb.OffsetY && y < lb.OffsetY+lb.Height
+		v := dst[(y*32+16)*3]
+		if inside && v != 255 {
+			t.Errorf("row %d inside image = %d, expected 255", y, v)
+		}
+		if !inside && v != 114 {
+			t.Errorf("row %d in border = %d, expected pad 114", y, v)
+		}
+	}
+}
+
+func TestUnletterboxBBox(t *testing.T) {
+	lb := ComputeLetterbox(1280, 720, 640, 640)
+
+	// Box covering the whole letterboxed image region maps to the full frame
+	b := BBox{
+		YMin: float32(lb.OffsetY) / 640,
+		XMin: 0,
+		YMax: float32(lb.OffsetY+lb.Height) / 640,
+		XMax: 1,
+	}
+	result := lb.UnletterboxBBox(b)
+
+	if result.YMin > 0.001 || result.YMax < 0.999 || result.XMin != 0 || result.XMax != 1 {
+		t.Errorf("UnletterboxBBox() = %+v, expected full frame", result)
+	}
+}
+
+func TestResizeNV12(t *testing.T) {
+	src := makeUniformNV12(16, 16, 100, 50, 200)
+	dst := make([]uint8, YUVFrameSize(YUVFormatNV12, 8, 8))
+
+	if err := ResizeNV12(src, dst, 16, 16, 8, 8); err != nil {
+		t.Fatalf("ResizeNV12() error = %v", err)
+	}
+
+	for i := 0; i < 64; i++ {
+		if dst[i] != 100 {
+			t.Fatalf("Y[%d] = %d, expected 100", i, dst[i])
+		}
+	}
+	for i := 64; i < len(dst); i += 2 {
+		if dst[i] != 50 || dst[i+1] != 200 {
+			t.Fatalf("UV at %d = (%d,%d), expected (50,200)", i, dst[i], dst[i+1])
+		}
+	}
+}
+
+func TestNV12ConvertersShortBuffer(t *testing.T) {
+	nv12 := make([]uint8, YUVFrameSize(YUVFormatNV12, 4, 4))
+	if err := ConvertI420toNV12(make([]uint8, 23), nv12, 4, 4); err == nil {
+		t.Error("ConvertI420toNV12: expected error for undersized source frame")
+	}
+	if err := ConvertI420toNV12(make([]uint8, 24), nv12[:23], 4, 4); err == nil {
+		t.Error("ConvertI420toNV12: expected error for undersized destination")
+	}
+	if err := ConvertYUYVtoNV12(make([]uint8, 31), nv12, 4, 4); err == nil {
+		t.Error("ConvertYUYVtoNV12: expected error for undersized source frame")
+	}
+	if err := ConvertYUYVtoNV12(make([]uint8, 32), nv12[:23], 4, 4); err == nil {
+		t.Error("ConvertYUYVtoNV12: expected error for undersized destination")
+	}
+
+	small := make([]uint8, YUVFrameSize(YUVFormatNV12, 2, 2))
+	if err := ResizeNV12(nv12[:23], small, 4, 4, 2, 2); err == nil {
+		t.Error("ResizeNV12: expected error for undersized source frame")
+	}
+	if err := ResizeNV12(nv12, small[:5], 4, 4, 2, 2); err == nil {
+		t.Error("ResizeNV12: expected error for undersized destination")
+	}
+	if err := ResizeNV12(nv12, small, 4, 4, 0, 2); err == nil {
+		t.Error("ResizeNV12: expected error for zero destination width")
+	}
+}
+
+func TestPrepareYUVInputNV12Passthrough(t *testing.T) {
+	src := makeUniformNV12(8, 8, 42, 10, 20)
+	dst := make([]uint8, len(src))
+	target := hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNV12}
+
+	if _, err := PrepareYUVInput(src, YUVFormatNV12, 8, 8, dst, target, 8, 8, YUVInputOptions{}); err != nil {
+		t.Fatalf("PrepareYUVInput() error = %v", err)
+	}
+	for i := range src {
+		if dst[i] != src[i] {
+			t.Fatalf("byte %d = %d, expected passthrough %d", i, dst[i], src[i])
+		}
+	}
+}
+
+func TestPrepareYUVInputI420ToNV12(t *testing.T) {
+	i420 := make([]uint8, YUVFrameSize(YUVFormatI420, 4, 4))
+	for i := 0; i < 16; i++ {
+		i420[i] = 80
+	}
+	for i := 16; i < 20; i++ {
+		i420[i] = 30 // U
+	}
+	for i := 20; i < 24; i++ {
+		i420[i] = 220 // V
+	}
+
+	dst := make([]uint8, YUVFrameSize(YUVFormatNV12, 4, 4))
+	target := hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNV12}
+	if _, err := PrepareYUVInput(i420, YUVFormatI420, 4, 4, dst, target, 4, 4, YUVInputOptions{}); err != nil {
+		t.Fatalf("PrepareYUVInput() error = %v", err)
+	}
+
+	expected := makeUniformNV12(4, 4, 80, 30, 220)
+	for i := range expected {
+		if dst[i] != expected[i] {
+			t.Fatalf("byte %d = %d, expected %d", i, dst[i], expected[i])
+		}
+	}
+}
+
+func TestPrepareYUVInputNCHW(t *testing.T) {
+	src := makeUniformNV12(8, 8, 81, 90, 240) // BT.601 red
+	dst := make([]uint8, 4*4*3)
+	target := hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderNCHW}
+
+	opts := YUVInputOptions{ColorSpace: ColorSpaceBT601Limited}
+	if _, err := PrepareYUVInput(src, YUVFormatNV12, 8, 8, dst, target, 4, 4, opts); err != nil {
+		t.Fatalf("PrepareYUVInput() error = %v", err)
+	}
+
+	// Planar layout: all R first, then G, then B
+	for i := 0; i < 16; i++ {
+		if dst[i] < 250 || dst[16+i] > 5 || dst[32+i] > 5 {
+			t.Fatalf("pixel %d = (%d,%d,%d), expected red planes", i, dst[i], dst[16+i], dst[32+i])
+		}
+	}
+}
+
+func TestPrepareYUVInputUnsupportedOrder(t *testing.T) {
+	src := makeUniformNV12(4, 4, 0, 128, 128)
+	target := hef.Format{Type: hef.FormatTypeUint8, Order: hef.FormatOrderHailoNmsByClass}
+
+	_, err := PrepareYUVInput(src, YUVFormatNV12, 4, 4, make([]uint8, 48), target, 4, 4, YUVInputOptions{})
+	if err == nil {
+		t.Error("expected error for NMS format order")
+	}
+}
+
+// Benchmarks
+
+func BenchmarkNV12toRGB(b *testing.B) {
+	src := makeUniformNV12(1280, 720, 100, 120, 140)
+	dst := make([]uint8, 1280*720*3)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ConvertNV12toRGB(src, dst, 1280, 720, ColorSpaceBT709Limited)
+	}
+}
+
+func BenchmarkLetterboxNV12toRGB(b *testing.B) {
+	src := makeUniformNV12(1280, 720, 100, 120, 140)
+	dst := make([]uint8, 640*640*3)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		LetterboxYUVtoRGB(src, YUVFormatNV12, 1280, 720, dst, 640, 640, ColorSpaceBT709Limited, 114)
+	}
+}